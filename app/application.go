@@ -2,36 +2,179 @@ package app
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/cloudinary/cloudinary-go"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/superbkibbles/bookstore_utils-go/logger"
 	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/clients/notifier"
 	"github.com/superbkibbles/realestate_property-api/constants"
 	"github.com/superbkibbles/realestate_property-api/http"
 	cloudstorage "github.com/superbkibbles/realestate_property-api/repository/cloudStorage"
 	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/services/agency"
+	"github.com/superbkibbles/realestate_property-api/services/amenity"
+	"github.com/superbkibbles/realestate_property-api/services/attachment"
+	"github.com/superbkibbles/realestate_property-api/services/audit"
+	"github.com/superbkibbles/realestate_property-api/services/complex"
+	"github.com/superbkibbles/realestate_property-api/services/currency"
+	"github.com/superbkibbles/realestate_property-api/services/favorite"
+	"github.com/superbkibbles/realestate_property-api/services/inquiry"
+	"github.com/superbkibbles/realestate_property-api/services/openhouse"
 	"github.com/superbkibbles/realestate_property-api/services/property"
+	"github.com/superbkibbles/realestate_property-api/services/search"
+	"github.com/superbkibbles/realestate_property-api/services/upload"
+	"github.com/superbkibbles/realestate_property-api/services/view"
+	"github.com/superbkibbles/realestate_property-api/services/viewing"
+)
+
+const (
+	assetsPrefix = "/assets"
 )
 
 var (
-	router  = gin.Default()
-	handler http.Propertyhandler
+	router            = gin.Default()
+	handler           http.Propertyhandler
+	uploadHandler     http.UploadHandler
+	viewHandler       http.ViewHandler
+	currencyHandler   http.CurrencyHandler
+	agencyHandler     http.AgencyHandler
+	complexHandler    http.ComplexHandler
+	amenityHandler    http.AmenityHandler
+	attachmentHandler http.AttachmentHandler
+	duplicateHandler  http.DuplicateHandler
+	searchHandler     http.SearchHandler
+	favoriteHandler   http.FavoriteHandler
+	inquiryHandler    http.InquiryHandler
+	viewingHandler    http.ViewingHandler
+	openHouseHandler  http.OpenHouseHandler
+	expiryHandler     http.ExpiryHandler
+	promotionHandler  http.PromotionHandler
+	auditHandler      http.AuditHandler
+	eventHandler      http.EventHandler
+	webhookHandler    http.WebhookHandler
+	adminOnly         gin.HandlerFunc
+
+	// instanceID tells the instances sharing job locks apart.
+	instanceID = uuid.New().String()
 )
 
+// listings hands the property service to the services it depends on, which
+// are built before it and only call it once the application runs.
+type listings struct {
+	property.Service
+}
+
 func StartApplication() {
 	elasticsearch.Client.Init()
-	cld, err := cloudinary.NewFromParams(os.Getenv(constants.CLOUD_STORAGE_NAME), os.Getenv(constants.CLOUD_STORAGE_API_KEY), os.Getenv(constants.CLOUD_STORAGE_API_SECRET))
-	if err != nil {
-		panic(err)
-	}
+	cloudRepo := newCloudStorage()
+
+	currencyService := currency.NewService(db.NewRepository(), getEnv(constants.CURRENCY_RATES_FILE, "currency_rates.json"), getEnv(constants.BASE_CURRENCY, "USD"))
+	adminOnly = http.AdminOnly(os.Getenv(constants.ADMIN_API_KEY))
 
-	handler = http.NewPropertyHandler(property.NewService(db.NewRepository(), cloudstorage.NewRepository(cld)))
+	notifications := newNotifier()
+	properties := &listings{}
+	duplicateService := newDuplicateService(properties)
+	expiryService := newExpiryService(notifications, properties)
+	promotionService := newPromotionService()
+	auditService := audit.NewService(db.NewAuditRepository(), db.NewRepository())
+	webhookService := newWebhookService()
+	eventService := newEventService(webhookService.Sink())
+	searchService := search.NewService(db.NewSavedSearchRepository(), db.NewSearchAlertRepository(), db.NewRepository(), currencyService, notifications)
+	favoriteService := favorite.NewService(db.NewFavoriteRepository(), db.NewRepository(), currencyService, notifications)
+	openHouseService := openhouse.NewService(db.NewRepository(), db.NewRSVPRepository(), properties)
+	propertyService := property.NewService(db.NewRepository(), cloudRepo, db.NewPriceHistoryRepository(), db.NewAgencyRepository(), db.NewComplexRepository(), db.NewAmenityRepository(), db.NewScheduledChangeRepository(), currencyService, duplicateService, expiryService, promotionService, auditService, eventService, searchService, favoriteService, openHouseService)
+	properties.Service = propertyService
+	handler = http.NewPropertyHandler(propertyService)
+	duplicateHandler = http.NewDuplicateHandler(duplicateService)
+	searchHandler = http.NewSearchHandler(searchService)
+	favoriteHandler = http.NewFavoriteHandler(favoriteService)
+	openHouseHandler = http.NewOpenHouseHandler(openHouseService)
+	expiryHandler = http.NewExpiryHandler(expiryService)
+	promotionHandler = http.NewPromotionHandler(promotionService)
+	auditHandler = http.NewAuditHandler(auditService)
+	eventHandler = http.NewEventHandler(eventService)
+	webhookHandler = http.NewWebhookHandler(webhookService)
+	signingSecret := uploadSigningSecret()
+	publicURL := strings.TrimSuffix(os.Getenv(constants.PUBLIC_URL), "/")
+	uploadHandler = http.NewUploadHandler(upload.NewService(db.NewRepository(), db.NewUploadTicketRepository(), cloudRepo, propertyService, upload.Config{
+		SigningSecret: signingSecret,
+		PublicURL:     publicURL,
+		TempDir:       getEnv(constants.UPLOAD_TMP_DIR, filepath.Join(os.TempDir(), "property_uploads")),
+	}))
+	attachmentHandler = http.NewAttachmentHandler(attachment.NewService(db.NewRepository(), cloudRepo, propertyService, attachment.Config{
+		SigningSecret: signingSecret,
+		PublicURL:     publicURL,
+	}))
+	viewingHandler = http.NewViewingHandler(viewing.NewService(db.NewViewingRepository(), db.NewRepository(), viewing.Config{
+		SigningSecret: signingSecret,
+		PublicURL:     publicURL,
+	}))
+	viewHandler = http.NewViewHandler(view.NewService(db.NewRepository(), db.NewViewRepository()))
+	currencyHandler = http.NewCurrencyHandler(currencyService)
+	inquiryHandler = http.NewInquiryHandler(inquiry.NewService(db.NewInquiryRepository(), db.NewRepository(), db.NewAgencyRepository(), notifications))
+	agencyHandler = http.NewAgencyHandler(agency.NewService(db.NewAgencyRepository(), propertyService))
+	amenityHandler = http.NewAmenityHandler(amenity.NewService(db.NewAmenityRepository(), db.NewRepository()))
+	complexHandler = http.NewComplexHandler(complex.NewService(db.NewComplexRepository(), db.NewRepository(), cloudRepo, propertyService, currencyService))
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true
-	config.AddAllowHeaders("local")
+	config.AddAllowHeaders("local", "X-User-ID", "X-Agency-ID", "X-Admin-Key", "X-Visitor-ID", "X-Request-ID", "Tus-Resumable", "Upload-Offset", "Upload-Length")
+	config.AddExposeHeaders("Location", "X-Request-ID", "Tus-Resumable", "Upload-Offset", "Upload-Length")
 	router.Use(cors.New(config))
+	router.Use(http.RequestID())
 	mapURLS()
-	// router.Static("assets", "clients/visuals")
+	backfillBasePrices(currencyService)
+	scheduleMediaGC(cloudRepo)
+	scheduleDuplicateScan(duplicateService)
+	scheduleSearchDigests(searchService)
+	scheduleListingExpiry(expiryService)
+	scheduleListingPublication(propertyService)
+	schedulePromotionImpressions(promotionService)
+	scheduleEventDispatch(eventService)
+	scheduleWebhookDeliveries(webhookService)
 	router.Run(os.Getenv(constants.PORT))
 }
+
+func newCloudStorage() cloudstorage.CloudStorage {
+	if os.Getenv(constants.STORAGE_BACKEND) == "local" {
+		dir := getEnv(constants.LOCAL_STORAGE_DIR, "clients/visuals")
+		router.Static(assetsPrefix, dir)
+		return cloudstorage.NewLocalRepository(dir, strings.TrimSuffix(os.Getenv(constants.PUBLIC_URL), "/")+assetsPrefix)
+	}
+
+	cld, err := cloudinary.NewFromParams(os.Getenv(constants.CLOUD_STORAGE_NAME), os.Getenv(constants.CLOUD_STORAGE_API_KEY), os.Getenv(constants.CLOUD_STORAGE_API_SECRET))
+	if err != nil {
+		panic(err)
+	}
+	return cloudstorage.NewRepository(cld)
+}
+
+func newNotifier() notifier.Notifier {
+	return notifier.New(notifier.Config{
+		SMTPAddr:     os.Getenv(constants.SMTP_ADDR),
+		SMTPFrom:     os.Getenv(constants.SMTP_FROM),
+		SMTPUsername: os.Getenv(constants.SMTP_USERNAME),
+		SMTPPassword: os.Getenv(constants.SMTP_PASSWORD),
+	})
+}
+
+func getEnv(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// uploadSigningSecret falls back to a per-process secret, which means upload
+// tickets only work on the instance that issued them until one is configured.
+func uploadSigningSecret() string {
+	if secret := os.Getenv(constants.UPLOAD_SIGNING_SECRET); secret != "" {
+		return secret
+	}
+	logger.Info(constants.UPLOAD_SIGNING_SECRET + " is not set, using a random secret")
+	return uuid.New().String()
+}
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/superbkibbles/bookstore_utils-go/logger"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/constants"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/services/currency"
+)
+
+// RunBasePriceBackfill sets the base prices of listings stored without them
+// once from the command line:
+//
+//	realestate_property-api base-price-backfill
+func RunBasePriceBackfill() {
+	elasticsearch.Client.Init()
+	service := currency.NewService(db.NewRepository(), getEnv(constants.CURRENCY_RATES_FILE, "currency_rates.json"), getEnv(constants.BASE_CURRENCY, "USD"))
+	updated, err := service.Backfill()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Message())
+		os.Exit(1)
+	}
+	fmt.Printf("set the base price of %d listings\n", updated)
+}
+
+// backfillBasePrices runs the backfill once at startup. It only touches
+// listings still missing a base price, so it is cheap once done.
+func backfillBasePrices(service currency.Service) {
+	go func() {
+		updated, err := service.Backfill()
+		if err != nil {
+			logger.Error("error while backfilling base prices", errors.New(err.Message()))
+			return
+		}
+		if updated > 0 {
+			logger.Info(fmt.Sprintf("set the base price of %d listings", updated))
+		}
+	}()
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/logger"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/constants"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/services/duplicate"
+)
+
+// RunDuplicateScan checks every active listing for duplicates once from the
+// command line:
+//
+//	realestate_property-api duplicate-scan
+func RunDuplicateScan() {
+	elasticsearch.Client.Init()
+	// The scan only records suspects, it never merges listings.
+	report, err := newDuplicateService(nil).Scan()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Message())
+		os.Exit(1)
+	}
+	out, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(out))
+}
+
+func scheduleDuplicateScan(service duplicate.Service) {
+	interval, err := time.ParseDuration(os.Getenv(constants.DUPLICATE_SCAN_INTERVAL))
+	if err != nil || interval <= 0 {
+		return
+	}
+
+	go func() {
+		for range time.Tick(interval) {
+			report, err := service.Scan()
+			if err != nil {
+				logger.Error("error while scanning for duplicate listings", errors.New(err.Message()))
+				continue
+			}
+			logger.Info(fmt.Sprintf("duplicate scan checked %d listings and found %d new suspects", report.Scanned, report.Suspects))
+		}
+	}()
+}
+
+func newDuplicateService(updater duplicate.Updater) duplicate.Service {
+	return duplicate.NewService(db.NewRepository(), db.NewDuplicateRepository(), updater, duplicate.Config{
+		SuspectScore: envScore(constants.DUPLICATE_SUSPECT_SCORE),
+		BlockScore:   envScore(constants.DUPLICATE_BLOCK_SCORE),
+	})
+}
+
+// envScore reads a score between 0 and 1; anything else counts as unset.
+func envScore(key string) float64 {
+	score, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil || score < 0 || score > 1 {
+		return 0
+	}
+	return score
+}
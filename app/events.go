@@ -0,0 +1,77 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/logger"
+	"github.com/superbkibbles/realestate_property-api/clients/eventsink"
+	"github.com/superbkibbles/realestate_property-api/constants"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/services/event"
+)
+
+// newEventService sends events to sinks and to the ones listed in
+// EVENT_SINKS, e.g. "stdout,webhook,nats". With EVENT_SINKS empty events
+// only reach the sinks passed in, the webhook subscriptions; without any sink
+// no events are written at all.
+func newEventService(sinks ...eventsink.Sink) event.Service {
+	if strings.TrimSpace(os.Getenv(constants.EVENT_SINKS)) == "" {
+		logger.Info(constants.EVENT_SINKS + " is not set, events only go to webhook subscriptions")
+	}
+	for _, name := range strings.Split(os.Getenv(constants.EVENT_SINKS), ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "":
+		case "stdout":
+			sinks = append(sinks, eventsink.NewStdout())
+		case "webhook":
+			sinks = append(sinks, eventsink.NewWebhook(os.Getenv(constants.EVENT_WEBHOOK_URL), os.Getenv(constants.EVENT_WEBHOOK_SECRET), 0))
+		case "nats":
+			publisher := eventsink.NewNATS(getEnv(constants.EVENT_NATS_URL, "nats://localhost:4222"), 0)
+			sinks = append(sinks, eventsink.NewBroker("nats", publisher, getEnv(constants.EVENT_SUBJECT_PREFIX, "realestate.")))
+		default:
+			logger.Info(fmt.Sprintf("ignoring unknown %s entry %q", constants.EVENT_SINKS, name))
+		}
+	}
+	maxAttempts, _ := strconv.Atoi(os.Getenv(constants.EVENT_MAX_ATTEMPTS))
+	retention, _ := time.ParseDuration(os.Getenv(constants.EVENT_RETENTION))
+	return event.NewService(db.NewOutboxRepository(), sinks, event.Config{
+		MaxAttempts: maxAttempts,
+		Retention:   retention,
+		SpoolDir:    getEnv(constants.EVENT_SPOOL_DIR, filepath.Join(os.TempDir(), "property_events")),
+	})
+}
+
+// scheduleEventDispatch sends outbox events every 5 seconds unless
+// EVENT_DISPATCH_INTERVAL says otherwise, and purges delivered ones hourly.
+func scheduleEventDispatch(service event.Service) {
+	interval, err := time.ParseDuration(getEnv(constants.EVENT_DISPATCH_INTERVAL, "5s"))
+	if err != nil || interval <= 0 {
+		return
+	}
+
+	go func() {
+		for range time.Tick(interval) {
+			report, err := service.Dispatch()
+			if err != nil {
+				logger.Error("error while dispatching events", errors.New(err.Message()))
+				continue
+			}
+			if report.Retried+report.Failed > 0 {
+				logger.Info(fmt.Sprintf("event dispatch delivered %d, retried %d and failed %d events", report.Delivered, report.Retried, report.Failed))
+			}
+		}
+	}()
+	go func() {
+		for range time.Tick(time.Hour) {
+			if _, err := service.Purge(); err != nil {
+				logger.Error("error while purging delivered events", errors.New(err.Message()))
+			}
+		}
+	}()
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/logger"
+	"github.com/superbkibbles/realestate_property-api/clients/notifier"
+	"github.com/superbkibbles/realestate_property-api/constants"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/services/expiry"
+)
+
+// scheduleListingExpiry runs the expiry scheduler every hour unless
+// LISTING_EXPIRY_INTERVAL says otherwise. Every instance runs it; the service
+// makes sure each listing is handled once.
+func scheduleListingExpiry(service expiry.Service) {
+	interval, err := time.ParseDuration(getEnv(constants.LISTING_EXPIRY_INTERVAL, "1h"))
+	if err != nil || interval <= 0 {
+		return
+	}
+
+	go func() {
+		for range time.Tick(interval) {
+			report, err := service.Run()
+			if err != nil {
+				logger.Error("error while expiring listings", errors.New(err.Message()))
+				continue
+			}
+			logger.Info(fmt.Sprintf("listing expiry scheduled %d, warned %d and expired %d listings, %d failed", report.Scheduled, report.Warned, report.Expired, report.Failed))
+		}
+	}()
+}
+
+func newExpiryService(notifications notifier.Notifier, updater expiry.Updater) expiry.Service {
+	warnBefore, _ := time.ParseDuration(os.Getenv(constants.LISTING_EXPIRY_WARNING))
+	return expiry.NewService(db.NewRepository(), db.NewAgencyRepository(), notifications, updater, expiry.Config{
+		Durations:  listingDurations(os.Getenv(constants.LISTING_DURATIONS)),
+		WarnBefore: warnBefore,
+	})
+}
+
+// listingDurations reads days per category, e.g. "apartment=60,land=120".
+// Invalid entries are logged and skipped.
+func listingDurations(value string) map[string]time.Duration {
+	durations := map[string]time.Duration{}
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		days, err := 0, errors.New("missing days")
+		if len(parts) == 2 {
+			days, err = strconv.Atoi(strings.TrimSpace(parts[1]))
+		}
+		if err != nil || days <= 0 {
+			logger.Info(fmt.Sprintf("ignoring invalid %s entry %q", constants.LISTING_DURATIONS, entry))
+			continue
+		}
+		durations[strings.ToLower(strings.TrimSpace(parts[0]))] = time.Duration(days) * 24 * time.Hour
+	}
+	return durations
+}
@@ -1,12 +1,24 @@
 package app
 
-const prefix = "/api/property"
+const (
+	prefix         = "/api/property"
+	agencyPrefix   = "/api/agency"
+	complexPrefix  = "/api/complex"
+	amenityPrefix  = "/api/amenity"
+	searchPrefix   = "/api/search"
+	favoritePrefix = "/api/favorite"
+	viewingPrefix  = "/api/viewing"
+	auditPrefix    = "/api/audit"
+	eventPrefix    = "/api/events"
+	webhookPrefix  = "/api/webhooks"
+)
 
 func mapURLS() {
 	router.GET(prefix, handler.Get)                                    // Get All Properties
 	router.GET(prefix+"/:id", handler.GetByID)                         // Get Properties By ID
 	router.POST(prefix, handler.Create)                                // Create a property
 	router.POST(prefix+"/search", handler.Search)                      // Search for properties
+	router.POST(prefix+"/facets", handler.Facets)                      // Count search results per amenity
 	router.PATCH(prefix+"/update/:id", handler.Update)                 // update for properties
 	router.POST(prefix+"/media/:id", handler.UploadMedia)              // Upload Media
 	router.POST(prefix+"/property_pic/:id", handler.UploadPropertyPic) // Upload Property Picture
@@ -15,4 +27,120 @@ func mapURLS() {
 	router.GET(prefix+"/deactive", handler.GetDeactive)                // Get Deactive properties
 	router.POST(prefix+"/:id/translate", handler.Translate)            // translate by id
 	router.GET(prefix+"/:id/translate", handler.GetTranslated)         // translate by id
+	router.GET(prefix+"/:id/price-history", handler.GetPriceHistory)   // Price changes of a property
+	router.GET(prefix+"/:id/similar", handler.Similar)                 // Comparable active listings
+	router.POST(prefix+"/:id/renew", expiryHandler.Renew)              // Restart the listing duration
+
+	router.PUT(prefix+"/:id/schedule", handler.Schedule)                                     // Set publish_at and unpublish_at
+	router.POST(prefix+"/:id/scheduled-changes", handler.ScheduleChange)                     // Queue an embargoed update
+	router.GET(prefix+"/:id/scheduled-changes", handler.GetScheduledChanges)                 // Queued updates of a listing
+	router.DELETE(prefix+"/:id/scheduled-changes/:change_id", handler.CancelScheduledChange) // Cancel a queued update
+
+	router.POST(prefix+"/:id/promotions", promotionHandler.Create)                    // Promote a listing
+	router.GET(prefix+"/:id/promotions", promotionHandler.GetByProperty)              // Promotions of a listing
+	router.DELETE(prefix+"/:id/promotions/:promotion_id", promotionHandler.Cancel)    // Stop a promotion
+	router.POST(prefix+"/:id/promotions/:promotion_id/click", promotionHandler.Click) // Count a click on a promoted result
+
+	router.GET(prefix+"/:id/audit", auditHandler.GetByProperty)                    // Changes made to a listing
+	router.GET(prefix+"/:id/as-of", auditHandler.AsOf)                             // A listing as it was at a past time
+	router.GET(auditPrefix+"/actors/:user_id", adminOnly, auditHandler.GetByActor) // Changes made by a user
+
+	router.GET(eventPrefix+"/failed", adminOnly, eventHandler.GetFailed)     // Events that ran out of attempts
+	router.POST(eventPrefix+"/:id/requeue", adminOnly, eventHandler.Requeue) // Send a failed event again
+
+	router.POST(webhookPrefix, webhookHandler.Create)                                       // Subscribe to listing events of the agency
+	router.GET(webhookPrefix, webhookHandler.Get)                                           // Subscriptions of the agency
+	router.GET(webhookPrefix+"/dead-letters", webhookHandler.GetDeadLetters)                // Deliveries that ran out of attempts
+	router.GET(webhookPrefix+"/:id", webhookHandler.GetByID)                                // Get a subscription
+	router.PUT(webhookPrefix+"/:id", webhookHandler.Update)                                 // Replace a subscription
+	router.DELETE(webhookPrefix+"/:id", webhookHandler.Delete)                              // Delete a subscription and its deliveries
+	router.POST(webhookPrefix+"/:id/test", webhookHandler.Test)                             // Send a ping right away
+	router.GET(webhookPrefix+"/:id/deliveries", webhookHandler.GetDeliveries)               // Delivery log of a subscription
+	router.POST(webhookPrefix+"/:id/replay", webhookHandler.ReplayAll)                      // Replay all dead deliveries
+	router.POST(webhookPrefix+"/:id/deliveries/:delivery_id/replay", webhookHandler.Replay) // Replay a dead delivery
+
+	router.GET(prefix+"/duplicates", adminOnly, duplicateHandler.Get)                            // Suspected duplicate listings
+	router.POST(prefix+"/duplicates/:duplicate_id/dismiss", adminOnly, duplicateHandler.Dismiss) // Mark a pair as distinct listings
+	router.POST(prefix+"/duplicates/:duplicate_id/merge", adminOnly, duplicateHandler.Merge)     // Keep one listing of a pair
+
+	router.POST(prefix+"/:id/uploads", uploadHandler.CreateTicket)                 // Issue a signed upload ticket
+	router.POST(prefix+"/:id/uploads/:ticket_id/complete", uploadHandler.Complete) // Attach an uploaded file
+	router.HEAD(prefix+"/uploads/:ticket_id", uploadHandler.Head)                  // Resumable upload offset
+	router.PATCH(prefix+"/uploads/:ticket_id", uploadHandler.Patch)                // Resumable upload chunk
+	router.PUT(prefix+"/uploads/:ticket_id", uploadHandler.Put)                    // Direct upload through this service
+
+	router.POST(prefix+"/:id/attachments", attachmentHandler.Upload)                          // Upload a floor plan or document
+	router.DELETE(prefix+"/:id/attachments/:attachment_id", attachmentHandler.Delete)         // Delete an attachment
+	router.GET(prefix+"/:id/attachments/:attachment_id/link", attachmentHandler.Link)         // Signed link to an attachment
+	router.GET(prefix+"/:id/attachments/:attachment_id/download", attachmentHandler.Download) // Download through a signed link
+
+	router.POST(prefix+"/:id/views", viewHandler.Record)                      // Record a property view
+	router.GET(prefix+"/:id/views", viewHandler.GetPropertyStats)             // Daily views of a property
+	router.GET(prefix+"/agency/:agency_id/views", viewHandler.GetAgencyStats) // Daily views of an agency
+
+	router.GET(favoritePrefix, favoriteHandler.Get)                                   // Favorites of the user
+	router.PUT(prefix+"/:id/favorite", favoriteHandler.Add)                           // Favorite a property
+	router.DELETE(prefix+"/:id/favorite", favoriteHandler.Remove)                     // Remove a favorite
+	router.GET(prefix+"/:id/favorites/stats", favoriteHandler.GetPropertyStats)       // Favorite count of a property
+	router.GET(prefix+"/agency/:agency_id/favorites", favoriteHandler.GetAgencyStats) // Favorite counts of an agency
+
+	router.POST(prefix+"/:id/inquiries", inquiryHandler.Create) // Contact the agency about a listing
+
+	router.POST(prefix+"/:id/viewings/slots", viewingHandler.PublishSlots)            // Publish viewing slots
+	router.GET(prefix+"/:id/viewings/slots", viewingHandler.GetSlots)                 // Upcoming viewing slots
+	router.DELETE(prefix+"/:id/viewings/slots/:slot_id", viewingHandler.WithdrawSlot) // Withdraw an open slot
+	router.POST(prefix+"/:id/viewings/slots/:slot_id/book", viewingHandler.Book)      // Book a viewing
+	router.GET(viewingPrefix, viewingHandler.GetBookings)                             // Viewings of the user
+	router.GET(viewingPrefix+"/feed", viewingHandler.FeedLink)                        // Calendar feed link of the agent
+	router.GET(viewingPrefix+"/feed/:agent_id/calendar.ics", viewingHandler.Feed)     // Calendar feed of an agent
+	router.GET(viewingPrefix+"/:id", viewingHandler.GetBooking)                       // Get a viewing
+	router.POST(viewingPrefix+"/:id/cancel", viewingHandler.Cancel)                   // Cancel a viewing
+	router.GET(viewingPrefix+"/:id/calendar.ics", viewingHandler.Calendar)            // Viewing as an iCalendar file
+
+	router.POST(prefix+"/:id/open-houses", openHouseHandler.Create)                           // Schedule an open house
+	router.GET(prefix+"/:id/open-houses", openHouseHandler.Get)                               // Upcoming open houses
+	router.DELETE(prefix+"/:id/open-houses/:open_house_id", openHouseHandler.Delete)          // Cancel an open house
+	router.GET(prefix+"/:id/open-houses/:open_house_id/rsvp", openHouseHandler.GetRSVPs)      // Who is coming
+	router.PUT(prefix+"/:id/open-houses/:open_house_id/rsvp", openHouseHandler.RSVP)          // RSVP to an open house
+	router.DELETE(prefix+"/:id/open-houses/:open_house_id/rsvp", openHouseHandler.CancelRSVP) // Cancel an RSVP
+
+	router.GET(prefix+"/currency/rates", currencyHandler.GetRates)            // Exchange rates
+	router.PUT(prefix+"/currency/rates", adminOnly, currencyHandler.SetRates) // Replace exchange rates
+
+	router.POST(agencyPrefix, agencyHandler.Create)                                        // Create an agency
+	router.GET(agencyPrefix, agencyHandler.Get)                                            // Get all agencies
+	router.GET(agencyPrefix+"/:id", agencyHandler.GetByID)                                 // Get agency by ID
+	router.PATCH(agencyPrefix+"/:id", agencyHandler.Update)                                // Update an agency profile
+	router.PUT(agencyPrefix+"/:id/verified", adminOnly, agencyHandler.Verify)              // Verify an agency
+	router.DELETE(agencyPrefix+"/:id", adminOnly, agencyHandler.Delete)                    // Delete an agency without listings
+	router.GET(agencyPrefix+"/:id/properties", agencyHandler.GetProperties)                // Properties of an agency
+	router.POST(agencyPrefix+"/:id/properties/search", agencyHandler.SearchProperties)     // Search properties of an agency
+	router.GET(agencyPrefix+"/:id/inquiries", inquiryHandler.Get)                          // Inquiries of an agency
+	router.GET(agencyPrefix+"/:id/inquiries/stats", inquiryHandler.GetLeadCounts)          // Lead counts per listing
+	router.PUT(agencyPrefix+"/:id/inquiries/:inquiry_id/assign", inquiryHandler.Assign)    // Assign an inquiry to an agent
+	router.PUT(agencyPrefix+"/:id/inquiries/:inquiry_id/status", inquiryHandler.SetStatus) // Move an inquiry through the pipeline
+	router.GET(agencyPrefix+"/:id/promotions", promotionHandler.Report)                    // Impressions and clicks per promotion
+
+	router.POST(complexPrefix, complexHandler.Create)                               // Create a complex
+	router.GET(complexPrefix, complexHandler.Get)                                   // Get all complexes
+	router.GET(complexPrefix+"/:id", complexHandler.GetByID)                        // Get complex by ID
+	router.PATCH(complexPrefix+"/:id", complexHandler.Update)                       // Update or rename a complex
+	router.DELETE(complexPrefix+"/:id", adminOnly, complexHandler.Delete)           // Delete a complex without units
+	router.POST(complexPrefix+"/:id/media", complexHandler.UploadMedia)             // Upload complex media
+	router.DELETE(complexPrefix+"/:id/media/:media_id", complexHandler.DeleteMedia) // Delete complex media
+	router.GET(complexPrefix+"/:id/units", complexHandler.GetUnits)                 // Units of a complex
+	router.POST(complexPrefix+"/:id/units/search", complexHandler.SearchUnits)      // Search units of a complex
+	router.GET(complexPrefix+"/:id/stats", complexHandler.GetStats)                 // Price range, availability and unit mix
+
+	router.GET(amenityPrefix, amenityHandler.Get)                         // Amenity catalogue
+	router.GET(amenityPrefix+"/:id", amenityHandler.GetByID)              // Get amenity by ID
+	router.POST(amenityPrefix, adminOnly, amenityHandler.Create)          // Add an amenity to the catalogue
+	router.PATCH(amenityPrefix+"/:id", adminOnly, amenityHandler.Update)  // Relabel an amenity
+	router.DELETE(amenityPrefix+"/:id", adminOnly, amenityHandler.Delete) // Remove an unused amenity
+
+	router.POST(searchPrefix, searchHandler.Create)          // Save a search
+	router.GET(searchPrefix, searchHandler.Get)              // Saved searches of the user
+	router.GET(searchPrefix+"/:id", searchHandler.GetByID)   // Get a saved search
+	router.PUT(searchPrefix+"/:id", searchHandler.Update)    // Replace a saved search
+	router.DELETE(searchPrefix+"/:id", searchHandler.Delete) // Delete a saved search
 }
@@ -0,0 +1,80 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/logger"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/constants"
+	cloudstorage "github.com/superbkibbles/realestate_property-api/repository/cloudStorage"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/services/media"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+const defaultMediaGCMinAge = 24 * time.Hour
+
+// RunMediaGC runs one media garbage collection pass from the command line:
+//
+//	realestate_property-api media-gc [-delete] [-min-age 24h]
+//
+// Without -delete it only reports what would be removed.
+func RunMediaGC(args []string) {
+	flags := flag.NewFlagSet("media-gc", flag.ExitOnError)
+	remove := flags.Bool("delete", false, "delete orphaned assets instead of only reporting them")
+	minAge := flags.Duration("min-age", mediaGCMinAge(), "ignore assets younger than this")
+	flags.Parse(args)
+
+	elasticsearch.Client.Init()
+	report, err := media.NewService(db.NewRepository(), db.NewComplexRepository(), newCloudStorage(), *minAge).CollectOrphans(!*remove)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Message())
+		os.Exit(1)
+	}
+	out, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(out))
+}
+
+func scheduleMediaGC(cloudRepo cloudstorage.CloudStorage) {
+	interval, err := time.ParseDuration(os.Getenv(constants.MEDIA_GC_INTERVAL))
+	if err != nil || interval <= 0 {
+		return
+	}
+	dryRun := os.Getenv(constants.MEDIA_GC_DELETE) != "true"
+	service := media.NewService(db.NewRepository(), db.NewComplexRepository(), cloudRepo, mediaGCMinAge())
+	locks := db.NewJobLockRepository()
+
+	go func() {
+		for range time.Tick(interval) {
+			// Only one instance collects per interval.
+			now := date_utils.GetNow().UTC()
+			locked, lockErr := locks.Acquire("media_gc", instanceID, now.Format(time.RFC3339), now.Add(interval).Format(time.RFC3339))
+			if lockErr != nil {
+				logger.Error("error while locking media garbage collection", errors.New(lockErr.Message()))
+				continue
+			}
+			if !locked {
+				continue
+			}
+			report, err := service.CollectOrphans(dryRun)
+			if err != nil {
+				logger.Error("error while collecting orphaned media", errors.New(err.Message()))
+				continue
+			}
+			logger.Info(fmt.Sprintf("media garbage collection found %d orphans (dry run: %t)", len(report.Orphans), report.DryRun))
+		}
+	}()
+}
+
+func mediaGCMinAge() time.Duration {
+	minAge, err := time.ParseDuration(os.Getenv(constants.MEDIA_GC_MIN_AGE))
+	if err != nil {
+		return defaultMediaGCMinAge
+	}
+	return minAge
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/logger"
+	"github.com/superbkibbles/realestate_property-api/constants"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/services/promotion"
+)
+
+func newPromotionService() promotion.Service {
+	pageSize, _ := strconv.Atoi(os.Getenv(constants.PROMOTION_PAGE_SIZE))
+	perPage, _ := strconv.Atoi(os.Getenv(constants.PROMOTION_PAGE_CAP))
+	return promotion.NewService(db.NewPromotionRepository(), db.NewRepository(), promotion.Config{
+		Weights:  promotionWeights(os.Getenv(constants.PROMOTION_WEIGHTS)),
+		PageSize: pageSize,
+		PerPage:  perPage,
+	})
+}
+
+// schedulePromotionImpressions writes the impressions each instance counted
+// every 10 seconds unless PROMOTION_FLUSH_INTERVAL says otherwise.
+func schedulePromotionImpressions(service promotion.Service) {
+	interval, err := time.ParseDuration(getEnv(constants.PROMOTION_FLUSH_INTERVAL, "10s"))
+	if err != nil || interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	go func() {
+		for range time.Tick(interval) {
+			service.FlushImpressions()
+		}
+	}()
+}
+
+// promotionWeights reads score weights per tier, e.g. "premium=5,featured=12".
+// Weights must be above 1, the score listings without a promotion get.
+func promotionWeights(value string) map[string]float64 {
+	weights := map[string]float64{}
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		weight := 0.0
+		if len(parts) == 2 {
+			weight, _ = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		}
+		if weight <= 1 {
+			logger.Info(fmt.Sprintf("ignoring invalid %s entry %q", constants.PROMOTION_WEIGHTS, entry))
+			continue
+		}
+		weights[strings.ToLower(strings.TrimSpace(parts[0]))] = weight
+	}
+	return weights
+}
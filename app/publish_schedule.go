@@ -0,0 +1,33 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/logger"
+	"github.com/superbkibbles/realestate_property-api/constants"
+	"github.com/superbkibbles/realestate_property-api/services/property"
+)
+
+// scheduleListingPublication applies publish_at, unpublish_at and queued
+// changes every minute unless PUBLISH_SCHEDULE_INTERVAL says otherwise.
+func scheduleListingPublication(service property.Service) {
+	interval, err := time.ParseDuration(getEnv(constants.PUBLISH_SCHEDULE_INTERVAL, "1m"))
+	if err != nil || interval <= 0 {
+		return
+	}
+
+	go func() {
+		for range time.Tick(interval) {
+			report, err := service.RunSchedule()
+			if err != nil {
+				logger.Error("error while running the publishing schedule", errors.New(err.Message()))
+				continue
+			}
+			if report.Published+report.Unpublished+report.Applied+report.Failed > 0 {
+				logger.Info(fmt.Sprintf("publishing schedule published %d and unpublished %d listings, applied %d and failed %d changes", report.Published, report.Unpublished, report.Applied, report.Failed))
+			}
+		}
+	}()
+}
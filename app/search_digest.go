@@ -0,0 +1,31 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/logger"
+	"github.com/superbkibbles/realestate_property-api/constants"
+	"github.com/superbkibbles/realestate_property-api/services/search"
+)
+
+// scheduleSearchDigests sends the alerts collected for digest searches, once
+// a day unless SAVED_SEARCH_DIGEST_INTERVAL says otherwise.
+func scheduleSearchDigests(service search.Service) {
+	interval, err := time.ParseDuration(getEnv(constants.SAVED_SEARCH_DIGEST_INTERVAL, "24h"))
+	if err != nil || interval <= 0 {
+		return
+	}
+
+	go func() {
+		for range time.Tick(interval) {
+			report, err := service.SendDigests()
+			if err != nil {
+				logger.Error("error while sending saved search digests", errors.New(err.Message()))
+				continue
+			}
+			logger.Info(fmt.Sprintf("sent %d saved search digests with %d alerts", report.Searches, report.Alerts))
+		}
+	}()
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/logger"
+	"github.com/superbkibbles/realestate_property-api/constants"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/services/webhook"
+)
+
+func newWebhookService() webhook.Service {
+	maxAttempts, _ := strconv.Atoi(os.Getenv(constants.WEBHOOK_MAX_ATTEMPTS))
+	timeout, _ := time.ParseDuration(os.Getenv(constants.WEBHOOK_TIMEOUT))
+	retention, _ := time.ParseDuration(os.Getenv(constants.WEBHOOK_LOG_RETENTION))
+	batchSize, _ := strconv.Atoi(os.Getenv(constants.WEBHOOK_BATCH_SIZE))
+	workers, _ := strconv.Atoi(os.Getenv(constants.WEBHOOK_WORKERS))
+	return webhook.NewService(db.NewSubscriptionRepository(), db.NewWebhookDeliveryRepository(), webhook.Config{
+		MaxAttempts: maxAttempts,
+		Timeout:     timeout,
+		Retention:   retention,
+		BatchSize:   batchSize,
+		Workers:     workers,
+	})
+}
+
+// scheduleWebhookDeliveries sends due deliveries every 5 seconds unless
+// WEBHOOK_DELIVERY_INTERVAL says otherwise, and purges old ones hourly.
+func scheduleWebhookDeliveries(service webhook.Service) {
+	interval, err := time.ParseDuration(getEnv(constants.WEBHOOK_DELIVERY_INTERVAL, "5s"))
+	if err != nil || interval <= 0 {
+		return
+	}
+
+	go func() {
+		for range time.Tick(interval) {
+			report, err := service.Deliver()
+			if err != nil {
+				logger.Error("error while delivering webhooks", errors.New(err.Message()))
+				continue
+			}
+			if report.Retried+report.Dead+report.Failed > 0 {
+				logger.Info(fmt.Sprintf("webhook delivery delivered %d, retried %d, dead-lettered %d and failed %d deliveries", report.Delivered, report.Retried, report.Dead, report.Failed))
+			}
+		}
+	}()
+	go func() {
+		for range time.Tick(time.Hour) {
+			if _, err := service.Purge(); err != nil {
+				logger.Error("error while purging webhook deliveries", errors.New(err.Message()))
+			}
+		}
+	}()
+}
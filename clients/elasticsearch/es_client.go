@@ -30,6 +30,14 @@ type EsClientInterface interface {
 	GetTranslatByID(indexTranslateProperty string, docType string, propertyID string, local string) (*elastic.SearchResult, error)
 	UpdateTranslate(indexTranslateProperty string, typeProperty string, id string, updateRequest property.EsUpdate) (*elastic.UpdateResponse, error)
 	GetAllTranslated(indexTranslateProperty string, typeProperty string, local string) (*elastic.SearchResult, error)
+	Create(index string, docType string, id string, doc interface{}) (*elastic.IndexResponse, error)
+	UpdateScript(index string, docType string, id string, script *elastic.Script) (*elastic.UpdateResponse, error)
+	Aggregate(index string, query elastic.Query, name string, aggregation elastic.Aggregation) (*elastic.SearchResult, error)
+	UpdateByQuery(index string, query elastic.Query, script *elastic.Script) (*elastic.BulkIndexByScrollResponse, error)
+	Delete(index string, docType string, id string) (*elastic.DeleteResponse, error)
+	SearchTop(index string, query elastic.Query, size int) (*elastic.SearchResult, error)
+	SearchLimit(index string, query elastic.Query, sort string, asc bool, size int) (*elastic.SearchResult, error)
+	DeleteByQuery(index string, query elastic.Query) (*elastic.BulkIndexByScrollResponse, error)
 	// GetDeactiveLocal(indexTranslateProperty string, typeProperty string, local string) (*elastic.SearchResult, error)
 	// GetActiveLocal(indexTranslateProperty string, typeProperty string, local string) (*elastic.SearchResult, error)
 }
@@ -256,3 +264,106 @@ func (c *esClient) Update(indexProperties string, typeProperty string, id string
 
 	return result, nil
 }
+
+// Create indexes doc under id and fails with a conflict if it already exists.
+func (c *esClient) Create(index string, docType string, id string, doc interface{}) (*elastic.IndexResponse, error) {
+	ctx := context.Background()
+	result, err := c.client.Index().
+		Index(index).
+		Type(docType).
+		Id(id).
+		OpType("create").
+		BodyJson(doc).
+		Do(ctx)
+	if err != nil {
+		if !elastic.IsConflict(err) {
+			logger.Error(fmt.Sprintf("error while trying to create document in index %s", index), err)
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *esClient) UpdateScript(index string, docType string, id string, script *elastic.Script) (*elastic.UpdateResponse, error) {
+	ctx := context.Background()
+	result, err := c.client.Update().Index(index).Type(docType).Id(id).Script(script).RetryOnConflict(3).FetchSource(true).Do(ctx)
+	if err != nil {
+		logger.Error(fmt.Sprintf("error when trying to run update script in index %s", index), err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *esClient) Aggregate(index string, query elastic.Query, name string, aggregation elastic.Aggregation) (*elastic.SearchResult, error) {
+	ctx := context.Background()
+	result, err := c.client.Search(index).Query(query).Size(0).Aggregation(name, aggregation).Do(ctx)
+	if err != nil {
+		logger.Error(fmt.Sprintf("error when trying to aggregate documents in index %s", index), err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// SearchTop returns the size best scoring documents.
+func (c *esClient) SearchTop(index string, query elastic.Query, size int) (*elastic.SearchResult, error) {
+	ctx := context.Background()
+	result, err := c.client.Search(index).Query(query).Size(size).Do(ctx)
+	if err != nil {
+		logger.Error(fmt.Sprintf("error when trying to search documents in index %s", index), err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// SearchLimit returns the first size documents in sort order.
+func (c *esClient) SearchLimit(index string, query elastic.Query, sort string, asc bool, size int) (*elastic.SearchResult, error) {
+	ctx := context.Background()
+	result, err := c.client.Search(index).Sort(sort, asc).Query(query).Size(size).Do(ctx)
+	if err != nil {
+		logger.Error(fmt.Sprintf("error when trying to search documents in index %s", index), err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *esClient) UpdateByQuery(index string, query elastic.Query, script *elastic.Script) (*elastic.BulkIndexByScrollResponse, error) {
+	ctx := context.Background()
+	result, err := c.client.UpdateByQuery(index).Query(query).Script(script).ProceedOnVersionConflict().Do(ctx)
+	if err != nil {
+		logger.Error(fmt.Sprintf("error when trying to update documents by query in index %s", index), err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *esClient) Delete(index string, docType string, id string) (*elastic.DeleteResponse, error) {
+	ctx := context.Background()
+	result, err := c.client.Delete().Index(index).Type(docType).Id(id).Do(ctx)
+	if err != nil {
+		if !elastic.IsNotFound(err) {
+			logger.Error(fmt.Sprintf("error when trying to delete document in index %s", index), err)
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *esClient) DeleteByQuery(index string, query elastic.Query) (*elastic.BulkIndexByScrollResponse, error) {
+	ctx := context.Background()
+	result, err := c.client.DeleteByQuery(index).Query(query).ProceedOnVersionConflict().Do(ctx)
+	if err != nil {
+		if !elastic.IsNotFound(err) {
+			logger.Error(fmt.Sprintf("error when trying to delete documents by query in index %s", index), err)
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
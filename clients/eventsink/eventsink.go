@@ -0,0 +1,134 @@
+package eventsink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/superbkibbles/realestate_property-api/domain/event"
+	"github.com/superbkibbles/realestate_property-api/utils/crypto_utils"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// Sink delivers events to one destination. Send must only return nil once
+// the destination has the event; it is sent again otherwise.
+type Sink interface {
+	Name() string
+	Send(event.Event) error
+}
+
+// Publisher is the part of a message broker client a broker sink needs.
+// A *nats.Conn satisfies it, a Kafka producer only needs a small adapter.
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// NewStdout writes every event as one line of JSON, for development and for
+// log shippers that pick events up from there.
+func NewStdout() Sink {
+	return &writerSink{name: "stdout", writer: os.Stdout}
+}
+
+type writerSink struct {
+	name   string
+	writer io.Writer
+	mu     sync.Mutex
+}
+
+func (s *writerSink) Name() string {
+	return s.name
+}
+
+func (s *writerSink) Send(e event.Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.writer.Write(append(line, '\n'))
+	return err
+}
+
+// NewWebhook posts every event to url, signed with secret as Post does.
+func NewWebhook(url string, secret string, timeout time.Duration) Sink {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &webhookSink{url: url, secret: secret, client: &http.Client{Timeout: timeout}}
+}
+
+type webhookSink struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func (s *webhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *webhookSink) Send(e event.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = Post(s.client, s.url, s.secret, e, body)
+	return err
+}
+
+// Post sends body, the JSON of e, to url and returns the status code. With
+// a secret the body is signed in the X-Signature header as
+// sha256=<hex HMAC-SHA256 of the body>. Responses outside 2xx are an error.
+func Post(client *http.Client, url string, secret string, e event.Event, body []byte) (int, error) {
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Event-ID", e.ID)
+	request.Header.Set("X-Event-Type", e.Type)
+	if secret != "" {
+		request.Header.Set("X-Signature", "sha256="+crypto_utils.GetHmacSha256(secret, string(body)))
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("webhook responded with %d", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
+
+// NewBroker publishes every event on prefix plus its type, e.g.
+// realestate.property.created.
+func NewBroker(name string, publisher Publisher, prefix string) Sink {
+	return &brokerSink{name: name, publisher: publisher, prefix: prefix}
+}
+
+type brokerSink struct {
+	name      string
+	publisher Publisher
+	prefix    string
+}
+
+func (s *brokerSink) Name() string {
+	return s.name
+}
+
+func (s *brokerSink) Send(e event.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.publisher.Publish(s.prefix+e.Type, data)
+}
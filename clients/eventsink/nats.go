@@ -0,0 +1,121 @@
+package eventsink
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewNATS returns a Publisher speaking the NATS client protocol, enough to
+// publish without pulling in a client library. It connects on first use,
+// waits for the server to confirm every message and reconnects after an
+// error. address is host:port or a nats:// URL with optional credentials.
+func NewNATS(address string, timeout time.Duration) Publisher {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &natsPublisher{address: address, timeout: timeout}
+}
+
+type natsPublisher struct {
+	address string
+	timeout time.Duration
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+type natsConnect struct {
+	Verbose  bool   `json:"verbose"`
+	Pedantic bool   `json:"pedantic"`
+	Name     string `json:"name"`
+	User     string `json:"user,omitempty"`
+	Pass     string `json:"pass,omitempty"`
+}
+
+func (p *natsPublisher) Publish(subject string, data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		if err := p.connect(); err != nil {
+			return err
+		}
+	}
+	err := p.publish(subject, data)
+	if err != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+	return err
+}
+
+func (p *natsPublisher) connect() error {
+	host, connect := p.address, natsConnect{Name: "realestate_property-api"}
+	if strings.Contains(p.address, "://") {
+		u, err := url.Parse(p.address)
+		if err != nil {
+			return err
+		}
+		host = u.Host
+		if u.User != nil {
+			connect.User = u.User.Username()
+			connect.Pass, _ = u.User.Password()
+		}
+	}
+	conn, err := net.DialTimeout("tcp", host, p.timeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(p.timeout))
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("unexpected greeting from nats: %s", strings.TrimSpace(line))
+	}
+	options, _ := json.Marshal(connect)
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\n", options); err != nil {
+		conn.Close()
+		return err
+	}
+	p.conn, p.reader = conn, reader
+	return nil
+}
+
+// publish sends PING after the message; the server answers PONG only after
+// it processed everything before it, or -ERR if it did not.
+func (p *natsPublisher) publish(subject string, data []byte) error {
+	p.conn.SetDeadline(time.Now().Add(p.timeout))
+	message := fmt.Sprintf("PUB %s %d\r\n%s\r\nPING\r\n", subject, len(data), data)
+	if _, err := p.conn.Write([]byte(message)); err != nil {
+		return err
+	}
+	for {
+		line, err := p.reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := p.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New("nats: " + strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
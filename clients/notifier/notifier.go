@@ -0,0 +1,115 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/logger"
+	"github.com/superbkibbles/realestate_property-api/domain/notification"
+	"github.com/superbkibbles/realestate_property-api/utils/net_utils"
+)
+
+const defaultTimeout = 10 * time.Second
+
+type Notifier interface {
+	Notify(notification.Message) error
+}
+
+// Config sets up email delivery. Without an SMTP address email messages are
+// written to the log instead, which is also how a local SMTP stand-in can be
+// left out in development.
+type Config struct {
+	SMTPAddr     string
+	SMTPFrom     string
+	SMTPUsername string
+	SMTPPassword string
+	Timeout      time.Duration
+}
+
+// New returns a notifier that sends each message on its own channel.
+func New(config Config) Notifier {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	n := &channelNotifier{
+		channels: map[string]Notifier{
+			notification.CHANNEL_WEBHOOK: &webhookNotifier{client: net_utils.NewPublicClient(config.Timeout)},
+			notification.CHANNEL_LOG:     &logNotifier{},
+		},
+	}
+	if config.SMTPAddr != "" {
+		n.channels[notification.CHANNEL_EMAIL] = &emailNotifier{config: config}
+	} else {
+		n.channels[notification.CHANNEL_EMAIL] = &logNotifier{}
+	}
+	return n
+}
+
+type channelNotifier struct {
+	channels map[string]Notifier
+}
+
+func (n *channelNotifier) Notify(message notification.Message) error {
+	channel, ok := n.channels[message.Channel]
+	if !ok {
+		return fmt.Errorf("unknown channel %s", message.Channel)
+	}
+	return channel.Notify(message)
+}
+
+type webhookNotifier struct {
+	client *http.Client
+}
+
+func (n *webhookNotifier) Notify(message notification.Message) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	response, err := n.client.Post(message.To, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %d", response.StatusCode)
+	}
+	return nil
+}
+
+type emailNotifier struct {
+	config Config
+}
+
+func (n *emailNotifier) Notify(message notification.Message) error {
+	var auth smtp.Auth
+	if n.config.SMTPUsername != "" {
+		host := n.config.SMTPAddr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", n.config.SMTPUsername, n.config.SMTPPassword, host)
+	}
+	// Headers come from user input, keep them on one line.
+	header := strings.NewReplacer("\r", " ", "\n", " ")
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "From: %s\r\n", n.config.SMTPFrom)
+	fmt.Fprintf(&body, "To: %s\r\n", header.Replace(message.To))
+	fmt.Fprintf(&body, "Subject: %s\r\n", header.Replace(message.Subject))
+	body.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	body.WriteString(message.Text)
+	return smtp.SendMail(n.config.SMTPAddr, auth, n.config.SMTPFrom, []string{message.To}, body.Bytes())
+}
+
+type logNotifier struct {
+}
+
+func (n *logNotifier) Notify(message notification.Message) error {
+	logger.Info(fmt.Sprintf("notification %s to %s: %s", message.Event, message.To, message.Subject))
+	return nil
+}
@@ -4,7 +4,50 @@ const (
 	PUBLIC_API_KEY           = "PUBLIC_API"
 	ELASTIC_URL              = "ELASTIC_URL"
 	PORT                     = "PORT"
+	PUBLIC_URL               = "PUBLIC_URL"
 	CLOUD_STORAGE_NAME       = "CLOUD_STORAGE_NAME"
 	CLOUD_STORAGE_API_KEY    = "CLOUD_STORAGE_API_KEY"
 	CLOUD_STORAGE_API_SECRET = "CLOUD_STORAGE_API_SECRET"
+	STORAGE_BACKEND          = "STORAGE_BACKEND"
+	LOCAL_STORAGE_DIR        = "LOCAL_STORAGE_DIR"
+	UPLOAD_SIGNING_SECRET    = "UPLOAD_SIGNING_SECRET"
+	UPLOAD_TMP_DIR           = "UPLOAD_TMP_DIR"
+	MEDIA_GC_INTERVAL        = "MEDIA_GC_INTERVAL"
+	MEDIA_GC_MIN_AGE         = "MEDIA_GC_MIN_AGE"
+	MEDIA_GC_DELETE          = "MEDIA_GC_DELETE"
+	ADMIN_API_KEY            = "ADMIN_API_KEY"
+	BASE_CURRENCY            = "BASE_CURRENCY"
+	CURRENCY_RATES_FILE      = "CURRENCY_RATES_FILE"
+	DUPLICATE_SCAN_INTERVAL  = "DUPLICATE_SCAN_INTERVAL"
+	DUPLICATE_SUSPECT_SCORE  = "DUPLICATE_SUSPECT_SCORE"
+	DUPLICATE_BLOCK_SCORE    = "DUPLICATE_BLOCK_SCORE"
+	SMTP_ADDR                = "SMTP_ADDR"
+	SMTP_FROM                = "SMTP_FROM"
+	SMTP_USERNAME            = "SMTP_USERNAME"
+	SMTP_PASSWORD            = "SMTP_PASSWORD"
+
+	SAVED_SEARCH_DIGEST_INTERVAL = "SAVED_SEARCH_DIGEST_INTERVAL"
+	LISTING_DURATIONS            = "LISTING_DURATIONS"
+	LISTING_EXPIRY_WARNING       = "LISTING_EXPIRY_WARNING"
+	LISTING_EXPIRY_INTERVAL      = "LISTING_EXPIRY_INTERVAL"
+	PUBLISH_SCHEDULE_INTERVAL    = "PUBLISH_SCHEDULE_INTERVAL"
+	PROMOTION_WEIGHTS            = "PROMOTION_WEIGHTS"
+	PROMOTION_PAGE_SIZE          = "PROMOTION_PAGE_SIZE"
+	PROMOTION_PAGE_CAP           = "PROMOTION_PAGE_CAP"
+	PROMOTION_FLUSH_INTERVAL     = "PROMOTION_FLUSH_INTERVAL"
+	EVENT_SINKS                  = "EVENT_SINKS"
+	EVENT_WEBHOOK_URL            = "EVENT_WEBHOOK_URL"
+	EVENT_WEBHOOK_SECRET         = "EVENT_WEBHOOK_SECRET"
+	EVENT_NATS_URL               = "EVENT_NATS_URL"
+	EVENT_SUBJECT_PREFIX         = "EVENT_SUBJECT_PREFIX"
+	EVENT_DISPATCH_INTERVAL      = "EVENT_DISPATCH_INTERVAL"
+	EVENT_MAX_ATTEMPTS           = "EVENT_MAX_ATTEMPTS"
+	EVENT_RETENTION              = "EVENT_RETENTION"
+	EVENT_SPOOL_DIR              = "EVENT_SPOOL_DIR"
+	WEBHOOK_DELIVERY_INTERVAL    = "WEBHOOK_DELIVERY_INTERVAL"
+	WEBHOOK_MAX_ATTEMPTS         = "WEBHOOK_MAX_ATTEMPTS"
+	WEBHOOK_TIMEOUT              = "WEBHOOK_TIMEOUT"
+	WEBHOOK_LOG_RETENTION        = "WEBHOOK_LOG_RETENTION"
+	WEBHOOK_BATCH_SIZE           = "WEBHOOK_BATCH_SIZE"
+	WEBHOOK_WORKERS              = "WEBHOOK_WORKERS"
 )
@@ -0,0 +1,128 @@
+package agency
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+)
+
+var locals = map[string]bool{
+	"en":  true,
+	"ar":  true,
+	"kur": true,
+}
+
+type Agency struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Logo        string            `json:"logo"`
+	Contacts    Contacts          `json:"contacts"`
+	Verified    bool              `json:"verified"`
+	Description map[string]string `json:"description"`
+	DateCreated string            `json:"date_created"`
+}
+
+type Contacts struct {
+	Phone   string `json:"phone"`
+	Email   string `json:"email"`
+	Website string `json:"website"`
+	Address string `json:"address"`
+}
+
+// Summary is the part of an agency embedded in property responses.
+type Summary struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Logo     string `json:"logo"`
+	Verified bool   `json:"verified"`
+}
+
+type Agencies []Agency
+
+func (a *Agency) Validate() rest_errors.RestErr {
+	a.Name = strings.TrimSpace(a.Name)
+	if a.Name == "" {
+		return rest_errors.NewBadRequestErr("invalid agency name")
+	}
+	return validateDescription(a.Description)
+}
+
+func (a Agency) Summary() *Summary {
+	return &Summary{
+		ID:       a.ID,
+		Name:     a.Name,
+		Logo:     a.Logo,
+		Verified: a.Verified,
+	}
+}
+
+// Localized returns the agency with only the description in local, falling
+// back to English.
+func (a Agency) Localized(local string) Agency {
+	if local == "" || len(a.Description) == 0 {
+		return a
+	}
+	description, ok := a.Description[local]
+	if !ok {
+		description = a.Description["en"]
+	}
+	a.Description = map[string]string{local: description}
+	return a
+}
+
+func validateDescription(description map[string]string) rest_errors.RestErr {
+	for local := range description {
+		if !locals[local] {
+			return rest_errors.NewBadRequestErr(fmt.Sprintf("invalid description language %s", local))
+		}
+	}
+	return nil
+}
+
+type UpdateField struct {
+	Field string      `json:"field"`
+	Value interface{} `json:"Value"`
+}
+
+type UpdateRequest struct {
+	Fields []UpdateField `json:"fields"`
+}
+
+// Validate checks the values of the profile fields an agency may edit: name,
+// logo, contacts and description. Verification is granted by an admin, so
+// it and any other field are refused.
+func (u UpdateRequest) Validate() rest_errors.RestErr {
+	for _, field := range u.Fields {
+		switch field.Field {
+		case "name":
+			name, ok := field.Value.(string)
+			if !ok || strings.TrimSpace(name) == "" {
+				return rest_errors.NewBadRequestErr("invalid agency name")
+			}
+		case "logo":
+			if _, ok := field.Value.(string); !ok {
+				return rest_errors.NewBadRequestErr("invalid logo")
+			}
+		case "contacts":
+			bytes, _ := json.Marshal(field.Value)
+			var contacts Contacts
+			if err := json.Unmarshal(bytes, &contacts); err != nil {
+				return rest_errors.NewBadRequestErr("invalid contacts")
+			}
+		case "description":
+			bytes, _ := json.Marshal(field.Value)
+			var description map[string]string
+			if err := json.Unmarshal(bytes, &description); err != nil {
+				return rest_errors.NewBadRequestErr("invalid description")
+			}
+			if err := validateDescription(description); err != nil {
+				return err
+			}
+		default:
+			return rest_errors.NewBadRequestErr(fmt.Sprintf("%s can not be updated", field.Field))
+		}
+	}
+	return nil
+}
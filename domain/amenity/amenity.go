@@ -0,0 +1,94 @@
+package amenity
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+)
+
+var (
+	idPattern = regexp.MustCompile(`^[a-z0-9_]{2,40}$`)
+
+	locals = map[string]bool{
+		"en":  true,
+		"ar":  true,
+		"kur": true,
+	}
+)
+
+// Amenity is an entry of the catalogue properties pick their amenities from.
+// Properties store only the id, e.g. "elevator".
+type Amenity struct {
+	ID          string            `json:"id"`
+	Category    string            `json:"category"`
+	Labels      map[string]string `json:"labels"`
+	Label       string            `json:"label,omitempty"`
+	DateCreated string            `json:"date_created"`
+}
+
+type Amenities []Amenity
+
+func (a *Amenity) Validate() rest_errors.RestErr {
+	a.ID = strings.TrimSpace(strings.ToLower(a.ID))
+	if !idPattern.MatchString(a.ID) {
+		return rest_errors.NewBadRequestErr("invalid amenity id, use lowercase letters, digits and underscores")
+	}
+	a.Category = strings.TrimSpace(a.Category)
+	return ValidateLabels(a.Labels)
+}
+
+func ValidateLabels(labels map[string]string) rest_errors.RestErr {
+	if strings.TrimSpace(labels["en"]) == "" {
+		return rest_errors.NewBadRequestErr("an english label is required")
+	}
+	for local := range labels {
+		if !locals[local] {
+			return rest_errors.NewBadRequestErr(fmt.Sprintf("invalid label language %s", local))
+		}
+	}
+	return nil
+}
+
+// LabelFor returns the label in local, falling back to English.
+func (a Amenity) LabelFor(local string) string {
+	if label, ok := a.Labels[local]; ok && label != "" {
+		return label
+	}
+	return a.Labels["en"]
+}
+
+func (a Amenities) Localized(local string) Amenities {
+	for i := range a {
+		a[i].Label = a[i].LabelFor(local)
+	}
+	return a
+}
+
+// Normalize lowercases and deduplicates amenity ids, keeping their order.
+func Normalize(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		id = strings.TrimSpace(strings.ToLower(id))
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+	}
+	return result
+}
+
+type UpdateRequest struct {
+	Category *string           `json:"category"`
+	Labels   map[string]string `json:"labels"`
+}
+
+func (u UpdateRequest) Validate() rest_errors.RestErr {
+	if u.Labels != nil {
+		return ValidateLabels(u.Labels)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+)
+
+const (
+	ACTION_CREATE       = "create"
+	ACTION_UPDATE       = "update"
+	ACTION_TRANSLATE    = "translate"
+	ACTION_UPLOAD_MEDIA = "upload_media"
+	ACTION_DELETE_MEDIA = "delete_media"
+
+	// ActorScheduler is the user of changes the service makes on its own,
+	// e.g. publishing a listing at its publish_at.
+	ActorScheduler = "scheduler"
+
+	// TimeLayout keeps milliseconds so entries of one second stay ordered.
+	TimeLayout = "2006-01-02T15:04:05.000Z07:00"
+)
+
+// Actor is who made a change and in which request.
+type Actor struct {
+	UserID    string `json:"user_id,omitempty"`
+	AgencyID  string `json:"agency_id,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Change is one top-level field before and after a mutation. A nil Before
+// means the field did not exist.
+type Change struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Entry records one mutation of a listing. Entries are never changed once
+// written. Translations are listed with their Local.
+type Entry struct {
+	ID         string   `json:"id"`
+	PropertyID string   `json:"property_id"`
+	Action     string   `json:"action"`
+	Local      string   `json:"local,omitempty"`
+	Actor      Actor    `json:"actor"`
+	Timestamp  string   `json:"timestamp"`
+	Fields     []string `json:"fields"`
+	Changes    []Change `json:"changes"`
+}
+
+type Entries []Entry
+
+// Query narrows entries down to a changed field and a time range, both ends
+// inclusive.
+type Query struct {
+	Field string `form:"field"`
+	From  string `form:"from"`
+	To    string `form:"to"`
+}
+
+func (q *Query) Validate() rest_errors.RestErr {
+	q.Field = strings.TrimSpace(q.Field)
+	for _, value := range []*string{&q.From, &q.To} {
+		if *value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(*value))
+		if err != nil {
+			return rest_errors.NewBadRequestErr("from and to must be RFC 3339 times")
+		}
+		*value = t.UTC().Format(TimeLayout)
+	}
+	return nil
+}
+
+// NewEntry diffs before and after, either of which may be nil, and returns
+// nil when nothing changed.
+func NewEntry(propertyID string, action string, actor Actor, before interface{}, after interface{}) *Entry {
+	changes := Diff(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+	fields := make([]string, 0, len(changes))
+	for _, change := range changes {
+		fields = append(fields, change.Field)
+	}
+	return &Entry{
+		PropertyID: propertyID,
+		Action:     action,
+		Actor:      actor,
+		Fields:     fields,
+		Changes:    changes,
+	}
+}
+
+// Diff compares the JSON fields of before and after, sorted by name. The id
+// is left out as it never changes.
+func Diff(before interface{}, after interface{}) []Change {
+	b, a := fields(before), fields(after)
+	names := make([]string, 0, len(a))
+	for name := range a {
+		names = append(names, name)
+	}
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []Change
+	for _, name := range names {
+		if name == "id" || reflect.DeepEqual(b[name], a[name]) {
+			continue
+		}
+		changes = append(changes, Change{Field: name, Before: b[name], After: a[name]})
+	}
+	return changes
+}
+
+func fields(v interface{}) map[string]interface{} {
+	doc := map[string]interface{}{}
+	if v == nil {
+		return doc
+	}
+	if value := reflect.ValueOf(v); value.Kind() == reflect.Ptr && value.IsNil() {
+		return doc
+	}
+	bytes, _ := json.Marshal(v)
+	json.Unmarshal(bytes, &doc)
+	return doc
+}
+
+// AsOf rolls current back through entries, newest first, to how the listing
+// was at the time of the oldest one. It reports false if the listing was
+// created within entries, so it did not exist yet. Translations are skipped.
+func AsOf(current property.Property, entries Entries) (*property.Property, bool) {
+	doc := fields(&current)
+	for _, entry := range entries {
+		if entry.Local != "" {
+			continue
+		}
+		if entry.Action == ACTION_CREATE {
+			return nil, false
+		}
+		for _, change := range entry.Changes {
+			if change.Before == nil {
+				delete(doc, change.Field)
+				continue
+			}
+			doc[change.Field] = change.Before
+		}
+	}
+
+	bytes, _ := json.Marshal(doc)
+	var p property.Property
+	json.Unmarshal(bytes, &p)
+	p.ID = current.ID
+	return &p, true
+}
@@ -0,0 +1,138 @@
+package complex
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/currency"
+)
+
+// FOLDER_PREFIX keeps complex media apart from the property folders in
+// storage.
+const FOLDER_PREFIX = "complex_"
+
+// Complex is a residential compound that groups many units.
+type Complex struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Developer   string   `json:"developer"`
+	Description string   `json:"description"`
+	Location    string   `json:"location"`
+	City        string   `json:"city"`
+	Boundary    []Point  `json:"boundary"`
+	Amenities   []string `json:"amenities"`
+	Media       []Media  `json:"media"`
+	DateCreated string   `json:"date_created"`
+}
+
+// Point is a corner of the complex boundary.
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+type Media struct {
+	Url      string `json:"url"`
+	FileType string `json:"file_type"`
+	PublicID string `json:"public_id"`
+	Kind     string `json:"kind"`
+}
+
+type Complexes []Complex
+
+// Stats summarize the units listed in a complex.
+type Stats struct {
+	ComplexID string           `json:"complex_id"`
+	Units     int64            `json:"units"`
+	Available int64            `json:"available"`
+	Sold      int64            `json:"sold"`
+	ForRent   int64            `json:"for_rent"`
+	MinPrice  *currency.Money  `json:"min_price,omitempty"`
+	MaxPrice  *currency.Money  `json:"max_price,omitempty"`
+	Bedrooms  map[string]int64 `json:"bedrooms"`
+	Types     map[string]int64 `json:"property_types"`
+}
+
+func (c *Complex) Validate() rest_errors.RestErr {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return rest_errors.NewBadRequestErr("invalid complex name")
+	}
+	return validateBoundary(c.Boundary)
+}
+
+func (c Complex) Folder() string {
+	return FOLDER_PREFIX + c.ID
+}
+
+func validateBoundary(boundary []Point) rest_errors.RestErr {
+	if len(boundary) == 0 {
+		return nil
+	}
+	if len(boundary) < 3 {
+		return rest_errors.NewBadRequestErr("boundary needs at least 3 points")
+	}
+	for _, point := range boundary {
+		if point.Lat < -90 || point.Lat > 90 || point.Lon < -180 || point.Lon > 180 {
+			return rest_errors.NewBadRequestErr(fmt.Sprintf("invalid boundary point %v,%v", point.Lat, point.Lon))
+		}
+	}
+	return nil
+}
+
+type UpdateField struct {
+	Field string      `json:"field"`
+	Value interface{} `json:"Value"`
+}
+
+type UpdateRequest struct {
+	Fields []UpdateField `json:"fields"`
+}
+
+func (u UpdateRequest) Validate() rest_errors.RestErr {
+	for i, field := range u.Fields {
+		switch field.Field {
+		case "name":
+			name, ok := field.Value.(string)
+			if !ok || strings.TrimSpace(name) == "" {
+				return rest_errors.NewBadRequestErr("invalid complex name")
+			}
+			u.Fields[i].Value = strings.TrimSpace(name)
+		case "developer", "description", "location", "city":
+			if _, ok := field.Value.(string); !ok {
+				return rest_errors.NewBadRequestErr(fmt.Sprintf("invalid %s", field.Field))
+			}
+		case "boundary":
+			bytes, _ := json.Marshal(field.Value)
+			var boundary []Point
+			if err := json.Unmarshal(bytes, &boundary); err != nil {
+				return rest_errors.NewBadRequestErr("invalid boundary")
+			}
+			if err := validateBoundary(boundary); err != nil {
+				return err
+			}
+		case "amenities":
+			bytes, _ := json.Marshal(field.Value)
+			var amenities []string
+			if err := json.Unmarshal(bytes, &amenities); err != nil {
+				return rest_errors.NewBadRequestErr("invalid amenities")
+			}
+		default:
+			return rest_errors.NewBadRequestErr(fmt.Sprintf("%s can not be updated", field.Field))
+		}
+	}
+	return nil
+}
+
+// Name returns the new name if the update renames the complex.
+func (u UpdateRequest) Name() (string, bool) {
+	for _, field := range u.Fields {
+		if field.Field == "name" {
+			name, _ := field.Value.(string)
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,79 @@
+package currency
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+)
+
+var Supported = map[string]bool{
+	"USD": true,
+	"IQD": true,
+	"EUR": true,
+	"GBP": true,
+	"TRY": true,
+	"AED": true,
+}
+
+// Rates holds how many units of each currency one unit of Base buys.
+type Rates struct {
+	Base      string             `json:"base"`
+	Rates     map[string]float64 `json:"rates"`
+	UpdatedAt string             `json:"updated_at"`
+}
+
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func Normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func Validate(code string) rest_errors.RestErr {
+	if !Supported[Normalize(code)] {
+		return rest_errors.NewBadRequestErr(fmt.Sprintf("unsupported currency %s", code))
+	}
+	return nil
+}
+
+func (r *Rates) Validate() rest_errors.RestErr {
+	r.Base = Normalize(r.Base)
+	if err := Validate(r.Base); err != nil {
+		return err
+	}
+	normalized := make(map[string]float64, len(r.Rates))
+	for code, rate := range r.Rates {
+		if err := Validate(code); err != nil {
+			return err
+		}
+		if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+			return rest_errors.NewBadRequestErr(fmt.Sprintf("invalid rate for %s", code))
+		}
+		normalized[Normalize(code)] = rate
+	}
+	normalized[r.Base] = 1
+	for code := range Supported {
+		if _, ok := normalized[code]; !ok {
+			return rest_errors.NewBadRequestErr(fmt.Sprintf("missing rate for %s", code))
+		}
+	}
+	r.Rates = normalized
+	return nil
+}
+
+// Convert turns amount in from into to, rounded to whole units.
+func (r Rates) Convert(amount float64, from string, to string) (int64, rest_errors.RestErr) {
+	fromRate, ok := r.Rates[Normalize(from)]
+	if !ok {
+		return 0, rest_errors.NewBadRequestErr(fmt.Sprintf("unsupported currency %s", from))
+	}
+	toRate, ok := r.Rates[Normalize(to)]
+	if !ok {
+		return 0, rest_errors.NewBadRequestErr(fmt.Sprintf("unsupported currency %s", to))
+	}
+	return int64(math.Round(amount / fromRate * toRate)), nil
+}
@@ -0,0 +1,76 @@
+package currency
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	rates := Rates{Base: "USD", Rates: map[string]float64{"USD": 1, "IQD": 1310, "EUR": 0.92}}
+	tests := []struct {
+		name     string
+		amount   float64
+		from     string
+		to       string
+		expected int64
+		status   int
+	}{
+		{name: "to base", amount: 131000, from: "IQD", to: "USD", expected: 100},
+		{name: "from base", amount: 100, from: "USD", to: "IQD", expected: 131000},
+		{name: "between others", amount: 1310, from: "IQD", to: "EUR", expected: 1},
+		{name: "rounds half up", amount: 1965, from: "IQD", to: "USD", expected: 2},
+		{name: "rounds down", amount: 1964, from: "IQD", to: "USD", expected: 1},
+		{name: "normalizes codes", amount: 100, from: " usd ", to: "eur", expected: 92},
+		{name: "unknown from", amount: 100, from: "JPY", to: "USD", status: http.StatusBadRequest},
+		{name: "unknown to", amount: 100, from: "USD", to: "GBP", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, err := rates.Convert(tt.amount, tt.from, tt.to)
+			if tt.status != 0 {
+				if err == nil || err.Status() != tt.status {
+					t.Fatalf("expected status %d, got %v", tt.status, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err.Message())
+			}
+			if amount != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, amount)
+			}
+		})
+	}
+}
+
+func TestRatesValidate(t *testing.T) {
+	complete := func() map[string]float64 {
+		return map[string]float64{"IQD": 1310, "EUR": 0.92, "GBP": 0.79, "TRY": 32, "AED": 3.67}
+	}
+	tests := []struct {
+		name  string
+		base  string
+		rates func() map[string]float64
+		valid bool
+	}{
+		{name: "complete", base: "usd", rates: complete, valid: true},
+		{name: "base rate is set", base: "USD", rates: func() map[string]float64 { r := complete(); r["USD"] = 5; return r }, valid: true},
+		{name: "unsupported base", base: "JPY", rates: complete},
+		{name: "unsupported currency", base: "USD", rates: func() map[string]float64 { r := complete(); r["JPY"] = 150; return r }},
+		{name: "missing currency", base: "USD", rates: func() map[string]float64 { r := complete(); delete(r, "AED"); return r }},
+		{name: "zero rate", base: "USD", rates: func() map[string]float64 { r := complete(); r["EUR"] = 0; return r }},
+		{name: "negative rate", base: "USD", rates: func() map[string]float64 { r := complete(); r["EUR"] = -1; return r }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rates := Rates{Base: tt.base, Rates: tt.rates()}
+			err := rates.Validate()
+			if tt.valid != (err == nil) {
+				t.Fatalf("expected valid %v, got %v", tt.valid, err)
+			}
+			if tt.valid && (rates.Base != "USD" || rates.Rates["USD"] != 1) {
+				t.Errorf("expected the base normalized with a rate of 1, got %s at %v", rates.Base, rates.Rates["USD"])
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package duplicate
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/utils/media_utils"
+)
+
+const (
+	STATUS_OPEN      = "open"
+	STATUS_DISMISSED = "dismissed"
+	STATUS_MERGED    = "merged"
+
+	REASON_GPS     = "gps"
+	REASON_COMPLEX = "complex"
+	REASON_FLOOR   = "floor"
+	REASON_FLAT    = "flat_no"
+	REASON_SIZE    = "size"
+	REASON_PHOTOS  = "photos"
+
+	// Two photos count as the same picture when their hashes differ in at
+	// most this many bits.
+	maxPhotoDistance = 6
+)
+
+// Suspect is a pair of listings that look like the same unit. Its ID is
+// derived from both property ids, so a pair is only ever recorded once and a
+// dismissed pair is not raised again.
+type Suspect struct {
+	ID            string   `json:"id"`
+	PropertyID    string   `json:"property_id"`
+	DuplicateOfID string   `json:"duplicate_of_id"`
+	Score         float64  `json:"score"`
+	Reasons       []string `json:"reasons"`
+	Status        string   `json:"status"`
+	KeptID        string   `json:"kept_id,omitempty"`
+	ResolvedBy    string   `json:"resolved_by,omitempty"`
+	ResolvedAt    string   `json:"resolved_at,omitempty"`
+	DateCreated   string   `json:"date_created"`
+}
+
+type Suspects []Suspect
+
+// Match is a stored listing scored against the one being checked.
+type Match struct {
+	PropertyID string   `json:"property_id"`
+	Score      float64  `json:"score"`
+	Reasons    []string `json:"reasons"`
+}
+
+type Matches []Match
+
+type MergeRequest struct {
+	Keep string `json:"keep"`
+}
+
+// ScanReport summarises a batch run over the active listings.
+type ScanReport struct {
+	StartedAt  string `json:"started_at"`
+	FinishedAt string `json:"finished_at"`
+	Scanned    int    `json:"scanned"`
+	Suspects   int    `json:"suspects"`
+	Failed     int    `json:"failed"`
+}
+
+func PairID(a string, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return a + "_" + b
+}
+
+func ValidateStatus(status string) rest_errors.RestErr {
+	switch status {
+	case "", STATUS_OPEN, STATUS_DISMISSED, STATUS_MERGED:
+		return nil
+	}
+	return rest_errors.NewBadRequestErr(fmt.Sprintf("invalid status %s", status))
+}
+
+func (r MergeRequest) Validate(s Suspect) rest_errors.RestErr {
+	if r.Keep != s.PropertyID && r.Keep != s.DuplicateOfID {
+		return rest_errors.NewBadRequestErr("keep must be one of the two listings")
+	}
+	return nil
+}
+
+// Other returns the listing of the pair that is not id.
+func (s Suspect) Other(id string) string {
+	if id == s.PropertyID {
+		return s.DuplicateOfID
+	}
+	return s.PropertyID
+}
+
+// Score rates how likely a and b describe the same unit, from 0 to 1, and
+// names the signals that matched. Listings of a different category or offer
+// (sale or rent) are never duplicates.
+func Score(a property.Property, b property.Property) (float64, []string) {
+	if a.Category != b.Category || a.ForRent != b.ForRent {
+		return 0, nil
+	}
+	var score float64
+	var reasons []string
+	add := func(weight float64, reason string) {
+		score += weight
+		reasons = append(reasons, reason)
+	}
+
+	if meters, ok := distance(a, b); ok {
+		switch {
+		case meters <= 25:
+			add(0.3, REASON_GPS)
+		case meters <= 100:
+			add(0.15, REASON_GPS)
+		}
+	}
+	sameComplex := a.ComplexID != "" && a.ComplexID == b.ComplexID
+	if sameComplex {
+		add(0.15, REASON_COMPLEX)
+	}
+	if a.FloorNumber != 0 && a.FloorNumber == b.FloorNumber {
+		add(0.1, REASON_FLOOR)
+	}
+	if flat := normalizeFlat(a.FlatNo); flat != "" && flat == normalizeFlat(b.FlatNo) {
+		add(0.2, REASON_FLAT)
+	}
+	if a.Space > 0 && b.Space > 0 && math.Abs(a.Space-b.Space) <= 0.03*math.Max(a.Space, b.Space) {
+		add(0.1, REASON_SIZE)
+	}
+	if samePhotos(a.Visuals, b.Visuals) {
+		add(0.3, REASON_PHOTOS)
+	}
+	return math.Min(score, 1), reasons
+}
+
+// Sort orders matches from the most to the least likely duplicate.
+func (m Matches) Sort() {
+	sort.SliceStable(m, func(i, j int) bool {
+		return m[i].Score > m[j].Score
+	})
+}
+
+func distance(a property.Property, b property.Property) (float64, bool) {
+	lat1, lon1, ok1 := a.GPS.Coordinates()
+	lat2, lon2, ok2 := b.GPS.Coordinates()
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	return haversine(lat1, lon1, lat2, lon2), true
+}
+
+// haversine returns the distance in meters between two coordinates.
+func haversine(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
+	const earthRadius = 6371000
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+// normalizeFlat makes "Flat 4-B", "4b" and "4 B" compare equal.
+func normalizeFlat(flat string) string {
+	flat = strings.ToLower(flat)
+	for _, prefix := range []string{"flat", "apt", "apartment", "unit", "no", "#"} {
+		flat = strings.TrimPrefix(strings.TrimSpace(flat), prefix)
+	}
+	var b strings.Builder
+	for _, r := range flat {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func samePhotos(a []property.Visual, b []property.Visual) bool {
+	for _, x := range a {
+		if x.Hash == "" {
+			continue
+		}
+		for _, y := range b {
+			if y.Hash != "" && media_utils.HashDistance(x.Hash, y.Hash) <= maxPhotoDistance {
+				return true
+			}
+		}
+	}
+	return false
+}
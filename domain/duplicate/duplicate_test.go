@@ -0,0 +1,121 @@
+package duplicate
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+)
+
+func listing(lat string, long string) property.Property {
+	p := property.Property{Category: "apartment"}
+	p.GPS.Lat, p.GPS.Long = lat, long
+	return p
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(a *property.Property, b *property.Property)
+		score   float64
+		reasons []string
+	}{
+		{name: "nothing in common", change: func(a *property.Property, b *property.Property) {}},
+		{
+			name:    "same spot",
+			change:  func(a *property.Property, b *property.Property) { b.GPS.Lat = "36.19110" },
+			score:   0.3,
+			reasons: []string{REASON_GPS},
+		},
+		{
+			name:    "same block",
+			change:  func(a *property.Property, b *property.Property) { b.GPS.Lat = "36.1916" },
+			score:   0.15,
+			reasons: []string{REASON_GPS},
+		},
+		{
+			name: "same unit",
+			change: func(a *property.Property, b *property.Property) {
+				b.GPS = a.GPS
+				a.ComplexID, b.ComplexID = "complex-1", "complex-1"
+				a.FloorNumber, b.FloorNumber = 4, 4
+				a.FlatNo, b.FlatNo = "Flat 4-B", "4b"
+				a.Space, b.Space = 100, 102
+				a.Visuals = []property.Visual{{Hash: "ffffffffffffffff"}}
+				b.Visuals = []property.Visual{{Hash: "ffffffffffffff0f"}}
+			},
+			score:   1,
+			reasons: []string{REASON_GPS, REASON_COMPLEX, REASON_FLOOR, REASON_FLAT, REASON_SIZE, REASON_PHOTOS},
+		},
+		{
+			name: "different sizes and photos",
+			change: func(a *property.Property, b *property.Property) {
+				a.Space, b.Space = 100, 104
+				a.Visuals = []property.Visual{{Hash: "ffffffffffffffff"}}
+				b.Visuals = []property.Visual{{Hash: "00000000000000ff"}}
+			},
+		},
+		{
+			name: "unset fields never match",
+			change: func(a *property.Property, b *property.Property) {
+				a.GPS.Lat, a.GPS.Long, b.GPS.Lat, b.GPS.Long = "0", "0", "0", "0"
+				a.FlatNo, b.FlatNo = "Flat", "#"
+				a.Visuals = []property.Visual{{}}
+				b.Visuals = []property.Visual{{}}
+			},
+		},
+		{
+			name: "different category",
+			change: func(a *property.Property, b *property.Property) {
+				b.GPS = a.GPS
+				b.Category = "house"
+			},
+		},
+		{
+			name: "rent and sale",
+			change: func(a *property.Property, b *property.Property) {
+				b.GPS = a.GPS
+				b.ForRent = true
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := listing("36.19100", "44.00900"), listing("36.20000", "44.00900")
+			tt.change(&a, &b)
+			score, reasons := Score(a, b)
+			if math.Abs(score-tt.score) > 1e-9 || !reflect.DeepEqual(reasons, tt.reasons) {
+				t.Errorf("expected %v %v, got %v %v", tt.score, tt.reasons, score, reasons)
+			}
+			if reverse, _ := Score(b, a); math.Abs(reverse-score) > 1e-9 {
+				t.Errorf("expected the score to be symmetric, got %v and %v", score, reverse)
+			}
+		})
+	}
+}
+
+func TestNormalizeFlat(t *testing.T) {
+	tests := []struct {
+		flat     string
+		expected string
+	}{
+		{flat: "Flat 4-B", expected: "4b"},
+		{flat: "4 B", expected: "4b"},
+		{flat: "Apt. 12", expected: "12"},
+		{flat: "#7", expected: "7"},
+		{flat: "Unit No 3", expected: "3"},
+		{flat: "", expected: ""},
+	}
+	for _, tt := range tests {
+		if normalized := normalizeFlat(tt.flat); normalized != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.flat, tt.expected, normalized)
+		}
+	}
+}
+
+func TestPairID(t *testing.T) {
+	if PairID("b", "a") != PairID("a", "b") || PairID("a", "b") != "a_b" {
+		t.Errorf("expected a_b either way, got %s and %s", PairID("a", "b"), PairID("b", "a"))
+	}
+}
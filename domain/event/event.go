@@ -0,0 +1,124 @@
+package event
+
+import (
+	"time"
+
+	"github.com/superbkibbles/realestate_property-api/domain/audit"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+)
+
+const (
+	TYPE_CREATED        = "property.created"
+	TYPE_UPDATED        = "property.updated"
+	TYPE_STATUS_CHANGED = "property.status_changed"
+	TYPE_MEDIA_ADDED    = "property.media_added"
+	TYPE_MEDIA_REMOVED  = "property.media_removed"
+
+	STATUS_PENDING     = "pending"
+	STATUS_DISPATCHING = "dispatching"
+	STATUS_DELIVERED   = "delivered"
+	STATUS_FAILED      = "failed"
+
+	// TimeLayout is used for every time in the outbox, so they also compare
+	// as strings.
+	TimeLayout = audit.TimeLayout
+
+	maxRetryDelay = time.Hour
+)
+
+// Types lists the events consumers can subscribe to.
+var Types = []string{TYPE_CREATED, TYPE_UPDATED, TYPE_STATUS_CHANGED, TYPE_MEDIA_ADDED, TYPE_MEDIA_REMOVED}
+
+// Event tells other services a listing changed. Delivery is at least once
+// and not ordered; consumers dedupe on ID and order by OccurredAt.
+type Event struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	PropertyID string `json:"property_id"`
+	AgencyID   string `json:"agency_id"`
+	OccurredAt string `json:"occurred_at"`
+	Data       Data   `json:"data"`
+}
+
+type Data struct {
+	Property       *property.Property `json:"property"`
+	Fields         []string           `json:"fields,omitempty"`
+	PreviousStatus string             `json:"previous_status,omitempty"`
+	Status         string             `json:"status,omitempty"`
+}
+
+// Delivery is an event in the outbox with its progress towards every sink.
+type Delivery struct {
+	Event
+	Status        string   `json:"status"`
+	Attempts      int      `json:"attempts"`
+	NextAttemptAt string   `json:"next_attempt_at"`
+	LockedUntil   string   `json:"locked_until,omitempty"`
+	DeliveredTo   []string `json:"delivered_to"`
+	LastError     string   `json:"last_error,omitempty"`
+	DeliveredAt   string   `json:"delivered_at,omitempty"`
+}
+
+// DispatchReport sums up one run of the dispatcher.
+type DispatchReport struct {
+	Delivered int `json:"delivered"`
+	Retried   int `json:"retried"`
+	Failed    int `json:"failed"`
+}
+
+// FromChange turns an audited change of a listing into the events other
+// services get. Translations are not published.
+func FromChange(action string, fields []string, before *property.Property, after *property.Property) []Event {
+	data := Data{Property: after, Fields: fields}
+	var types []string
+	switch action {
+	case audit.ACTION_CREATE:
+		types = []string{TYPE_CREATED}
+		data.Status = after.Status
+	case audit.ACTION_UPDATE:
+		types = []string{TYPE_UPDATED}
+		if before != nil && before.Status != after.Status {
+			types = append(types, TYPE_STATUS_CHANGED)
+			data.PreviousStatus = before.Status
+			data.Status = after.Status
+		}
+	case audit.ACTION_UPLOAD_MEDIA:
+		types = []string{TYPE_MEDIA_ADDED}
+	case audit.ACTION_DELETE_MEDIA:
+		types = []string{TYPE_MEDIA_REMOVED}
+	}
+
+	events := make([]Event, 0, len(types))
+	for _, t := range types {
+		events = append(events, Event{
+			Type:       t,
+			PropertyID: after.ID,
+			AgencyID:   after.AgencyID,
+			Data:       data,
+		})
+	}
+	return events
+}
+
+// RetryDelay doubles from 5 seconds with every failed attempt, up to an
+// hour.
+func RetryDelay(attempts int) time.Duration {
+	delay := 5 * time.Second
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
+
+// Delivered reports whether the event already reached the sink.
+func (d Delivery) Delivered(sink string) bool {
+	for _, name := range d.DeliveredTo {
+		if name == sink {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package favorite
+
+import (
+	"strings"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/notification"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+)
+
+const (
+	MaxFavoritesPerUser = 500
+)
+
+// Favorite bookmarks a listing for a user whatever its status. The listing's
+// price and sold flag are remembered to notice changes worth telling the user
+// about.
+type Favorite struct {
+	ID         string `json:"id"`
+	UserID     string `json:"user_id"`
+	PropertyID string `json:"property_id"`
+	AgencyID   string `json:"agency_id"`
+	Channel    string `json:"channel"`
+	Target     string `json:"target,omitempty"`
+
+	LastPrice    int64  `json:"last_price"`
+	LastCurrency string `json:"last_currency"`
+	LastSold     bool   `json:"last_sold"`
+	DateCreated  string `json:"date_created"`
+
+	// Property is the listing as it is now, filled in when favorites are
+	// listed.
+	Property *property.Property `json:"property,omitempty"`
+}
+
+type Favorites []Favorite
+
+// Request picks where change notifications go; by default they are logged.
+type Request struct {
+	Channel string `json:"channel"`
+	Target  string `json:"target"`
+}
+
+// Counts is the number of users who favorited each listing.
+type Counts struct {
+	ID         string           `json:"id"`
+	Total      int64            `json:"total"`
+	Properties map[string]int64 `json:"properties"`
+}
+
+func ID(userID string, propertyID string) string {
+	return userID + "_" + propertyID
+}
+
+func (r *Request) Validate() rest_errors.RestErr {
+	if r.Channel == "" {
+		r.Channel = notification.CHANNEL_LOG
+	}
+	r.Target = strings.TrimSpace(r.Target)
+	return notification.ValidateTarget(r.Channel, r.Target)
+}
@@ -0,0 +1,161 @@
+package inquiry
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+)
+
+const (
+	STATUS_NEW       = "new"
+	STATUS_CONTACTED = "contacted"
+	STATUS_QUALIFIED = "qualified"
+	STATUS_CLOSED    = "closed"
+	STATUS_SPAM      = "spam"
+
+	maxNameLength    = 100
+	maxMessageLength = 2000
+	maxContactTime   = 100
+	maxLinks         = 2
+)
+
+var (
+	phonePattern = regexp.MustCompile(`^\+?[0-9 ()\-]{7,20}$`)
+	linkPattern  = regexp.MustCompile(`(?i)https?://|www\.`)
+)
+
+// Inquiry is a prospective buyer contacting the agency of a listing.
+type Inquiry struct {
+	ID                   string   `json:"id"`
+	PropertyID           string   `json:"property_id"`
+	AgencyID             string   `json:"agency_id"`
+	UserID               string   `json:"user_id,omitempty"`
+	Name                 string   `json:"name"`
+	Phone                string   `json:"phone"`
+	PhoneDigits          string   `json:"phone_digits"`
+	Email                string   `json:"email,omitempty"`
+	Message              string   `json:"message"`
+	PreferredContactTime string   `json:"preferred_contact_time,omitempty"`
+	Status               string   `json:"status"`
+	SpamReasons          []string `json:"spam_reasons,omitempty"`
+	AssignedTo           string   `json:"assigned_to,omitempty"`
+	DateCreated          string   `json:"date_created"`
+	DateUpdated          string   `json:"date_updated,omitempty"`
+}
+
+type Inquiries []Inquiry
+
+// Request is what a buyer sends. Website is a honeypot: the form hides it,
+// so only bots fill it in.
+type Request struct {
+	Name                 string `json:"name"`
+	Phone                string `json:"phone"`
+	Email                string `json:"email"`
+	Message              string `json:"message"`
+	PreferredContactTime string `json:"preferred_contact_time"`
+	Website              string `json:"website"`
+}
+
+type AssignRequest struct {
+	AssignedTo string `json:"assigned_to"`
+}
+
+type StatusRequest struct {
+	Status string `json:"status"`
+}
+
+// ListingLeads counts the inquiries of one listing per status.
+type ListingLeads struct {
+	Total    int64            `json:"total"`
+	ByStatus map[string]int64 `json:"by_status"`
+}
+
+type LeadCounts struct {
+	AgencyID   string                  `json:"agency_id"`
+	Total      int64                   `json:"total"`
+	Properties map[string]ListingLeads `json:"properties"`
+}
+
+func (r *Request) Validate() rest_errors.RestErr {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Phone = strings.TrimSpace(r.Phone)
+	r.Email = strings.TrimSpace(r.Email)
+	r.Message = strings.TrimSpace(r.Message)
+	r.PreferredContactTime = strings.TrimSpace(r.PreferredContactTime)
+
+	if r.Name == "" || len(r.Name) > maxNameLength {
+		return rest_errors.NewBadRequestErr("name is required and must be at most 100 characters")
+	}
+	if !phonePattern.MatchString(r.Phone) || digits(r.Phone) < 7 {
+		return rest_errors.NewBadRequestErr("invalid phone number")
+	}
+	if r.Email != "" && !strings.Contains(r.Email, "@") {
+		return rest_errors.NewBadRequestErr("invalid email")
+	}
+	if r.Message == "" || len(r.Message) > maxMessageLength {
+		return rest_errors.NewBadRequestErr(fmt.Sprintf("message is required and must be at most %d characters", maxMessageLength))
+	}
+	if len(r.PreferredContactTime) > maxContactTime {
+		return rest_errors.NewBadRequestErr("preferred_contact_time is too long")
+	}
+	return nil
+}
+
+// SpamReasons lists why a request looks automated or abusive; it is empty for
+// ordinary inquiries.
+func (r Request) SpamReasons() []string {
+	var reasons []string
+	if r.Website != "" {
+		reasons = append(reasons, "honeypot")
+	}
+	if len(linkPattern.FindAllString(r.Message, -1)) > maxLinks {
+		reasons = append(reasons, "links")
+	}
+	if repeated(r.Message) {
+		reasons = append(reasons, "repeated_characters")
+	}
+	return reasons
+}
+
+// Phone numbers are compared on their digits only.
+func NormalizePhone(phone string) string {
+	var b strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func ValidateStatus(status string) rest_errors.RestErr {
+	switch status {
+	case STATUS_NEW, STATUS_CONTACTED, STATUS_QUALIFIED, STATUS_CLOSED, STATUS_SPAM:
+		return nil
+	}
+	return rest_errors.NewBadRequestErr(fmt.Sprintf("invalid status %s", status))
+}
+
+func digits(s string) int {
+	return len(NormalizePhone(s))
+}
+
+// repeated catches messages such as "aaaaaaaaaaaa" or "!!!!!!!!!!".
+func repeated(message string) bool {
+	run := 1
+	var last rune
+	for i, r := range message {
+		if i > 0 && r == last {
+			run++
+			if run >= 10 {
+				return true
+			}
+		} else {
+			run = 1
+		}
+		last = r
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package inquiry
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSpamReasons(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  Request
+		expected []string
+	}{
+		{name: "ordinary", request: Request{Message: "Is the flat still available? See www.example.com"}},
+		{name: "honeypot", request: Request{Message: "Hello", Website: "http://spam.example"}, expected: []string{"honeypot"}},
+		{name: "two links", request: Request{Message: "http://a.example and https://b.example"}},
+		{name: "three links", request: Request{Message: "http://a.example https://b.example www.c.example"}, expected: []string{"links"}},
+		{name: "nine repeated", request: Request{Message: "wow!!!!!!!!!"}},
+		{name: "ten repeated", request: Request{Message: "wow!!!!!!!!!!"}, expected: []string{"repeated_characters"}},
+		{name: "repeated letters", request: Request{Message: "Helloooooooooo"}, expected: []string{"repeated_characters"}},
+		{
+			name:     "everything",
+			request:  Request{Message: "HTTP://a www.b www.c zzzzzzzzzz", Website: "x"},
+			expected: []string{"honeypot", "links", "repeated_characters"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if reasons := tt.request.SpamReasons(); !reflect.DeepEqual(reasons, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, reasons)
+			}
+		})
+	}
+}
+
+func TestRequestValidate(t *testing.T) {
+	valid := func() Request {
+		return Request{Name: " Sara ", Phone: "+964 (750) 123-4567", Message: " Hello ", Email: "sara@example.com"}
+	}
+	tests := []struct {
+		name   string
+		change func(r *Request)
+		valid  bool
+	}{
+		{name: "valid", change: func(r *Request) {}, valid: true},
+		{name: "no email", change: func(r *Request) { r.Email = "" }, valid: true},
+		{name: "no name", change: func(r *Request) { r.Name = "  " }},
+		{name: "long name", change: func(r *Request) { r.Name = strings.Repeat("a", maxNameLength+1) }},
+		{name: "short phone", change: func(r *Request) { r.Phone = "12-34" }},
+		{name: "phone with letters", change: func(r *Request) { r.Phone = "0750 CALL ME" }},
+		{name: "invalid email", change: func(r *Request) { r.Email = "sara" }},
+		{name: "no message", change: func(r *Request) { r.Message = "" }},
+		{name: "long message", change: func(r *Request) { r.Message = strings.Repeat("a", maxMessageLength+1) }},
+		{name: "long contact time", change: func(r *Request) { r.PreferredContactTime = strings.Repeat("a", maxContactTime+1) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid()
+			tt.change(&r)
+			err := r.Validate()
+			if tt.valid != (err == nil) {
+				t.Fatalf("expected valid %v, got %v", tt.valid, err)
+			}
+			if tt.valid && (r.Name != "Sara" || r.Message != "Hello") {
+				t.Errorf("expected trimmed values, got %q and %q", r.Name, r.Message)
+			}
+		})
+	}
+}
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		phone    string
+		expected string
+	}{
+		{phone: "+964 (750) 123-4567", expected: "9647501234567"},
+		{phone: "0750 123 4567", expected: "07501234567"},
+		{phone: "", expected: ""},
+	}
+	for _, tt := range tests {
+		if normalized := NormalizePhone(tt.phone); normalized != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.phone, tt.expected, normalized)
+		}
+	}
+}
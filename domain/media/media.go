@@ -0,0 +1,51 @@
+package media
+
+import "time"
+
+const (
+	// ASSET_TAG marks the assets this service stored, so nothing else in a
+	// shared storage account is ever collected.
+	ASSET_TAG = "property"
+
+	REASON_PROPERTY_DELETED = "property_deleted"
+	REASON_COMPLEX_DELETED  = "complex_deleted"
+	REASON_UNREFERENCED     = "unreferenced"
+)
+
+// StoredAsset is a file as listed by the storage backend. PublicID includes
+// the folder, e.g. "<property id>/<asset id>".
+type StoredAsset struct {
+	PublicID  string    `json:"public_id"`
+	Folder    string    `json:"folder"`
+	Url       string    `json:"url"`
+	Kind      string    `json:"kind"`
+	Tags      []string  `json:"tags,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Owned reports whether the asset was stored by this service.
+func (a StoredAsset) Owned() bool {
+	for _, tag := range a.Tags {
+		if tag == ASSET_TAG {
+			return true
+		}
+	}
+	return false
+}
+
+type Orphan struct {
+	StoredAsset
+	Reason  string `json:"reason"`
+	Deleted bool   `json:"deleted"`
+	Error   string `json:"error,omitempty"`
+}
+
+type GcReport struct {
+	DryRun         bool     `json:"dry_run"`
+	StartedAt      string   `json:"started_at"`
+	FinishedAt     string   `json:"finished_at"`
+	FoldersScanned int      `json:"folders_scanned"`
+	AssetsScanned  int      `json:"assets_scanned"`
+	Skipped        []string `json:"skipped_folders,omitempty"`
+	Orphans        []Orphan `json:"orphans"`
+}
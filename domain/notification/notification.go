@@ -0,0 +1,51 @@
+package notification
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/utils/net_utils"
+)
+
+const (
+	CHANNEL_WEBHOOK = "webhook"
+	CHANNEL_EMAIL   = "email"
+	CHANNEL_LOG     = "log"
+)
+
+// Message is one notification for a user. Payload is sent as JSON to
+// webhooks; email and log receive Subject and Text.
+type Message struct {
+	Channel string      `json:"channel"`
+	To      string      `json:"to"`
+	Event   string      `json:"event"`
+	Subject string      `json:"subject"`
+	Text    string      `json:"text"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// ValidateTarget checks that to can receive messages on channel: a http(s)
+// URL on a public address for webhooks and an address for email.
+func ValidateTarget(channel string, to string) rest_errors.RestErr {
+	switch channel {
+	case CHANNEL_WEBHOOK:
+		u, err := url.Parse(to)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return rest_errors.NewBadRequestErr("webhook target must be a http or https URL")
+		}
+		if err := net_utils.CheckPublicHost(u.Hostname()); err != nil {
+			return rest_errors.NewBadRequestErr(fmt.Sprintf("webhook target must be a public address: %s", err.Error()))
+		}
+	case CHANNEL_EMAIL:
+		if _, err := mail.ParseAddress(to); err != nil || !strings.Contains(to, "@") {
+			return rest_errors.NewBadRequestErr("email target must be an email address")
+		}
+	case CHANNEL_LOG:
+	default:
+		return rest_errors.NewBadRequestErr(fmt.Sprintf("invalid channel %s", channel))
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package promotion
+
+import (
+	"strings"
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+)
+
+const (
+	TIER_BASIC    = "basic"
+	TIER_PREMIUM  = "premium"
+	TIER_FEATURED = "featured"
+
+	PLACEMENT_SEARCH = "search"
+	PLACEMENT_LIST   = "list"
+	PLACEMENT_ALL    = "all"
+
+	STATUS_SCHEDULED = "scheduled"
+	STATUS_ACTIVE    = "active"
+	STATUS_ENDED     = "ended"
+	STATUS_CANCELLED = "cancelled"
+
+	MaxDuration = 90 * 24 * time.Hour
+
+	// ClickWindow is how long repeated clicks by the same visitor count
+	// once.
+	ClickWindow = 24 * time.Hour
+)
+
+// Promotion boosts a listing in search or list results between Start and
+// End. It stops applying at End without anything having to run.
+type Promotion struct {
+	ID          string `json:"id"`
+	PropertyID  string `json:"property_id"`
+	AgencyID    string `json:"agency_id"`
+	Tier        string `json:"tier"`
+	Placement   string `json:"placement"`
+	Start       string `json:"start"`
+	End         string `json:"end"`
+	CancelledAt string `json:"cancelled_at,omitempty"`
+	DateCreated string `json:"date_created"`
+
+	Impressions int64 `json:"impressions"`
+	Clicks      int64 `json:"clicks"`
+}
+
+type Promotions []Promotion
+
+// Click is a visitor opening a promoted result.
+type Click struct {
+	PromotionID string    `json:"promotion_id"`
+	PropertyID  string    `json:"property_id"`
+	VisitorID   string    `json:"visitor_id"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+type Request struct {
+	Tier      string `json:"tier"`
+	Placement string `json:"placement"`
+	Start     string `json:"start"`
+	End       string `json:"end"`
+}
+
+// Active maps listing IDs to the promotion boosting them right now.
+type Active map[string]Promotion
+
+// Report sums up the promotions of an agency.
+type Report struct {
+	AgencyID    string        `json:"agency_id"`
+	Impressions int64         `json:"impressions"`
+	Clicks      int64         `json:"clicks"`
+	Promotions  []ReportEntry `json:"promotions"`
+}
+
+type ReportEntry struct {
+	Promotion
+	Status           string  `json:"status"`
+	ClickThroughRate float64 `json:"click_through_rate"`
+}
+
+// Validate defaults start to now and normalizes both times to UTC.
+func (r *Request) Validate(now time.Time) rest_errors.RestErr {
+	r.Tier = strings.ToLower(strings.TrimSpace(r.Tier))
+	switch r.Tier {
+	case TIER_BASIC, TIER_PREMIUM, TIER_FEATURED:
+	default:
+		return rest_errors.NewBadRequestErr("tier must be basic, premium or featured")
+	}
+	r.Placement = strings.ToLower(strings.TrimSpace(r.Placement))
+	switch r.Placement {
+	case "":
+		r.Placement = PLACEMENT_ALL
+	case PLACEMENT_SEARCH, PLACEMENT_LIST, PLACEMENT_ALL:
+	default:
+		return rest_errors.NewBadRequestErr("placement must be search, list or all")
+	}
+
+	start := now
+	if strings.TrimSpace(r.Start) != "" {
+		parsed, err := time.Parse(time.RFC3339, strings.TrimSpace(r.Start))
+		if err != nil {
+			return rest_errors.NewBadRequestErr("start must be an RFC 3339 time")
+		}
+		if parsed.After(now) {
+			start = parsed
+		}
+	}
+	end, err := time.Parse(time.RFC3339, strings.TrimSpace(r.End))
+	if err != nil {
+		return rest_errors.NewBadRequestErr("end must be an RFC 3339 time")
+	}
+	if !end.After(start) {
+		return rest_errors.NewBadRequestErr("end must be after start")
+	}
+	if end.Sub(start) > MaxDuration {
+		return rest_errors.NewBadRequestErr("a promotion can run for at most 90 days")
+	}
+	r.Start = start.UTC().Format(time.RFC3339)
+	r.End = end.UTC().Format(time.RFC3339)
+	return nil
+}
+
+// Status tells where the promotion is in its life at now.
+func (p Promotion) Status(now time.Time) string {
+	at := now.UTC().Format(time.RFC3339)
+	switch {
+	case p.CancelledAt != "":
+		return STATUS_CANCELLED
+	case at < p.Start:
+		return STATUS_SCHEDULED
+	case at < p.End:
+		return STATUS_ACTIVE
+	}
+	return STATUS_ENDED
+}
+
+// Overlaps reports whether both promotions boost the same placement at the
+// same time.
+func (p Promotion) Overlaps(other Promotion) bool {
+	if p.CancelledAt != "" || other.CancelledAt != "" {
+		return false
+	}
+	if p.Placement != other.Placement && p.Placement != PLACEMENT_ALL && other.Placement != PLACEMENT_ALL {
+		return false
+	}
+	return p.Start < other.End && other.Start < p.End
+}
+
+func (p Promotion) Entry(now time.Time) ReportEntry {
+	entry := ReportEntry{Promotion: p, Status: p.Status(now)}
+	if p.Impressions > 0 {
+		entry.ClickThroughRate = float64(p.Clicks) / float64(p.Impressions)
+	}
+	return entry
+}
+
+// Mark flags the promoted listings so clients can label them.
+func (a Active) Mark(properties property.Properties) {
+	for i := range properties {
+		promotion, ok := a[properties[i].ID]
+		properties[i].Promoted = ok
+		properties[i].PromotionID = promotion.ID
+	}
+}
+
+// Cap reorders marked properties so no page of pageSize holds more than
+// perPage promoted listings. Promoted listings over the cap move down to the
+// next page with room, ahead of the organic listings they outranked.
+func Cap(properties property.Properties, pageSize int, perPage int) property.Properties {
+	if pageSize <= 0 || perPage < 0 || perPage >= pageSize {
+		return properties
+	}
+	ranked := make(property.Properties, 0, len(properties))
+	var deferred property.Properties
+	onPage := 0
+	for i := 0; i < len(properties) || len(deferred) > 0; {
+		if len(ranked)%pageSize == 0 {
+			onPage = 0
+		}
+		if len(deferred) > 0 && onPage < perPage {
+			ranked = append(ranked, deferred[0])
+			deferred = deferred[1:]
+			onPage++
+			continue
+		}
+		if i == len(properties) {
+			// Only promoted listings are left, there is nothing to space
+			// them out with.
+			ranked = append(ranked, deferred...)
+			break
+		}
+		p := properties[i]
+		i++
+		if p.Promoted && onPage >= perPage {
+			deferred = append(deferred, p)
+			continue
+		}
+		if p.Promoted {
+			onPage++
+		}
+		ranked = append(ranked, p)
+	}
+	return ranked
+}
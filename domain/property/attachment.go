@@ -0,0 +1,146 @@
+package property
+
+import (
+	"fmt"
+	"mime/multipart"
+	"strings"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/utils/media_utils"
+)
+
+const (
+	ATTACHMENT_FLOOR_PLAN = "floor_plan"
+	ATTACHMENT_DOCUMENT   = "document"
+	ATTACHMENT_BROCHURE   = "brochure"
+
+	VISIBILITY_PUBLIC = "public"
+	VISIBILITY_AGENCY = "agency"
+
+	MaxAttachmentSize = 20 << 20
+	MaxAttachments    = 30
+)
+
+var (
+	attachmentKinds = map[string]bool{
+		ATTACHMENT_FLOOR_PLAN: true,
+		ATTACHMENT_DOCUMENT:   true,
+		ATTACHMENT_BROCHURE:   true,
+	}
+
+	attachmentMimeTypes = map[string]bool{
+		media_utils.MIME_PDF:  true,
+		media_utils.MIME_JPEG: true,
+		media_utils.MIME_PNG:  true,
+		media_utils.MIME_WEBP: true,
+	}
+
+	attachmentLocals = map[string]bool{
+		"en":  true,
+		"ar":  true,
+		"kur": true,
+	}
+)
+
+// Attachment is a floor plan, document or brochure of a property. Agency
+// only attachments have no Url; they are fetched through a signed link.
+type Attachment struct {
+	ID          string            `json:"id"`
+	Kind        string            `json:"kind"`
+	Title       string            `json:"title"`
+	Labels      map[string]string `json:"labels"`
+	Label       string            `json:"label,omitempty"`
+	Visibility  string            `json:"visibility"`
+	Url         string            `json:"url,omitempty"`
+	PublicID    string            `json:"public_id"`
+	FileType    string            `json:"file_type"`
+	Size        int64             `json:"size"`
+	DateCreated string            `json:"date_created"`
+}
+
+func (a Attachment) IsPrivate() bool {
+	return a.Visibility == VISIBILITY_AGENCY
+}
+
+// AttachmentLink is an expiring url to download an attachment.
+type AttachmentLink struct {
+	Url       string `json:"url"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+type AttachmentRequest struct {
+	Kind       string
+	Title      string
+	Labels     map[string]string
+	Visibility string
+	File       *multipart.FileHeader
+}
+
+func (r *AttachmentRequest) Validate() rest_errors.RestErr {
+	if !attachmentKinds[r.Kind] {
+		return rest_errors.NewBadRequestErr("invalid kind, must be floor_plan, document or brochure")
+	}
+	r.Title = strings.TrimSpace(r.Title)
+	if r.Title == "" {
+		return rest_errors.NewBadRequestErr("invalid title")
+	}
+	for local := range r.Labels {
+		if !attachmentLocals[local] {
+			return rest_errors.NewBadRequestErr(fmt.Sprintf("invalid label language %s", local))
+		}
+	}
+	if r.Visibility == "" {
+		r.Visibility = VISIBILITY_PUBLIC
+	}
+	if r.Visibility != VISIBILITY_PUBLIC && r.Visibility != VISIBILITY_AGENCY {
+		return rest_errors.NewBadRequestErr("invalid visibility, must be public or agency")
+	}
+	if r.File == nil {
+		return rest_errors.NewBadRequestErr("no file was uploaded in the file field")
+	}
+	if r.File.Size > MaxAttachmentSize {
+		return rest_errors.NewBadRequestErr(fmt.Sprintf("file exceeds the maximum size of %d bytes", MaxAttachmentSize))
+	}
+	return nil
+}
+
+// ValidateContent checks the sniffed type of the uploaded file.
+func (r *AttachmentRequest) ValidateContent(head []byte) (string, rest_errors.RestErr) {
+	mimeType := media_utils.DetectContentType(head)
+	if !attachmentMimeTypes[mimeType] {
+		return "", rest_errors.NewBadRequestErr(fmt.Sprintf("unsupported file type %s", mimeType))
+	}
+	return mimeType, nil
+}
+
+// LocalizeAttachments sets the label of every attachment in local, falling back to the
+// English label and then to the title.
+func (p *Property) LocalizeAttachments(local string) {
+	for i, a := range p.Attachments {
+		label := a.Labels[local]
+		if label == "" {
+			label = a.Labels["en"]
+		}
+		if label == "" {
+			label = a.Title
+		}
+		p.Attachments[i].Label = label
+	}
+}
+
+// HidePrivateAttachments drops agency only attachments unless agencyID is the
+// agency of the listing.
+func (properties Properties) HidePrivateAttachments(agencyID string) {
+	for i := range properties {
+		if agencyID != "" && properties[i].AgencyID == agencyID {
+			continue
+		}
+		visible := make([]Attachment, 0, len(properties[i].Attachments))
+		for _, a := range properties[i].Attachments {
+			if !a.IsPrivate() {
+				visible = append(visible, a)
+			}
+		}
+		properties[i].Attachments = visible
+	}
+}
@@ -0,0 +1,91 @@
+package property
+
+import (
+	"mime/multipart"
+	"reflect"
+	"testing"
+)
+
+func attachmentIDs(p Property) []string {
+	ids := make([]string, 0, len(p.Attachments))
+	for _, a := range p.Attachments {
+		ids = append(ids, a.ID)
+	}
+	return ids
+}
+
+func TestHidePrivateAttachments(t *testing.T) {
+	tests := []struct {
+		name     string
+		agencyID string
+		expected []string
+	}{
+		{name: "anonymous", expected: []string{"plan"}},
+		{name: "other agency", agencyID: "agency-2", expected: []string{"plan"}},
+		{name: "own agency", agencyID: "agency-1", expected: []string{"plan", "contract"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			properties := Properties{
+				{AgencyID: "agency-1", Attachments: []Attachment{
+					{ID: "plan", Visibility: VISIBILITY_PUBLIC},
+					{ID: "contract", Visibility: VISIBILITY_AGENCY},
+				}},
+				// A listing without an agency is never shown private files.
+				{Attachments: []Attachment{{ID: "deed", Visibility: VISIBILITY_AGENCY}}},
+			}
+			properties.HidePrivateAttachments(tt.agencyID)
+			if ids := attachmentIDs(properties[0]); !reflect.DeepEqual(ids, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, ids)
+			}
+			if len(properties[1].Attachments) != 0 {
+				t.Errorf("expected the private attachment of the second listing hidden, got %v", attachmentIDs(properties[1]))
+			}
+		})
+	}
+}
+
+func TestAttachmentRequestValidate(t *testing.T) {
+	file := &multipart.FileHeader{Size: 1 << 10}
+	tests := []struct {
+		name       string
+		request    AttachmentRequest
+		valid      bool
+		visibility string
+	}{
+		{name: "defaults to public", request: AttachmentRequest{Kind: ATTACHMENT_FLOOR_PLAN, Title: " Plan ", File: file}, valid: true, visibility: VISIBILITY_PUBLIC},
+		{name: "agency only", request: AttachmentRequest{Kind: ATTACHMENT_DOCUMENT, Title: "Contract", Visibility: VISIBILITY_AGENCY, Labels: map[string]string{"kur": "Peyman"}, File: file}, valid: true, visibility: VISIBILITY_AGENCY},
+		{name: "unknown kind", request: AttachmentRequest{Kind: "photo", Title: "Plan", File: file}},
+		{name: "blank title", request: AttachmentRequest{Kind: ATTACHMENT_BROCHURE, Title: "  ", File: file}},
+		{name: "unknown label language", request: AttachmentRequest{Kind: ATTACHMENT_BROCHURE, Title: "Brochure", Labels: map[string]string{"fr": "Brochure"}, File: file}},
+		{name: "unknown visibility", request: AttachmentRequest{Kind: ATTACHMENT_BROCHURE, Title: "Brochure", Visibility: "private", File: file}},
+		{name: "no file", request: AttachmentRequest{Kind: ATTACHMENT_BROCHURE, Title: "Brochure"}},
+		{name: "too large", request: AttachmentRequest{Kind: ATTACHMENT_BROCHURE, Title: "Brochure", File: &multipart.FileHeader{Size: MaxAttachmentSize + 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.request.Validate()
+			if tt.valid != (err == nil) {
+				t.Fatalf("expected valid %v, got %v", tt.valid, err)
+			}
+			if tt.valid && tt.request.Visibility != tt.visibility {
+				t.Errorf("expected visibility %s, got %s", tt.visibility, tt.request.Visibility)
+			}
+		})
+	}
+}
+
+func TestLocalizeAttachments(t *testing.T) {
+	p := Property{Attachments: []Attachment{
+		{Title: "Plan", Labels: map[string]string{"en": "Floor plan", "ar": "مخطط"}},
+		{Title: "Plan", Labels: map[string]string{"en": "Floor plan"}},
+		{Title: "Plan"},
+	}}
+	p.LocalizeAttachments("ar")
+	expected := []string{"مخطط", "Floor plan", "Plan"}
+	for i, a := range p.Attachments {
+		if a.Label != expected[i] {
+			t.Errorf("attachment %d: expected %q, got %q", i, expected[i], a.Label)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package property
+
+import "time"
+
+// ExpiryReport sums up one run of the expiry scheduler.
+type ExpiryReport struct {
+	Scheduled int `json:"scheduled"`
+	Warned    int `json:"warned"`
+	Expired   int `json:"expired"`
+	Failed    int `json:"failed"`
+}
+
+// Expired reports whether the listing is past its expiry date. Listings
+// without one never expire.
+func (p Property) Expired(now time.Time) bool {
+	expiresAt, err := time.Parse(time.RFC3339, p.ExpiresAt)
+	return err == nil && !expiresAt.After(now)
+}
@@ -0,0 +1,167 @@
+package property
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	// Timezones must resolve even on hosts without a zoneinfo database.
+	_ "time/tzdata"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+)
+
+const (
+	MaxOpenHouses   = 20
+	maxOpenHouseLen = 12 * time.Hour
+	maxCapacity     = 10000
+	maxGuests       = 10
+)
+
+var localLayouts = []string{"2006-01-02T15:04", "2006-01-02T15:04:05"}
+
+// OpenHouse is an event anyone can attend without booking a slot. Start and
+// End are stored in UTC so they can be searched, e.g. with a range on
+// open_houses.start; Timezone is where the event takes place.
+type OpenHouse struct {
+	ID          string `json:"id"`
+	Start       string `json:"start"`
+	End         string `json:"end"`
+	Timezone    string `json:"timezone"`
+	LocalStart  string `json:"local_start"`
+	LocalEnd    string `json:"local_end"`
+	Capacity    int64  `json:"capacity"`
+	RSVPCount   int64  `json:"rsvp_count"`
+	Note        string `json:"note,omitempty"`
+	DateCreated string `json:"date_created"`
+}
+
+// OpenHouseRequest takes times either with an offset or as wall clock times
+// in Timezone, e.g. "2026-05-01T10:00".
+type OpenHouseRequest struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Timezone string `json:"timezone"`
+	Capacity int64  `json:"capacity"`
+	Note     string `json:"note"`
+}
+
+// RSVP is a user's intention to attend an open house with Guests people.
+type RSVP struct {
+	ID          string `json:"id"`
+	PropertyID  string `json:"property_id"`
+	OpenHouseID string `json:"open_house_id"`
+	UserID      string `json:"user_id"`
+	Name        string `json:"name"`
+	Guests      int64  `json:"guests"`
+	DateCreated string `json:"date_created"`
+}
+
+type RSVPRequest struct {
+	Name   string `json:"name"`
+	Guests int64  `json:"guests"`
+}
+
+// RSVPID makes a user's RSVP to an open house unique.
+func RSVPID(openHouseID string, userID string) string {
+	return openHouseID + "_" + userID
+}
+
+// OpenHouse builds the event from the request, validated against now.
+func (r OpenHouseRequest) OpenHouse(now time.Time) (*OpenHouse, rest_errors.RestErr) {
+	r.Timezone = strings.TrimSpace(r.Timezone)
+	if r.Timezone == "" {
+		return nil, rest_errors.NewBadRequestErr("timezone is required, e.g. Asia/Baghdad")
+	}
+	location, err := time.LoadLocation(r.Timezone)
+	if err != nil {
+		return nil, rest_errors.NewBadRequestErr(fmt.Sprintf("unknown timezone %s", r.Timezone))
+	}
+	start, startErr := parseEventTime(r.Start, location)
+	end, endErr := parseEventTime(r.End, location)
+	if startErr != nil || endErr != nil {
+		return nil, rest_errors.NewBadRequestErr("start and end must be times such as 2026-05-01T10:00 or 2026-05-01T10:00:00+03:00")
+	}
+	if !end.After(start) || end.Sub(start) > maxOpenHouseLen {
+		return nil, rest_errors.NewBadRequestErr("an open house must end after it starts and last at most 12 hours")
+	}
+	if !start.After(now) {
+		return nil, rest_errors.NewBadRequestErr("an open house must start in the future")
+	}
+	if r.Capacity < 1 || r.Capacity > maxCapacity {
+		return nil, rest_errors.NewBadRequestErr(fmt.Sprintf("capacity must be between 1 and %d", maxCapacity))
+	}
+	if len(r.Note) > 500 {
+		return nil, rest_errors.NewBadRequestErr("note must be at most 500 characters")
+	}
+
+	return &OpenHouse{
+		Start:       start.UTC().Format(time.RFC3339),
+		End:         end.UTC().Format(time.RFC3339),
+		Timezone:    r.Timezone,
+		LocalStart:  start.In(location).Format(time.RFC3339),
+		LocalEnd:    end.In(location).Format(time.RFC3339),
+		Capacity:    r.Capacity,
+		Note:        strings.TrimSpace(r.Note),
+		DateCreated: now.Format(time.RFC3339),
+	}, nil
+}
+
+func parseEventTime(value string, location *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	var err error
+	for _, layout := range localLayouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, value, location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+func (r *RSVPRequest) Validate() rest_errors.RestErr {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Guests == 0 {
+		r.Guests = 1
+	}
+	if r.Name == "" || len(r.Name) > 100 {
+		return rest_errors.NewBadRequestErr("name is required and must be at most 100 characters")
+	}
+	if r.Guests < 1 || r.Guests > maxGuests {
+		return rest_errors.NewBadRequestErr(fmt.Sprintf("guests must be between 1 and %d", maxGuests))
+	}
+	return nil
+}
+
+func (o OpenHouse) Ended(now time.Time) bool {
+	end, err := time.Parse(time.RFC3339, o.End)
+	return err == nil && !end.After(now)
+}
+
+// FindOpenHouse returns the open house with id, or nil.
+func (p *Property) FindOpenHouse(id string) *OpenHouse {
+	for i := range p.OpenHouses {
+		if p.OpenHouses[i].ID == id {
+			return &p.OpenHouses[i]
+		}
+	}
+	return nil
+}
+
+// DropEndedOpenHouses keeps only events still to come or under way, earliest
+// first.
+func (p *Property) DropEndedOpenHouses(now time.Time) {
+	upcoming := make([]OpenHouse, 0, len(p.OpenHouses))
+	for _, o := range p.OpenHouses {
+		if !o.Ended(now) {
+			upcoming = append(upcoming, o)
+		}
+	}
+	sort.Slice(upcoming, func(i, j int) bool {
+		return upcoming[i].Start < upcoming[j].Start
+	})
+	p.OpenHouses = upcoming
+}
@@ -0,0 +1,67 @@
+package property
+
+import "github.com/superbkibbles/bookstore_utils-go/rest_errors"
+
+type PriceChange struct {
+	ID          string `json:"id"`
+	PropertyID  string `json:"property_id"`
+	OldPrice    int64  `json:"old_price"`
+	NewPrice    int64  `json:"new_price"`
+	OldCurrency string `json:"old_currency"`
+	NewCurrency string `json:"new_currency"`
+	ChangedBy   string `json:"changed_by"`
+	ChangedAt   string `json:"changed_at"`
+}
+
+type PriceHistory []PriceChange
+
+// PriceChange looks for price or currency in an update and returns the
+// change it makes to p, or nil when both stay the same.
+func (u EsUpdate) PriceChange(p *Property) (*PriceChange, rest_errors.RestErr) {
+	change := PriceChange{
+		PropertyID:  p.ID,
+		OldPrice:    p.Price,
+		NewPrice:    p.Price,
+		OldCurrency: p.Currency,
+		NewCurrency: p.Currency,
+	}
+	for _, field := range u.Fields {
+		switch field.Field {
+		case "price":
+			price, ok := toInt64(field.Value)
+			if !ok || price < 0 {
+				return nil, rest_errors.NewBadRequestErr("invalid price")
+			}
+			change.NewPrice = price
+		case "currency":
+			currency, ok := field.Value.(string)
+			if !ok || currency == "" {
+				return nil, rest_errors.NewBadRequestErr("invalid currency")
+			}
+			change.NewCurrency = currency
+		}
+	}
+	if change.NewPrice == change.OldPrice && change.NewCurrency == change.OldCurrency {
+		return nil, nil
+	}
+	return &change, nil
+}
+
+// IsReduction is only meaningful when the currency stays the same.
+func (c PriceChange) IsReduction() bool {
+	return c.OldCurrency == c.NewCurrency && c.NewPrice < c.OldPrice
+}
+
+// toInt64 accepts the numeric types a field value can hold, either decoded
+// from JSON or set in code.
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return int64(v), v == float64(int64(v))
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	}
+	return 0, false
+}
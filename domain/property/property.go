@@ -1,6 +1,14 @@
 package property
 
-import "github.com/superbkibbles/bookstore_utils-go/rest_errors"
+import (
+	"strconv"
+	"strings"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/agency"
+	"github.com/superbkibbles/realestate_property-api/domain/amenity"
+	"github.com/superbkibbles/realestate_property-api/domain/currency"
+)
 
 const (
 	STATUS_ACTIVE   = "active"
@@ -23,6 +31,10 @@ type Property struct {
 	BuiltYear      int64  `json:"built_year"`
 	Price          int64  `json:"price"`
 	Currency       string `json:"currency"`
+	PreviousPrice  int64  `json:"previous_price"`
+	PriceChangedAt string `json:"price_changed_at,omitempty"`
+	PriceReduced   bool   `json:"price_reduced"`
+	BasePrice      int64  `json:"base_price"`
 	Rooms          int64  `json:"rooms"`
 	Bathrooms      int64  `json:"bathrooms"`
 	Bedrooms       int64  `json:"bedrooms"`
@@ -32,9 +44,15 @@ type Property struct {
 	Kitchen        int64  `json:"kitchen"`
 	PropertyKind   string `json:"property_kind"`
 
+	DisplayPrice *currency.Money `json:"display_price,omitempty"`
+	Agency       *agency.Summary `json:"agency,omitempty"`
+
 	Category string `json:"category"`
 
-	Promoted bool `json:"promoted"`
+	// Promoted and PromotionID are set from the running promotions when
+	// listings are returned.
+	Promoted    bool   `json:"promoted"`
+	PromotionID string `json:"promotion_id,omitempty"`
 
 	Space        float64 `json:"space"`
 	BuildingSize float64 `json:"building_size"`
@@ -45,26 +63,42 @@ type Property struct {
 	City        string      `json:"city"`
 	GPS         coordinates `json:"gps"`
 	NearSchools []school    `json:"near_schools"`
+	Amenities   []string    `json:"amenities"`
 
 	Visuals     []Visual `json:"visuals"`
 	Videos      []Video  `json:"videos"`
 	PropertyPic string   `json:"property_pic"`
 
-	ForRent      bool   `json:"for_rent"`
+	Attachments []Attachment `json:"attachments"`
+
+	OpenHouses []OpenHouse `json:"open_houses"`
+
+	ForRent     bool         `json:"for_rent"`
+	RentalTerms *RentalTerms `json:"rental_terms,omitempty"`
+
 	PropertyNo   string `json:"property_no"`
 	Viewers      int64  `json:"Viewers"`
 	Status       string `json:"status"`
+	MergedInto   string `json:"merged_into,omitempty"`
 	DateCreated  string `json:"date_created"`
 	IsSold       bool   `json:"is_sold"`
 	IsNew        bool   `json:"is_new"`
 	IsCommercial bool   `json:"is_commercial"`
 	SoldDate     string `json:"sold_date"`
+
+	ExpiresAt      string `json:"expires_at,omitempty"`
+	ExpiryWarnedAt string `json:"expiry_warned_at,omitempty"`
+	ExpiredAt      string `json:"expired_at,omitempty"`
+
+	PublishAt   string `json:"publish_at,omitempty"`
+	UnpublishAt string `json:"unpublish_at,omitempty"`
 }
 
 type Visual struct {
 	Url      string `json:"url"`
 	FileType string `json:"file_type"`
 	PublicID string `json:"public_id"`
+	Hash     string `json:"hash,omitempty"`
 }
 
 type Video struct {
@@ -82,11 +116,27 @@ type coordinates struct {
 	Long string `json:"long"`
 }
 
+// Coordinates parses the stored GPS strings. Missing or zero coordinates are
+// reported as not set.
+func (c coordinates) Coordinates() (float64, float64, bool) {
+	lat, latErr := strconv.ParseFloat(strings.TrimSpace(c.Lat), 64)
+	lon, lonErr := strconv.ParseFloat(strings.TrimSpace(c.Long), 64)
+	if latErr != nil || lonErr != nil || (lat == 0 && lon == 0) {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
+
 type Properties []Property
 
 func (p *Property) Validate() rest_errors.RestErr {
 	if p.Category != "apartment" && p.Category != "house" && p.Category != "villa" && p.Category != "land" && p.Category != "farm" {
 		return rest_errors.NewBadRequestErr("invalid JSON BODY category")
 	}
-	return nil
+	p.Amenities = amenity.Normalize(p.Amenities)
+	p.Currency = currency.Normalize(p.Currency)
+	if err := currency.Validate(p.Currency); err != nil {
+		return err
+	}
+	return p.ValidateRentalTerms()
 }
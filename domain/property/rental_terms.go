@@ -0,0 +1,90 @@
+package property
+
+import (
+	"math"
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+)
+
+const (
+	RENT_PERIOD_DAILY   = "daily"
+	RENT_PERIOD_WEEKLY  = "weekly"
+	RENT_PERIOD_MONTHLY = "monthly"
+	RENT_PERIOD_YEARLY  = "yearly"
+
+	availableFromLayout = "2006-01-02"
+)
+
+// How many of each period make up a month, used to compare rents.
+var periodsPerMonth = map[string]float64{
+	RENT_PERIOD_DAILY:   365.0 / 12,
+	RENT_PERIOD_WEEKLY:  52.0 / 12,
+	RENT_PERIOD_MONTHLY: 1,
+	RENT_PERIOD_YEARLY:  1.0 / 12,
+}
+
+// RentalTerms describe a for_rent listing. Price is the rent per Period;
+// MonthlyRent and MonthlyBaseRent are derived from it on every write.
+type RentalTerms struct {
+	Period         string `json:"period"`
+	Deposit        int64  `json:"deposit"`
+	MinLeaseMonths int64  `json:"min_lease_months"`
+	Furnished      bool   `json:"furnished"`
+	AvailableFrom  string `json:"available_from,omitempty"`
+
+	MonthlyRent     int64 `json:"monthly_rent"`
+	MonthlyBaseRent int64 `json:"monthly_base_rent"`
+}
+
+func (r *RentalTerms) Validate() rest_errors.RestErr {
+	if _, ok := periodsPerMonth[r.Period]; !ok {
+		return rest_errors.NewBadRequestErr("invalid rental period, must be daily, weekly, monthly or yearly")
+	}
+	if r.Deposit < 0 {
+		return rest_errors.NewBadRequestErr("invalid deposit")
+	}
+	if r.MinLeaseMonths < 0 {
+		return rest_errors.NewBadRequestErr("invalid minimum lease")
+	}
+	if r.AvailableFrom != "" {
+		if _, err := time.Parse(availableFromLayout, r.AvailableFrom); err != nil {
+			return rest_errors.NewBadRequestErr("invalid available_from, expected YYYY-MM-DD")
+		}
+	}
+	return nil
+}
+
+// PeriodsPerMonth returns how many of each period make up a month, for
+// rents normalized outside of Go.
+func PeriodsPerMonth() map[string]float64 {
+	periods := make(map[string]float64, len(periodsPerMonth))
+	for period, count := range periodsPerMonth {
+		periods[period] = count
+	}
+	return periods
+}
+
+func (r *RentalTerms) monthly(amount int64) int64 {
+	return int64(math.Round(float64(amount) * periodsPerMonth[r.Period]))
+}
+
+func (p *Property) ValidateRentalTerms() rest_errors.RestErr {
+	if !p.ForRent {
+		return nil
+	}
+	if p.RentalTerms == nil {
+		return rest_errors.NewBadRequestErr("rental_terms are required for rentals")
+	}
+	return p.RentalTerms.Validate()
+}
+
+// NormalizeRent fills in the monthly rents from Price and BasePrice, so
+// rentals quoted per day, week or year can be compared.
+func (p *Property) NormalizeRent() {
+	if !p.ForRent || p.RentalTerms == nil {
+		return
+	}
+	p.RentalTerms.MonthlyRent = p.RentalTerms.monthly(p.Price)
+	p.RentalTerms.MonthlyBaseRent = p.RentalTerms.monthly(p.BasePrice)
+}
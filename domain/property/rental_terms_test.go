@@ -0,0 +1,78 @@
+package property
+
+import "testing"
+
+func TestRentalTermsValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		terms RentalTerms
+		valid bool
+	}{
+		{name: "monthly", terms: RentalTerms{Period: RENT_PERIOD_MONTHLY}, valid: true},
+		{name: "full", terms: RentalTerms{Period: RENT_PERIOD_YEARLY, Deposit: 1000, MinLeaseMonths: 12, AvailableFrom: "2024-02-29"}, valid: true},
+		{name: "missing period", terms: RentalTerms{}},
+		{name: "unknown period", terms: RentalTerms{Period: "hourly"}},
+		{name: "negative deposit", terms: RentalTerms{Period: RENT_PERIOD_DAILY, Deposit: -1}},
+		{name: "negative lease", terms: RentalTerms{Period: RENT_PERIOD_WEEKLY, MinLeaseMonths: -1}},
+		{name: "invalid date", terms: RentalTerms{Period: RENT_PERIOD_MONTHLY, AvailableFrom: "2023-02-29"}},
+		{name: "date with time", terms: RentalTerms{Period: RENT_PERIOD_MONTHLY, AvailableFrom: "2024-01-01T00:00:00Z"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.terms.Validate(); tt.valid != (err == nil) {
+				t.Errorf("expected valid %v, got %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestNormalizeRent(t *testing.T) {
+	tests := []struct {
+		name        string
+		period      string
+		price       int64
+		basePrice   int64
+		monthly     int64
+		monthlyBase int64
+	}{
+		{name: "monthly", period: RENT_PERIOD_MONTHLY, price: 1310000, basePrice: 1000, monthly: 1310000, monthlyBase: 1000},
+		{name: "daily", period: RENT_PERIOD_DAILY, price: 100, basePrice: 10, monthly: 3042, monthlyBase: 304},
+		{name: "weekly", period: RENT_PERIOD_WEEKLY, price: 300, basePrice: 30, monthly: 1300, monthlyBase: 130},
+		{name: "yearly", period: RENT_PERIOD_YEARLY, price: 12000, basePrice: 1000, monthly: 1000, monthlyBase: 83},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Property{ForRent: true, Price: tt.price, BasePrice: tt.basePrice, RentalTerms: &RentalTerms{Period: tt.period}}
+			p.NormalizeRent()
+			if p.RentalTerms.MonthlyRent != tt.monthly || p.RentalTerms.MonthlyBaseRent != tt.monthlyBase {
+				t.Errorf("expected %d and %d, got %d and %d", tt.monthly, tt.monthlyBase, p.RentalTerms.MonthlyRent, p.RentalTerms.MonthlyBaseRent)
+			}
+		})
+	}
+
+	sale := Property{Price: 100, RentalTerms: &RentalTerms{Period: RENT_PERIOD_DAILY}}
+	sale.NormalizeRent()
+	if sale.RentalTerms.MonthlyRent != 0 {
+		t.Errorf("expected listings for sale left alone, got %d", sale.RentalTerms.MonthlyRent)
+	}
+}
+
+func TestValidateRentalTerms(t *testing.T) {
+	tests := []struct {
+		name  string
+		p     Property
+		valid bool
+	}{
+		{name: "for sale", p: Property{}, valid: true},
+		{name: "rental", p: Property{ForRent: true, RentalTerms: &RentalTerms{Period: RENT_PERIOD_MONTHLY}}, valid: true},
+		{name: "rental without terms", p: Property{ForRent: true}},
+		{name: "rental with invalid terms", p: Property{ForRent: true, RentalTerms: &RentalTerms{Period: "hourly"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.p.ValidateRentalTerms(); tt.valid != (err == nil) {
+				t.Errorf("expected valid %v, got %v", tt.valid, err)
+			}
+		})
+	}
+}
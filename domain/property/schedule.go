@@ -0,0 +1,151 @@
+package property
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+)
+
+const (
+	CHANGE_PENDING   = "pending"
+	CHANGE_APPLYING  = "applying"
+	CHANGE_APPLIED   = "applied"
+	CHANGE_FAILED    = "failed"
+	CHANGE_CANCELLED = "cancelled"
+
+	MaxScheduledChanges = 20
+)
+
+// ScheduleRequest sets when a listing goes live and when it comes down.
+// Empty times clear the schedule.
+type ScheduleRequest struct {
+	PublishAt   string `json:"publish_at"`
+	UnpublishAt string `json:"unpublish_at"`
+}
+
+// ScheduledChange is an update held back until ApplyAt, e.g. a price change
+// embargoed until launch day.
+type ScheduledChange struct {
+	ID          string                  `json:"id"`
+	PropertyID  string                  `json:"property_id"`
+	AgencyID    string                  `json:"agency_id"`
+	ApplyAt     string                  `json:"apply_at"`
+	Fields      []UpdatePropertyRequest `json:"fields"`
+	Status      string                  `json:"status"`
+	Error       string                  `json:"error,omitempty"`
+	CreatedBy   string                  `json:"created_by,omitempty"`
+	DateCreated string                  `json:"date_created"`
+	AppliedAt   string                  `json:"applied_at,omitempty"`
+	// LockedUntil is when an instance applying the change is given up on,
+	// so the change is applied again if it crashed halfway.
+	LockedUntil string `json:"locked_until,omitempty"`
+}
+
+type ScheduledChanges []ScheduledChange
+
+type ScheduledChangeRequest struct {
+	ApplyAt string                  `json:"apply_at"`
+	Fields  []UpdatePropertyRequest `json:"fields"`
+}
+
+// ScheduleReport sums up one run of the publishing scheduler.
+type ScheduleReport struct {
+	Published   int `json:"published"`
+	Unpublished int `json:"unpublished"`
+	Applied     int `json:"applied"`
+	Failed      int `json:"failed"`
+}
+
+// Validate normalizes both times to UTC. A listing can only come down after
+// it went live.
+func (r *ScheduleRequest) Validate(now time.Time) rest_errors.RestErr {
+	publishAt, err := futureTime("publish_at", r.PublishAt, now)
+	if err != nil {
+		return err
+	}
+	unpublishAt, err := futureTime("unpublish_at", r.UnpublishAt, now)
+	if err != nil {
+		return err
+	}
+	if !publishAt.IsZero() && !unpublishAt.IsZero() && !unpublishAt.After(publishAt) {
+		return rest_errors.NewBadRequestErr("unpublish_at must be after publish_at")
+	}
+	r.PublishAt = formatTime(publishAt)
+	r.UnpublishAt = formatTime(unpublishAt)
+	return nil
+}
+
+func (r *ScheduledChangeRequest) Validate(now time.Time) rest_errors.RestErr {
+	applyAt, err := futureTime("apply_at", r.ApplyAt, now)
+	if err != nil {
+		return err
+	}
+	if applyAt.IsZero() {
+		return rest_errors.NewBadRequestErr("apply_at is required")
+	}
+	if len(r.Fields) == 0 {
+		return rest_errors.NewBadRequestErr("fields are required")
+	}
+	if err := (EsUpdate{Fields: r.Fields}).Validate(); err != nil {
+		return err
+	}
+	r.ApplyAt = formatTime(applyAt)
+	return nil
+}
+
+// ValidateSchedule checks the publishing times a listing was created with.
+func (p *Property) ValidateSchedule(now time.Time) rest_errors.RestErr {
+	request := ScheduleRequest{PublishAt: p.PublishAt, UnpublishAt: p.UnpublishAt}
+	if err := request.Validate(now); err != nil {
+		return err
+	}
+	p.PublishAt = request.PublishAt
+	p.UnpublishAt = request.UnpublishAt
+	return nil
+}
+
+// Unpublished reports whether the listing is still waiting to go live. Such
+// listings are hidden from everyone but their agency.
+func (p Property) Unpublished() bool {
+	return p.PublishAt != ""
+}
+
+// VisibleTo reports whether the caller of agencyID may see the listing.
+func (p Property) VisibleTo(agencyID string) bool {
+	return !p.Unpublished() || (agencyID != "" && agencyID == p.AgencyID)
+}
+
+// VisibleTo leaves out listings waiting to go live, but those of agencyID.
+func (properties Properties) VisibleTo(agencyID string) Properties {
+	visible := make(Properties, 0, len(properties))
+	for _, p := range properties {
+		if p.VisibleTo(agencyID) {
+			visible = append(visible, p)
+		}
+	}
+	return visible
+}
+
+func futureTime(field string, value string, now time.Time) (time.Time, rest_errors.RestErr) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, rest_errors.NewBadRequestErr(fmt.Sprintf("%s must be an RFC 3339 time, e.g. 2026-05-01T09:00:00+03:00", field))
+	}
+	if !t.After(now) {
+		return time.Time{}, rest_errors.NewBadRequestErr(fmt.Sprintf("%s must be in the future", field))
+	}
+	return t.UTC(), nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
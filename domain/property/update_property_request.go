@@ -1,6 +1,13 @@
 package property
 
-import "github.com/superbkibbles/bookstore_utils-go/rest_errors"
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/amenity"
+	"github.com/superbkibbles/realestate_property-api/domain/currency"
+)
 
 type EsUpdate struct {
 	Fields []UpdatePropertyRequest `json:"fields"`
@@ -12,16 +19,86 @@ type UpdatePropertyRequest struct {
 }
 
 func (u EsUpdate) Validate() rest_errors.RestErr {
-	for _, field := range u.Fields {
+	for i, field := range u.Fields {
 		switch field.Field {
-		case "category":	
+		case "category":
 			switch field.Value {
 			case "apartment", "house", "villa", "land", "farm":
-				return nil
 			default:
 				return rest_errors.NewBadRequestErr("invalid JSON BODY category")
 			}
+		case "currency":
+			code, ok := field.Value.(string)
+			if !ok {
+				return rest_errors.NewBadRequestErr("invalid currency")
+			}
+			if err := currency.Validate(code); err != nil {
+				return err
+			}
+			u.Fields[i].Value = currency.Normalize(code)
+		case "amenities":
+			bytes, _ := json.Marshal(field.Value)
+			var amenities []string
+			if err := json.Unmarshal(bytes, &amenities); err != nil {
+				return rest_errors.NewBadRequestErr("invalid amenities")
+			}
+			u.Fields[i].Value = amenity.Normalize(amenities)
+		case "rental_terms":
+			if field.Value == nil {
+				continue
+			}
+			bytes, _ := json.Marshal(field.Value)
+			var terms RentalTerms
+			if err := json.Unmarshal(bytes, &terms); err != nil {
+				return rest_errors.NewBadRequestErr("invalid rental_terms")
+			}
+			if err := terms.Validate(); err != nil {
+				return err
+			}
+		case "base_price", "previous_price", "price_changed_at", "price_reduced", "display_price", "agency", "complex_name", "attachments", "open_houses", "merged_into", "expires_at", "expiry_warned_at", "expired_at", "publish_at", "unpublish_at", "promoted", "promotion_id",
+			// Counters kept by the view, favorite and promotion services.
+			"Viewers", "views", "favorites", "impressions", "clicks":
+			return rest_errors.NewBadRequestErr(fmt.Sprintf("%s can not be updated directly", field.Field))
 		}
 	}
 	return nil
 }
+
+func (u EsUpdate) Has(fields ...string) bool {
+	for _, field := range u.Fields {
+		for _, name := range fields {
+			if field.Field == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (u EsUpdate) FieldNames() []string {
+	names := make([]string, 0, len(u.Fields))
+	for _, field := range u.Fields {
+		names = append(names, field.Field)
+	}
+	return names
+}
+
+// ApplyTo sets the updated fields on p. Objects such as rental_terms are
+// replaced as a whole.
+func (u EsUpdate) ApplyTo(p *Property) rest_errors.RestErr {
+	bytes, _ := json.Marshal(p)
+	var doc map[string]interface{}
+	json.Unmarshal(bytes, &doc)
+	for _, field := range u.Fields {
+		doc[field.Field] = field.Value
+	}
+
+	bytes, _ = json.Marshal(doc)
+	var updated Property
+	if err := json.Unmarshal(bytes, &updated); err != nil {
+		return rest_errors.NewBadRequestErr("invalid field value")
+	}
+	updated.ID = p.ID
+	*p = updated
+	return nil
+}
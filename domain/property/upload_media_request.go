@@ -1,3 +1,192 @@
 package property
 
-type UploadMediaRequest struct{}
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/utils/media_utils"
+)
+
+const (
+	MEDIA_KIND_IMAGE = "image"
+	MEDIA_KIND_VIDEO = "video"
+
+	maxImageSize   = 10 << 20
+	maxVideoSize   = 200 << 20
+	maxImagesCount = 20
+	maxVideosCount = 3
+)
+
+var allowedMimeTypes = map[string]string{
+	media_utils.MIME_JPEG:      MEDIA_KIND_IMAGE,
+	media_utils.MIME_PNG:       MEDIA_KIND_IMAGE,
+	media_utils.MIME_WEBP:      MEDIA_KIND_IMAGE,
+	media_utils.MIME_MP4:       MEDIA_KIND_VIDEO,
+	media_utils.MIME_QUICKTIME: MEDIA_KIND_VIDEO,
+}
+
+type UploadMediaRequest struct {
+	Files      []*multipart.FileHeader
+	ImagesOnly bool
+
+	Media []MediaFile
+}
+
+// MediaFile is an uploaded file that passed validation. Images hold their
+// content with metadata already stripped; videos are re-opened from source.
+type MediaFile struct {
+	Name     string
+	MimeType string
+	Kind     string
+	Ext      string
+	Size     int64
+	// Hash is the perceptual hash of an image, empty when it can not be
+	// decoded.
+	Hash string
+
+	open    func() (io.ReadSeekCloser, error)
+	content []byte
+}
+
+type FileError struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+func (m MediaFile) Open() (io.ReadCloser, error) {
+	if m.content != nil {
+		return ioutil.NopCloser(bytes.NewReader(m.content)), nil
+	}
+	return m.open()
+}
+
+func (r *UploadMediaRequest) Validate() rest_errors.RestErr {
+	if len(r.Files) == 0 {
+		return rest_errors.NewBadRequestErr("no files were uploaded")
+	}
+
+	var causes []interface{}
+	images, videos := 0, 0
+	r.Media = nil
+	for _, header := range r.Files {
+		media, err := inspectFile(header, maxVideoSize)
+		if err != nil {
+			causes = append(causes, FileError{File: header.Filename, Error: err.Error()})
+			continue
+		}
+		if r.ImagesOnly && media.Kind != MEDIA_KIND_IMAGE {
+			causes = append(causes, FileError{File: header.Filename, Error: "only images are allowed"})
+			continue
+		}
+		if media.Kind == MEDIA_KIND_VIDEO {
+			videos++
+		} else {
+			images++
+		}
+		r.Media = append(r.Media, *media)
+	}
+
+	if images > maxImagesCount {
+		causes = append(causes, FileError{Error: fmt.Sprintf("at most %d images can be uploaded at once", maxImagesCount)})
+	}
+	if videos > maxVideosCount {
+		causes = append(causes, FileError{Error: fmt.Sprintf("at most %d videos can be uploaded at once", maxVideosCount)})
+	}
+	if len(causes) > 0 {
+		return rest_errors.NewRestError("invalid media files", http.StatusBadRequest, "bad_request", causes)
+	}
+	return nil
+}
+
+func inspectFile(header *multipart.FileHeader, videoLimit int64) (*MediaFile, error) {
+	open := func() (io.ReadSeekCloser, error) {
+		return header.Open()
+	}
+	return InspectMedia(header.Filename, header.Size, videoLimit, open)
+}
+
+// InspectMedia sniffs and validates a file that is read through open. Videos
+// may be at most videoLimit bytes, images are always capped at maxImageSize.
+func InspectMedia(name string, size int64, videoLimit int64, open func() (io.ReadSeekCloser, error)) (*MediaFile, error) {
+	f, err := open()
+	if err != nil {
+		return nil, fmt.Errorf("could not read file")
+	}
+	defer f.Close()
+
+	head := make([]byte, media_utils.SniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("could not read file")
+	}
+	mimeType := media_utils.DetectContentType(head[:n])
+	kind, ok := allowedMimeTypes[mimeType]
+	if !ok {
+		return nil, fmt.Errorf("file type %s is not allowed", mimeType)
+	}
+
+	media := MediaFile{
+		Name:     name,
+		MimeType: mimeType,
+		Kind:     kind,
+		Ext:      media_utils.ExtensionFor(mimeType),
+		Size:     size,
+		open:     open,
+	}
+	if kind == MEDIA_KIND_VIDEO {
+		if size > videoLimit {
+			return nil, fmt.Errorf("videos must be smaller than %d MB", videoLimit>>20)
+		}
+		return &media, nil
+	}
+
+	if size > maxImageSize {
+		return nil, fmt.Errorf("images must be smaller than %d MB", maxImageSize>>20)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("could not read file")
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(f, maxImageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("could not read file")
+	}
+	if len(data) > maxImageSize {
+		return nil, fmt.Errorf("images must be smaller than %d MB", maxImageSize>>20)
+	}
+	stripped, err := media_utils.StripMetadata(mimeType, data)
+	if err != nil {
+		return nil, err
+	}
+	media.content = stripped
+	media.Size = int64(len(stripped))
+	media.Hash, _ = media_utils.PerceptualHash(stripped)
+	return &media, nil
+}
+
+// UploadResult reports the outcome of uploading a single file.
+type UploadResult struct {
+	File     string `json:"file"`
+	Kind     string `json:"kind,omitempty"`
+	FileType string `json:"file_type,omitempty"`
+	Url      string `json:"url,omitempty"`
+	PublicID string `json:"public_id,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Hash     string `json:"-"`
+}
+
+type UploadResults []UploadResult
+
+func (r UploadResults) Failed() int {
+	failed := 0
+	for _, result := range r {
+		if result.Error != "" {
+			failed++
+		}
+	}
+	return failed
+}
@@ -19,6 +19,49 @@ func (q *EsQuery) Build() elastic.Query {
 		query.Filter(elastic.NewRangeQuery(fRange.Field).From(fRange.From).To(fRange.To))
 	}
 
+	for _, allOf := range q.AllOf {
+		for _, value := range allOf.Values {
+			query.Filter(elastic.NewTermQuery(exactField(allOf.Field, value), value))
+		}
+	}
+
+	for _, anyOf := range q.AnyOf {
+		if len(anyOf.Values) > 0 {
+			query.Filter(elastic.NewTermsQuery(exactField(anyOf.Field, anyOf.Values[0]), anyOf.Values...))
+		}
+	}
+
+	for _, filter := range q.Filters {
+		query.Filter(elastic.NewTermQuery(exactField(filter.Field, filter.Value), filter.Value))
+	}
+
+	// Listings waiting for their publish_at are only found by their agency.
+	unpublished := elastic.NewBoolQuery().Filter(elastic.NewExistsQuery("publish_at"))
+	if q.Owner != "" {
+		unpublished.MustNot(elastic.NewTermQuery("agency_id.keyword", q.Owner))
+	}
+	query.MustNot(unpublished)
+
 	query.Must(equalsQuery...)
-	return query
+	if len(q.Boosts) == 0 {
+		return query
+	}
+
+	// Boosted listings add their weight to the score, others add 1.
+	scored := elastic.NewFunctionScoreQuery().Query(query).ScoreMode("max").BoostMode("sum")
+	for _, boost := range q.Boosts {
+		if len(boost.IDs) > 0 {
+			scored.Add(elastic.NewIdsQuery().Ids(boost.IDs...), elastic.NewWeightFactorFunction(boost.Weight))
+		}
+	}
+	return scored
+}
+
+// exactField matches strings on their keyword sub-field instead of the
+// analyzed text.
+func exactField(field string, value interface{}) string {
+	if _, ok := value.(string); ok {
+		return field + ".keyword"
+	}
+	return field
 }
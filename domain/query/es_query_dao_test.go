@@ -0,0 +1,82 @@
+package query
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func source(t *testing.T, q EsQuery) map[string]interface{} {
+	src, err := q.Build().Source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bytes, _ := json.Marshal(src)
+	var doc map[string]interface{}
+	json.Unmarshal(bytes, &doc)
+	return doc
+}
+
+func TestBuildUnpublished(t *testing.T) {
+	tests := []struct {
+		name     string
+		owner    string
+		expected string
+	}{
+		{
+			name:     "anonymous",
+			expected: `{"bool":{"filter":{"exists":{"field":"publish_at"}}}}`,
+		},
+		{
+			name:     "agency sees its own",
+			owner:    "agency-1",
+			expected: `{"bool":{"filter":{"exists":{"field":"publish_at"}},"must_not":{"term":{"agency_id.keyword":"agency-1"}}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := source(t, EsQuery{Owner: tt.owner})
+			mustNot, _ := json.Marshal(doc["bool"].(map[string]interface{})["must_not"])
+			if string(mustNot) != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, mustNot)
+			}
+		})
+	}
+}
+
+func TestBuildBoosts(t *testing.T) {
+	tests := []struct {
+		name     string
+		boosts   []Boost
+		expected string
+	}{
+		{name: "no promotions"},
+		{name: "empty tier", boosts: []Boost{{Weight: 3}}, expected: `null`},
+		{
+			name:     "tiers",
+			boosts:   []Boost{{IDs: []string{"a", "b"}, Weight: 3}, {IDs: []string{"c"}, Weight: 2}},
+			expected: `[{"filter":{"ids":{"values":["a","b"]}},"weight":3},{"filter":{"ids":{"values":["c"]}},"weight":2}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := source(t, EsQuery{Boosts: tt.boosts, Filters: []FieldValue{{Field: "status", Value: "active"}}})
+			scored, ok := doc["function_score"].(map[string]interface{})
+			if tt.expected == "" {
+				if ok || doc["bool"] == nil {
+					t.Fatalf("expected a plain bool query, got %v", doc)
+				}
+				return
+			}
+			if !ok || scored["score_mode"] != "max" || scored["boost_mode"] != "sum" {
+				t.Fatalf("expected a function score query summing the highest boost, got %v", doc)
+			}
+			if scored["query"].(map[string]interface{})["bool"] == nil {
+				t.Errorf("expected the filters kept inside the function score query, got %v", scored["query"])
+			}
+			functions, _ := json.Marshal(scored["functions"])
+			if string(functions) != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, functions)
+			}
+		})
+	}
+}
@@ -1,9 +1,27 @@
 package query
 
+import "strings"
+
 type EsQuery struct {
 	Equals []FieldValue  `json:"equals"`
 	Gt     []GtValue     `json:"gt"`
 	Range  []RangeStruct `json:"range"`
+	AllOf  []FieldValues `json:"all_of"`
+	AnyOf  []FieldValues `json:"any_of"`
+
+	// Filters are exact matches added by the services, e.g. to scope a
+	// search to one agency. They are never read from requests.
+	Filters []FieldValue `json:"-"`
+	// Boosts raise the score of listings by ID, e.g. promoted ones.
+	Boosts []Boost `json:"-"`
+	// Owner is the agency of the caller. Listings waiting to go live are
+	// only found by their own agency.
+	Owner string `json:"-"`
+}
+
+type Boost struct {
+	IDs    []string
+	Weight float64
 }
 
 type FieldValue struct {
@@ -11,13 +29,38 @@ type FieldValue struct {
 	Value interface{} `json:"value"`
 }
 
+// FieldValues matches list fields such as amenities: with all_of a property
+// must have every value, with any_of at least one.
+type FieldValues struct {
+	Field  string        `json:"field"`
+	Values []interface{} `json:"values"`
+}
+
 type GtValue struct {
 	Field string      `json:"field"`
 	Value interface{} `json:"value"`
 }
 
+// RangeStruct bounds are inclusive and optional. Date fields also accept
+// Elasticsearch date math such as "now-7d".
 type RangeStruct struct {
-	Field string `json:"field"`
-	From  int64  `json:"from"`
-	To    int64  `json:"to"`
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// NormalizeAmenities matches amenity filters the way amenities are stored.
+func (q *EsQuery) NormalizeAmenities() {
+	for _, filters := range [][]FieldValues{q.AllOf, q.AnyOf} {
+		for i := range filters {
+			if filters[i].Field != "amenities" {
+				continue
+			}
+			for j, value := range filters[i].Values {
+				if id, ok := value.(string); ok {
+					filters[i].Values[j] = strings.TrimSpace(strings.ToLower(id))
+				}
+			}
+		}
+	}
 }
@@ -0,0 +1,241 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+var dateMath = regexp.MustCompile(`^now(?:([+-])(\d+)([yMwdhm]))?$`)
+
+// Matches evaluates the query against one document in process, so a single
+// listing can be checked against many saved searches without a search per
+// search. It follows Build closely: equals matches when any word of the value
+// is a word of the field, like a match query on analyzed text, and ranges
+// compare numbers or dates. Date math is limited to now, optionally plus or
+// minus a number of y, M, w, d, h or m.
+func (q *EsQuery) Matches(doc map[string]interface{}) bool {
+	for _, eq := range q.Equals {
+		if !anyValue(lookup(doc, eq.Field), func(v interface{}) bool { return matchesText(v, eq.Value) }) {
+			return false
+		}
+	}
+	for _, gtFilter := range q.Gt {
+		if !anyValue(lookup(doc, gtFilter.Field), func(v interface{}) bool {
+			c, ok := compare(v, gtFilter.Value)
+			return ok && c > 0
+		}) {
+			return false
+		}
+	}
+	for _, fRange := range q.Range {
+		if !anyValue(lookup(doc, fRange.Field), func(v interface{}) bool { return inRange(v, fRange.From, fRange.To) }) {
+			return false
+		}
+	}
+	for _, allOf := range q.AllOf {
+		values := lookup(doc, allOf.Field)
+		for _, value := range allOf.Values {
+			if !anyValue(values, func(v interface{}) bool { return equal(v, value) }) {
+				return false
+			}
+		}
+	}
+	for _, anyOf := range q.AnyOf {
+		if len(anyOf.Values) == 0 {
+			continue
+		}
+		found := false
+		for _, value := range anyOf.Values {
+			if anyValue(lookup(doc, anyOf.Field), func(v interface{}) bool { return equal(v, value) }) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, filter := range q.Filters {
+		if !anyValue(lookup(doc, filter.Field), func(v interface{}) bool { return equal(v, filter.Value) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// lookup returns every value at a dotted path, flattening arrays on the way.
+func lookup(doc interface{}, field string) []interface{} {
+	values := []interface{}{doc}
+	for _, key := range strings.Split(strings.TrimSuffix(field, ".keyword"), ".") {
+		var next []interface{}
+		for _, value := range flatten(values) {
+			if object, ok := value.(map[string]interface{}); ok {
+				if child, found := object[key]; found && child != nil {
+					next = append(next, child)
+				}
+			}
+		}
+		values = next
+	}
+	return flatten(values)
+}
+
+func flatten(values []interface{}) []interface{} {
+	var flat []interface{}
+	for _, value := range values {
+		if list, ok := value.([]interface{}); ok {
+			flat = append(flat, flatten(list)...)
+			continue
+		}
+		flat = append(flat, value)
+	}
+	return flat
+}
+
+func anyValue(values []interface{}, match func(interface{}) bool) bool {
+	for _, value := range values {
+		if match(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesText(field interface{}, value interface{}) bool {
+	text, ok := value.(string)
+	if !ok {
+		return equal(field, value)
+	}
+	words := map[string]bool{}
+	for _, word := range tokenize(fmt.Sprint(field)) {
+		words[word] = true
+	}
+	for _, word := range tokenize(text) {
+		if words[word] {
+			return true
+		}
+	}
+	return false
+}
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func equal(field interface{}, value interface{}) bool {
+	if a, ok := toFloat(field); ok {
+		if b, ok := toFloat(value); ok {
+			return a == b
+		}
+	}
+	return fmt.Sprint(field) == fmt.Sprint(value)
+}
+
+func inRange(field interface{}, from interface{}, to interface{}) bool {
+	if from != nil {
+		if c, ok := compare(field, from); !ok || c < 0 {
+			return false
+		}
+	}
+	if to != nil {
+		if c, ok := compare(field, to); !ok || c > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// compare orders a stored value against a bound as numbers, dates or, as a
+// last resort, strings.
+func compare(field interface{}, bound interface{}) (int, bool) {
+	if a, ok := toFloat(field); ok {
+		if b, ok := toFloat(bound); ok {
+			return compareFloats(a, b), true
+		}
+		return 0, false
+	}
+	text, ok := field.(string)
+	if !ok {
+		return 0, false
+	}
+	if a, ok := toTime(text); ok {
+		if b, ok := toTime(fmt.Sprint(bound)); ok {
+			return compareFloats(float64(a.Unix()), float64(b.Unix())), true
+		}
+	}
+	return strings.Compare(text, fmt.Sprint(bound)), true
+}
+
+func compareFloats(a float64, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toTime(value string) (time.Time, bool) {
+	if m := dateMath.FindStringSubmatch(value); m != nil {
+		now := date_utils.GetNow()
+		if m[1] == "" {
+			return now, true
+		}
+		n, _ := strconv.Atoi(m[2])
+		if m[1] == "-" {
+			n = -n
+		}
+		switch m[3] {
+		case "y":
+			return now.AddDate(n, 0, 0), true
+		case "M":
+			return now.AddDate(0, n, 0), true
+		case "w":
+			return now.AddDate(0, 0, 7*n), true
+		case "d":
+			return now.AddDate(0, 0, n), true
+		case "h":
+			return now.Add(time.Duration(n) * time.Hour), true
+		}
+		return now.Add(time.Duration(n) * time.Minute), true
+	}
+	if t, err := date_utils.ParseDBFormat(value); err == nil {
+		return t, true
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
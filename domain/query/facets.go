@@ -0,0 +1,11 @@
+package query
+
+// Facet is the number of matching properties for one value of a field.
+type Facet struct {
+	Value string `json:"value"`
+	Label string `json:"label,omitempty"`
+	Count int64  `json:"count"`
+}
+
+// Facets are keyed by field, e.g. "amenities".
+type Facets map[string][]Facet
@@ -0,0 +1,105 @@
+package search
+
+import (
+	"strings"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/currency"
+	"github.com/superbkibbles/realestate_property-api/domain/notification"
+	"github.com/superbkibbles/realestate_property-api/domain/query"
+)
+
+const (
+	FREQUENCY_INSTANT = "instant"
+	FREQUENCY_DIGEST  = "digest"
+
+	ALERT_PENDING = "pending"
+	ALERT_SENT    = "sent"
+
+	MaxSearchesPerUser = 50
+	maxNameLength      = 100
+)
+
+// SavedSearch is a search a user wants to be alerted about. Alerts go out as
+// soon as a listing matches, or are collected into a digest.
+type SavedSearch struct {
+	ID        string        `json:"id"`
+	UserID    string        `json:"user_id"`
+	Name      string        `json:"name"`
+	Query     query.EsQuery `json:"query"`
+	Sort      string        `json:"sort,omitempty"`
+	Asc       bool          `json:"asc"`
+	Currency  string        `json:"currency,omitempty"`
+	Local     string        `json:"local,omitempty"`
+	Channel   string        `json:"channel"`
+	Target    string        `json:"target,omitempty"`
+	Frequency string        `json:"frequency"`
+
+	LastNotifiedAt string `json:"last_notified_at,omitempty"`
+	DateCreated    string `json:"date_created"`
+}
+
+type SavedSearches []SavedSearch
+
+// Alert is a listing that matched a saved search. Its ID is derived from
+// both, so a listing is only ever announced once per search.
+type Alert struct {
+	ID          string `json:"id"`
+	SearchID    string `json:"search_id"`
+	UserID      string `json:"user_id"`
+	PropertyID  string `json:"property_id"`
+	Title       string `json:"title"`
+	Price       int64  `json:"price"`
+	Currency    string `json:"currency"`
+	Status      string `json:"status"`
+	DateCreated string `json:"date_created"`
+	SentAt      string `json:"sent_at,omitempty"`
+}
+
+type Alerts []Alert
+
+// DigestReport summarises a run of the digest sender.
+type DigestReport struct {
+	StartedAt  string `json:"started_at"`
+	FinishedAt string `json:"finished_at"`
+	Searches   int    `json:"searches"`
+	Alerts     int    `json:"alerts"`
+	Failed     int    `json:"failed"`
+}
+
+func AlertID(searchID string, propertyID string) string {
+	return searchID + "_" + propertyID
+}
+
+func (s *SavedSearch) Validate() rest_errors.RestErr {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return rest_errors.NewBadRequestErr("name is required")
+	}
+	if len(s.Name) > maxNameLength {
+		return rest_errors.NewBadRequestErr("name is too long")
+	}
+	if s.Channel == "" {
+		s.Channel = notification.CHANNEL_LOG
+	}
+	s.Target = strings.TrimSpace(s.Target)
+	if err := notification.ValidateTarget(s.Channel, s.Target); err != nil {
+		return err
+	}
+	switch s.Frequency {
+	case "":
+		s.Frequency = FREQUENCY_INSTANT
+	case FREQUENCY_INSTANT, FREQUENCY_DIGEST:
+	default:
+		return rest_errors.NewBadRequestErr("frequency must be instant or digest")
+	}
+	if s.Currency != "" {
+		s.Currency = currency.Normalize(s.Currency)
+		if err := currency.Validate(s.Currency); err != nil {
+			return err
+		}
+	}
+	s.Query.Filters = nil
+	s.Query.NormalizeAmenities()
+	return nil
+}
@@ -0,0 +1,84 @@
+package upload
+
+import (
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+)
+
+const (
+	MODE_DIRECT    = "direct"
+	MODE_RESUMABLE = "resumable"
+
+	STATUS_PENDING   = "pending"
+	STATUS_UPLOADED  = "uploaded"
+	STATUS_COMPLETED = "completed"
+	STATUS_REJECTED  = "rejected"
+
+	MaxUploadSize = 2 << 30
+)
+
+// Ticket is a short-lived permission to upload one file for a property,
+// either directly to storage or in chunks through this service.
+type Ticket struct {
+	ID          string `json:"id"`
+	PropertyID  string `json:"property_id"`
+	PublicID    string `json:"public_id"`
+	Folder      string `json:"folder"`
+	Kind        string `json:"kind"`
+	Mode        string `json:"mode"`
+	FileName    string `json:"file_name"`
+	Length      int64  `json:"length"`
+	Offset      int64  `json:"offset"`
+	Url         string `json:"url"`
+	FileType    string `json:"file_type"`
+	Hash        string `json:"hash,omitempty"`
+	Status      string `json:"status"`
+	ExpiresAt   string `json:"expires_at"`
+	DateCreated string `json:"date_created"`
+
+	// Token authorizes the upload and its completion. Like the URLs it is
+	// only returned when the ticket is created.
+	Token     string        `json:"token,omitempty"`
+	UploadURL string        `json:"upload_url,omitempty"`
+	Direct    *DirectUpload `json:"direct,omitempty"`
+}
+
+// DirectUpload describes how a client sends the file straight to storage:
+// the fields are sent as multipart form values next to the file.
+type DirectUpload struct {
+	Url    string            `json:"url"`
+	Method string            `json:"method"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+type TicketRequest struct {
+	Mode     string `json:"mode"`
+	Kind     string `json:"kind"`
+	FileName string `json:"file_name"`
+	Length   int64  `json:"length"`
+}
+
+func (r TicketRequest) Validate() rest_errors.RestErr {
+	switch r.Kind {
+	case property.MEDIA_KIND_IMAGE, property.MEDIA_KIND_VIDEO:
+	default:
+		return rest_errors.NewBadRequestErr("invalid kind, must be image or video")
+	}
+	switch r.Mode {
+	case MODE_DIRECT:
+		// Direct uploads skip our metadata stripping, so they are video only.
+		if r.Kind != property.MEDIA_KIND_VIDEO {
+			return rest_errors.NewBadRequestErr("direct uploads are only allowed for videos")
+		}
+	case MODE_RESUMABLE:
+		if r.Length <= 0 {
+			return rest_errors.NewBadRequestErr("length is required for resumable uploads")
+		}
+	default:
+		return rest_errors.NewBadRequestErr("invalid mode, must be direct or resumable")
+	}
+	if r.Length < 0 || r.Length > MaxUploadSize {
+		return rest_errors.NewBadRequestErr("invalid length")
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package view
+
+import (
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+)
+
+const (
+	// DedupWindow is how long repeated views by the same visitor count once.
+	DedupWindow = 30 * time.Minute
+
+	dayLayout     = "2006-01-02"
+	defaultPeriod = 30
+	maxPeriod     = 366
+)
+
+type View struct {
+	PropertyID string    `json:"property_id"`
+	AgencyID   string    `json:"agency_id"`
+	VisitorID  string    `json:"visitor_id"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+type DailyViews struct {
+	Date  string `json:"date"`
+	Views int64  `json:"views"`
+}
+
+type Stats struct {
+	ID    string       `json:"id"`
+	From  string       `json:"from"`
+	To    string       `json:"to"`
+	Total int64        `json:"total"`
+	Days  []DailyViews `json:"days"`
+}
+
+// StatsRequest is a day range, both ends inclusive, in YYYY-MM-DD.
+type StatsRequest struct {
+	From string `form:"from"`
+	To   string `form:"to"`
+
+	FromTime time.Time `form:"-"`
+	ToTime   time.Time `form:"-"`
+}
+
+func (r *StatsRequest) Validate() rest_errors.RestErr {
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if r.To != "" {
+		parsed, err := time.Parse(dayLayout, r.To)
+		if err != nil {
+			return rest_errors.NewBadRequestErr("invalid to date, expected YYYY-MM-DD")
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, -(defaultPeriod - 1))
+	if r.From != "" {
+		parsed, err := time.Parse(dayLayout, r.From)
+		if err != nil {
+			return rest_errors.NewBadRequestErr("invalid from date, expected YYYY-MM-DD")
+		}
+		from = parsed
+	}
+	if from.After(to) {
+		return rest_errors.NewBadRequestErr("from must not be after to")
+	}
+	if to.Sub(from) > maxPeriod*24*time.Hour {
+		return rest_errors.NewBadRequestErr("date range is too long")
+	}
+
+	r.FromTime = from
+	r.ToTime = to
+	r.From = from.Format(dayLayout)
+	r.To = to.Format(dayLayout)
+	return nil
+}
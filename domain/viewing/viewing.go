@@ -0,0 +1,169 @@
+package viewing
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+)
+
+const (
+	SLOT_OPEN      = "open"
+	SLOT_BOOKED    = "booked"
+	SLOT_WITHDRAWN = "withdrawn"
+
+	BOOKING_CONFIRMED = "confirmed"
+	BOOKING_CANCELLED = "cancelled"
+
+	MaxSlotsPerRequest = 50
+	minSlotLength      = 10 * time.Minute
+	maxSlotLength      = 4 * time.Hour
+	maxSlotLead        = 180 * 24 * time.Hour
+	maxNoteLength      = 500
+)
+
+// Slot is a time an agent is available to show a listing. Times are stored
+// in UTC as RFC 3339.
+type Slot struct {
+	ID          string `json:"id"`
+	PropertyID  string `json:"property_id"`
+	AgencyID    string `json:"agency_id"`
+	AgentID     string `json:"agent_id"`
+	Start       string `json:"start"`
+	End         string `json:"end"`
+	Status      string `json:"status"`
+	BookingID   string `json:"booking_id,omitempty"`
+	DateCreated string `json:"date_created"`
+}
+
+type Slots []Slot
+
+// Booking is a buyer's viewing. The listing's title and address are copied
+// in so calendars don't change when the listing does.
+type Booking struct {
+	ID            string `json:"id"`
+	SlotID        string `json:"slot_id"`
+	PropertyID    string `json:"property_id"`
+	PropertyTitle string `json:"property_title"`
+	Address       string `json:"address"`
+	AgencyID      string `json:"agency_id"`
+	AgentID       string `json:"agent_id"`
+	UserID        string `json:"user_id"`
+	Name          string `json:"name"`
+	Phone         string `json:"phone"`
+	Email         string `json:"email,omitempty"`
+	Note          string `json:"note,omitempty"`
+	Start         string `json:"start"`
+	End           string `json:"end"`
+	Status        string `json:"status"`
+	CancelledBy   string `json:"cancelled_by,omitempty"`
+	DateCreated   string `json:"date_created"`
+	DateCancelled string `json:"date_cancelled,omitempty"`
+}
+
+type Bookings []Booking
+
+type SlotTime struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// SlotsRequest publishes slots for the calling agent.
+type SlotsRequest struct {
+	Slots []SlotTime `json:"slots"`
+}
+
+type BookingRequest struct {
+	Name  string `json:"name"`
+	Phone string `json:"phone"`
+	Email string `json:"email"`
+	Note  string `json:"note"`
+}
+
+// FeedLink is the address of an agent's calendar feed. It is a secret: anyone
+// holding it can read the agent's viewings.
+type FeedLink struct {
+	Url string `json:"url"`
+}
+
+// Validate normalizes the slot times to UTC and rejects slots in the past,
+// too short or long, or overlapping each other.
+func (r *SlotsRequest) Validate(now time.Time) rest_errors.RestErr {
+	if len(r.Slots) == 0 || len(r.Slots) > MaxSlotsPerRequest {
+		return rest_errors.NewBadRequestErr(fmt.Sprintf("between 1 and %d slots can be published at once", MaxSlotsPerRequest))
+	}
+	for i, slot := range r.Slots {
+		start, end, err := slot.Times()
+		if err != nil {
+			return err
+		}
+		if !start.After(now) || start.After(now.Add(maxSlotLead)) {
+			return rest_errors.NewBadRequestErr("slots must start in the future and within 180 days")
+		}
+		if length := end.Sub(start); length < minSlotLength || length > maxSlotLength {
+			return rest_errors.NewBadRequestErr("slots must last between 10 minutes and 4 hours")
+		}
+		for _, other := range r.Slots[:i] {
+			otherStart, otherEnd, _ := other.Times()
+			if Overlaps(start, end, otherStart, otherEnd) {
+				return rest_errors.NewBadRequestErr("slots must not overlap")
+			}
+		}
+		r.Slots[i] = SlotTime{Start: start.Format(time.RFC3339), End: end.Format(time.RFC3339)}
+	}
+	return nil
+}
+
+// Times parses the slot. Times need an explicit offset so slots don't move
+// with the server's timezone.
+func (t SlotTime) Times() (time.Time, time.Time, rest_errors.RestErr) {
+	start, startErr := time.Parse(time.RFC3339, strings.TrimSpace(t.Start))
+	end, endErr := time.Parse(time.RFC3339, strings.TrimSpace(t.End))
+	if startErr != nil || endErr != nil {
+		return time.Time{}, time.Time{}, rest_errors.NewBadRequestErr("slot start and end must be RFC 3339 times, e.g. 2026-05-01T10:00:00+03:00")
+	}
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, rest_errors.NewBadRequestErr("slot end must be after its start")
+	}
+	return start.UTC(), end.UTC(), nil
+}
+
+func (s Slot) Times() (time.Time, time.Time) {
+	start, _ := time.Parse(time.RFC3339, s.Start)
+	end, _ := time.Parse(time.RFC3339, s.End)
+	return start, end
+}
+
+func (b Booking) Times() (time.Time, time.Time) {
+	start, _ := time.Parse(time.RFC3339, b.Start)
+	end, _ := time.Parse(time.RFC3339, b.End)
+	return start, end
+}
+
+func (r *BookingRequest) Validate() rest_errors.RestErr {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Phone = strings.TrimSpace(r.Phone)
+	r.Email = strings.TrimSpace(r.Email)
+	r.Note = strings.TrimSpace(r.Note)
+
+	if r.Name == "" || len(r.Name) > 100 {
+		return rest_errors.NewBadRequestErr("name is required and must be at most 100 characters")
+	}
+	if r.Phone == "" && r.Email == "" {
+		return rest_errors.NewBadRequestErr("a phone number or email is required")
+	}
+	if r.Email != "" && !strings.Contains(r.Email, "@") {
+		return rest_errors.NewBadRequestErr("invalid email")
+	}
+	if len(r.Note) > maxNoteLength {
+		return rest_errors.NewBadRequestErr(fmt.Sprintf("note must be at most %d characters", maxNoteLength))
+	}
+	return nil
+}
+
+// Overlaps reports whether two time ranges share any time; touching ranges
+// don't overlap.
+func Overlaps(start time.Time, end time.Time, otherStart time.Time, otherEnd time.Time) bool {
+	return start.Before(otherEnd) && otherStart.Before(end)
+}
@@ -0,0 +1,144 @@
+package webhook
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/event"
+	"github.com/superbkibbles/realestate_property-api/domain/notification"
+)
+
+const (
+	STATUS_PENDING    = "pending"
+	STATUS_DELIVERING = "delivering"
+	STATUS_DELIVERED  = "delivered"
+	STATUS_DEAD       = "dead"
+
+	// TYPE_PING is only sent by test deliveries.
+	TYPE_PING = "webhook.ping"
+
+	MinSecretLength = 16
+	// MaxLog is how many attempts a delivery keeps in its log.
+	MaxLog = 20
+)
+
+// Subscription pushes the events of an agency's listings to URL. No event
+// types means all of them. The secret is only shown when it is created.
+type Subscription struct {
+	ID          string   `json:"id"`
+	AgencyID    string   `json:"agency_id"`
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret,omitempty"`
+	EventTypes  []string `json:"event_types"`
+	Active      bool     `json:"active"`
+	DateCreated string   `json:"date_created"`
+	DateUpdated string   `json:"date_updated,omitempty"`
+}
+
+type Subscriptions []Subscription
+
+// Request creates or replaces a subscription. An empty secret is generated
+// on create and kept on update; Active defaults to true.
+type Request struct {
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret"`
+	EventTypes []string `json:"event_types"`
+	Active     *bool    `json:"active"`
+}
+
+// Delivery is one event sent to one subscription. Payload is the exact
+// body, so a replay is signed the same way as the first attempt.
+type Delivery struct {
+	ID             string    `json:"id"`
+	SubscriptionID string    `json:"subscription_id"`
+	AgencyID       string    `json:"agency_id"`
+	EventID        string    `json:"event_id"`
+	EventType      string    `json:"event_type"`
+	Payload        string    `json:"payload"`
+	Status         string    `json:"status"`
+	Attempts       int       `json:"attempts"`
+	NextAttemptAt  string    `json:"next_attempt_at"`
+	LockedUntil    string    `json:"locked_until,omitempty"`
+	LastError      string    `json:"last_error,omitempty"`
+	DateCreated    string    `json:"date_created"`
+	DeliveredAt    string    `json:"delivered_at,omitempty"`
+	Log            []Attempt `json:"log"`
+}
+
+type Deliveries []Delivery
+
+// Attempt is one request made for a delivery.
+type Attempt struct {
+	At         string `json:"at"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// DeliveryReport sums up one run of the delivery scheduler. Failed counts
+// the deliveries left for the next run because they could not be claimed
+// or recorded.
+type DeliveryReport struct {
+	Delivered int `json:"delivered"`
+	Retried   int `json:"retried"`
+	Dead      int `json:"dead"`
+	Failed    int `json:"failed"`
+}
+
+func (r *Request) Validate() rest_errors.RestErr {
+	r.URL = strings.TrimSpace(r.URL)
+	if err := notification.ValidateTarget(notification.CHANNEL_WEBHOOK, r.URL); err != nil {
+		return rest_errors.NewBadRequestErr("url must be a http or https URL on a public address")
+	}
+	r.Secret = strings.TrimSpace(r.Secret)
+	if r.Secret != "" && len(r.Secret) < MinSecretLength {
+		return rest_errors.NewBadRequestErr(fmt.Sprintf("secret must be at least %d characters", MinSecretLength))
+	}
+
+	types := make([]string, 0, len(r.EventTypes))
+	seen := map[string]bool{}
+	for _, t := range r.EventTypes {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if !known(t) {
+			return rest_errors.NewBadRequestErr(fmt.Sprintf("invalid event type %s, must be one of %s", t, strings.Join(event.Types, ", ")))
+		}
+		if !seen[t] {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+	r.EventTypes = types
+	return nil
+}
+
+func known(eventType string) bool {
+	for _, t := range event.Types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether the subscription wants the event.
+func (s Subscription) Matches(e event.Event) bool {
+	if !s.Active || s.AgencyID != e.AgencyID {
+		return false
+	}
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range s.EventTypes {
+		if t == e.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryID is the same every time an event reaches a subscription, so an
+// event handed over twice is only delivered once.
+func DeliveryID(subscriptionID string, eventID string) string {
+	return subscriptionID + "_" + eventID
+}
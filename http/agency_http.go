@@ -0,0 +1,146 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	domainAgency "github.com/superbkibbles/realestate_property-api/domain/agency"
+	"github.com/superbkibbles/realestate_property-api/domain/query"
+	"github.com/superbkibbles/realestate_property-api/services/agency"
+)
+
+type AgencyHandler interface {
+	Create(*gin.Context)
+	Get(*gin.Context)
+	GetByID(*gin.Context)
+	Update(*gin.Context)
+	Verify(*gin.Context)
+	Delete(*gin.Context)
+	GetProperties(*gin.Context)
+	SearchProperties(*gin.Context)
+}
+
+type agencyHandler struct {
+	service agency.Service
+}
+
+func NewAgencyHandler(serv agency.Service) AgencyHandler {
+	return &agencyHandler{
+		service: serv,
+	}
+}
+
+func (ah *agencyHandler) Create(c *gin.Context) {
+	var a domainAgency.Agency
+	if err := c.ShouldBindJSON(&a); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	newAgency, err := ah.service.Create(a)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusCreated, newAgency)
+}
+
+func (ah *agencyHandler) Get(c *gin.Context) {
+	agencies, err := ah.service.Get(c.Query("sort"), c.Query("asc") == "true", c.GetHeader("local"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, agencies)
+}
+
+func (ah *agencyHandler) GetByID(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+
+	a, err := ah.service.GetByID(id, c.GetHeader("local"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+func (ah *agencyHandler) Update(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	var request domainAgency.UpdateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	a, err := ah.service.Update(id, getAgencyID(c), request)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+func (ah *agencyHandler) Verify(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	var request struct {
+		Verified bool `json:"verified"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	a, err := ah.service.SetVerified(id, request.Verified)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+func (ah *agencyHandler) Delete(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+
+	if err := ah.service.Delete(id); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (ah *agencyHandler) GetProperties(c *gin.Context) {
+	ah.properties(c, query.EsQuery{})
+}
+
+// SearchProperties takes the same filters as the property search.
+func (ah *agencyHandler) SearchProperties(c *gin.Context) {
+	var q query.EsQuery
+	if err := c.ShouldBindJSON(&q); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid Body JSON")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+	ah.properties(c, q)
+}
+
+func (ah *agencyHandler) properties(c *gin.Context, q query.EsQuery) {
+	id := strings.TrimSpace(c.Param("id"))
+	sort := c.Query("sort")
+	asc := c.Query("asc") == "true"
+	local := c.GetHeader("local")
+	q.Owner = getAgencyID(c)
+
+	properties, err := ah.service.GetProperties(id, q, sort, asc, local, c.Query("currency"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	prepareProperties(c, properties)
+	c.JSON(http.StatusOK, properties)
+}
@@ -0,0 +1,92 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	domainAmenity "github.com/superbkibbles/realestate_property-api/domain/amenity"
+	"github.com/superbkibbles/realestate_property-api/services/amenity"
+)
+
+type AmenityHandler interface {
+	Create(*gin.Context)
+	Get(*gin.Context)
+	GetByID(*gin.Context)
+	Update(*gin.Context)
+	Delete(*gin.Context)
+}
+
+type amenityHandler struct {
+	service amenity.Service
+}
+
+func NewAmenityHandler(serv amenity.Service) AmenityHandler {
+	return &amenityHandler{
+		service: serv,
+	}
+}
+
+func (ah *amenityHandler) Create(c *gin.Context) {
+	var a domainAmenity.Amenity
+	if err := c.ShouldBindJSON(&a); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	newAmenity, err := ah.service.Create(a)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusCreated, newAmenity)
+}
+
+func (ah *amenityHandler) Get(c *gin.Context) {
+	amenities, err := ah.service.Get(c.GetHeader("local"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, amenities)
+}
+
+func (ah *amenityHandler) GetByID(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+
+	a, err := ah.service.GetByID(id, c.GetHeader("local"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+func (ah *amenityHandler) Update(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	var request domainAmenity.UpdateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	a, err := ah.service.Update(id, request)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+func (ah *amenityHandler) Delete(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+
+	if err := ah.service.Delete(id); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
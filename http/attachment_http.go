@@ -0,0 +1,97 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	domainProperty "github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/services/attachment"
+)
+
+type AttachmentHandler interface {
+	Upload(*gin.Context)
+	Delete(*gin.Context)
+	Link(*gin.Context)
+	Download(*gin.Context)
+}
+
+type attachmentHandler struct {
+	service attachment.Service
+}
+
+func NewAttachmentHandler(serv attachment.Service) AttachmentHandler {
+	return &attachmentHandler{
+		service: serv,
+	}
+}
+
+// Upload takes a multipart form with the file in "file" and the kind, title,
+// visibility and labels[<local>] fields.
+func (ah *attachmentHandler) Upload(c *gin.Context) {
+	propertyID := strings.TrimSpace(c.Param("id"))
+	file, err := c.FormFile("file")
+	if err != nil {
+		restErr := rest_errors.NewBadRequestErr("No file was uploaded in the file field")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	request := domainProperty.AttachmentRequest{
+		Kind:       c.PostForm("kind"),
+		Title:      c.PostForm("title"),
+		Labels:     c.PostFormMap("labels"),
+		Visibility: c.PostForm("visibility"),
+		File:       file,
+	}
+	result, uploadErr := ah.service.Upload(propertyID, request, getActor(c))
+	if uploadErr != nil {
+		c.JSON(uploadErr.Status(), uploadErr)
+		return
+	}
+	c.JSON(http.StatusCreated, result)
+}
+
+func (ah *attachmentHandler) Delete(c *gin.Context) {
+	propertyID := strings.TrimSpace(c.Param("id"))
+	attachmentID := strings.TrimSpace(c.Param("attachment_id"))
+
+	if err := ah.service.Delete(propertyID, attachmentID, getActor(c)); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (ah *attachmentHandler) Link(c *gin.Context) {
+	propertyID := strings.TrimSpace(c.Param("id"))
+	attachmentID := strings.TrimSpace(c.Param("attachment_id"))
+
+	link, err := ah.service.Link(propertyID, attachmentID, getAgencyID(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, link)
+}
+
+func (ah *attachmentHandler) Download(c *gin.Context) {
+	propertyID := strings.TrimSpace(c.Param("id"))
+	attachmentID := strings.TrimSpace(c.Param("attachment_id"))
+
+	file, a, err := ah.service.Open(propertyID, attachmentID, c.Query("expires"), c.Query("signature"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	defer file.Close()
+
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", a.ID+"."+a.FileType))
+	c.Status(http.StatusOK)
+	io.Copy(c.Writer, file)
+}
@@ -0,0 +1,77 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	domainAudit "github.com/superbkibbles/realestate_property-api/domain/audit"
+	domainProperty "github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/services/audit"
+)
+
+type AuditHandler interface {
+	GetByProperty(*gin.Context)
+	GetByActor(*gin.Context)
+	AsOf(*gin.Context)
+}
+
+type auditHandler struct {
+	service audit.Service
+}
+
+func NewAuditHandler(serv audit.Service) AuditHandler {
+	return &auditHandler{
+		service: serv,
+	}
+}
+
+// GetByProperty takes optional ?field=price&from=&to= filters.
+func (ah *auditHandler) GetByProperty(c *gin.Context) {
+	propertyID := strings.TrimSpace(c.Param("id"))
+	var q domainAudit.Query
+	if err := c.ShouldBindQuery(&q); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid query")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	entries, err := ah.service.GetByProperty(propertyID, getAgencyID(c), q)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+func (ah *auditHandler) GetByActor(c *gin.Context) {
+	userID := strings.TrimSpace(c.Param("user_id"))
+	var q domainAudit.Query
+	if err := c.ShouldBindQuery(&q); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid query")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	entries, err := ah.service.GetByActor(userID, q)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// AsOf shows the listing as it was at ?at=, an RFC 3339 time.
+func (ah *auditHandler) AsOf(c *gin.Context) {
+	propertyID := strings.TrimSpace(c.Param("id"))
+
+	p, err := ah.service.AsOf(propertyID, getAgencyID(c), c.Query("at"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	properties := domainProperty.Properties{*p}
+	prepareProperties(c, properties)
+	c.JSON(http.StatusOK, properties[0])
+}
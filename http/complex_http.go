@@ -0,0 +1,175 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	domainComplex "github.com/superbkibbles/realestate_property-api/domain/complex"
+	domainProperty "github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/domain/query"
+	"github.com/superbkibbles/realestate_property-api/services/complex"
+)
+
+type ComplexHandler interface {
+	Create(*gin.Context)
+	Get(*gin.Context)
+	GetByID(*gin.Context)
+	Update(*gin.Context)
+	Delete(*gin.Context)
+	UploadMedia(*gin.Context)
+	DeleteMedia(*gin.Context)
+	GetUnits(*gin.Context)
+	SearchUnits(*gin.Context)
+	GetStats(*gin.Context)
+}
+
+type complexHandler struct {
+	service complex.Service
+}
+
+func NewComplexHandler(serv complex.Service) ComplexHandler {
+	return &complexHandler{
+		service: serv,
+	}
+}
+
+func (ch *complexHandler) Create(c *gin.Context) {
+	var request domainComplex.Complex
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	newComplex, err := ch.service.Create(request)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusCreated, newComplex)
+}
+
+func (ch *complexHandler) Get(c *gin.Context) {
+	complexes, err := ch.service.Get(c.Query("sort"), c.Query("asc") == "true")
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, complexes)
+}
+
+func (ch *complexHandler) GetByID(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+
+	result, err := ch.service.GetByID(id)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (ch *complexHandler) Update(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	var request domainComplex.UpdateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	result, err := ch.service.Update(id, request)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (ch *complexHandler) Delete(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+
+	if err := ch.service.Delete(id); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (ch *complexHandler) UploadMedia(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid multipart form")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+	files := form.File["files"]
+	if len(files) < 1 {
+		restErr := rest_errors.NewBadRequestErr("No files were uploaded in the files field")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	result, uploadErr := ch.service.UploadMedia(id, domainProperty.UploadMediaRequest{Files: files})
+	if uploadErr != nil {
+		c.JSON(uploadErr.Status(), uploadErr)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (ch *complexHandler) DeleteMedia(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	mediaID := strings.TrimSpace(c.Param("media_id"))
+
+	if err := ch.service.DeleteMedia(id, mediaID); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (ch *complexHandler) GetUnits(c *gin.Context) {
+	ch.units(c, query.EsQuery{})
+}
+
+// SearchUnits takes the same filters as the property search.
+func (ch *complexHandler) SearchUnits(c *gin.Context) {
+	var q query.EsQuery
+	if err := c.ShouldBindJSON(&q); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid Body JSON")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+	ch.units(c, q)
+}
+
+func (ch *complexHandler) units(c *gin.Context, q query.EsQuery) {
+	id := strings.TrimSpace(c.Param("id"))
+	sort := c.Query("sort")
+	asc := c.Query("asc") == "true"
+	local := c.GetHeader("local")
+
+	properties, err := ch.service.GetUnits(id, q, sort, asc, local, c.Query("currency"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	prepareProperties(c, properties)
+	c.JSON(http.StatusOK, properties)
+}
+
+func (ch *complexHandler) GetStats(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+
+	stats, err := ch.service.GetStats(id, c.Query("currency"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	domainCurrency "github.com/superbkibbles/realestate_property-api/domain/currency"
+	"github.com/superbkibbles/realestate_property-api/services/currency"
+)
+
+type CurrencyHandler interface {
+	GetRates(*gin.Context)
+	SetRates(*gin.Context)
+}
+
+type currencyHandler struct {
+	service currency.Service
+}
+
+func NewCurrencyHandler(serv currency.Service) CurrencyHandler {
+	return &currencyHandler{
+		service: serv,
+	}
+}
+
+func (ch *currencyHandler) GetRates(c *gin.Context) {
+	c.JSON(http.StatusOK, ch.service.GetRates())
+}
+
+func (ch *currencyHandler) SetRates(c *gin.Context) {
+	var rates domainCurrency.Rates
+	if err := c.ShouldBindJSON(&rates); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	updated, err := ch.service.SetRates(rates)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	domainDuplicate "github.com/superbkibbles/realestate_property-api/domain/duplicate"
+	"github.com/superbkibbles/realestate_property-api/services/duplicate"
+)
+
+type DuplicateHandler interface {
+	Get(*gin.Context)
+	Dismiss(*gin.Context)
+	Merge(*gin.Context)
+}
+
+type duplicateHandler struct {
+	service duplicate.Service
+}
+
+func NewDuplicateHandler(serv duplicate.Service) DuplicateHandler {
+	return &duplicateHandler{
+		service: serv,
+	}
+}
+
+// Get lists suspected duplicates, optionally filtered with ?status=open.
+func (dh *duplicateHandler) Get(c *gin.Context) {
+	suspects, err := dh.service.Get(strings.TrimSpace(c.Query("status")))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, suspects)
+}
+
+func (dh *duplicateHandler) Dismiss(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("duplicate_id"))
+
+	suspect, err := dh.service.Dismiss(id, getUserID(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, suspect)
+}
+
+func (dh *duplicateHandler) Merge(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("duplicate_id"))
+	var request domainDuplicate.MergeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	suspect, err := dh.service.Merge(id, request, getActor(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, suspect)
+}
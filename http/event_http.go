@@ -0,0 +1,41 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/superbkibbles/realestate_property-api/services/event"
+)
+
+type EventHandler interface {
+	GetFailed(*gin.Context)
+	Requeue(*gin.Context)
+}
+
+type eventHandler struct {
+	service event.Service
+}
+
+func NewEventHandler(serv event.Service) EventHandler {
+	return &eventHandler{
+		service: serv,
+	}
+}
+
+func (eh *eventHandler) GetFailed(c *gin.Context) {
+	deliveries, err := eh.service.GetFailed()
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+func (eh *eventHandler) Requeue(c *gin.Context) {
+	if err := eh.service.Requeue(strings.TrimSpace(c.Param("id"))); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
@@ -0,0 +1,37 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	domainProperty "github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/services/expiry"
+)
+
+type ExpiryHandler interface {
+	Renew(*gin.Context)
+}
+
+type expiryHandler struct {
+	service expiry.Service
+}
+
+func NewExpiryHandler(serv expiry.Service) ExpiryHandler {
+	return &expiryHandler{
+		service: serv,
+	}
+}
+
+func (eh *expiryHandler) Renew(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+
+	p, err := eh.service.Renew(id, getActor(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	properties := domainProperty.Properties{*p}
+	prepareProperties(c, properties)
+	c.JSON(http.StatusOK, properties[0])
+}
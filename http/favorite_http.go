@@ -0,0 +1,103 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	domainFavorite "github.com/superbkibbles/realestate_property-api/domain/favorite"
+	domainProperty "github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/services/favorite"
+)
+
+type FavoriteHandler interface {
+	Add(*gin.Context)
+	Remove(*gin.Context)
+	Get(*gin.Context)
+	GetPropertyStats(*gin.Context)
+	GetAgencyStats(*gin.Context)
+}
+
+type favoriteHandler struct {
+	service favorite.Service
+}
+
+func NewFavoriteHandler(serv favorite.Service) FavoriteHandler {
+	return &favoriteHandler{
+		service: serv,
+	}
+}
+
+// Add takes an optional body choosing where change notifications go.
+func (fh *favoriteHandler) Add(c *gin.Context) {
+	propertyID := strings.TrimSpace(c.Param("id"))
+	var request domainFavorite.Request
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+			c.JSON(restErr.Status(), restErr)
+			return
+		}
+	}
+
+	result, created, err := fh.service.Add(getUserID(c), propertyID, request)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	if created {
+		c.JSON(http.StatusCreated, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (fh *favoriteHandler) Remove(c *gin.Context) {
+	propertyID := strings.TrimSpace(c.Param("id"))
+
+	if err := fh.service.Remove(getUserID(c), propertyID); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (fh *favoriteHandler) Get(c *gin.Context) {
+	favorites, err := fh.service.Get(getUserID(c), c.GetHeader("local"), c.Query("currency"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	for i := range favorites {
+		if favorites[i].Property == nil {
+			continue
+		}
+		properties := domainProperty.Properties{*favorites[i].Property}
+		prepareProperties(c, properties)
+		favorites[i].Property = &properties[0]
+	}
+	c.JSON(http.StatusOK, favorites)
+}
+
+func (fh *favoriteHandler) GetPropertyStats(c *gin.Context) {
+	propertyID := strings.TrimSpace(c.Param("id"))
+
+	counts, err := fh.service.GetPropertyStats(propertyID)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, counts)
+}
+
+func (fh *favoriteHandler) GetAgencyStats(c *gin.Context) {
+	agencyID := strings.TrimSpace(c.Param("agency_id"))
+
+	counts, err := fh.service.GetAgencyStats(agencyID)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, counts)
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/audit"
+	domainProperty "github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/utils/crypto_utils"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+const (
+	// The gateway in front of this service authenticates callers and
+	// forwards their user id in these headers.
+	headerUserID   = "X-User-ID"
+	headerAgencyID = "X-Agency-ID"
+	headerAdminKey = "X-Admin-Key"
+
+	headerVisitorID = "X-Visitor-ID"
+
+	headerRequestID = "X-Request-ID"
+	keyRequestID    = "request_id"
+)
+
+func getUserID(c *gin.Context) string {
+	return strings.TrimSpace(c.GetHeader(headerUserID))
+}
+
+// getAgencyID returns the agency the caller acts for, also forwarded by the
+// gateway.
+func getAgencyID(c *gin.Context) string {
+	return strings.TrimSpace(c.GetHeader(headerAgencyID))
+}
+
+// getVisitorID tells anonymous visitors apart for counting views and clicks,
+// by X-Visitor-ID or else by their address and user agent.
+func getVisitorID(c *gin.Context) string {
+	if visitorID := strings.TrimSpace(c.GetHeader(headerVisitorID)); visitorID != "" {
+		return visitorID
+	}
+	return crypto_utils.GetMd5(c.ClientIP() + "|" + c.Request.UserAgent())
+}
+
+// getActor identifies the caller in the audit log.
+func getActor(c *gin.Context) audit.Actor {
+	return audit.Actor{
+		UserID:    getUserID(c),
+		AgencyID:  getAgencyID(c),
+		RequestID: c.GetString(keyRequestID),
+	}
+}
+
+// RequestID keeps the X-Request-ID the gateway sent, or makes one up, and
+// echoes it in the response so log lines and audit entries can be matched.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := strings.TrimSpace(c.GetHeader(headerRequestID))
+		if id == "" {
+			id = uuid.New().String()
+		}
+		c.Set(keyRequestID, id)
+		c.Header(headerRequestID, id)
+		c.Next()
+	}
+}
+
+// AdminOnly guards operator endpoints with the key from ADMIN_API_KEY. With no
+// key configured every request is refused.
+func AdminOnly(adminKey string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(headerAdminKey)
+		if adminKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) != 1 {
+			restErr := rest_errors.NewUnauthorizedError("invalid admin key")
+			c.AbortWithStatusJSON(restErr.Status(), restErr)
+			return
+		}
+		c.Next()
+	}
+}
+
+// wantsEmbed reports whether the caller asked for a related resource, e.g.
+// ?embed=agency.
+func wantsEmbed(c *gin.Context, resource string) bool {
+	for _, embed := range strings.Split(c.Query("embed"), ",") {
+		if strings.TrimSpace(embed) == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// prepareProperties shapes properties for the caller: agency only attachments
+// are dropped unless the caller is the listing's agency, attachment labels
+// are localized and past open houses are left out.
+func prepareProperties(c *gin.Context, properties domainProperty.Properties) {
+	properties.HidePrivateAttachments(getAgencyID(c))
+	now := date_utils.GetNow()
+	for i := range properties {
+		properties[i].LocalizeAttachments(c.GetHeader("local"))
+		properties[i].DropEndedOpenHouses(now)
+	}
+}
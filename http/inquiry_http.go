@@ -0,0 +1,104 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	domainInquiry "github.com/superbkibbles/realestate_property-api/domain/inquiry"
+	"github.com/superbkibbles/realestate_property-api/services/inquiry"
+)
+
+type InquiryHandler interface {
+	Create(*gin.Context)
+	Get(*gin.Context)
+	Assign(*gin.Context)
+	SetStatus(*gin.Context)
+	GetLeadCounts(*gin.Context)
+}
+
+type inquiryHandler struct {
+	service inquiry.Service
+}
+
+func NewInquiryHandler(serv inquiry.Service) InquiryHandler {
+	return &inquiryHandler{
+		service: serv,
+	}
+}
+
+func (ih *inquiryHandler) Create(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	var request domainInquiry.Request
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	i, err := ih.service.Create(id, getUserID(c), request)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"id": i.ID, "status": "received"})
+}
+
+func (ih *inquiryHandler) Get(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+
+	inquiries, err := ih.service.Get(id, getAgencyID(c), c.Query("status"), strings.TrimSpace(c.Query("property_id")))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, inquiries)
+}
+
+func (ih *inquiryHandler) Assign(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	inquiryID := strings.TrimSpace(c.Param("inquiry_id"))
+	var request domainInquiry.AssignRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	i, err := ih.service.Assign(id, getAgencyID(c), inquiryID, request)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, i)
+}
+
+func (ih *inquiryHandler) SetStatus(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	inquiryID := strings.TrimSpace(c.Param("inquiry_id"))
+	var request domainInquiry.StatusRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	i, err := ih.service.SetStatus(id, getAgencyID(c), inquiryID, request)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, i)
+}
+
+func (ih *inquiryHandler) GetLeadCounts(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+
+	counts, err := ih.service.GetLeadCounts(id, getAgencyID(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, counts)
+}
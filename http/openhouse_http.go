@@ -0,0 +1,114 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	domainProperty "github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/services/openhouse"
+)
+
+type OpenHouseHandler interface {
+	Create(*gin.Context)
+	Get(*gin.Context)
+	Delete(*gin.Context)
+	GetRSVPs(*gin.Context)
+	RSVP(*gin.Context)
+	CancelRSVP(*gin.Context)
+}
+
+type openHouseHandler struct {
+	service openhouse.Service
+}
+
+func NewOpenHouseHandler(serv openhouse.Service) OpenHouseHandler {
+	return &openHouseHandler{
+		service: serv,
+	}
+}
+
+func (oh *openHouseHandler) Create(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	var request domainProperty.OpenHouseRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	openHouse, err := oh.service.Create(id, getAgencyID(c), request)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusCreated, openHouse)
+}
+
+func (oh *openHouseHandler) Get(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+
+	openHouses, err := oh.service.Get(id)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, openHouses)
+}
+
+func (oh *openHouseHandler) Delete(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	openHouseID := strings.TrimSpace(c.Param("open_house_id"))
+
+	if err := oh.service.Delete(id, openHouseID, getAgencyID(c)); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (oh *openHouseHandler) GetRSVPs(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	openHouseID := strings.TrimSpace(c.Param("open_house_id"))
+
+	rsvps, err := oh.service.GetRSVPs(id, openHouseID, getAgencyID(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, rsvps)
+}
+
+func (oh *openHouseHandler) RSVP(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	openHouseID := strings.TrimSpace(c.Param("open_house_id"))
+	var request domainProperty.RSVPRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	rsvp, created, err := oh.service.RSVP(id, openHouseID, getUserID(c), request)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	if created {
+		c.JSON(http.StatusCreated, rsvp)
+		return
+	}
+	c.JSON(http.StatusOK, rsvp)
+}
+
+func (oh *openHouseHandler) CancelRSVP(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	openHouseID := strings.TrimSpace(c.Param("open_house_id"))
+
+	if err := oh.service.CancelRSVP(id, openHouseID, getUserID(c)); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
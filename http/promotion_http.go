@@ -0,0 +1,94 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	domainPromotion "github.com/superbkibbles/realestate_property-api/domain/promotion"
+	"github.com/superbkibbles/realestate_property-api/services/promotion"
+)
+
+type PromotionHandler interface {
+	Create(*gin.Context)
+	GetByProperty(*gin.Context)
+	Cancel(*gin.Context)
+	Click(*gin.Context)
+	Report(*gin.Context)
+}
+
+type promotionHandler struct {
+	service promotion.Service
+}
+
+func NewPromotionHandler(serv promotion.Service) PromotionHandler {
+	return &promotionHandler{
+		service: serv,
+	}
+}
+
+func (ph *promotionHandler) Create(c *gin.Context) {
+	propertyID := strings.TrimSpace(c.Param("id"))
+	var request domainPromotion.Request
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	p, err := ph.service.Create(propertyID, getAgencyID(c), request)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusCreated, p)
+}
+
+func (ph *promotionHandler) GetByProperty(c *gin.Context) {
+	propertyID := strings.TrimSpace(c.Param("id"))
+
+	promotions, err := ph.service.GetByProperty(propertyID, getAgencyID(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, promotions)
+}
+
+func (ph *promotionHandler) Cancel(c *gin.Context) {
+	propertyID := strings.TrimSpace(c.Param("id"))
+	promotionID := strings.TrimSpace(c.Param("promotion_id"))
+
+	if err := ph.service.Cancel(propertyID, promotionID, getAgencyID(c)); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Click is a beacon clients call when a promoted result is opened, with the
+// promotion_id the result was returned with. Visitors are identified like
+// for views.
+func (ph *promotionHandler) Click(c *gin.Context) {
+	propertyID := strings.TrimSpace(c.Param("id"))
+	promotionID := strings.TrimSpace(c.Param("promotion_id"))
+
+	recorded, err := ph.service.Click(propertyID, promotionID, getVisitorID(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recorded": recorded})
+}
+
+func (ph *promotionHandler) Report(c *gin.Context) {
+	agencyID := strings.TrimSpace(c.Param("id"))
+
+	report, err := ph.service.Report(agencyID, getAgencyID(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -1,7 +1,9 @@
 package http
 
 import (
+	"mime/multipart"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -25,6 +27,13 @@ type Propertyhandler interface {
 	GetDeactive(*gin.Context)
 	Translate(*gin.Context)
 	GetTranslated(*gin.Context)
+	GetPriceHistory(*gin.Context)
+	Facets(*gin.Context)
+	Similar(*gin.Context)
+	Schedule(*gin.Context)
+	ScheduleChange(*gin.Context)
+	GetScheduledChanges(*gin.Context)
+	CancelScheduledChange(*gin.Context)
 }
 
 type propertyHandler struct {
@@ -42,22 +51,27 @@ func (ph *propertyHandler) UploadMedia(c *gin.Context) {
 
 	form, err := c.MultipartForm()
 	if err != nil {
-		restErr := rest_errors.NewBadRequestErr("Invalid JSON Body")
+		restErr := rest_errors.NewBadRequestErr("Invalid multipart form")
 		c.JSON(restErr.Status(), restErr)
 		return
 	}
 	files := form.File["files"]
 	if len(files) < 1 {
-		restErr := rest_errors.NewBadRequestErr("Invalid JSON Body")
+		restErr := rest_errors.NewBadRequestErr("No files were uploaded in the files field")
 		c.JSON(restErr.Status(), restErr)
 		return
 	}
 
-	if err := ph.service.UploadMedia(files, propertyID); err != nil {
-		c.JSON(err.Status(), err)
+	results, uploadErr := ph.service.UploadMedia(domainProperty.UploadMediaRequest{Files: files}, propertyID, getActor(c))
+	if uploadErr != nil {
+		c.JSON(uploadErr.Status(), uploadErr)
+		return
+	}
+	if results.Failed() > 0 {
+		c.JSON(http.StatusMultiStatus, results)
 		return
 	}
-	c.String(200, "uploaded")
+	c.JSON(http.StatusOK, results)
 }
 
 func (ph *propertyHandler) Update(c *gin.Context) {
@@ -70,7 +84,7 @@ func (ph *propertyHandler) Update(c *gin.Context) {
 		return
 	}
 
-	property, err := ph.service.Update(id, updateRequest)
+	property, err := ph.service.Update(id, updateRequest, getActor(c))
 	if err != nil {
 		c.JSON(err.Status(), err)
 		return
@@ -89,7 +103,7 @@ func (ph *propertyHandler) Translate(c *gin.Context) {
 		c.JSON(restErr.Status(), restErr)
 		return
 	}
-	property, err := ph.service.Translate(id, translateProperty, local)
+	property, err := ph.service.Translate(id, translateProperty, local, getActor(c))
 	if err != nil {
 		c.JSON(err.Status(), err)
 		return
@@ -107,7 +121,7 @@ func (ph *propertyHandler) Create(c *gin.Context) {
 		return
 	}
 
-	newProperty, resultErr := ph.service.Create(property)
+	newProperty, resultErr := ph.service.Create(property, getActor(c))
 	if resultErr != nil {
 		logger.Error("error when trying to create service property", nil)
 		c.JSON(resultErr.Status(), resultErr)
@@ -121,11 +135,15 @@ func (ph *propertyHandler) Get(c *gin.Context) {
 	sort := c.Query("sort")
 	asc := c.Query("asc") == "true"
 	local := c.GetHeader("local")
-	properties, err := ph.service.Get(sort, asc, local)
+	properties, err := ph.service.Get(sort, asc, local, c.Query("currency"), getAgencyID(c))
 	if err != nil {
 		c.JSON(err.Status(), err)
 		return
 	}
+	if err := ph.embed(c, properties); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
 
 	c.JSON(http.StatusOK, properties)
 }
@@ -135,11 +153,15 @@ func (ph *propertyHandler) GetActive(c *gin.Context) {
 	asc := c.Query("asc") == "true"
 	local := c.GetHeader("local")
 
-	p, err := ph.service.GetActive(sort, asc, local)
+	p, err := ph.service.GetActive(sort, asc, local, c.Query("currency"))
 	if err != nil {
 		c.JSON(err.Status(), err)
 		return
 	}
+	if err := ph.embed(c, p); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
 
 	c.JSON(http.StatusOK, p)
 }
@@ -149,11 +171,15 @@ func (ph *propertyHandler) GetDeactive(c *gin.Context) {
 	asc := c.Query("asc") == "true"
 	local := c.GetHeader("local")
 
-	p, err := ph.service.GetDeactive(sort, asc, local)
+	p, err := ph.service.GetDeactive(sort, asc, local, c.Query("currency"), getAgencyID(c))
 	if err != nil {
 		c.JSON(err.Status(), err)
 		return
 	}
+	if err := ph.embed(c, p); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
 
 	c.JSON(http.StatusOK, p)
 }
@@ -167,11 +193,17 @@ func (ph *propertyHandler) GetByID(c *gin.Context) {
 		return
 	}
 
-	property, err := ph.service.GetByID(id, local)
+	property, err := ph.service.GetByID(id, local, c.Query("currency"), getAgencyID(c))
 	if err != nil {
 		c.JSON(err.Status(), err)
 		return
 	}
+	properties := domainProperty.Properties{*property}
+	if err := ph.embed(c, properties); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	property = &properties[0]
 
 	c.JSON(http.StatusOK, property)
 }
@@ -185,7 +217,7 @@ func (ph *propertyHandler) GetTranslated(c *gin.Context) {
 		return
 	}
 
-	property, err := ph.service.GetTranslated(id, local)
+	property, err := ph.service.GetTranslated(id, local, getAgencyID(c))
 	if err != nil {
 		c.JSON(err.Status(), err)
 		return
@@ -206,20 +238,46 @@ func (ph *propertyHandler) Search(c *gin.Context) {
 		return
 	}
 
-	properties, err := ph.service.Search(q, sort, asc, local)
+	properties, err := ph.service.Search(q, sort, asc, local, c.Query("currency"))
 	if err != nil {
 		c.JSON(err.Status(), err)
 		return
 	}
+	if err := ph.embed(c, properties); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
 
 	c.JSON(http.StatusOK, properties)
 }
 
+// Facets counts the properties matching the search body, by default per
+// amenity. Other fields are picked with ?fields=amenities,city.
+func (ph *propertyHandler) Facets(c *gin.Context) {
+	var q query.EsQuery
+	if err := c.ShouldBindJSON(&q); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid Body JSON")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+	fields := []string{"amenities"}
+	if c.Query("fields") != "" {
+		fields = strings.Split(c.Query("fields"), ",")
+	}
+
+	facets, err := ph.service.Facets(q, fields, c.GetHeader("local"), c.Query("currency"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, facets)
+}
+
 func (ph *propertyHandler) DeleteMedia(c *gin.Context) {
 	propertyID := strings.TrimSpace(c.Param("id"))
 	mediaID := strings.TrimSpace(c.Param("media_id"))
 
-	if err := ph.service.DeleteMedia(propertyID, mediaID); err != nil {
+	if err := ph.service.DeleteMedia(propertyID, mediaID, getActor(c)); err != nil {
 		c.JSON(err.Status(), err)
 	}
 
@@ -232,16 +290,112 @@ func (ph *propertyHandler) UploadPropertyPic(c *gin.Context) {
 	file, err := c.FormFile("property_pic")
 	if err != nil {
 		logger.Info(err.Error())
-		restErr := rest_errors.NewBadRequestErr("Bad Request")
+		restErr := rest_errors.NewBadRequestErr("No file was uploaded in the property_pic field")
 		c.JSON(restErr.Status(), restErr)
 		return
 	}
 
-	p, uploadErr := ph.service.UploadProperyPic(agencyID, file)
-	if err != nil {
+	request := domainProperty.UploadMediaRequest{Files: []*multipart.FileHeader{file}}
+	p, uploadErr := ph.service.UploadProperyPic(agencyID, request, getActor(c))
+	if uploadErr != nil {
 		c.JSON(uploadErr.Status(), uploadErr)
 		return
 	}
 
 	c.JSON(http.StatusOK, p)
 }
+
+func (ph *propertyHandler) GetPriceHistory(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+
+	history, err := ph.service.GetPriceHistory(id, getAgencyID(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// Similar takes an optional ?size= of at most 24 listings.
+func (ph *propertyHandler) Similar(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	size, _ := strconv.Atoi(c.Query("size"))
+
+	properties, err := ph.service.Similar(id, size, c.GetHeader("local"), c.Query("currency"), getAgencyID(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	if err := ph.embed(c, properties); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, properties)
+}
+
+func (ph *propertyHandler) Schedule(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	var request domainProperty.ScheduleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	p, err := ph.service.Schedule(id, getActor(c), request)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	properties := domainProperty.Properties{*p}
+	prepareProperties(c, properties)
+	c.JSON(http.StatusOK, properties[0])
+}
+
+func (ph *propertyHandler) ScheduleChange(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	var request domainProperty.ScheduledChangeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	change, err := ph.service.ScheduleChange(id, getAgencyID(c), getUserID(c), request)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusCreated, change)
+}
+
+func (ph *propertyHandler) GetScheduledChanges(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+
+	changes, err := ph.service.GetScheduledChanges(id, getAgencyID(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, changes)
+}
+
+func (ph *propertyHandler) CancelScheduledChange(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	changeID := strings.TrimSpace(c.Param("change_id"))
+
+	if err := ph.service.CancelScheduledChange(id, changeID, getAgencyID(c)); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (ph *propertyHandler) embed(c *gin.Context, properties domainProperty.Properties) rest_errors.RestErr {
+	prepareProperties(c, properties)
+	if !wantsEmbed(c, "agency") {
+		return nil
+	}
+	return ph.service.EmbedAgencies(properties)
+}
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	domainSearch "github.com/superbkibbles/realestate_property-api/domain/search"
+	"github.com/superbkibbles/realestate_property-api/services/search"
+)
+
+type SearchHandler interface {
+	Create(*gin.Context)
+	Get(*gin.Context)
+	GetByID(*gin.Context)
+	Update(*gin.Context)
+	Delete(*gin.Context)
+}
+
+type searchHandler struct {
+	service search.Service
+}
+
+func NewSearchHandler(serv search.Service) SearchHandler {
+	return &searchHandler{
+		service: serv,
+	}
+}
+
+func (sh *searchHandler) Create(c *gin.Context) {
+	var saved domainSearch.SavedSearch
+	if err := c.ShouldBindJSON(&saved); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	result, err := sh.service.Create(getUserID(c), saved)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusCreated, result)
+}
+
+func (sh *searchHandler) Get(c *gin.Context) {
+	searches, err := sh.service.Get(getUserID(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, searches)
+}
+
+func (sh *searchHandler) GetByID(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+
+	result, err := sh.service.GetByID(getUserID(c), id)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// Update replaces the saved search with the body.
+func (sh *searchHandler) Update(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	var saved domainSearch.SavedSearch
+	if err := c.ShouldBindJSON(&saved); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	result, err := sh.service.Update(getUserID(c), id, saved)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (sh *searchHandler) Delete(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+
+	if err := sh.service.Delete(getUserID(c), id); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,122 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	domainUpload "github.com/superbkibbles/realestate_property-api/domain/upload"
+	"github.com/superbkibbles/realestate_property-api/services/upload"
+)
+
+const (
+	tusResumable   = "1.0.0"
+	tusContentType = "application/offset+octet-stream"
+)
+
+type UploadHandler interface {
+	CreateTicket(*gin.Context)
+	Head(*gin.Context)
+	Patch(*gin.Context)
+	Put(*gin.Context)
+	Complete(*gin.Context)
+}
+
+type uploadHandler struct {
+	service upload.Service
+}
+
+func NewUploadHandler(serv upload.Service) UploadHandler {
+	return &uploadHandler{
+		service: serv,
+	}
+}
+
+func (uh *uploadHandler) CreateTicket(c *gin.Context) {
+	propertyID := strings.TrimSpace(c.Param("id"))
+	var request domainUpload.TicketRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	ticket, err := uh.service.CreateTicket(propertyID, request)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	if ticket.UploadURL != "" {
+		c.Header("Location", ticket.UploadURL)
+	}
+	c.JSON(http.StatusCreated, ticket)
+}
+
+// Head reports how much of a resumable upload has arrived (tus HEAD).
+func (uh *uploadHandler) Head(c *gin.Context) {
+	ticketID := strings.TrimSpace(c.Param("ticket_id"))
+	c.Header("Tus-Resumable", tusResumable)
+	c.Header("Cache-Control", "no-store")
+
+	ticket, err := uh.service.GetStatus(ticketID, c.Query("token"))
+	if err != nil {
+		c.Status(err.Status())
+		return
+	}
+	c.Header("Upload-Offset", strconv.FormatInt(ticket.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(ticket.Length, 10))
+	c.Status(http.StatusOK)
+}
+
+// Patch appends a chunk to a resumable upload (tus PATCH).
+func (uh *uploadHandler) Patch(c *gin.Context) {
+	ticketID := strings.TrimSpace(c.Param("ticket_id"))
+	c.Header("Tus-Resumable", tusResumable)
+
+	if c.ContentType() != tusContentType {
+		restErr := rest_errors.NewRestError("Content-Type must be "+tusContentType, http.StatusUnsupportedMediaType, "unsupported_media_type", nil)
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+	offset, parseErr := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if parseErr != nil || offset < 0 {
+		restErr := rest_errors.NewBadRequestErr("Invalid Upload-Offset header")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	ticket, err := uh.service.WriteChunk(ticketID, c.Query("token"), offset, c.Request.Body)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.Header("Upload-Offset", strconv.FormatInt(ticket.Offset, 10))
+	c.Status(http.StatusNoContent)
+}
+
+// Put receives a whole file for a direct upload when the storage backend
+// cannot accept it itself.
+func (uh *uploadHandler) Put(c *gin.Context) {
+	ticketID := strings.TrimSpace(c.Param("ticket_id"))
+
+	ticket, err := uh.service.WriteDirect(ticketID, c.Query("token"), c.Request.Body)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, ticket)
+}
+
+func (uh *uploadHandler) Complete(c *gin.Context) {
+	propertyID := strings.TrimSpace(c.Param("id"))
+	ticketID := strings.TrimSpace(c.Param("ticket_id"))
+
+	p, err := uh.service.Complete(propertyID, ticketID, c.Query("token"), getActor(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
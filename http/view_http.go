@@ -0,0 +1,73 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	domainView "github.com/superbkibbles/realestate_property-api/domain/view"
+	"github.com/superbkibbles/realestate_property-api/services/view"
+)
+
+type ViewHandler interface {
+	Record(*gin.Context)
+	GetPropertyStats(*gin.Context)
+	GetAgencyStats(*gin.Context)
+}
+
+type viewHandler struct {
+	service view.Service
+}
+
+func NewViewHandler(serv view.Service) ViewHandler {
+	return &viewHandler{
+		service: serv,
+	}
+}
+
+// Record is a beacon the listing page calls once it is shown. Visitors are
+// identified by X-Visitor-ID, or by their address and user agent.
+func (vh *viewHandler) Record(c *gin.Context) {
+	propertyID := strings.TrimSpace(c.Param("id"))
+	recorded, err := vh.service.Record(propertyID, getVisitorID(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recorded": recorded})
+}
+
+func (vh *viewHandler) GetPropertyStats(c *gin.Context) {
+	propertyID := strings.TrimSpace(c.Param("id"))
+	var request domainView.StatsRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid query")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	stats, err := vh.service.GetPropertyStats(propertyID, request)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+func (vh *viewHandler) GetAgencyStats(c *gin.Context) {
+	agencyID := strings.TrimSpace(c.Param("agency_id"))
+	var request domainView.StatsRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid query")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	stats, err := vh.service.GetAgencyStats(agencyID, request)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
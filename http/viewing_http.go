@@ -0,0 +1,158 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	domainViewing "github.com/superbkibbles/realestate_property-api/domain/viewing"
+	"github.com/superbkibbles/realestate_property-api/services/viewing"
+)
+
+const calendarContentType = "text/calendar; charset=utf-8"
+
+type ViewingHandler interface {
+	PublishSlots(*gin.Context)
+	GetSlots(*gin.Context)
+	WithdrawSlot(*gin.Context)
+	Book(*gin.Context)
+	GetBookings(*gin.Context)
+	GetBooking(*gin.Context)
+	Cancel(*gin.Context)
+	Calendar(*gin.Context)
+	FeedLink(*gin.Context)
+	Feed(*gin.Context)
+}
+
+type viewingHandler struct {
+	service viewing.Service
+}
+
+func NewViewingHandler(serv viewing.Service) ViewingHandler {
+	return &viewingHandler{
+		service: serv,
+	}
+}
+
+func (vh *viewingHandler) PublishSlots(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	var request domainViewing.SlotsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	slots, err := vh.service.PublishSlots(id, getAgencyID(c), getUserID(c), request)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusCreated, slots)
+}
+
+func (vh *viewingHandler) GetSlots(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+
+	slots, err := vh.service.GetSlots(id, getAgencyID(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, slots)
+}
+
+func (vh *viewingHandler) WithdrawSlot(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	slotID := strings.TrimSpace(c.Param("slot_id"))
+
+	if err := vh.service.WithdrawSlot(id, slotID, getAgencyID(c)); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (vh *viewingHandler) Book(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	slotID := strings.TrimSpace(c.Param("slot_id"))
+	var request domainViewing.BookingRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	booking, err := vh.service.Book(id, slotID, getUserID(c), request)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusCreated, booking)
+}
+
+func (vh *viewingHandler) GetBookings(c *gin.Context) {
+	bookings, err := vh.service.GetBookings(getUserID(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, bookings)
+}
+
+func (vh *viewingHandler) GetBooking(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+
+	booking, err := vh.service.GetBooking(id, getUserID(c), getAgencyID(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, booking)
+}
+
+func (vh *viewingHandler) Cancel(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+
+	booking, err := vh.service.Cancel(id, getUserID(c), getAgencyID(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, booking)
+}
+
+func (vh *viewingHandler) Calendar(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+
+	calendar, err := vh.service.Calendar(id, getUserID(c), getAgencyID(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="viewing.ics"`)
+	c.Data(http.StatusOK, calendarContentType, calendar)
+}
+
+func (vh *viewingHandler) FeedLink(c *gin.Context) {
+	link, err := vh.service.FeedLink(getUserID(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, link)
+}
+
+// Feed is polled by calendar apps, which can't send the gateway headers, so
+// the link itself is the credential.
+func (vh *viewingHandler) Feed(c *gin.Context) {
+	agentID := strings.TrimSpace(c.Param("agent_id"))
+
+	calendar, err := vh.service.Feed(agentID, c.Query("token"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.Data(http.StatusOK, calendarContentType, calendar)
+}
@@ -0,0 +1,142 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	domainWebhook "github.com/superbkibbles/realestate_property-api/domain/webhook"
+	"github.com/superbkibbles/realestate_property-api/services/webhook"
+)
+
+type WebhookHandler interface {
+	Create(*gin.Context)
+	Get(*gin.Context)
+	GetByID(*gin.Context)
+	Update(*gin.Context)
+	Delete(*gin.Context)
+	Test(*gin.Context)
+	GetDeliveries(*gin.Context)
+	GetDeadLetters(*gin.Context)
+	Replay(*gin.Context)
+	ReplayAll(*gin.Context)
+}
+
+type webhookHandler struct {
+	service webhook.Service
+}
+
+func NewWebhookHandler(serv webhook.Service) WebhookHandler {
+	return &webhookHandler{
+		service: serv,
+	}
+}
+
+func (wh *webhookHandler) Create(c *gin.Context) {
+	var request domainWebhook.Request
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	sub, err := wh.service.Create(getAgencyID(c), request)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusCreated, sub)
+}
+
+func (wh *webhookHandler) Get(c *gin.Context) {
+	subscriptions, err := wh.service.Get(getAgencyID(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+func (wh *webhookHandler) GetByID(c *gin.Context) {
+	sub, err := wh.service.GetByID(getAgencyID(c), strings.TrimSpace(c.Param("id")))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+func (wh *webhookHandler) Update(c *gin.Context) {
+	var request domainWebhook.Request
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := rest_errors.NewBadRequestErr("Invalid JSON body")
+		c.JSON(restErr.Status(), restErr)
+		return
+	}
+
+	sub, err := wh.service.Update(getAgencyID(c), strings.TrimSpace(c.Param("id")), request)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+func (wh *webhookHandler) Delete(c *gin.Context) {
+	if err := wh.service.Delete(getAgencyID(c), strings.TrimSpace(c.Param("id"))); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Test responds with the ping delivery, including the status code the
+// subscriber answered with.
+func (wh *webhookHandler) Test(c *gin.Context) {
+	delivery, err := wh.service.Test(getAgencyID(c), strings.TrimSpace(c.Param("id")))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
+
+// GetDeliveries takes an optional ?status=dead filter.
+func (wh *webhookHandler) GetDeliveries(c *gin.Context) {
+	deliveries, err := wh.service.GetDeliveries(getAgencyID(c), strings.TrimSpace(c.Param("id")), c.Query("status"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+func (wh *webhookHandler) GetDeadLetters(c *gin.Context) {
+	deliveries, err := wh.service.GetDeadLetters(getAgencyID(c))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+func (wh *webhookHandler) Replay(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	deliveryID := strings.TrimSpace(c.Param("delivery_id"))
+
+	if err := wh.service.Replay(getAgencyID(c), id, deliveryID); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+func (wh *webhookHandler) ReplayAll(c *gin.Context) {
+	replayed, err := wh.service.ReplayAll(getAgencyID(c), strings.TrimSpace(c.Param("id")))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"replayed": replayed})
+}
@@ -1,11 +1,25 @@
 package main
 
 import (
+	"os"
+
 	"github.com/joho/godotenv"
 	"github.com/superbkibbles/realestate_property-api/app"
 )
 
 func main() {
 	godotenv.Load()
+	if len(os.Args) > 1 && os.Args[1] == "media-gc" {
+		app.RunMediaGC(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "duplicate-scan" {
+		app.RunDuplicateScan()
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "base-price-backfill" {
+		app.RunBasePriceBackfill()
+		return
+	}
 	app.StartApplication()
 }
@@ -2,11 +2,21 @@ package cloudstorage
 
 import (
 	"context"
-	"mime/multipart"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/cloudinary/cloudinary-go"
+	"github.com/cloudinary/cloudinary-go/api"
+	"github.com/cloudinary/cloudinary-go/api/admin"
 	"github.com/cloudinary/cloudinary-go/api/uploader"
 	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/media"
+	"github.com/superbkibbles/realestate_property-api/domain/upload"
 )
 
 const (
@@ -16,8 +26,18 @@ const (
 )
 
 type CloudStorage interface {
-	Save(file multipart.File, publicID string, folderName string) (*cloudRes, rest_errors.RestErr)
+	Save(file io.Reader, publicID string, folderName string) (*cloudRes, rest_errors.RestErr)
+	// Delete takes the id with its folder, e.g. folderName + "/" + publicID
+	// for an id returned by Save.
 	Delete(publicID string) rest_errors.RestErr
+	SignUpload(publicID string, folderName string, kind string) (*upload.DirectUpload, rest_errors.RestErr)
+	Find(publicID string, folderName string, kind string) (*cloudRes, rest_errors.RestErr)
+	ListFolders() ([]string, rest_errors.RestErr)
+	List(folderName string) ([]media.StoredAsset, rest_errors.RestErr)
+	SavePrivate(file io.Reader, publicID string, folderName string) (*cloudRes, rest_errors.RestErr)
+	DeletePrivate(publicID string, folderName string) rest_errors.RestErr
+	PrivateURL(publicID string, folderName string, format string, expiresAt time.Time) (string, rest_errors.RestErr)
+	OpenPrivate(publicID string, folderName string, format string) (io.ReadCloser, rest_errors.RestErr)
 }
 
 type cloudRes struct {
@@ -38,17 +58,21 @@ func NewRepository(cloud *cloudinary.Cloudinary) CloudStorage {
 
 func (repo *cloudStorage) Delete(publicID string) rest_errors.RestErr {
 	ctx := context.Background()
-	_, err := repo.cloud.Upload.Destroy(ctx, uploader.DestroyParams{PublicID: publicID})
+	resp, err := repo.cloud.Upload.Destroy(ctx, uploader.DestroyParams{PublicID: publicID})
+	if err == nil && resp.Result == "not found" {
+		// Cloudinary only looks at images unless told otherwise.
+		_, err = repo.cloud.Upload.Destroy(ctx, uploader.DestroyParams{PublicID: publicID, ResourceType: api.Video})
+	}
 	if err != nil {
 		return rest_errors.NewInternalServerErr("Error while trying to Delete Image/Video", err)
 	}
 	return nil
 }
 
-func (repo *cloudStorage) Save(file multipart.File, publicID string, folderName string) (*cloudRes, rest_errors.RestErr) {
+func (repo *cloudStorage) Save(file io.Reader, publicID string, folderName string) (*cloudRes, rest_errors.RestErr) {
 	ctx := context.Background()
 	var res cloudRes
-	resp, err := repo.cloud.Upload.Upload(ctx, file, uploader.UploadParams{PublicID: publicID, Folder: folderName, Tags: []string{"property"}})
+	resp, err := repo.cloud.Upload.Upload(ctx, file, uploader.UploadParams{PublicID: publicID, Folder: folderName, Tags: []string{media.ASSET_TAG}})
 	if err != nil {
 		return nil, rest_errors.NewInternalServerErr("Cloudinary Error", err)
 	}
@@ -57,3 +81,165 @@ func (repo *cloudStorage) Save(file multipart.File, publicID string, folderName
 	res.PublicID = publicID
 	return &res, nil
 }
+
+// SignUpload returns the parameters for an upload straight from the client to
+// Cloudinary. Cloudinary accepts the signature for one hour.
+func (repo *cloudStorage) SignUpload(publicID string, folderName string, kind string) (*upload.DirectUpload, rest_errors.RestErr) {
+	cloud := repo.cloud.Config.Cloud
+	params := url.Values{}
+	params.Set("folder", folderName)
+	params.Set("public_id", publicID)
+	signature, err := api.SignParameters(params, cloud.APISecret)
+	if err != nil {
+		return nil, rest_errors.NewInternalServerErr("Error while trying to sign upload", err)
+	}
+
+	return &upload.DirectUpload{
+		Url:    fmt.Sprintf("https://api.cloudinary.com/v1_1/%s/%s/upload", cloud.CloudName, kind),
+		Method: "POST",
+		Fields: map[string]string{
+			"api_key":   cloud.APIKey,
+			"folder":    folderName,
+			"public_id": publicID,
+			"timestamp": params.Get("timestamp"),
+			"signature": signature,
+		},
+	}, nil
+}
+
+func (repo *cloudStorage) Find(publicID string, folderName string, kind string) (*cloudRes, rest_errors.RestErr) {
+	ctx := context.Background()
+	resp, err := repo.cloud.Admin.Asset(ctx, admin.AssetParams{PublicID: folderName + "/" + publicID, AssetType: api.AssetType(kind)})
+	if err != nil {
+		return nil, rest_errors.NewInternalServerErr("Cloudinary Error", err)
+	}
+	if resp.Error.Message != "" {
+		if strings.Contains(strings.ToLower(resp.Error.Message), "not found") {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no asset was found with id %s", publicID))
+		}
+		return nil, rest_errors.NewInternalServerErr("Cloudinary Error", errors.New(resp.Error.Message))
+	}
+	return &cloudRes{
+		Url:      resp.SecureURL,
+		Ext:      resp.Format,
+		PublicID: publicID,
+	}, nil
+}
+
+func (repo *cloudStorage) ListFolders() ([]string, rest_errors.RestErr) {
+	ctx := context.Background()
+	var folders []string
+	cursor := ""
+	for {
+		resp, err := repo.cloud.Admin.RootFolders(ctx, admin.RootFoldersParams{MaxResults: 500, NextCursor: cursor})
+		if err != nil {
+			return nil, rest_errors.NewInternalServerErr("Cloudinary Error", err)
+		}
+		if resp.Error.Message != "" {
+			return nil, rest_errors.NewInternalServerErr("Cloudinary Error", errors.New(resp.Error.Message))
+		}
+		for _, folder := range resp.Folders {
+			folders = append(folders, folder.Path)
+		}
+		if resp.NextCursor == "" {
+			return folders, nil
+		}
+		cursor = resp.NextCursor
+	}
+}
+
+func (repo *cloudStorage) List(folderName string) ([]media.StoredAsset, rest_errors.RestErr) {
+	ctx := context.Background()
+	var assets []media.StoredAsset
+	for _, assetType := range []api.AssetType{api.Image, api.Video} {
+		cursor := ""
+		for {
+			resp, err := repo.cloud.Admin.Assets(ctx, admin.AssetsParams{
+				AssetType:    assetType,
+				DeliveryType: "upload",
+				Prefix:       folderName + "/",
+				MaxResults:   500,
+				Tags:         true,
+				NextCursor:   cursor,
+			})
+			if err != nil {
+				return nil, rest_errors.NewInternalServerErr("Cloudinary Error", err)
+			}
+			if resp.Error.Message != "" {
+				return nil, rest_errors.NewInternalServerErr("Cloudinary Error", errors.New(resp.Error.Message))
+			}
+			for _, asset := range resp.Assets {
+				assets = append(assets, media.StoredAsset{
+					PublicID:  asset.PublicID,
+					Folder:    folderName,
+					Url:       asset.SecureURL,
+					Kind:      asset.AssetType,
+					Tags:      asset.Tags,
+					CreatedAt: asset.CreatedAt,
+				})
+			}
+			if resp.NextCursor == "" {
+				break
+			}
+			cursor = resp.NextCursor
+		}
+	}
+	return assets, nil
+}
+
+// SavePrivate uploads with the private delivery type, so the file can only
+// be downloaded through a signed url.
+func (repo *cloudStorage) SavePrivate(file io.Reader, publicID string, folderName string) (*cloudRes, rest_errors.RestErr) {
+	ctx := context.Background()
+	resp, err := repo.cloud.Upload.Upload(ctx, file, uploader.UploadParams{PublicID: publicID, Folder: folderName, Type: api.Private, Tags: []string{media.ASSET_TAG}})
+	if err != nil {
+		return nil, rest_errors.NewInternalServerErr("Cloudinary Error", err)
+	}
+	if resp.Error.Message != "" {
+		return nil, rest_errors.NewInternalServerErr("Cloudinary Error", errors.New(resp.Error.Message))
+	}
+	return &cloudRes{
+		Ext:      resp.Format,
+		PublicID: publicID,
+	}, nil
+}
+
+func (repo *cloudStorage) DeletePrivate(publicID string, folderName string) rest_errors.RestErr {
+	ctx := context.Background()
+	if _, err := repo.cloud.Upload.Destroy(ctx, uploader.DestroyParams{PublicID: folderName + "/" + publicID, Type: api.Private}); err != nil {
+		return rest_errors.NewInternalServerErr("Error while trying to Delete Document", err)
+	}
+	return nil
+}
+
+func (repo *cloudStorage) PrivateURL(publicID string, folderName string, format string, expiresAt time.Time) (string, rest_errors.RestErr) {
+	link, err := repo.cloud.Upload.PrivateDownloadUrl(uploader.PrivateDownloadUrlParams{
+		PublicID:     folderName + "/" + publicID,
+		Format:       format,
+		DeliveryType: api.Private,
+		ExpiresAt:    &expiresAt,
+	})
+	if err != nil {
+		return "", rest_errors.NewInternalServerErr("Error while trying to sign download", err)
+	}
+	return link, nil
+}
+
+func (repo *cloudStorage) OpenPrivate(publicID string, folderName string, format string) (io.ReadCloser, rest_errors.RestErr) {
+	link, restErr := repo.PrivateURL(publicID, folderName, format, time.Now().Add(time.Minute))
+	if restErr != nil {
+		return nil, restErr
+	}
+	resp, err := http.Get(link)
+	if err != nil {
+		return nil, rest_errors.NewInternalServerErr("Cloudinary Error", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no document was found with id %s", publicID))
+		}
+		return nil, rest_errors.NewInternalServerErr("Cloudinary Error", fmt.Errorf("download failed with status %d", resp.StatusCode))
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,189 @@
+package cloudstorage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/media"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/domain/upload"
+	"github.com/superbkibbles/realestate_property-api/utils/media_utils"
+)
+
+// localStorage keeps assets on disk under root and serves them from baseURL.
+// It lets the whole media flow run offline. Everything under root was stored
+// by this service, so all of it is listed with media.ASSET_TAG. Private files are kept next to
+// root, outside the served directory.
+type localStorage struct {
+	root        string
+	privateRoot string
+	baseURL     string
+}
+
+func NewLocalRepository(root string, baseURL string) CloudStorage {
+	return &localStorage{
+		root:        root,
+		privateRoot: filepath.Clean(root) + "_private",
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+func (repo *localStorage) Save(file io.Reader, publicID string, folderName string) (*cloudRes, rest_errors.RestErr) {
+	fileName, err := repo.write(repo.root, file, publicID, folderName)
+	if err != nil {
+		return nil, err
+	}
+	ext := strings.TrimPrefix(filepath.Ext(fileName), ".")
+
+	return &cloudRes{
+		Url:      fmt.Sprintf("%s/%s/%s", repo.baseURL, filepath.Base(folderName), fileName),
+		Ext:      ext,
+		PublicID: publicID,
+	}, nil
+}
+
+func (repo *localStorage) write(root string, file io.Reader, publicID string, folderName string) (string, rest_errors.RestErr) {
+	reader := bufio.NewReaderSize(file, media_utils.SniffLen)
+	head, _ := reader.Peek(media_utils.SniffLen)
+	ext := media_utils.ExtensionFor(media_utils.DetectContentType(head))
+	if ext == "" {
+		ext = "bin"
+	}
+
+	if err := os.MkdirAll(filepath.Join(root, filepath.Base(folderName)), 0755); err != nil {
+		return "", rest_errors.NewInternalServerErr("Error while creating folder", err)
+	}
+	fileName := filepath.Base(publicID) + "." + ext
+	out, err := os.Create(filepath.Join(root, filepath.Base(folderName), fileName))
+	if err != nil {
+		return "", rest_errors.NewInternalServerErr("Error while creating file", err)
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, reader); err != nil {
+		return "", rest_errors.NewInternalServerErr("Error while saving file", err)
+	}
+	return fileName, nil
+}
+
+func (repo *localStorage) Delete(publicID string) rest_errors.RestErr {
+	matches, _ := filepath.Glob(filepath.Join(repo.root, "*", filepath.Base(publicID)+".*"))
+	for _, match := range matches {
+		if err := os.Remove(match); err != nil {
+			return rest_errors.NewInternalServerErr("Error while trying to Delete Image/Video", err)
+		}
+	}
+	return nil
+}
+
+// SignUpload returns nil: local storage has no endpoint of its own, so direct
+// uploads are received by this service instead.
+func (repo *localStorage) SignUpload(publicID string, folderName string, kind string) (*upload.DirectUpload, rest_errors.RestErr) {
+	return nil, nil
+}
+
+func (repo *localStorage) Find(publicID string, folderName string, kind string) (*cloudRes, rest_errors.RestErr) {
+	matches, _ := filepath.Glob(filepath.Join(repo.root, filepath.Base(folderName), filepath.Base(publicID)+".*"))
+	if len(matches) == 0 {
+		return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no asset was found with id %s", publicID))
+	}
+	fileName := filepath.Base(matches[0])
+	return &cloudRes{
+		Url:      fmt.Sprintf("%s/%s/%s", repo.baseURL, filepath.Base(folderName), fileName),
+		Ext:      strings.TrimPrefix(filepath.Ext(fileName), "."),
+		PublicID: publicID,
+	}, nil
+}
+
+func (repo *localStorage) ListFolders() ([]string, rest_errors.RestErr) {
+	entries, err := ioutil.ReadDir(repo.root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("Error while listing folders", err)
+	}
+	var folders []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			folders = append(folders, entry.Name())
+		}
+	}
+	return folders, nil
+}
+
+func (repo *localStorage) List(folderName string) ([]media.StoredAsset, rest_errors.RestErr) {
+	folder := filepath.Base(folderName)
+	entries, err := ioutil.ReadDir(filepath.Join(repo.root, folder))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("Error while listing files", err)
+	}
+	var assets []media.StoredAsset
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		ext := filepath.Ext(entry.Name())
+		kind := property.MEDIA_KIND_IMAGE
+		if ext == ".mp4" || ext == ".mov" {
+			kind = property.MEDIA_KIND_VIDEO
+		}
+		assets = append(assets, media.StoredAsset{
+			PublicID:  folder + "/" + strings.TrimSuffix(entry.Name(), ext),
+			Folder:    folder,
+			Url:       fmt.Sprintf("%s/%s/%s", repo.baseURL, folder, entry.Name()),
+			Kind:      kind,
+			Tags:      []string{media.ASSET_TAG},
+			CreatedAt: entry.ModTime(),
+		})
+	}
+	return assets, nil
+}
+
+func (repo *localStorage) SavePrivate(file io.Reader, publicID string, folderName string) (*cloudRes, rest_errors.RestErr) {
+	fileName, err := repo.write(repo.privateRoot, file, publicID, folderName)
+	if err != nil {
+		return nil, err
+	}
+	return &cloudRes{
+		Ext:      strings.TrimPrefix(filepath.Ext(fileName), "."),
+		PublicID: publicID,
+	}, nil
+}
+
+func (repo *localStorage) DeletePrivate(publicID string, folderName string) rest_errors.RestErr {
+	matches, _ := filepath.Glob(filepath.Join(repo.privateRoot, filepath.Base(folderName), filepath.Base(publicID)+".*"))
+	for _, match := range matches {
+		if err := os.Remove(match); err != nil {
+			return rest_errors.NewInternalServerErr("Error while trying to Delete Document", err)
+		}
+	}
+	return nil
+}
+
+// PrivateURL returns no url: private files are streamed by this service
+// through its own signed links.
+func (repo *localStorage) PrivateURL(publicID string, folderName string, format string, expiresAt time.Time) (string, rest_errors.RestErr) {
+	return "", nil
+}
+
+func (repo *localStorage) OpenPrivate(publicID string, folderName string, format string) (io.ReadCloser, rest_errors.RestErr) {
+	fileName := filepath.Base(publicID) + "." + filepath.Base(format)
+	file, err := os.Open(filepath.Join(repo.privateRoot, filepath.Base(folderName), fileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no document was found with id %s", publicID))
+		}
+		return nil, rest_errors.NewInternalServerErr("Error while opening file", err)
+	}
+	return file, nil
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/domain/agency"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+)
+
+const (
+	indexAgencies = "agency"
+)
+
+type AgencyRepository interface {
+	Create(agency.Agency) (*agency.Agency, rest_errors.RestErr)
+	Get(sort string, asc bool) (agency.Agencies, rest_errors.RestErr)
+	GetByID(id string) (*agency.Agency, rest_errors.RestErr)
+	GetByIDs(ids []string) (agency.Agencies, rest_errors.RestErr)
+	Update(id string, updateRequest agency.UpdateRequest) (*agency.Agency, rest_errors.RestErr)
+	Delete(id string) rest_errors.RestErr
+}
+
+type agencyRepository struct {
+}
+
+func NewAgencyRepository() AgencyRepository {
+	return &agencyRepository{}
+}
+
+func (db *agencyRepository) Create(a agency.Agency) (*agency.Agency, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Save(indexAgencies, typeProperty, a)
+	if err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to save agency", errors.New("database error"))
+	}
+	a.ID = result.Id
+	return &a, nil
+}
+
+func (db *agencyRepository) Get(sort string, asc bool) (agency.Agencies, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Get(indexAgencies, typeProperty, sort, asc)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return agency.Agencies{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get agencies", errors.New("database error"))
+	}
+	return searchResultToAgencies(result), nil
+}
+
+func (db *agencyRepository) GetByID(id string) (*agency.Agency, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.GetByID(indexAgencies, typeProperty, id)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no agency was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get agency", errors.New("database error"))
+	}
+
+	var a agency.Agency
+	bytes, _ := result.Source.MarshalJSON()
+	if err := json.Unmarshal(bytes, &a); err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to parse database response", errors.New("database error"))
+	}
+	a.ID = result.Id
+	return &a, nil
+}
+
+func (db *agencyRepository) GetByIDs(ids []string) (agency.Agencies, rest_errors.RestErr) {
+	if len(ids) == 0 {
+		return agency.Agencies{}, nil
+	}
+	result, err := elasticsearch.Client.Search(indexAgencies, elastic.NewIdsQuery().Ids(ids...), "", false)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return agency.Agencies{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get agencies", errors.New("database error"))
+	}
+	return searchResultToAgencies(result), nil
+}
+
+func (db *agencyRepository) Update(id string, updateRequest agency.UpdateRequest) (*agency.Agency, rest_errors.RestErr) {
+	var esUpdate property.EsUpdate
+	for _, field := range updateRequest.Fields {
+		esUpdate.Fields = append(esUpdate.Fields, property.UpdatePropertyRequest{Field: field.Field, Value: field.Value})
+	}
+
+	result, err := elasticsearch.Client.Update(indexAgencies, typeProperty, id, esUpdate)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no agency was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to update agency", errors.New("database error"))
+	}
+
+	var a agency.Agency
+	bytes, _ := result.GetResult.Source.MarshalJSON()
+	json.Unmarshal(bytes, &a)
+	a.ID = result.Id
+	return &a, nil
+}
+
+func (db *agencyRepository) Delete(id string) rest_errors.RestErr {
+	if _, err := elasticsearch.Client.Delete(indexAgencies, typeProperty, id); err != nil {
+		if elastic.IsNotFound(err) {
+			return rest_errors.NewNotFoundErr(fmt.Sprintf("no agency was found with id %s", id))
+		}
+		return rest_errors.NewInternalServerErr("error when trying to delete agency", errors.New("database error"))
+	}
+	return nil
+}
+
+func searchResultToAgencies(result *elastic.SearchResult) agency.Agencies {
+	agencies := agency.Agencies{}
+	for _, hit := range result.Hits.Hits {
+		var a agency.Agency
+		bytes, _ := hit.Source.MarshalJSON()
+		if err := json.Unmarshal(bytes, &a); err != nil {
+			continue
+		}
+		a.ID = hit.Id
+		agencies = append(agencies, a)
+	}
+	return agencies
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/domain/amenity"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+)
+
+const (
+	indexAmenities = "amenity"
+)
+
+type AmenityRepository interface {
+	Create(amenity.Amenity) (*amenity.Amenity, rest_errors.RestErr)
+	Get() (amenity.Amenities, rest_errors.RestErr)
+	GetByID(id string) (*amenity.Amenity, rest_errors.RestErr)
+	GetByIDs(ids []string) (amenity.Amenities, rest_errors.RestErr)
+	Update(id string, updateRequest amenity.UpdateRequest) (*amenity.Amenity, rest_errors.RestErr)
+	Delete(id string) rest_errors.RestErr
+}
+
+type amenityRepository struct {
+}
+
+func NewAmenityRepository() AmenityRepository {
+	return &amenityRepository{}
+}
+
+// Create stores the amenity under its own id so it can not be added twice.
+func (db *amenityRepository) Create(a amenity.Amenity) (*amenity.Amenity, rest_errors.RestErr) {
+	if _, err := elasticsearch.Client.Create(indexAmenities, typeProperty, a.ID, a); err != nil {
+		if elastic.IsConflict(err) {
+			return nil, rest_errors.NewRestError(fmt.Sprintf("amenity %s already exists", a.ID), http.StatusConflict, "conflict", nil)
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to save amenity", errors.New("database error"))
+	}
+	return &a, nil
+}
+
+func (db *amenityRepository) Get() (amenity.Amenities, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Get(indexAmenities, typeProperty, "id.keyword", true)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return amenity.Amenities{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get amenities", errors.New("database error"))
+	}
+	return searchResultToAmenities(result), nil
+}
+
+func (db *amenityRepository) GetByID(id string) (*amenity.Amenity, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.GetByID(indexAmenities, typeProperty, id)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no amenity was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get amenity", errors.New("database error"))
+	}
+
+	var a amenity.Amenity
+	bytes, _ := result.Source.MarshalJSON()
+	if err := json.Unmarshal(bytes, &a); err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to parse database response", errors.New("database error"))
+	}
+	a.ID = result.Id
+	return &a, nil
+}
+
+func (db *amenityRepository) GetByIDs(ids []string) (amenity.Amenities, rest_errors.RestErr) {
+	if len(ids) == 0 {
+		return amenity.Amenities{}, nil
+	}
+	result, err := elasticsearch.Client.Search(indexAmenities, elastic.NewIdsQuery().Ids(ids...), "", false)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return amenity.Amenities{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get amenities", errors.New("database error"))
+	}
+	return searchResultToAmenities(result), nil
+}
+
+func (db *amenityRepository) Update(id string, updateRequest amenity.UpdateRequest) (*amenity.Amenity, rest_errors.RestErr) {
+	var esUpdate property.EsUpdate
+	if updateRequest.Category != nil {
+		esUpdate.Fields = append(esUpdate.Fields, property.UpdatePropertyRequest{Field: "category", Value: *updateRequest.Category})
+	}
+	if updateRequest.Labels != nil {
+		esUpdate.Fields = append(esUpdate.Fields, property.UpdatePropertyRequest{Field: "labels", Value: updateRequest.Labels})
+	}
+
+	result, err := elasticsearch.Client.Update(indexAmenities, typeProperty, id, esUpdate)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no amenity was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to update amenity", errors.New("database error"))
+	}
+
+	var a amenity.Amenity
+	bytes, _ := result.GetResult.Source.MarshalJSON()
+	json.Unmarshal(bytes, &a)
+	a.ID = result.Id
+	return &a, nil
+}
+
+func (db *amenityRepository) Delete(id string) rest_errors.RestErr {
+	if _, err := elasticsearch.Client.Delete(indexAmenities, typeProperty, id); err != nil {
+		if elastic.IsNotFound(err) {
+			return rest_errors.NewNotFoundErr(fmt.Sprintf("no amenity was found with id %s", id))
+		}
+		return rest_errors.NewInternalServerErr("error when trying to delete amenity", errors.New("database error"))
+	}
+	return nil
+}
+
+func searchResultToAmenities(result *elastic.SearchResult) amenity.Amenities {
+	amenities := amenity.Amenities{}
+	for _, hit := range result.Hits.Hits {
+		var a amenity.Amenity
+		bytes, _ := hit.Source.MarshalJSON()
+		if err := json.Unmarshal(bytes, &a); err != nil {
+			continue
+		}
+		a.ID = hit.Id
+		amenities = append(amenities, a)
+	}
+	return amenities
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/domain/audit"
+)
+
+const (
+	indexAudit = "audit"
+)
+
+type AuditRepository interface {
+	Create(audit.Entry) (*audit.Entry, rest_errors.RestErr)
+	Find(field string, id string, q audit.Query) (audit.Entries, rest_errors.RestErr)
+	GetSince(propertyID string, after string) (audit.Entries, rest_errors.RestErr)
+}
+
+type auditRepository struct {
+}
+
+func NewAuditRepository() AuditRepository {
+	return &auditRepository{}
+}
+
+// auditDocument stores the changes as a JSON string: their values mix
+// strings, numbers and objects, which a dynamic mapping can not index.
+type auditDocument struct {
+	audit.Entry
+	Changes string `json:"changes"`
+}
+
+// Create writes the entry under a new id with Create, so entries are only
+// ever added.
+func (db *auditRepository) Create(entry audit.Entry) (*audit.Entry, rest_errors.RestErr) {
+	entry.ID = uuid.New().String()
+	changes, _ := json.Marshal(entry.Changes)
+	if _, err := elasticsearch.Client.Create(indexAudit, typeProperty, entry.ID, auditDocument{Entry: entry, Changes: string(changes)}); err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to save audit entry", errors.New("database error"))
+	}
+	return &entry, nil
+}
+
+// Find returns the entries whose field, property_id or actor.user_id, is
+// id, newest first.
+func (db *auditRepository) Find(field string, id string, q audit.Query) (audit.Entries, rest_errors.RestErr) {
+	query := elastic.NewBoolQuery().Filter(elastic.NewTermQuery(field+".keyword", id))
+	if q.Field != "" {
+		query.Filter(elastic.NewTermQuery("fields.keyword", q.Field))
+	}
+	if q.From != "" || q.To != "" {
+		timestamps := elastic.NewRangeQuery("timestamp")
+		if q.From != "" {
+			timestamps.Gte(q.From)
+		}
+		if q.To != "" {
+			timestamps.Lte(q.To)
+		}
+		query.Filter(timestamps)
+	}
+	return db.search(query)
+}
+
+// GetSince returns the entries of a listing written after the given time,
+// newest first.
+func (db *auditRepository) GetSince(propertyID string, after string) (audit.Entries, rest_errors.RestErr) {
+	return db.search(elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("property_id.keyword", propertyID)).
+		Filter(elastic.NewRangeQuery("timestamp").Gt(after)))
+}
+
+func (db *auditRepository) search(query elastic.Query) (audit.Entries, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Search(indexAudit, query, "timestamp", false)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return audit.Entries{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get audit entries", errors.New("database error"))
+	}
+
+	entries := audit.Entries{}
+	for _, hit := range result.Hits.Hits {
+		var doc auditDocument
+		bytes, _ := hit.Source.MarshalJSON()
+		if err := json.Unmarshal(bytes, &doc); err != nil {
+			continue
+		}
+		entry := doc.Entry
+		if err := json.Unmarshal([]byte(doc.Changes), &entry.Changes); err != nil {
+			continue
+		}
+		entry.ID = hit.Id
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,183 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/domain/complex"
+	"github.com/superbkibbles/realestate_property-api/domain/currency"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+)
+
+const (
+	indexComplexes   = "complex"
+	aggregationUnits = "units"
+)
+
+type ComplexRepository interface {
+	Create(complex.Complex) (*complex.Complex, rest_errors.RestErr)
+	Get(sort string, asc bool) (complex.Complexes, rest_errors.RestErr)
+	GetByID(id string) (*complex.Complex, rest_errors.RestErr)
+	Update(id string, updateRequest complex.UpdateRequest) (*complex.Complex, rest_errors.RestErr)
+	UpdateMedia(id string, media []complex.Media) (*complex.Complex, rest_errors.RestErr)
+	Delete(id string) rest_errors.RestErr
+	UnitStats(id string) (*complex.Stats, rest_errors.RestErr)
+}
+
+type complexRepository struct {
+}
+
+func NewComplexRepository() ComplexRepository {
+	return &complexRepository{}
+}
+
+func (db *complexRepository) Create(c complex.Complex) (*complex.Complex, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Save(indexComplexes, typeProperty, c)
+	if err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to save complex", errors.New("database error"))
+	}
+	c.ID = result.Id
+	return &c, nil
+}
+
+func (db *complexRepository) Get(sort string, asc bool) (complex.Complexes, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Get(indexComplexes, typeProperty, sort, asc)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return complex.Complexes{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get complexes", errors.New("database error"))
+	}
+
+	complexes := complex.Complexes{}
+	for _, hit := range result.Hits.Hits {
+		var c complex.Complex
+		bytes, _ := hit.Source.MarshalJSON()
+		if err := json.Unmarshal(bytes, &c); err != nil {
+			continue
+		}
+		c.ID = hit.Id
+		complexes = append(complexes, c)
+	}
+	return complexes, nil
+}
+
+func (db *complexRepository) GetByID(id string) (*complex.Complex, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.GetByID(indexComplexes, typeProperty, id)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no complex was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get complex", errors.New("database error"))
+	}
+
+	var c complex.Complex
+	bytes, _ := result.Source.MarshalJSON()
+	if err := json.Unmarshal(bytes, &c); err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to parse database response", errors.New("database error"))
+	}
+	c.ID = result.Id
+	return &c, nil
+}
+
+func (db *complexRepository) Update(id string, updateRequest complex.UpdateRequest) (*complex.Complex, rest_errors.RestErr) {
+	var esUpdate property.EsUpdate
+	for _, field := range updateRequest.Fields {
+		esUpdate.Fields = append(esUpdate.Fields, property.UpdatePropertyRequest{Field: field.Field, Value: field.Value})
+	}
+	return db.update(id, esUpdate)
+}
+
+func (db *complexRepository) UpdateMedia(id string, media []complex.Media) (*complex.Complex, rest_errors.RestErr) {
+	return db.update(id, property.EsUpdate{
+		Fields: []property.UpdatePropertyRequest{{Field: "media", Value: media}},
+	})
+}
+
+func (db *complexRepository) update(id string, esUpdate property.EsUpdate) (*complex.Complex, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Update(indexComplexes, typeProperty, id, esUpdate)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no complex was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to update complex", errors.New("database error"))
+	}
+
+	var c complex.Complex
+	bytes, _ := result.GetResult.Source.MarshalJSON()
+	json.Unmarshal(bytes, &c)
+	c.ID = result.Id
+	return &c, nil
+}
+
+func (db *complexRepository) Delete(id string) rest_errors.RestErr {
+	if _, err := elasticsearch.Client.Delete(indexComplexes, typeProperty, id); err != nil {
+		if elastic.IsNotFound(err) {
+			return rest_errors.NewNotFoundErr(fmt.Sprintf("no complex was found with id %s", id))
+		}
+		return rest_errors.NewInternalServerErr("error when trying to delete complex", errors.New("database error"))
+	}
+	return nil
+}
+
+// UnitStats aggregates the properties of a complex. Prices are base amounts
+// and are returned without a currency.
+func (db *complexRepository) UnitStats(id string) (*complex.Stats, rest_errors.RestErr) {
+	query := elastic.NewTermQuery("complex_id.keyword", id)
+	units := elastic.NewFilterAggregation().Filter(elastic.NewMatchAllQuery()).
+		SubAggregation("price", elastic.NewStatsAggregation().Field("base_price")).
+		SubAggregation("available", elastic.NewFilterAggregation().Filter(elastic.NewBoolQuery().
+			Filter(elastic.NewTermQuery("status.keyword", property.STATUS_ACTIVE)).
+			MustNot(elastic.NewTermQuery("is_sold", true)))).
+		SubAggregation("sold", elastic.NewFilterAggregation().Filter(elastic.NewTermQuery("is_sold", true))).
+		SubAggregation("for_rent", elastic.NewFilterAggregation().Filter(elastic.NewTermQuery("for_rent", true))).
+		SubAggregation("bedrooms", elastic.NewTermsAggregation().Field("bedrooms").Size(20)).
+		SubAggregation("types", elastic.NewTermsAggregation().Field("property_type.keyword").Size(20))
+
+	stats := complex.Stats{
+		ComplexID: id,
+		Bedrooms:  map[string]int64{},
+		Types:     map[string]int64{},
+	}
+	result, err := elasticsearch.Client.Aggregate(indexProperties, query, aggregationUnits, units)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return &stats, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get complex stats", errors.New("database error"))
+	}
+
+	bucket, found := result.Aggregations.Filter(aggregationUnits)
+	if !found {
+		return &stats, nil
+	}
+	stats.Units = bucket.DocCount
+	if price, ok := bucket.Stats("price"); ok && price.Min != nil && price.Max != nil {
+		stats.MinPrice = &currency.Money{Amount: int64(*price.Min)}
+		stats.MaxPrice = &currency.Money{Amount: int64(*price.Max)}
+	}
+	if available, ok := bucket.Filter("available"); ok {
+		stats.Available = available.DocCount
+	}
+	if sold, ok := bucket.Filter("sold"); ok {
+		stats.Sold = sold.DocCount
+	}
+	if forRent, ok := bucket.Filter("for_rent"); ok {
+		stats.ForRent = forRent.DocCount
+	}
+	if bedrooms, ok := bucket.Terms("bedrooms"); ok {
+		for _, b := range bedrooms.Buckets {
+			stats.Bedrooms[fmt.Sprint(b.Key)] = b.DocCount
+		}
+	}
+	if types, ok := bucket.Terms("types"); ok {
+		for _, b := range types.Buckets {
+			stats.Types[fmt.Sprint(b.Key)] = b.DocCount
+		}
+	}
+	return &stats, nil
+}
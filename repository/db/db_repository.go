@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 
+	elastic "github.com/olivere/elastic/v7"
 	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
 	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
 	"github.com/superbkibbles/realestate_property-api/domain/property"
@@ -17,12 +18,24 @@ const (
 	indexProperties        = "property"
 	indexTranslateProperty = "translate_property"
 	typeProperty           = "_doc"
+	aggregationFacets      = "facets"
 )
 
+// facetFields are the fields properties can be counted by, with the
+// Elasticsearch field holding their exact values.
+var facetFields = map[string]string{
+	"amenities":     "amenities.keyword",
+	"category":      "category.keyword",
+	"city":          "city.keyword",
+	"property_type": "property_type.keyword",
+	"bedrooms":      "bedrooms",
+}
+
 type DbRepository interface {
 	Create(property.Property) (*property.Property, rest_errors.RestErr)
 	Get(sort string, asc bool) (property.Properties, rest_errors.RestErr)
 	GetByID(string) (*property.Property, rest_errors.RestErr)
+	GetByIDs(ids []string) (property.Properties, rest_errors.RestErr)
 	Search(query query.EsQuery, sort string, asc bool) (property.Properties, rest_errors.RestErr)
 	Update(id string, updateRequest property.EsUpdate) (*property.Property, rest_errors.RestErr)
 	UploadMedia(visuals []property.Visual, videos []property.Video, propertyID string) rest_errors.RestErr
@@ -34,6 +47,23 @@ type DbRepository interface {
 	GetAllTranslated(local string) (property.TranslateProperties, rest_errors.RestErr)
 	GetActiveLocal(local string) (property.TranslateProperties, rest_errors.RestErr)
 	GetDeactiveLocal(local string) (property.TranslateProperties, rest_errors.RestErr)
+	IncrementViewers(id string) rest_errors.RestErr
+	UpdateBasePrices(rates map[string]float64) rest_errors.RestErr
+	BackfillBasePrices(rates map[string]float64) (int64, rest_errors.RestErr)
+	SetComplexName(complexID string, name string) rest_errors.RestErr
+	Facets(query query.EsQuery, fields []string) (query.Facets, rest_errors.RestErr)
+	CountByAmenity(amenityID string) (int64, rest_errors.RestErr)
+	DuplicateCandidates(p property.Property) (property.Properties, rest_errors.RestErr)
+	Similar(p property.Property, size int) (property.Properties, rest_errors.RestErr)
+	AddOpenHouse(id string, openHouse property.OpenHouse, now string) rest_errors.RestErr
+	RemoveOpenHouse(id string, openHouseID string) (bool, rest_errors.RestErr)
+	CountRSVP(id string, openHouseID string, guests int64) (bool, rest_errors.RestErr)
+	GetWithoutExpiry() (property.Properties, rest_errors.RestErr)
+	GetExpiring(until string) (property.Properties, rest_errors.RestErr)
+	ClaimExpiryWarning(id string, at string) (bool, rest_errors.RestErr)
+	Expire(id string, at string) (bool, rest_errors.RestErr)
+	GetDueSchedule(field string, now string) (property.Properties, rest_errors.RestErr)
+	ClaimSchedule(id string, field string, now string) (bool, rest_errors.RestErr)
 }
 
 type dbRepository struct {
@@ -235,6 +265,24 @@ func (db *dbRepository) GetByID(id string) (*property.Property, rest_errors.Rest
 	return &property, nil
 }
 
+func (db *dbRepository) GetByIDs(ids []string) (property.Properties, rest_errors.RestErr) {
+	if len(ids) == 0 {
+		return property.Properties{}, nil
+	}
+	result, err := elasticsearch.Client.Search(indexProperties, elastic.NewIdsQuery().Ids(ids...), "", false)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return property.Properties{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get properties", errors.New("database error"))
+	}
+	properties, restErr := helpers.SearchResultToProperties(result)
+	if restErr != nil {
+		return property.Properties{}, nil
+	}
+	return properties, nil
+}
+
 func (db *dbRepository) Search(query query.EsQuery, sort string, asc bool) (property.Properties, rest_errors.RestErr) {
 	result, err := elasticsearch.Client.Search(indexProperties, query.Build(), sort, asc)
 	if err != nil {
@@ -317,3 +365,371 @@ func (db *dbRepository) GetDeactiveLocal(local string) (property.TranslateProper
 
 	return properties, nil
 }
+
+// IncrementViewers bumps the counter inside Elasticsearch so concurrent views
+// don't overwrite each other.
+func (db *dbRepository) IncrementViewers(id string) rest_errors.RestErr {
+	script := elastic.NewScript("ctx._source.Viewers = (ctx._source.Viewers == null ? 0 : ctx._source.Viewers) + params.count").
+		Param("count", 1)
+	if _, err := elasticsearch.Client.UpdateScript(indexProperties, typeProperty, id, script); err != nil {
+		if strings.Contains(err.Error(), "404") {
+			return rest_errors.NewNotFoundErr(fmt.Sprintf("no Property was found with id %s", id))
+		}
+		return rest_errors.NewInternalServerErr("error when trying to count view", errors.New("database error"))
+	}
+	return nil
+}
+
+// UpdateBasePrices recomputes the base currency amounts of every property from
+// its own price and currency after the exchange rates changed. Amounts are
+// divided as doubles and rounded like currency.Convert and NormalizeRent, as
+// a whole rate such as 1 reaches the script as an integer.
+func (db *dbRepository) UpdateBasePrices(rates map[string]float64) rest_errors.RestErr {
+	_, err := db.updateBasePrices(elastic.NewMatchAllQuery(), rates)
+	return err
+}
+
+// BackfillBasePrices sets the base currency amounts of the properties stored
+// without them and returns how many were updated.
+func (db *dbRepository) BackfillBasePrices(rates map[string]float64) (int64, rest_errors.RestErr) {
+	query := elastic.NewBoolQuery().
+		Filter(elastic.NewExistsQuery("price")).
+		MustNot(elastic.NewExistsQuery("base_price"))
+	return db.updateBasePrices(query, rates)
+}
+
+func (db *dbRepository) updateBasePrices(query elastic.Query, rates map[string]float64) (int64, rest_errors.RestErr) {
+	script := elastic.NewScript(`
+		def currency = ctx._source.currency == null ? '' : ctx._source.currency.toUpperCase();
+		def rate = params.rates[currency];
+		if (rate == null || ctx._source.price == null) {
+			ctx.op = 'noop';
+		} else {
+			long basePrice = Math.round(((Number) ctx._source.price).doubleValue() / ((Number) rate).doubleValue());
+			ctx._source.base_price = basePrice;
+			def terms = ctx._source.rental_terms;
+			if (terms != null && terms.period != null && params.periods[terms.period] != null) {
+				terms.monthly_base_rent = Math.round(basePrice * ((Number) params.periods[terms.period]).doubleValue());
+			}
+		}`).Param("rates", rates).Param("periods", property.PeriodsPerMonth())
+	result, err := elasticsearch.Client.UpdateByQuery(indexProperties, query, script)
+	if err != nil {
+		return 0, rest_errors.NewInternalServerErr("error when trying to update base prices", errors.New("database error"))
+	}
+	return result.Updated, nil
+}
+
+// SetComplexName copies a renamed complex onto all of its properties.
+func (db *dbRepository) SetComplexName(complexID string, name string) rest_errors.RestErr {
+	query := elastic.NewTermQuery("complex_id.keyword", complexID)
+	script := elastic.NewScript("ctx._source.complex_name = params.name").Param("name", name)
+	if _, err := elasticsearch.Client.UpdateByQuery(indexProperties, query, script); err != nil {
+		return rest_errors.NewInternalServerErr("error when trying to update complex name", errors.New("database error"))
+	}
+	return nil
+}
+
+// CountByAmenity counts every property using the amenity, including the
+// ones not published yet, which public queries leave out.
+func (db *dbRepository) CountByAmenity(amenityID string) (int64, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.SearchTop(indexProperties, elastic.NewTermQuery(facetFields["amenities"], amenityID), 0)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, rest_errors.NewInternalServerErr("error when trying to count properties", errors.New("database error"))
+	}
+	return result.TotalHits(), nil
+}
+
+// Facets counts the properties matching query per value of each field.
+func (db *dbRepository) Facets(q query.EsQuery, fields []string) (query.Facets, rest_errors.RestErr) {
+	aggregation := elastic.NewFilterAggregation().Filter(elastic.NewMatchAllQuery())
+	for _, field := range fields {
+		esField, ok := facetFields[field]
+		if !ok {
+			return nil, rest_errors.NewBadRequestErr(fmt.Sprintf("can not facet on %s", field))
+		}
+		aggregation.SubAggregation(field, elastic.NewTermsAggregation().Field(esField).Size(100))
+	}
+
+	facets := query.Facets{}
+	result, err := elasticsearch.Client.Aggregate(indexProperties, q.Build(), aggregationFacets, aggregation)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return facets, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get facets", errors.New("database error"))
+	}
+	bucket, found := result.Aggregations.Filter(aggregationFacets)
+	if !found {
+		return facets, nil
+	}
+	for _, field := range fields {
+		facets[field] = []query.Facet{}
+		terms, ok := bucket.Terms(field)
+		if !ok {
+			continue
+		}
+		for _, b := range terms.Buckets {
+			facets[field] = append(facets[field], query.Facet{Value: fmt.Sprint(b.Key), Count: b.DocCount})
+		}
+	}
+	return facets, nil
+}
+
+// DuplicateCandidates returns the active listings of the same category that
+// share a complex, city or photo with p. Scoring them is left to the caller.
+func (db *dbRepository) DuplicateCandidates(p property.Property) (property.Properties, rest_errors.RestErr) {
+	q := elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("category.keyword", p.Category)).
+		Filter(elastic.NewTermQuery("status.keyword", property.STATUS_ACTIVE)).
+		MinimumNumberShouldMatch(1)
+	if p.ID != "" {
+		q.MustNot(elastic.NewIdsQuery().Ids(p.ID))
+	}
+	if p.ComplexID != "" {
+		q.Should(elastic.NewTermQuery("complex_id.keyword", p.ComplexID))
+	}
+	if p.City != "" {
+		q.Should(elastic.NewMatchPhraseQuery("city", p.City))
+	}
+	var hashes []interface{}
+	for _, v := range p.Visuals {
+		if v.Hash != "" {
+			hashes = append(hashes, v.Hash)
+		}
+	}
+	if len(hashes) > 0 {
+		q.Should(elastic.NewTermsQuery("visuals.hash.keyword", hashes...))
+	}
+	if p.ComplexID == "" && p.City == "" && len(hashes) == 0 {
+		return property.Properties{}, nil
+	}
+
+	result, err := elasticsearch.Client.Search(indexProperties, q, "", false)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return property.Properties{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to find duplicate candidates", errors.New("database error"))
+	}
+	properties, restErr := helpers.SearchResultToProperties(result)
+	if restErr != nil {
+		return property.Properties{}, nil
+	}
+	return properties, nil
+}
+
+// distanceScript scores a listing from 1 at the origin down to 0.5 at
+// params.scale kilometers. GPS is stored as strings, so it is parsed here.
+const distanceScript = `
+	if (doc['gps.lat.keyword'].size() == 0 || doc['gps.long.keyword'].size() == 0) {
+		return 0;
+	}
+	try {
+		double lat = Double.parseDouble(doc['gps.lat.keyword'].value);
+		double lon = Double.parseDouble(doc['gps.long.keyword'].value);
+		double dLat = Math.toRadians(lat - params.lat);
+		double dLon = Math.toRadians(lon - params.lon);
+		double h = Math.pow(Math.sin(dLat / 2), 2) + Math.cos(Math.toRadians(params.lat)) * Math.cos(Math.toRadians(lat)) * Math.pow(Math.sin(dLon / 2), 2);
+		double km = 12742 * Math.asin(Math.sqrt(h));
+		return params.scale / (params.scale + km);
+	} catch (NumberFormatException e) {
+		return 0;
+	}`
+
+// Similar finds active, unsold listings of the same category and offer within
+// the price band of p. Text similarity from more_like_this is added to how
+// close each one is in price, size, bedrooms and distance.
+func (db *dbRepository) Similar(p property.Property, size int) (property.Properties, rest_errors.RestErr) {
+	filter := elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("category.keyword", p.Category)).
+		Filter(elastic.NewTermQuery("status.keyword", property.STATUS_ACTIVE)).
+		MustNot(elastic.NewTermQuery("is_sold", true)).
+		MustNot(elastic.NewIdsQuery().Ids(p.ID)).
+		Should(elastic.NewMoreLikeThisQuery().
+			Field("title", "description", "location", "city", "property_type").
+			LikeItems(elastic.NewMoreLikeThisQueryItem().Index(indexProperties).Id(p.ID)).
+			MinTermFreq(1).
+			MinDocFreq(1))
+	if p.ForRent {
+		filter.Filter(elastic.NewTermQuery("for_rent", true))
+	} else {
+		filter.MustNot(elastic.NewTermQuery("for_rent", true))
+	}
+	if p.BasePrice > 0 {
+		filter.Filter(elastic.NewRangeQuery("base_price").Gte(p.BasePrice * 6 / 10).Lte(p.BasePrice * 14 / 10))
+	}
+
+	scored := elastic.NewFunctionScoreQuery().Query(filter).ScoreMode("sum").BoostMode("sum")
+	if p.BasePrice > 0 {
+		scored.AddScoreFunc(elastic.NewGaussDecayFunction().FieldName("base_price").Origin(p.BasePrice).Scale(p.BasePrice / 5).Weight(1))
+	}
+	if p.Space > 0 {
+		scored.AddScoreFunc(elastic.NewGaussDecayFunction().FieldName("space").Origin(p.Space).Scale(p.Space / 5).Weight(1))
+	}
+	if p.Bedrooms > 0 {
+		scored.AddScoreFunc(elastic.NewGaussDecayFunction().FieldName("bedrooms").Origin(p.Bedrooms).Scale(1).Weight(1))
+	}
+	if lat, lon, ok := p.GPS.Coordinates(); ok {
+		script := elastic.NewScript(distanceScript).Params(map[string]interface{}{"lat": lat, "lon": lon, "scale": 5.0})
+		scored.AddScoreFunc(elastic.NewScriptFunction(script).Weight(2))
+	}
+
+	result, err := elasticsearch.Client.SearchTop(indexProperties, scored, size)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return property.Properties{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to find similar properties", errors.New("database error"))
+	}
+	properties, restErr := helpers.SearchResultToProperties(result)
+	if restErr != nil {
+		return property.Properties{}, nil
+	}
+	return properties, nil
+}
+
+// AddOpenHouse appends an open house and drops those that ended before now.
+// Open houses are changed by scripts so concurrent RSVPs are not lost.
+func (db *dbRepository) AddOpenHouse(id string, openHouse property.OpenHouse, now string) rest_errors.RestErr {
+	script := elastic.NewScript(`
+		def upcoming = new ArrayList();
+		if (ctx._source.open_houses != null) {
+			for (o in ctx._source.open_houses) {
+				if (o.end.compareTo(params.now) > 0) {
+					upcoming.add(o);
+				}
+			}
+		}
+		upcoming.add(params.open_house);
+		ctx._source.open_houses = upcoming;`).
+		Param("open_house", openHouse).
+		Param("now", now)
+	_, err := db.scriptUpdate(id, script)
+	return err
+}
+
+// RemoveOpenHouse reports false when the property had no such open house.
+func (db *dbRepository) RemoveOpenHouse(id string, openHouseID string) (bool, rest_errors.RestErr) {
+	script := elastic.NewScript(`
+		if (ctx._source.open_houses == null || !ctx._source.open_houses.removeIf(o -> o.id == params.id)) {
+			ctx.op = 'noop';
+		}`).
+		Param("id", openHouseID)
+	return db.scriptUpdate(id, script)
+}
+
+// CountRSVP adds guests to the RSVP count of an open house, or removes them
+// when negative. It reports false when the open house is gone or adding them
+// would exceed its capacity.
+func (db *dbRepository) CountRSVP(id string, openHouseID string, guests int64) (bool, rest_errors.RestErr) {
+	script := elastic.NewScript(`
+		ctx.op = 'noop';
+		if (ctx._source.open_houses != null) {
+			for (o in ctx._source.open_houses) {
+				if (o.id == params.id) {
+					def count = o.rsvp_count + params.guests;
+					if (params.guests < 0 || count <= o.capacity) {
+						o.rsvp_count = Math.max(0, count);
+						ctx.op = 'index';
+					}
+				}
+			}
+		}`).
+		Param("id", openHouseID).
+		Param("guests", guests)
+	return db.scriptUpdate(id, script)
+}
+
+// scriptUpdate runs a script on a property and reports whether it changed it.
+func (db *dbRepository) scriptUpdate(id string, script *elastic.Script) (bool, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.UpdateScript(indexProperties, typeProperty, id, script)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return false, rest_errors.NewNotFoundErr(fmt.Sprintf("no Property was found with id %s", id))
+		}
+		return false, rest_errors.NewInternalServerErr("error when trying to update property", errors.New("database error"))
+	}
+	return result.Result != resultNoop, nil
+}
+
+// GetWithoutExpiry returns active listings that have no expiry date yet.
+func (db *dbRepository) GetWithoutExpiry() (property.Properties, rest_errors.RestErr) {
+	query := elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("status.keyword", property.STATUS_ACTIVE)).
+		MustNot(elastic.NewExistsQuery("expires_at"))
+	return db.search(query, "", false)
+}
+
+// GetExpiring returns active listings expiring at or before until, expired
+// ones included, soonest first.
+func (db *dbRepository) GetExpiring(until string) (property.Properties, rest_errors.RestErr) {
+	query := elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("status.keyword", property.STATUS_ACTIVE)).
+		Filter(elastic.NewRangeQuery("expires_at").Lte(until))
+	return db.search(query, "expires_at", true)
+}
+
+// ClaimExpiryWarning marks a listing as warned. Only the first caller gets
+// true, so with several instances running the agency is warned once.
+func (db *dbRepository) ClaimExpiryWarning(id string, at string) (bool, rest_errors.RestErr) {
+	script := elastic.NewScript(`
+		if (ctx._source.expiry_warned_at != null) {
+			ctx.op = 'noop';
+		} else {
+			ctx._source.expiry_warned_at = params.at;
+		}`).
+		Param("at", at)
+	return db.scriptUpdate(id, script)
+}
+
+// Expire deactivates a listing that is still active and past its expiry
+// date, and drops its open houses. Only the first caller gets true.
+func (db *dbRepository) Expire(id string, at string) (bool, rest_errors.RestErr) {
+	script := elastic.NewScript(`
+		if (ctx._source.status != params.active || ctx._source.expires_at == null || ctx._source.expires_at.compareTo(params.at) > 0) {
+			ctx.op = 'noop';
+		} else {
+			ctx._source.status = params.deactive;
+			ctx._source.expired_at = params.at;
+			ctx._source.open_houses = [];
+		}`).
+		Param("active", property.STATUS_ACTIVE).
+		Param("deactive", property.STATUS_DEACTIVE).
+		Param("at", at)
+	return db.scriptUpdate(id, script)
+}
+
+// GetDueSchedule returns listings whose field, publish_at or unpublish_at, is
+// at or before now.
+func (db *dbRepository) GetDueSchedule(field string, now string) (property.Properties, rest_errors.RestErr) {
+	return db.search(elastic.NewRangeQuery(field).Lte(now), field, true)
+}
+
+// ClaimSchedule clears a due publish_at or unpublish_at. Only the first
+// caller gets true, so one instance applies the transition.
+func (db *dbRepository) ClaimSchedule(id string, field string, now string) (bool, rest_errors.RestErr) {
+	script := elastic.NewScript(`
+		def at = ctx._source[params.field];
+		if (at == null || at.compareTo(params.now) > 0) {
+			ctx.op = 'noop';
+		} else {
+			ctx._source.remove(params.field);
+		}`).
+		Param("field", field).
+		Param("now", now)
+	return db.scriptUpdate(id, script)
+}
+
+func (db *dbRepository) search(query elastic.Query, sort string, asc bool) (property.Properties, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Search(indexProperties, query, sort, asc)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return property.Properties{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to search documents", errors.New("database error"))
+	}
+	return helpers.SearchResultToProperties(result)
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/domain/duplicate"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+)
+
+const (
+	indexDuplicates = "duplicate"
+)
+
+type DuplicateRepository interface {
+	Create(duplicate.Suspect) (bool, rest_errors.RestErr)
+	Get(status string) (duplicate.Suspects, rest_errors.RestErr)
+	GetByID(id string) (*duplicate.Suspect, rest_errors.RestErr)
+	Resolve(id string, status string, keptID string, resolvedBy string, resolvedAt string) (*duplicate.Suspect, rest_errors.RestErr)
+}
+
+type duplicateRepository struct {
+}
+
+func NewDuplicateRepository() DuplicateRepository {
+	return &duplicateRepository{}
+}
+
+// Create records a suspect pair and reports whether it is new. A pair that was
+// already recorded, whatever its status, is left untouched.
+func (db *duplicateRepository) Create(s duplicate.Suspect) (bool, rest_errors.RestErr) {
+	if _, err := elasticsearch.Client.Create(indexDuplicates, typeProperty, s.ID, s); err != nil {
+		if elastic.IsConflict(err) {
+			return false, nil
+		}
+		return false, rest_errors.NewInternalServerErr("error when trying to save duplicate", errors.New("database error"))
+	}
+	return true, nil
+}
+
+func (db *duplicateRepository) Get(status string) (duplicate.Suspects, rest_errors.RestErr) {
+	var query elastic.Query = elastic.NewMatchAllQuery()
+	if status != "" {
+		query = elastic.NewTermQuery("status.keyword", status)
+	}
+	result, err := elasticsearch.Client.Search(indexDuplicates, query, "score", false)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return duplicate.Suspects{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get duplicates", errors.New("database error"))
+	}
+
+	suspects := duplicate.Suspects{}
+	for _, hit := range result.Hits.Hits {
+		var s duplicate.Suspect
+		bytes, _ := hit.Source.MarshalJSON()
+		if err := json.Unmarshal(bytes, &s); err != nil {
+			continue
+		}
+		s.ID = hit.Id
+		suspects = append(suspects, s)
+	}
+	return suspects, nil
+}
+
+func (db *duplicateRepository) GetByID(id string) (*duplicate.Suspect, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.GetByID(indexDuplicates, typeProperty, id)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no duplicate was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get duplicate", errors.New("database error"))
+	}
+
+	var s duplicate.Suspect
+	bytes, _ := result.Source.MarshalJSON()
+	if err := json.Unmarshal(bytes, &s); err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to parse database response", errors.New("database error"))
+	}
+	s.ID = result.Id
+	return &s, nil
+}
+
+func (db *duplicateRepository) Resolve(id string, status string, keptID string, resolvedBy string, resolvedAt string) (*duplicate.Suspect, rest_errors.RestErr) {
+	esUpdate := property.EsUpdate{Fields: []property.UpdatePropertyRequest{
+		{Field: "status", Value: status},
+		{Field: "kept_id", Value: keptID},
+		{Field: "resolved_by", Value: resolvedBy},
+		{Field: "resolved_at", Value: resolvedAt},
+	}}
+	result, err := elasticsearch.Client.Update(indexDuplicates, typeProperty, id, esUpdate)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no duplicate was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to update duplicate", errors.New("database error"))
+	}
+
+	var s duplicate.Suspect
+	bytes, _ := result.GetResult.Source.MarshalJSON()
+	json.Unmarshal(bytes, &s)
+	s.ID = result.Id
+	return &s, nil
+}
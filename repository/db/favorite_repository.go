@@ -0,0 +1,145 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/domain/favorite"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+)
+
+const (
+	indexFavorites       = "favorite"
+	aggregationFavorites = "favorites"
+)
+
+type FavoriteRepository interface {
+	Create(favorite.Favorite) (bool, rest_errors.RestErr)
+	GetByID(id string) (*favorite.Favorite, rest_errors.RestErr)
+	GetByUser(userID string) (favorite.Favorites, rest_errors.RestErr)
+	GetByProperty(propertyID string) (favorite.Favorites, rest_errors.RestErr)
+	SetLastState(id string, price int64, currency string, sold bool) rest_errors.RestErr
+	Delete(id string) rest_errors.RestErr
+	Count(field string, id string) (*favorite.Counts, rest_errors.RestErr)
+}
+
+type favoriteRepository struct {
+}
+
+func NewFavoriteRepository() FavoriteRepository {
+	return &favoriteRepository{}
+}
+
+// Create stores the favorite under its user and property so it exists once,
+// and reports whether it is new.
+func (db *favoriteRepository) Create(f favorite.Favorite) (bool, rest_errors.RestErr) {
+	if _, err := elasticsearch.Client.Create(indexFavorites, typeProperty, f.ID, f); err != nil {
+		if elastic.IsConflict(err) {
+			return false, nil
+		}
+		return false, rest_errors.NewInternalServerErr("error when trying to save favorite", errors.New("database error"))
+	}
+	return true, nil
+}
+
+func (db *favoriteRepository) GetByID(id string) (*favorite.Favorite, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.GetByID(indexFavorites, typeProperty, id)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no favorite was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get favorite", errors.New("database error"))
+	}
+
+	var f favorite.Favorite
+	bytes, _ := result.Source.MarshalJSON()
+	if err := json.Unmarshal(bytes, &f); err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to parse database response", errors.New("database error"))
+	}
+	f.ID = result.Id
+	return &f, nil
+}
+
+func (db *favoriteRepository) GetByUser(userID string) (favorite.Favorites, rest_errors.RestErr) {
+	return db.search(elastic.NewTermQuery("user_id.keyword", userID))
+}
+
+func (db *favoriteRepository) GetByProperty(propertyID string) (favorite.Favorites, rest_errors.RestErr) {
+	return db.search(elastic.NewTermQuery("property_id.keyword", propertyID))
+}
+
+func (db *favoriteRepository) search(query elastic.Query) (favorite.Favorites, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Search(indexFavorites, query, "", false)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return favorite.Favorites{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get favorites", errors.New("database error"))
+	}
+
+	favorites := favorite.Favorites{}
+	for _, hit := range result.Hits.Hits {
+		var f favorite.Favorite
+		bytes, _ := hit.Source.MarshalJSON()
+		if err := json.Unmarshal(bytes, &f); err != nil {
+			continue
+		}
+		f.ID = hit.Id
+		favorites = append(favorites, f)
+	}
+	return favorites, nil
+}
+
+func (db *favoriteRepository) SetLastState(id string, price int64, currency string, sold bool) rest_errors.RestErr {
+	esUpdate := property.EsUpdate{Fields: []property.UpdatePropertyRequest{
+		{Field: "last_price", Value: price},
+		{Field: "last_currency", Value: currency},
+		{Field: "last_sold", Value: sold},
+	}}
+	if _, err := elasticsearch.Client.Update(indexFavorites, typeProperty, id, esUpdate); err != nil {
+		if elastic.IsNotFound(err) {
+			return rest_errors.NewNotFoundErr(fmt.Sprintf("no favorite was found with id %s", id))
+		}
+		return rest_errors.NewInternalServerErr("error when trying to update favorite", errors.New("database error"))
+	}
+	return nil
+}
+
+func (db *favoriteRepository) Delete(id string) rest_errors.RestErr {
+	if _, err := elasticsearch.Client.Delete(indexFavorites, typeProperty, id); err != nil {
+		if elastic.IsNotFound(err) {
+			return rest_errors.NewNotFoundErr(fmt.Sprintf("no favorite was found with id %s", id))
+		}
+		return rest_errors.NewInternalServerErr("error when trying to delete favorite", errors.New("database error"))
+	}
+	return nil
+}
+
+// Count counts favorites per listing where field, property_id or agency_id,
+// equals id.
+func (db *favoriteRepository) Count(field string, id string) (*favorite.Counts, rest_errors.RestErr) {
+	query := elastic.NewTermQuery(field+".keyword", id)
+	aggregation := elastic.NewTermsAggregation().Field("property_id.keyword").Size(1000)
+
+	counts := favorite.Counts{ID: id, Properties: map[string]int64{}}
+	result, err := elasticsearch.Client.Aggregate(indexFavorites, query, aggregationFavorites, aggregation)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return &counts, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to count favorites", errors.New("database error"))
+	}
+	if result.Hits != nil {
+		counts.Total = result.TotalHits()
+	}
+	if terms, ok := result.Aggregations.Terms(aggregationFavorites); ok {
+		for _, b := range terms.Buckets {
+			counts.Properties[fmt.Sprint(b.Key)] = b.DocCount
+		}
+	}
+	return &counts, nil
+}
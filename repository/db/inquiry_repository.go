@@ -0,0 +1,163 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/domain/inquiry"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+)
+
+const (
+	indexInquiries   = "inquiry"
+	aggregationLeads = "leads"
+)
+
+type InquiryRepository interface {
+	Create(inquiry.Inquiry) (*inquiry.Inquiry, rest_errors.RestErr)
+	GetByID(id string) (*inquiry.Inquiry, rest_errors.RestErr)
+	GetByAgency(agencyID string, status string, propertyID string) (inquiry.Inquiries, rest_errors.RestErr)
+	Update(id string, esUpdate property.EsUpdate) (*inquiry.Inquiry, rest_errors.RestErr)
+	CountSince(phoneDigits string, since string) (int64, rest_errors.RestErr)
+	LeadCounts(agencyID string) (*inquiry.LeadCounts, rest_errors.RestErr)
+}
+
+type inquiryRepository struct {
+}
+
+func NewInquiryRepository() InquiryRepository {
+	return &inquiryRepository{}
+}
+
+func (db *inquiryRepository) Create(i inquiry.Inquiry) (*inquiry.Inquiry, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Save(indexInquiries, typeProperty, i)
+	if err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to save inquiry", errors.New("database error"))
+	}
+	i.ID = result.Id
+	return &i, nil
+}
+
+func (db *inquiryRepository) GetByID(id string) (*inquiry.Inquiry, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.GetByID(indexInquiries, typeProperty, id)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no inquiry was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get inquiry", errors.New("database error"))
+	}
+
+	var i inquiry.Inquiry
+	bytes, _ := result.Source.MarshalJSON()
+	if err := json.Unmarshal(bytes, &i); err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to parse database response", errors.New("database error"))
+	}
+	i.ID = result.Id
+	return &i, nil
+}
+
+// GetByAgency returns the newest inquiries first, optionally only those with
+// status or about propertyID.
+func (db *inquiryRepository) GetByAgency(agencyID string, status string, propertyID string) (inquiry.Inquiries, rest_errors.RestErr) {
+	query := elastic.NewBoolQuery().Filter(elastic.NewTermQuery("agency_id.keyword", agencyID))
+	if status != "" {
+		query.Filter(elastic.NewTermQuery("status.keyword", status))
+	}
+	if propertyID != "" {
+		query.Filter(elastic.NewTermQuery("property_id.keyword", propertyID))
+	}
+	result, err := elasticsearch.Client.Search(indexInquiries, query, "date_created", false)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return inquiry.Inquiries{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get inquiries", errors.New("database error"))
+	}
+
+	inquiries := inquiry.Inquiries{}
+	for _, hit := range result.Hits.Hits {
+		var i inquiry.Inquiry
+		bytes, _ := hit.Source.MarshalJSON()
+		if err := json.Unmarshal(bytes, &i); err != nil {
+			continue
+		}
+		i.ID = hit.Id
+		inquiries = append(inquiries, i)
+	}
+	return inquiries, nil
+}
+
+func (db *inquiryRepository) Update(id string, esUpdate property.EsUpdate) (*inquiry.Inquiry, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Update(indexInquiries, typeProperty, id, esUpdate)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no inquiry was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to update inquiry", errors.New("database error"))
+	}
+
+	var i inquiry.Inquiry
+	bytes, _ := result.GetResult.Source.MarshalJSON()
+	json.Unmarshal(bytes, &i)
+	i.ID = result.Id
+	return &i, nil
+}
+
+// CountSince counts the inquiries sent from a phone number since an ISO date.
+func (db *inquiryRepository) CountSince(phoneDigits string, since string) (int64, rest_errors.RestErr) {
+	query := elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("phone_digits.keyword", phoneDigits)).
+		Filter(elastic.NewRangeQuery("date_created").Gte(since))
+	aggregation := elastic.NewFilterAggregation().Filter(elastic.NewMatchAllQuery())
+
+	result, err := elasticsearch.Client.Aggregate(indexInquiries, query, aggregationLeads, aggregation)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, rest_errors.NewInternalServerErr("error when trying to count inquiries", errors.New("database error"))
+	}
+	bucket, found := result.Aggregations.Filter(aggregationLeads)
+	if !found {
+		return 0, nil
+	}
+	return bucket.DocCount, nil
+}
+
+// LeadCounts counts the inquiries of an agency per listing and status. Spam
+// is left out.
+func (db *inquiryRepository) LeadCounts(agencyID string) (*inquiry.LeadCounts, rest_errors.RestErr) {
+	query := elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("agency_id.keyword", agencyID)).
+		MustNot(elastic.NewTermQuery("status.keyword", inquiry.STATUS_SPAM))
+	aggregation := elastic.NewTermsAggregation().Field("property_id.keyword").Size(1000).
+		SubAggregation("status", elastic.NewTermsAggregation().Field("status.keyword").Size(10))
+
+	counts := inquiry.LeadCounts{AgencyID: agencyID, Properties: map[string]inquiry.ListingLeads{}}
+	result, err := elasticsearch.Client.Aggregate(indexInquiries, query, aggregationLeads, aggregation)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return &counts, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to count leads", errors.New("database error"))
+	}
+	terms, ok := result.Aggregations.Terms(aggregationLeads)
+	if !ok {
+		return &counts, nil
+	}
+	for _, b := range terms.Buckets {
+		leads := inquiry.ListingLeads{Total: b.DocCount, ByStatus: map[string]int64{}}
+		if statuses, ok := b.Terms("status"); ok {
+			for _, s := range statuses.Buckets {
+				leads.ByStatus[fmt.Sprint(s.Key)] = s.DocCount
+			}
+		}
+		counts.Properties[fmt.Sprint(b.Key)] = leads
+		counts.Total += b.DocCount
+	}
+	return &counts, nil
+}
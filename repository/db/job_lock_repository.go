@@ -0,0 +1,63 @@
+package db
+
+import (
+	"errors"
+
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+)
+
+const (
+	indexJobLocks = "job_lock"
+
+	resultNoop = "noop"
+)
+
+// JobLockRepository lets one instance at a time run a scheduled job. A lock
+// is a lease: it is free again once until has passed, so a crashed instance
+// never blocks the job for good.
+type JobLockRepository interface {
+	Acquire(name string, owner string, now string, until string) (bool, rest_errors.RestErr)
+}
+
+type jobLockRepository struct {
+}
+
+func NewJobLockRepository() JobLockRepository {
+	return &jobLockRepository{}
+}
+
+type jobLock struct {
+	Owner       string `json:"owner"`
+	LockedUntil string `json:"locked_until"`
+}
+
+// Acquire takes the lock called name until the given time and reports false
+// if another owner holds it. Times must share one layout, they are compared
+// as strings.
+func (db *jobLockRepository) Acquire(name string, owner string, now string, until string) (bool, rest_errors.RestErr) {
+	_, err := elasticsearch.Client.Create(indexJobLocks, typeProperty, name, jobLock{Owner: owner, LockedUntil: until})
+	if err == nil {
+		return true, nil
+	}
+	if !elastic.IsConflict(err) {
+		return false, rest_errors.NewInternalServerErr("error when trying to acquire job lock", errors.New("database error"))
+	}
+
+	script := elastic.NewScript(`
+		if (ctx._source.owner == params.owner || ctx._source.locked_until.compareTo(params.now) <= 0) {
+			ctx._source.owner = params.owner;
+			ctx._source.locked_until = params.until;
+		} else {
+			ctx.op = 'noop';
+		}`).
+		Param("owner", owner).
+		Param("now", now).
+		Param("until", until)
+	result, err := elasticsearch.Client.UpdateScript(indexJobLocks, typeProperty, name, script)
+	if err != nil {
+		return false, rest_errors.NewInternalServerErr("error when trying to acquire job lock", errors.New("database error"))
+	}
+	return result.Result != resultNoop, nil
+}
@@ -0,0 +1,219 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/domain/event"
+)
+
+const (
+	indexOutbox = "outbox"
+)
+
+type OutboxRepository interface {
+	Create(event.Event) (*event.Event, rest_errors.RestErr)
+	GetDue(now string) ([]event.Delivery, rest_errors.RestErr)
+	GetFailed() ([]event.Delivery, rest_errors.RestErr)
+	Claim(id string, now string, until string) (bool, rest_errors.RestErr)
+	Delivered(id string, deliveredTo []string, at string) rest_errors.RestErr
+	Retry(id string, attempts int, deliveredTo []string, nextAttemptAt string, message string) rest_errors.RestErr
+	Fail(id string, attempts int, deliveredTo []string, message string) rest_errors.RestErr
+	Requeue(id string, now string) (bool, rest_errors.RestErr)
+	Purge(before string) (int64, rest_errors.RestErr)
+}
+
+type outboxRepository struct {
+}
+
+func NewOutboxRepository() OutboxRepository {
+	return &outboxRepository{}
+}
+
+// outboxDocument stores the data as a JSON string so the listing in it
+// does not add to the mapping of the outbox.
+type outboxDocument struct {
+	event.Delivery
+	Data string `json:"data"`
+}
+
+// Create adds the event to the outbox, due right away. Events without an id
+// get a new one; an event already stored under its id is left as it is, so
+// writing one again is safe.
+func (db *outboxRepository) Create(e event.Event) (*event.Event, rest_errors.RestErr) {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	data, _ := json.Marshal(e.Data)
+	doc := outboxDocument{
+		Delivery: event.Delivery{
+			Event:         e,
+			Status:        event.STATUS_PENDING,
+			NextAttemptAt: e.OccurredAt,
+			DeliveredTo:   []string{},
+		},
+		Data: string(data),
+	}
+	if _, err := elasticsearch.Client.Create(indexOutbox, typeProperty, e.ID, doc); err != nil && !elastic.IsConflict(err) {
+		return nil, rest_errors.NewInternalServerErr("error when trying to save event", errors.New("database error"))
+	}
+	return &e, nil
+}
+
+// GetDue returns the pending events whose next attempt is due and the ones
+// whose dispatcher stopped before finishing them, oldest first.
+func (db *outboxRepository) GetDue(now string) ([]event.Delivery, rest_errors.RestErr) {
+	pending := elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("status.keyword", event.STATUS_PENDING)).
+		Filter(elastic.NewRangeQuery("next_attempt_at").Lte(now))
+	abandoned := elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("status.keyword", event.STATUS_DISPATCHING)).
+		Filter(elastic.NewRangeQuery("locked_until").Lte(now))
+	return db.search(elastic.NewBoolQuery().Should(pending, abandoned).MinimumNumberShouldMatch(1), true)
+}
+
+// GetFailed returns the events that ran out of attempts, newest first.
+func (db *outboxRepository) GetFailed() ([]event.Delivery, rest_errors.RestErr) {
+	return db.search(elastic.NewTermQuery("status.keyword", event.STATUS_FAILED), false)
+}
+
+func (db *outboxRepository) search(query elastic.Query, asc bool) ([]event.Delivery, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Search(indexOutbox, query, "occurred_at", asc)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return []event.Delivery{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get events", errors.New("database error"))
+	}
+
+	deliveries := []event.Delivery{}
+	for _, hit := range result.Hits.Hits {
+		var doc outboxDocument
+		bytes, _ := hit.Source.MarshalJSON()
+		if err := json.Unmarshal(bytes, &doc); err != nil {
+			continue
+		}
+		delivery := doc.Delivery
+		if err := json.Unmarshal([]byte(doc.Data), &delivery.Data); err != nil {
+			continue
+		}
+		delivery.ID = hit.Id
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+// Claim locks a due event until the given time and reports false if another
+// dispatcher got it first.
+func (db *outboxRepository) Claim(id string, now string, until string) (bool, rest_errors.RestErr) {
+	script := elastic.NewScript(`
+		boolean pending = ctx._source.status == params.pending && ctx._source.next_attempt_at.compareTo(params.now) <= 0;
+		boolean abandoned = ctx._source.status == params.dispatching && ctx._source.locked_until != null && ctx._source.locked_until.compareTo(params.now) <= 0;
+		if (pending || abandoned) {
+			ctx._source.status = params.dispatching;
+			ctx._source.locked_until = params.until;
+		} else {
+			ctx.op = 'noop';
+		}`).
+		Param("pending", event.STATUS_PENDING).
+		Param("dispatching", event.STATUS_DISPATCHING).
+		Param("now", now).
+		Param("until", until)
+	return db.script(id, script, "error when trying to claim event")
+}
+
+func (db *outboxRepository) Delivered(id string, deliveredTo []string, at string) rest_errors.RestErr {
+	script := elastic.NewScript(`
+		ctx._source.status = params.status;
+		ctx._source.delivered_to = params.delivered_to;
+		ctx._source.delivered_at = params.at;
+		ctx._source.remove('locked_until');
+		ctx._source.remove('last_error');`).
+		Param("status", event.STATUS_DELIVERED).
+		Param("delivered_to", deliveredTo).
+		Param("at", at)
+	_, err := db.script(id, script, "error when trying to finish event")
+	return err
+}
+
+// Retry releases the event until nextAttemptAt, keeping the sinks it
+// already reached so they do not get it again.
+func (db *outboxRepository) Retry(id string, attempts int, deliveredTo []string, nextAttemptAt string, message string) rest_errors.RestErr {
+	script := elastic.NewScript(`
+		ctx._source.status = params.status;
+		ctx._source.attempts = params.attempts;
+		ctx._source.delivered_to = params.delivered_to;
+		ctx._source.next_attempt_at = params.next_attempt_at;
+		ctx._source.last_error = params.message;
+		ctx._source.remove('locked_until');`).
+		Param("status", event.STATUS_PENDING).
+		Param("attempts", attempts).
+		Param("delivered_to", deliveredTo).
+		Param("next_attempt_at", nextAttemptAt).
+		Param("message", message)
+	_, err := db.script(id, script, "error when trying to retry event")
+	return err
+}
+
+func (db *outboxRepository) Fail(id string, attempts int, deliveredTo []string, message string) rest_errors.RestErr {
+	script := elastic.NewScript(`
+		ctx._source.status = params.status;
+		ctx._source.attempts = params.attempts;
+		ctx._source.delivered_to = params.delivered_to;
+		ctx._source.last_error = params.message;
+		ctx._source.remove('locked_until');`).
+		Param("status", event.STATUS_FAILED).
+		Param("attempts", attempts).
+		Param("delivered_to", deliveredTo).
+		Param("message", message)
+	_, err := db.script(id, script, "error when trying to fail event")
+	return err
+}
+
+// Requeue makes a failed event due again with a fresh set of attempts and
+// reports false if it had not failed.
+func (db *outboxRepository) Requeue(id string, now string) (bool, rest_errors.RestErr) {
+	script := elastic.NewScript(`
+		if (ctx._source.status != params.failed) {
+			ctx.op = 'noop';
+		} else {
+			ctx._source.status = params.pending;
+			ctx._source.attempts = 0;
+			ctx._source.next_attempt_at = params.now;
+		}`).
+		Param("failed", event.STATUS_FAILED).
+		Param("pending", event.STATUS_PENDING).
+		Param("now", now)
+	return db.script(id, script, "error when trying to requeue event")
+}
+
+func (db *outboxRepository) script(id string, script *elastic.Script, message string) (bool, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.UpdateScript(indexOutbox, typeProperty, id, script)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return false, rest_errors.NewNotFoundErr(fmt.Sprintf("no event was found with id %s", id))
+		}
+		return false, rest_errors.NewInternalServerErr(message, errors.New("database error"))
+	}
+	return result.Result != resultNoop, nil
+}
+
+// Purge deletes the events delivered before the given time.
+func (db *outboxRepository) Purge(before string) (int64, rest_errors.RestErr) {
+	query := elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("status.keyword", event.STATUS_DELIVERED)).
+		Filter(elastic.NewRangeQuery("delivered_at").Lt(before))
+	result, err := elasticsearch.Client.DeleteByQuery(indexOutbox, query)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, rest_errors.NewInternalServerErr("error when trying to purge events", errors.New("database error"))
+	}
+	return result.Deleted, nil
+}
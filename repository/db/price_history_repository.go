@@ -0,0 +1,57 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+)
+
+const (
+	indexPriceHistory = "price_history"
+)
+
+type PriceHistoryRepository interface {
+	Create(property.PriceChange) (*property.PriceChange, rest_errors.RestErr)
+	GetByPropertyID(propertyID string) (property.PriceHistory, rest_errors.RestErr)
+}
+
+type priceHistoryRepository struct {
+}
+
+func NewPriceHistoryRepository() PriceHistoryRepository {
+	return &priceHistoryRepository{}
+}
+
+func (db *priceHistoryRepository) Create(change property.PriceChange) (*property.PriceChange, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Save(indexPriceHistory, typeProperty, change)
+	if err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to save price change", errors.New("databse error"))
+	}
+	change.ID = result.Id
+	return &change, nil
+}
+
+func (db *priceHistoryRepository) GetByPropertyID(propertyID string) (property.PriceHistory, rest_errors.RestErr) {
+	query := elastic.NewTermQuery("property_id.keyword", propertyID)
+	result, err := elasticsearch.Client.Search(indexPriceHistory, query, "changed_at", false)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return property.PriceHistory{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get price history", errors.New("database error"))
+	}
+
+	history := property.PriceHistory{}
+	for _, hit := range result.Hits.Hits {
+		var change property.PriceChange
+		bytes, _ := hit.Source.MarshalJSON()
+		json.Unmarshal(bytes, &change)
+		change.ID = hit.Id
+		history = append(history, change)
+	}
+	return history, nil
+}
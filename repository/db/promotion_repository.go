@@ -0,0 +1,158 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/domain/promotion"
+)
+
+const (
+	indexPromotions      = "promotion"
+	indexPromotionClicks = "promotion_click"
+)
+
+type PromotionRepository interface {
+	Create(promotion.Promotion) (*promotion.Promotion, rest_errors.RestErr)
+	GetByID(id string) (*promotion.Promotion, rest_errors.RestErr)
+	GetByProperty(propertyID string) (promotion.Promotions, rest_errors.RestErr)
+	GetByAgency(agencyID string) (promotion.Promotions, rest_errors.RestErr)
+	GetRunning(placement string, now string) (promotion.Promotions, rest_errors.RestErr)
+	Cancel(id string, at string) (bool, rest_errors.RestErr)
+	CountImpressions(id string, count int64) rest_errors.RestErr
+	RecordClick(id string, click promotion.Click) (bool, rest_errors.RestErr)
+	CountClick(id string) rest_errors.RestErr
+}
+
+type promotionRepository struct {
+}
+
+func NewPromotionRepository() PromotionRepository {
+	return &promotionRepository{}
+}
+
+func (db *promotionRepository) Create(p promotion.Promotion) (*promotion.Promotion, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Save(indexPromotions, typeProperty, p)
+	if err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to save promotion", errors.New("database error"))
+	}
+	p.ID = result.Id
+	return &p, nil
+}
+
+func (db *promotionRepository) GetByID(id string) (*promotion.Promotion, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.GetByID(indexPromotions, typeProperty, id)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no promotion was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get promotion", errors.New("database error"))
+	}
+
+	var p promotion.Promotion
+	bytes, _ := result.Source.MarshalJSON()
+	if err := json.Unmarshal(bytes, &p); err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to parse database response", errors.New("database error"))
+	}
+	p.ID = result.Id
+	return &p, nil
+}
+
+func (db *promotionRepository) GetByProperty(propertyID string) (promotion.Promotions, rest_errors.RestErr) {
+	return db.search(elastic.NewTermQuery("property_id.keyword", propertyID))
+}
+
+func (db *promotionRepository) GetByAgency(agencyID string) (promotion.Promotions, rest_errors.RestErr) {
+	return db.search(elastic.NewTermQuery("agency_id.keyword", agencyID))
+}
+
+// GetRunning returns the promotions boosting placement at now.
+func (db *promotionRepository) GetRunning(placement string, now string) (promotion.Promotions, rest_errors.RestErr) {
+	query := elastic.NewBoolQuery().
+		Filter(elastic.NewTermsQuery("placement.keyword", placement, promotion.PLACEMENT_ALL)).
+		Filter(elastic.NewRangeQuery("start").Lte(now)).
+		Filter(elastic.NewRangeQuery("end").Gt(now)).
+		MustNot(elastic.NewExistsQuery("cancelled_at"))
+	return db.search(query)
+}
+
+func (db *promotionRepository) search(query elastic.Query) (promotion.Promotions, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Search(indexPromotions, query, "start", false)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return promotion.Promotions{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get promotions", errors.New("database error"))
+	}
+
+	promotions := promotion.Promotions{}
+	for _, hit := range result.Hits.Hits {
+		var p promotion.Promotion
+		bytes, _ := hit.Source.MarshalJSON()
+		if err := json.Unmarshal(bytes, &p); err != nil {
+			continue
+		}
+		p.ID = hit.Id
+		promotions = append(promotions, p)
+	}
+	return promotions, nil
+}
+
+// Cancel ends the promotion at and reports false if it was already
+// cancelled.
+func (db *promotionRepository) Cancel(id string, at string) (bool, rest_errors.RestErr) {
+	script := elastic.NewScript(`
+		if (ctx._source.cancelled_at != null) {
+			ctx.op = 'noop';
+		} else {
+			ctx._source.cancelled_at = params.at;
+		}`).Param("at", at)
+	result, err := elasticsearch.Client.UpdateScript(indexPromotions, typeProperty, id, script)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return false, rest_errors.NewNotFoundErr(fmt.Sprintf("no promotion was found with id %s", id))
+		}
+		return false, rest_errors.NewInternalServerErr("error when trying to cancel promotion", errors.New("database error"))
+	}
+	return result.Result != resultNoop, nil
+}
+
+// CountImpressions adds count impressions to a promotion. The update is
+// retried on version conflicts, so concurrent counts are not lost.
+func (db *promotionRepository) CountImpressions(id string, count int64) rest_errors.RestErr {
+	script := elastic.NewScript("ctx._source.impressions += params.count").Param("count", count)
+	if _, err := elasticsearch.Client.UpdateScript(indexPromotions, typeProperty, id, script); err != nil {
+		if elastic.IsNotFound(err) {
+			return rest_errors.NewNotFoundErr(fmt.Sprintf("no promotion was found with id %s", id))
+		}
+		return rest_errors.NewInternalServerErr("error when trying to count promotion impressions", errors.New("database error"))
+	}
+	return nil
+}
+
+// RecordClick stores a click under id and reports false if a click with the
+// same id was already stored, which is how clicks are deduplicated.
+func (db *promotionRepository) RecordClick(id string, click promotion.Click) (bool, rest_errors.RestErr) {
+	if _, err := elasticsearch.Client.Create(indexPromotionClicks, typeProperty, id, click); err != nil {
+		if elastic.IsConflict(err) {
+			return false, nil
+		}
+		return false, rest_errors.NewInternalServerErr("error when trying to save promotion click", errors.New("database error"))
+	}
+	return true, nil
+}
+
+func (db *promotionRepository) CountClick(id string) rest_errors.RestErr {
+	script := elastic.NewScript("ctx._source.clicks += 1")
+	if _, err := elasticsearch.Client.UpdateScript(indexPromotions, typeProperty, id, script); err != nil {
+		if elastic.IsNotFound(err) {
+			return rest_errors.NewNotFoundErr(fmt.Sprintf("no promotion was found with id %s", id))
+		}
+		return rest_errors.NewInternalServerErr("error when trying to count promotion click", errors.New("database error"))
+	}
+	return nil
+}
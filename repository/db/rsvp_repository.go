@@ -0,0 +1,93 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+)
+
+const (
+	indexRSVPs = "open_house_rsvp"
+)
+
+type RSVPRepository interface {
+	Create(property.RSVP) (bool, rest_errors.RestErr)
+	GetByID(id string) (*property.RSVP, rest_errors.RestErr)
+	GetByOpenHouse(openHouseID string) ([]property.RSVP, rest_errors.RestErr)
+	Delete(id string) rest_errors.RestErr
+}
+
+type rsvpRepository struct {
+}
+
+func NewRSVPRepository() RSVPRepository {
+	return &rsvpRepository{}
+}
+
+// Create stores the RSVP under its open house and user so it exists once,
+// and reports whether it is new.
+func (db *rsvpRepository) Create(r property.RSVP) (bool, rest_errors.RestErr) {
+	if _, err := elasticsearch.Client.Create(indexRSVPs, typeProperty, r.ID, r); err != nil {
+		if elastic.IsConflict(err) {
+			return false, nil
+		}
+		return false, rest_errors.NewInternalServerErr("error when trying to save RSVP", errors.New("database error"))
+	}
+	return true, nil
+}
+
+func (db *rsvpRepository) GetByID(id string) (*property.RSVP, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.GetByID(indexRSVPs, typeProperty, id)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no RSVP was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get RSVP", errors.New("database error"))
+	}
+
+	var r property.RSVP
+	bytes, _ := result.Source.MarshalJSON()
+	if err := json.Unmarshal(bytes, &r); err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to parse database response", errors.New("database error"))
+	}
+	r.ID = result.Id
+	return &r, nil
+}
+
+func (db *rsvpRepository) GetByOpenHouse(openHouseID string) ([]property.RSVP, rest_errors.RestErr) {
+	query := elastic.NewTermQuery("open_house_id.keyword", openHouseID)
+	result, err := elasticsearch.Client.Search(indexRSVPs, query, "date_created", true)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return []property.RSVP{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get RSVPs", errors.New("database error"))
+	}
+
+	rsvps := []property.RSVP{}
+	for _, hit := range result.Hits.Hits {
+		var r property.RSVP
+		bytes, _ := hit.Source.MarshalJSON()
+		if err := json.Unmarshal(bytes, &r); err != nil {
+			continue
+		}
+		r.ID = hit.Id
+		rsvps = append(rsvps, r)
+	}
+	return rsvps, nil
+}
+
+func (db *rsvpRepository) Delete(id string) rest_errors.RestErr {
+	if _, err := elasticsearch.Client.Delete(indexRSVPs, typeProperty, id); err != nil {
+		if elastic.IsNotFound(err) {
+			return rest_errors.NewNotFoundErr(fmt.Sprintf("no RSVP was found with id %s", id))
+		}
+		return rest_errors.NewInternalServerErr("error when trying to delete RSVP", errors.New("database error"))
+	}
+	return nil
+}
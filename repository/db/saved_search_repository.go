@@ -0,0 +1,161 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/domain/search"
+)
+
+const (
+	indexSavedSearches = "saved_search"
+)
+
+type SavedSearchRepository interface {
+	Create(search.SavedSearch) (*search.SavedSearch, rest_errors.RestErr)
+	GetByUser(userID string) (search.SavedSearches, rest_errors.RestErr)
+	GetAll() (search.SavedSearches, rest_errors.RestErr)
+	GetByID(id string) (*search.SavedSearch, rest_errors.RestErr)
+	Update(id string, s search.SavedSearch) (*search.SavedSearch, rest_errors.RestErr)
+	SetLastNotified(id string, at string) rest_errors.RestErr
+	Delete(id string) rest_errors.RestErr
+}
+
+type savedSearchRepository struct {
+}
+
+func NewSavedSearchRepository() SavedSearchRepository {
+	return &savedSearchRepository{}
+}
+
+// searchDocument stores the query as a JSON string: filter values mix strings
+// and numbers, which a dynamic mapping can not index.
+type searchDocument struct {
+	search.SavedSearch
+	Query string `json:"query"`
+}
+
+func toSearchDocument(s search.SavedSearch) searchDocument {
+	q, _ := json.Marshal(s.Query)
+	return searchDocument{SavedSearch: s, Query: string(q)}
+}
+
+func fromSearchDocument(id string, source json.RawMessage) (*search.SavedSearch, error) {
+	var doc searchDocument
+	if err := json.Unmarshal(source, &doc); err != nil {
+		return nil, err
+	}
+	s := doc.SavedSearch
+	if doc.Query != "" {
+		if err := json.Unmarshal([]byte(doc.Query), &s.Query); err != nil {
+			return nil, err
+		}
+	}
+	s.ID = id
+	return &s, nil
+}
+
+func (db *savedSearchRepository) Create(s search.SavedSearch) (*search.SavedSearch, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Save(indexSavedSearches, typeProperty, toSearchDocument(s))
+	if err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to save search", errors.New("database error"))
+	}
+	s.ID = result.Id
+	return &s, nil
+}
+
+func (db *savedSearchRepository) GetByUser(userID string) (search.SavedSearches, rest_errors.RestErr) {
+	return db.search(elastic.NewTermQuery("user_id.keyword", userID))
+}
+
+func (db *savedSearchRepository) GetAll() (search.SavedSearches, rest_errors.RestErr) {
+	return db.search(elastic.NewMatchAllQuery())
+}
+
+func (db *savedSearchRepository) search(query elastic.Query) (search.SavedSearches, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Search(indexSavedSearches, query, "", false)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return search.SavedSearches{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get saved searches", errors.New("database error"))
+	}
+
+	searches := search.SavedSearches{}
+	for _, hit := range result.Hits.Hits {
+		s, err := fromSearchDocument(hit.Id, hit.Source)
+		if err != nil {
+			continue
+		}
+		searches = append(searches, *s)
+	}
+	return searches, nil
+}
+
+func (db *savedSearchRepository) GetByID(id string) (*search.SavedSearch, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.GetByID(indexSavedSearches, typeProperty, id)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no saved search was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get saved search", errors.New("database error"))
+	}
+
+	s, parseErr := fromSearchDocument(result.Id, result.Source)
+	if parseErr != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to parse database response", errors.New("database error"))
+	}
+	return s, nil
+}
+
+func (db *savedSearchRepository) Update(id string, s search.SavedSearch) (*search.SavedSearch, rest_errors.RestErr) {
+	doc := toSearchDocument(s)
+	esUpdate := property.EsUpdate{Fields: []property.UpdatePropertyRequest{
+		{Field: "name", Value: doc.Name},
+		{Field: "query", Value: doc.Query},
+		{Field: "sort", Value: doc.Sort},
+		{Field: "asc", Value: doc.Asc},
+		{Field: "currency", Value: doc.Currency},
+		{Field: "local", Value: doc.Local},
+		{Field: "channel", Value: doc.Channel},
+		{Field: "target", Value: doc.Target},
+		{Field: "frequency", Value: doc.Frequency},
+	}}
+	return db.update(id, esUpdate)
+}
+
+func (db *savedSearchRepository) SetLastNotified(id string, at string) rest_errors.RestErr {
+	_, err := db.update(id, property.EsUpdate{Fields: []property.UpdatePropertyRequest{{Field: "last_notified_at", Value: at}}})
+	return err
+}
+
+func (db *savedSearchRepository) update(id string, esUpdate property.EsUpdate) (*search.SavedSearch, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Update(indexSavedSearches, typeProperty, id, esUpdate)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no saved search was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to update saved search", errors.New("database error"))
+	}
+
+	s, parseErr := fromSearchDocument(result.Id, result.GetResult.Source)
+	if parseErr != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to parse database response", errors.New("database error"))
+	}
+	return s, nil
+}
+
+func (db *savedSearchRepository) Delete(id string) rest_errors.RestErr {
+	if _, err := elasticsearch.Client.Delete(indexSavedSearches, typeProperty, id); err != nil {
+		if elastic.IsNotFound(err) {
+			return rest_errors.NewNotFoundErr(fmt.Sprintf("no saved search was found with id %s", id))
+		}
+		return rest_errors.NewInternalServerErr("error when trying to delete saved search", errors.New("database error"))
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+)
+
+const (
+	indexScheduledChanges = "scheduled_change"
+)
+
+type ScheduledChangeRepository interface {
+	Create(property.ScheduledChange) (*property.ScheduledChange, rest_errors.RestErr)
+	GetByID(id string) (*property.ScheduledChange, rest_errors.RestErr)
+	GetByProperty(propertyID string) (property.ScheduledChanges, rest_errors.RestErr)
+	GetDue(now string) (property.ScheduledChanges, rest_errors.RestErr)
+	SetStatus(id string, from string, to string) (bool, rest_errors.RestErr)
+	Claim(id string, now string, until string) (bool, rest_errors.RestErr)
+	Finish(id string, status string, message string, at string) rest_errors.RestErr
+}
+
+type scheduledChangeRepository struct {
+}
+
+func NewScheduledChangeRepository() ScheduledChangeRepository {
+	return &scheduledChangeRepository{}
+}
+
+// changeDocument stores the fields as a JSON string: their values mix
+// strings, numbers and objects, which a dynamic mapping can not index.
+type changeDocument struct {
+	property.ScheduledChange
+	Fields string `json:"fields"`
+}
+
+func fromChangeDocument(id string, source json.RawMessage) (*property.ScheduledChange, error) {
+	var doc changeDocument
+	if err := json.Unmarshal(source, &doc); err != nil {
+		return nil, err
+	}
+	c := doc.ScheduledChange
+	if doc.Fields != "" {
+		if err := json.Unmarshal([]byte(doc.Fields), &c.Fields); err != nil {
+			return nil, err
+		}
+	}
+	c.ID = id
+	return &c, nil
+}
+
+func (db *scheduledChangeRepository) Create(c property.ScheduledChange) (*property.ScheduledChange, rest_errors.RestErr) {
+	fields, _ := json.Marshal(c.Fields)
+	result, err := elasticsearch.Client.Save(indexScheduledChanges, typeProperty, changeDocument{ScheduledChange: c, Fields: string(fields)})
+	if err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to save scheduled change", errors.New("database error"))
+	}
+	c.ID = result.Id
+	return &c, nil
+}
+
+func (db *scheduledChangeRepository) GetByID(id string) (*property.ScheduledChange, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.GetByID(indexScheduledChanges, typeProperty, id)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no scheduled change was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get scheduled change", errors.New("database error"))
+	}
+
+	bytes, _ := result.Source.MarshalJSON()
+	c, parseErr := fromChangeDocument(result.Id, bytes)
+	if parseErr != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to parse database response", errors.New("database error"))
+	}
+	return c, nil
+}
+
+func (db *scheduledChangeRepository) GetByProperty(propertyID string) (property.ScheduledChanges, rest_errors.RestErr) {
+	return db.search(elastic.NewTermQuery("property_id.keyword", propertyID))
+}
+
+// GetDue returns the pending changes to apply at or before now and the ones
+// whose instance stopped before finishing them, oldest first.
+func (db *scheduledChangeRepository) GetDue(now string) (property.ScheduledChanges, rest_errors.RestErr) {
+	pending := elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("status.keyword", property.CHANGE_PENDING)).
+		Filter(elastic.NewRangeQuery("apply_at").Lte(now))
+	abandoned := elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("status.keyword", property.CHANGE_APPLYING)).
+		Filter(elastic.NewBoolQuery().
+			Should(elastic.NewRangeQuery("locked_until").Lte(now), elastic.NewBoolQuery().MustNot(elastic.NewExistsQuery("locked_until"))).
+			MinimumNumberShouldMatch(1))
+	return db.search(elastic.NewBoolQuery().Should(pending, abandoned).MinimumNumberShouldMatch(1))
+}
+
+func (db *scheduledChangeRepository) search(query elastic.Query) (property.ScheduledChanges, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Search(indexScheduledChanges, query, "apply_at", true)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return property.ScheduledChanges{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get scheduled changes", errors.New("database error"))
+	}
+
+	changes := property.ScheduledChanges{}
+	for _, hit := range result.Hits.Hits {
+		bytes, _ := hit.Source.MarshalJSON()
+		c, err := fromChangeDocument(hit.Id, bytes)
+		if err != nil {
+			continue
+		}
+		changes = append(changes, *c)
+	}
+	return changes, nil
+}
+
+// SetStatus moves a change from one status to another and reports false
+// when it was no longer in from, e.g. because another instance claimed it.
+func (db *scheduledChangeRepository) SetStatus(id string, from string, to string) (bool, rest_errors.RestErr) {
+	script := elastic.NewScript(`
+		if (ctx._source.status != params.from) {
+			ctx.op = 'noop';
+		} else {
+			ctx._source.status = params.to;
+		}`).
+		Param("from", from).
+		Param("to", to)
+	result, err := elasticsearch.Client.UpdateScript(indexScheduledChanges, typeProperty, id, script)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return false, rest_errors.NewNotFoundErr(fmt.Sprintf("no scheduled change was found with id %s", id))
+		}
+		return false, rest_errors.NewInternalServerErr("error when trying to update scheduled change", errors.New("database error"))
+	}
+	return result.Result != resultNoop, nil
+}
+
+// Claim locks a due change until the given time and reports false if
+// another instance got it first. Changes claimed before they had a lock are
+// taken over as well.
+func (db *scheduledChangeRepository) Claim(id string, now string, until string) (bool, rest_errors.RestErr) {
+	script := elastic.NewScript(`
+		boolean pending = ctx._source.status == params.pending && ctx._source.apply_at.compareTo(params.now) <= 0;
+		boolean abandoned = ctx._source.status == params.applying && (ctx._source.locked_until == null || ctx._source.locked_until.compareTo(params.now) <= 0);
+		if (pending || abandoned) {
+			ctx._source.status = params.applying;
+			ctx._source.locked_until = params.until;
+		} else {
+			ctx.op = 'noop';
+		}`).
+		Param("pending", property.CHANGE_PENDING).
+		Param("applying", property.CHANGE_APPLYING).
+		Param("now", now).
+		Param("until", until)
+	result, err := elasticsearch.Client.UpdateScript(indexScheduledChanges, typeProperty, id, script)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return false, rest_errors.NewNotFoundErr(fmt.Sprintf("no scheduled change was found with id %s", id))
+		}
+		return false, rest_errors.NewInternalServerErr("error when trying to claim scheduled change", errors.New("database error"))
+	}
+	return result.Result != resultNoop, nil
+}
+
+func (db *scheduledChangeRepository) Finish(id string, status string, message string, at string) rest_errors.RestErr {
+	if _, err := elasticsearch.Client.Update(indexScheduledChanges, typeProperty, id, property.EsUpdate{Fields: []property.UpdatePropertyRequest{
+		{Field: "status", Value: status},
+		{Field: "error", Value: message},
+		{Field: "applied_at", Value: at},
+	}}); err != nil {
+		return rest_errors.NewInternalServerErr("error when trying to update scheduled change", errors.New("database error"))
+	}
+	return nil
+}
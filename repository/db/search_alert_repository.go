@@ -0,0 +1,74 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/domain/search"
+)
+
+const (
+	indexSearchAlerts = "search_alert"
+)
+
+type SearchAlertRepository interface {
+	Create(search.Alert) (bool, rest_errors.RestErr)
+	GetPending() (search.Alerts, rest_errors.RestErr)
+	MarkSent(ids []string, sentAt string) rest_errors.RestErr
+}
+
+type searchAlertRepository struct {
+}
+
+func NewSearchAlertRepository() SearchAlertRepository {
+	return &searchAlertRepository{}
+}
+
+// Create records an alert and reports whether it is new, which is what keeps
+// a listing from being announced twice for the same search.
+func (db *searchAlertRepository) Create(a search.Alert) (bool, rest_errors.RestErr) {
+	if _, err := elasticsearch.Client.Create(indexSearchAlerts, typeProperty, a.ID, a); err != nil {
+		if elastic.IsConflict(err) {
+			return false, nil
+		}
+		return false, rest_errors.NewInternalServerErr("error when trying to save search alert", errors.New("database error"))
+	}
+	return true, nil
+}
+
+func (db *searchAlertRepository) GetPending() (search.Alerts, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Search(indexSearchAlerts, elastic.NewTermQuery("status.keyword", search.ALERT_PENDING), "", false)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return search.Alerts{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get search alerts", errors.New("database error"))
+	}
+
+	alerts := search.Alerts{}
+	for _, hit := range result.Hits.Hits {
+		var a search.Alert
+		bytes, _ := hit.Source.MarshalJSON()
+		if err := json.Unmarshal(bytes, &a); err != nil {
+			continue
+		}
+		a.ID = hit.Id
+		alerts = append(alerts, a)
+	}
+	return alerts, nil
+}
+
+func (db *searchAlertRepository) MarkSent(ids []string, sentAt string) rest_errors.RestErr {
+	if len(ids) == 0 {
+		return nil
+	}
+	script := elastic.NewScript("ctx._source.status = params.status; ctx._source.sent_at = params.sent_at").
+		Params(map[string]interface{}{"status": search.ALERT_SENT, "sent_at": sentAt})
+	if _, err := elasticsearch.Client.UpdateByQuery(indexSearchAlerts, elastic.NewIdsQuery().Ids(ids...), script); err != nil {
+		return rest_errors.NewInternalServerErr("error when trying to update search alerts", errors.New("database error"))
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/domain/webhook"
+)
+
+const (
+	indexSubscriptions = "webhook_subscription"
+)
+
+type SubscriptionRepository interface {
+	Create(webhook.Subscription) (*webhook.Subscription, rest_errors.RestErr)
+	GetByID(id string) (*webhook.Subscription, rest_errors.RestErr)
+	GetByAgency(agencyID string) (webhook.Subscriptions, rest_errors.RestErr)
+	GetPaused() (webhook.Subscriptions, rest_errors.RestErr)
+	Update(id string, s webhook.Subscription) (*webhook.Subscription, rest_errors.RestErr)
+	Delete(id string) rest_errors.RestErr
+}
+
+type subscriptionRepository struct {
+}
+
+func NewSubscriptionRepository() SubscriptionRepository {
+	return &subscriptionRepository{}
+}
+
+func (db *subscriptionRepository) Create(s webhook.Subscription) (*webhook.Subscription, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Save(indexSubscriptions, typeProperty, s)
+	if err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to save webhook subscription", errors.New("database error"))
+	}
+	s.ID = result.Id
+	return &s, nil
+}
+
+func (db *subscriptionRepository) GetByID(id string) (*webhook.Subscription, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.GetByID(indexSubscriptions, typeProperty, id)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no webhook subscription was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get webhook subscription", errors.New("database error"))
+	}
+
+	var s webhook.Subscription
+	bytes, _ := result.Source.MarshalJSON()
+	if err := json.Unmarshal(bytes, &s); err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to parse database response", errors.New("database error"))
+	}
+	s.ID = result.Id
+	return &s, nil
+}
+
+func (db *subscriptionRepository) GetByAgency(agencyID string) (webhook.Subscriptions, rest_errors.RestErr) {
+	return db.search(elastic.NewTermQuery("agency_id.keyword", agencyID))
+}
+
+// GetPaused returns the subscriptions of every agency that are not active.
+func (db *subscriptionRepository) GetPaused() (webhook.Subscriptions, rest_errors.RestErr) {
+	return db.search(elastic.NewTermQuery("active", false))
+}
+
+func (db *subscriptionRepository) search(query elastic.Query) (webhook.Subscriptions, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Search(indexSubscriptions, query, "date_created", false)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return webhook.Subscriptions{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get webhook subscriptions", errors.New("database error"))
+	}
+
+	subscriptions := webhook.Subscriptions{}
+	for _, hit := range result.Hits.Hits {
+		var s webhook.Subscription
+		bytes, _ := hit.Source.MarshalJSON()
+		if err := json.Unmarshal(bytes, &s); err != nil {
+			continue
+		}
+		s.ID = hit.Id
+		subscriptions = append(subscriptions, s)
+	}
+	return subscriptions, nil
+}
+
+func (db *subscriptionRepository) Update(id string, s webhook.Subscription) (*webhook.Subscription, rest_errors.RestErr) {
+	esUpdate := property.EsUpdate{Fields: []property.UpdatePropertyRequest{
+		{Field: "url", Value: s.URL},
+		{Field: "secret", Value: s.Secret},
+		{Field: "event_types", Value: s.EventTypes},
+		{Field: "active", Value: s.Active},
+		{Field: "date_updated", Value: s.DateUpdated},
+	}}
+	if _, err := elasticsearch.Client.Update(indexSubscriptions, typeProperty, id, esUpdate); err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no webhook subscription was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to update webhook subscription", errors.New("database error"))
+	}
+	s.ID = id
+	return &s, nil
+}
+
+func (db *subscriptionRepository) Delete(id string) rest_errors.RestErr {
+	if _, err := elasticsearch.Client.Delete(indexSubscriptions, typeProperty, id); err != nil {
+		if elastic.IsNotFound(err) {
+			return rest_errors.NewNotFoundErr(fmt.Sprintf("no webhook subscription was found with id %s", id))
+		}
+		return rest_errors.NewInternalServerErr("error when trying to delete webhook subscription", errors.New("database error"))
+	}
+	return nil
+}
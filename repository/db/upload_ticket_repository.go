@@ -0,0 +1,71 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/domain/upload"
+)
+
+const (
+	indexUploadTickets = "upload_ticket"
+)
+
+type UploadTicketRepository interface {
+	Create(upload.Ticket) (*upload.Ticket, rest_errors.RestErr)
+	GetByID(id string) (*upload.Ticket, rest_errors.RestErr)
+	Update(id string, updateRequest property.EsUpdate) (*upload.Ticket, rest_errors.RestErr)
+}
+
+type uploadTicketRepository struct {
+}
+
+func NewUploadTicketRepository() UploadTicketRepository {
+	return &uploadTicketRepository{}
+}
+
+func (db *uploadTicketRepository) Create(ticket upload.Ticket) (*upload.Ticket, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Save(indexUploadTickets, typeProperty, ticket)
+	if err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to save upload ticket", errors.New("databse error"))
+	}
+	ticket.ID = result.Id
+	return &ticket, nil
+}
+
+func (db *uploadTicketRepository) GetByID(id string) (*upload.Ticket, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.GetByID(indexUploadTickets, typeProperty, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "404") {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no upload ticket was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr(fmt.Sprintf("error when trying to get upload ticket %s", id), errors.New("database error"))
+	}
+
+	var ticket upload.Ticket
+	bytes, _ := result.Source.MarshalJSON()
+	json.Unmarshal(bytes, &ticket)
+	ticket.ID = result.Id
+	return &ticket, nil
+}
+
+func (db *uploadTicketRepository) Update(id string, updateRequest property.EsUpdate) (*upload.Ticket, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Update(indexUploadTickets, typeProperty, id, updateRequest)
+	if err != nil {
+		if strings.Contains(err.Error(), "404") {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no upload ticket was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to update upload ticket", errors.New("databse error"))
+	}
+
+	var ticket upload.Ticket
+	bytes, _ := result.GetResult.Source.MarshalJSON()
+	json.Unmarshal(bytes, &ticket)
+	ticket.ID = result.Id
+	return &ticket, nil
+}
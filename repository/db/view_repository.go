@@ -0,0 +1,71 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/domain/view"
+)
+
+const (
+	indexViews       = "property_view"
+	aggregationDaily = "daily"
+)
+
+type ViewRepository interface {
+	Record(id string, v view.View) (bool, rest_errors.RestErr)
+	DailyViews(field string, value string, from time.Time, to time.Time) ([]view.DailyViews, rest_errors.RestErr)
+}
+
+type viewRepository struct {
+}
+
+func NewViewRepository() ViewRepository {
+	return &viewRepository{}
+}
+
+// Record stores a view under id and reports false if a view with the same id
+// was already stored, which is how views are deduplicated.
+func (db *viewRepository) Record(id string, v view.View) (bool, rest_errors.RestErr) {
+	if _, err := elasticsearch.Client.Create(indexViews, typeProperty, id, v); err != nil {
+		if elastic.IsConflict(err) {
+			return false, nil
+		}
+		return false, rest_errors.NewInternalServerErr("error when trying to save view", errors.New("database error"))
+	}
+	return true, nil
+}
+
+func (db *viewRepository) DailyViews(field string, value string, from time.Time, to time.Time) ([]view.DailyViews, rest_errors.RestErr) {
+	query := elastic.NewBoolQuery().Filter(
+		elastic.NewTermQuery(field+".keyword", value),
+		elastic.NewRangeQuery("timestamp").Gte(from).Lt(to.AddDate(0, 0, 1)),
+	)
+	histogram := elastic.NewDateHistogramAggregation().
+		Field("timestamp").
+		CalendarInterval("day").
+		Format("yyyy-MM-dd").
+		MinDocCount(0).
+		ExtendedBounds(from.Format("2006-01-02"), to.Format("2006-01-02"))
+
+	result, err := elasticsearch.Client.Aggregate(indexViews, query, aggregationDaily, histogram)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get views", errors.New("database error"))
+	}
+
+	var days []view.DailyViews
+	buckets, found := result.Aggregations.DateHistogram(aggregationDaily)
+	if !found {
+		return days, nil
+	}
+	for _, bucket := range buckets.Buckets {
+		days = append(days, view.DailyViews{Date: *bucket.KeyAsString, Views: bucket.DocCount})
+	}
+	return days, nil
+}
@@ -0,0 +1,327 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/domain/viewing"
+)
+
+const (
+	indexViewingSlots   = "viewing_slot"
+	indexViewings       = "viewing"
+	indexAgentCalendars = "viewing_agent"
+)
+
+// ViewingRepository stores viewing slots and their bookings. Slots change
+// state only through scripts that check the current state, so two buyers
+// can never book the same slot.
+type ViewingRepository interface {
+	CreateSlot(viewing.Slot) (*viewing.Slot, rest_errors.RestErr)
+	GetSlot(id string) (*viewing.Slot, rest_errors.RestErr)
+	GetSlots(propertyID string, from string, status string) (viewing.Slots, rest_errors.RestErr)
+	HasOverlap(agentID string, start string, end string) (bool, rest_errors.RestErr)
+	ReserveTimes(agentID string, slots viewing.Slots, now string) (bool, rest_errors.RestErr)
+	FreeTime(agentID string, slotID string) rest_errors.RestErr
+	BookSlot(id string, bookingID string) (bool, rest_errors.RestErr)
+	ReleaseSlot(id string, bookingID string) rest_errors.RestErr
+	WithdrawSlot(id string) (bool, rest_errors.RestErr)
+	CreateBooking(viewing.Booking) (*viewing.Booking, rest_errors.RestErr)
+	GetBooking(id string) (*viewing.Booking, rest_errors.RestErr)
+	GetBookingsByUser(userID string) (viewing.Bookings, rest_errors.RestErr)
+	GetBookingsByAgent(agentID string, from string) (viewing.Bookings, rest_errors.RestErr)
+	CancelBooking(id string, cancelledBy string, cancelledAt string) (*viewing.Booking, bool, rest_errors.RestErr)
+}
+
+type viewingRepository struct {
+}
+
+// agentCalendar holds the times an agent has published slots for.
+type agentCalendar struct {
+	Times []reservedTime `json:"times"`
+}
+
+type reservedTime struct {
+	SlotID string `json:"slot_id"`
+	Start  string `json:"start"`
+	End    string `json:"end"`
+}
+
+func NewViewingRepository() ViewingRepository {
+	return &viewingRepository{}
+}
+
+func (db *viewingRepository) CreateSlot(s viewing.Slot) (*viewing.Slot, rest_errors.RestErr) {
+	if _, err := elasticsearch.Client.Create(indexViewingSlots, typeProperty, s.ID, s); err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to save viewing slot", errors.New("database error"))
+	}
+	return &s, nil
+}
+
+func (db *viewingRepository) GetSlot(id string) (*viewing.Slot, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.GetByID(indexViewingSlots, typeProperty, id)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no viewing slot was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get viewing slot", errors.New("database error"))
+	}
+
+	var s viewing.Slot
+	bytes, _ := result.Source.MarshalJSON()
+	if err := json.Unmarshal(bytes, &s); err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to parse database response", errors.New("database error"))
+	}
+	s.ID = result.Id
+	return &s, nil
+}
+
+// GetSlots returns the slots of a listing starting at or after from, earliest
+// first, optionally only those with status.
+func (db *viewingRepository) GetSlots(propertyID string, from string, status string) (viewing.Slots, rest_errors.RestErr) {
+	query := elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("property_id.keyword", propertyID)).
+		Filter(elastic.NewRangeQuery("start").Gte(from))
+	if status != "" {
+		query.Filter(elastic.NewTermQuery("status.keyword", status))
+	}
+	result, err := elasticsearch.Client.Search(indexViewingSlots, query, "start", true)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return viewing.Slots{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get viewing slots", errors.New("database error"))
+	}
+
+	slots := viewing.Slots{}
+	for _, hit := range result.Hits.Hits {
+		var s viewing.Slot
+		bytes, _ := hit.Source.MarshalJSON()
+		if err := json.Unmarshal(bytes, &s); err != nil {
+			continue
+		}
+		s.ID = hit.Id
+		slots = append(slots, s)
+	}
+	return slots, nil
+}
+
+// HasOverlap reports whether the agent already has an open or booked slot
+// during start to end, on any listing.
+func (db *viewingRepository) HasOverlap(agentID string, start string, end string) (bool, rest_errors.RestErr) {
+	query := elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("agent_id.keyword", agentID)).
+		Filter(elastic.NewTermsQuery("status.keyword", viewing.SLOT_OPEN, viewing.SLOT_BOOKED)).
+		Filter(elastic.NewRangeQuery("start").Lt(end)).
+		Filter(elastic.NewRangeQuery("end").Gt(start))
+	result, err := elasticsearch.Client.SearchTop(indexViewingSlots, query, 1)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return false, nil
+		}
+		return false, rest_errors.NewInternalServerErr("error when trying to check viewing slots", errors.New("database error"))
+	}
+	return len(result.Hits.Hits) > 0, nil
+}
+
+// ReserveTimes adds the times of slots to the agent's calendar unless one of
+// them overlaps a time reserved before. The check and the write are one
+// script on one document, so two requests can never both reserve the same
+// time, which a search for overlapping slots can not promise. Times that have
+// passed are dropped on the way.
+func (db *viewingRepository) ReserveTimes(agentID string, slots viewing.Slots, now string) (bool, rest_errors.RestErr) {
+	if _, err := elasticsearch.Client.Create(indexAgentCalendars, typeProperty, agentID, agentCalendar{Times: []reservedTime{}}); err != nil && !elastic.IsConflict(err) {
+		return false, rest_errors.NewInternalServerErr("error when trying to reserve viewing slots", errors.New("database error"))
+	}
+	times := make([]interface{}, 0, len(slots))
+	for _, slot := range slots {
+		times = append(times, map[string]interface{}{"slot_id": slot.ID, "start": slot.Start, "end": slot.End})
+	}
+	script := elastic.NewScript(`
+		if (ctx._source.times == null) {
+			ctx._source.times = [];
+		}
+		ctx._source.times.removeIf(t -> t.end.compareTo(params.now) <= 0);
+		boolean overlaps = false;
+		for (r in params.times) {
+			for (t in ctx._source.times) {
+				if (t.start.compareTo(r.end) < 0 && t.end.compareTo(r.start) > 0) {
+					overlaps = true;
+				}
+			}
+		}
+		if (overlaps) {
+			ctx.op = 'noop';
+		} else {
+			ctx._source.times.addAll(params.times);
+		}`).
+		Param("times", times).
+		Param("now", now)
+	result, err := elasticsearch.Client.UpdateScript(indexAgentCalendars, typeProperty, agentID, script)
+	if err != nil {
+		return false, rest_errors.NewInternalServerErr("error when trying to reserve viewing slots", errors.New("database error"))
+	}
+	return result.Result != resultNoop, nil
+}
+
+// FreeTime removes the time of a slot from the agent's calendar.
+func (db *viewingRepository) FreeTime(agentID string, slotID string) rest_errors.RestErr {
+	script := elastic.NewScript(`
+		if (ctx._source.times == null || !ctx._source.times.removeIf(t -> t.slot_id == params.slot_id)) {
+			ctx.op = 'noop';
+		}`).
+		Param("slot_id", slotID)
+	if _, err := elasticsearch.Client.UpdateScript(indexAgentCalendars, typeProperty, agentID, script); err != nil && !elastic.IsNotFound(err) {
+		return rest_errors.NewInternalServerErr("error when trying to free viewing slot", errors.New("database error"))
+	}
+	return nil
+}
+
+// BookSlot reserves an open slot for bookingID. It reports false when the
+// slot was no longer open.
+func (db *viewingRepository) BookSlot(id string, bookingID string) (bool, rest_errors.RestErr) {
+	script := elastic.NewScript(`
+		if (ctx._source.status != params.open) {
+			ctx.op = 'noop';
+		} else {
+			ctx._source.status = params.booked;
+			ctx._source.booking_id = params.booking_id;
+		}`).
+		Param("open", viewing.SLOT_OPEN).
+		Param("booked", viewing.SLOT_BOOKED).
+		Param("booking_id", bookingID)
+	return db.updateSlot(id, script)
+}
+
+// ReleaseSlot reopens a slot, but only while bookingID still holds it.
+func (db *viewingRepository) ReleaseSlot(id string, bookingID string) rest_errors.RestErr {
+	script := elastic.NewScript(`
+		if (ctx._source.booking_id != params.booking_id) {
+			ctx.op = 'noop';
+		} else {
+			ctx._source.status = params.open;
+			ctx._source.remove('booking_id');
+		}`).
+		Param("open", viewing.SLOT_OPEN).
+		Param("booking_id", bookingID)
+	_, err := db.updateSlot(id, script)
+	return err
+}
+
+// WithdrawSlot takes an open slot off the calendar. It reports false when the
+// slot was not open.
+func (db *viewingRepository) WithdrawSlot(id string) (bool, rest_errors.RestErr) {
+	script := elastic.NewScript(`
+		if (ctx._source.status != params.open) {
+			ctx.op = 'noop';
+		} else {
+			ctx._source.status = params.withdrawn;
+		}`).
+		Param("open", viewing.SLOT_OPEN).
+		Param("withdrawn", viewing.SLOT_WITHDRAWN)
+	return db.updateSlot(id, script)
+}
+
+func (db *viewingRepository) updateSlot(id string, script *elastic.Script) (bool, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.UpdateScript(indexViewingSlots, typeProperty, id, script)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return false, rest_errors.NewNotFoundErr(fmt.Sprintf("no viewing slot was found with id %s", id))
+		}
+		return false, rest_errors.NewInternalServerErr("error when trying to update viewing slot", errors.New("database error"))
+	}
+	return result.Result != resultNoop, nil
+}
+
+func (db *viewingRepository) CreateBooking(b viewing.Booking) (*viewing.Booking, rest_errors.RestErr) {
+	if _, err := elasticsearch.Client.Create(indexViewings, typeProperty, b.ID, b); err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to save viewing", errors.New("database error"))
+	}
+	return &b, nil
+}
+
+func (db *viewingRepository) GetBooking(id string) (*viewing.Booking, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.GetByID(indexViewings, typeProperty, id)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no viewing was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get viewing", errors.New("database error"))
+	}
+
+	var b viewing.Booking
+	bytes, _ := result.Source.MarshalJSON()
+	if err := json.Unmarshal(bytes, &b); err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to parse database response", errors.New("database error"))
+	}
+	b.ID = result.Id
+	return &b, nil
+}
+
+func (db *viewingRepository) GetBookingsByUser(userID string) (viewing.Bookings, rest_errors.RestErr) {
+	return db.searchBookings(elastic.NewBoolQuery().Filter(elastic.NewTermQuery("user_id.keyword", userID)))
+}
+
+// GetBookingsByAgent returns the agent's viewings starting at or after from,
+// cancelled ones included so calendars can drop them.
+func (db *viewingRepository) GetBookingsByAgent(agentID string, from string) (viewing.Bookings, rest_errors.RestErr) {
+	return db.searchBookings(elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("agent_id.keyword", agentID)).
+		Filter(elastic.NewRangeQuery("start").Gte(from)))
+}
+
+func (db *viewingRepository) searchBookings(query elastic.Query) (viewing.Bookings, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Search(indexViewings, query, "start", true)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return viewing.Bookings{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get viewings", errors.New("database error"))
+	}
+
+	bookings := viewing.Bookings{}
+	for _, hit := range result.Hits.Hits {
+		var b viewing.Booking
+		bytes, _ := hit.Source.MarshalJSON()
+		if err := json.Unmarshal(bytes, &b); err != nil {
+			continue
+		}
+		b.ID = hit.Id
+		bookings = append(bookings, b)
+	}
+	return bookings, nil
+}
+
+// CancelBooking cancels a confirmed booking. It reports false when the
+// booking was already cancelled.
+func (db *viewingRepository) CancelBooking(id string, cancelledBy string, cancelledAt string) (*viewing.Booking, bool, rest_errors.RestErr) {
+	script := elastic.NewScript(`
+		if (ctx._source.status != params.confirmed) {
+			ctx.op = 'noop';
+		} else {
+			ctx._source.status = params.cancelled;
+			ctx._source.cancelled_by = params.cancelled_by;
+			ctx._source.date_cancelled = params.cancelled_at;
+		}`).
+		Param("confirmed", viewing.BOOKING_CONFIRMED).
+		Param("cancelled", viewing.BOOKING_CANCELLED).
+		Param("cancelled_by", cancelledBy).
+		Param("cancelled_at", cancelledAt)
+	result, err := elasticsearch.Client.UpdateScript(indexViewings, typeProperty, id, script)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, false, rest_errors.NewNotFoundErr(fmt.Sprintf("no viewing was found with id %s", id))
+		}
+		return nil, false, rest_errors.NewInternalServerErr("error when trying to cancel viewing", errors.New("database error"))
+	}
+
+	var b viewing.Booking
+	bytes, _ := result.GetResult.Source.MarshalJSON()
+	json.Unmarshal(bytes, &b)
+	b.ID = result.Id
+	return &b, result.Result != resultNoop, nil
+}
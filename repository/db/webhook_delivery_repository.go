@@ -0,0 +1,257 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/elasticsearch"
+	"github.com/superbkibbles/realestate_property-api/domain/webhook"
+)
+
+const (
+	indexWebhookDeliveries = "webhook_delivery"
+)
+
+type WebhookDeliveryRepository interface {
+	Create(webhook.Delivery) (bool, rest_errors.RestErr)
+	GetByID(id string) (*webhook.Delivery, rest_errors.RestErr)
+	GetBySubscription(subscriptionID string, status string) (webhook.Deliveries, rest_errors.RestErr)
+	GetDead(agencyID string) (webhook.Deliveries, rest_errors.RestErr)
+	GetDue(now string, paused []string, size int) (webhook.Deliveries, rest_errors.RestErr)
+	Claim(id string, now string, until string) (bool, rest_errors.RestErr)
+	Record(id string, status string, attempts int, nextAttemptAt string, attempt webhook.Attempt) rest_errors.RestErr
+	Replay(id string, now string) (bool, rest_errors.RestErr)
+	ReplayDead(subscriptionID string, now string) (int64, rest_errors.RestErr)
+	DeleteBySubscription(subscriptionID string) rest_errors.RestErr
+	Purge(before string) (int64, rest_errors.RestErr)
+}
+
+type webhookDeliveryRepository struct {
+}
+
+func NewWebhookDeliveryRepository() WebhookDeliveryRepository {
+	return &webhookDeliveryRepository{}
+}
+
+// Create reports false if the delivery already exists.
+func (db *webhookDeliveryRepository) Create(d webhook.Delivery) (bool, rest_errors.RestErr) {
+	if _, err := elasticsearch.Client.Create(indexWebhookDeliveries, typeProperty, d.ID, d); err != nil {
+		if elastic.IsConflict(err) {
+			return false, nil
+		}
+		return false, rest_errors.NewInternalServerErr("error when trying to save webhook delivery", errors.New("database error"))
+	}
+	return true, nil
+}
+
+func (db *webhookDeliveryRepository) GetByID(id string) (*webhook.Delivery, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.GetByID(indexWebhookDeliveries, typeProperty, id)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no webhook delivery was found with id %s", id))
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get webhook delivery", errors.New("database error"))
+	}
+
+	var d webhook.Delivery
+	bytes, _ := result.Source.MarshalJSON()
+	if err := json.Unmarshal(bytes, &d); err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to parse database response", errors.New("database error"))
+	}
+	d.ID = result.Id
+	return &d, nil
+}
+
+// GetBySubscription returns the deliveries of a subscription, newest first,
+// optionally only the ones in status.
+func (db *webhookDeliveryRepository) GetBySubscription(subscriptionID string, status string) (webhook.Deliveries, rest_errors.RestErr) {
+	query := elastic.NewBoolQuery().Filter(elastic.NewTermQuery("subscription_id.keyword", subscriptionID))
+	if status != "" {
+		query.Filter(elastic.NewTermQuery("status.keyword", status))
+	}
+	return db.search(query, false)
+}
+
+// GetDead returns the dead letters of every subscription of an agency.
+func (db *webhookDeliveryRepository) GetDead(agencyID string) (webhook.Deliveries, rest_errors.RestErr) {
+	return db.search(elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("agency_id.keyword", agencyID)).
+		Filter(elastic.NewTermQuery("status.keyword", webhook.STATUS_DEAD)), false)
+}
+
+// GetDue returns up to size of the pending deliveries whose next attempt is
+// due and the ones whose scheduler stopped before finishing them, oldest
+// first. Deliveries of the paused subscriptions are left out, so they wait
+// without holding up the others.
+func (db *webhookDeliveryRepository) GetDue(now string, paused []string, size int) (webhook.Deliveries, rest_errors.RestErr) {
+	pending := elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("status.keyword", webhook.STATUS_PENDING)).
+		Filter(elastic.NewRangeQuery("next_attempt_at").Lte(now))
+	abandoned := elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("status.keyword", webhook.STATUS_DELIVERING)).
+		Filter(elastic.NewRangeQuery("locked_until").Lte(now))
+	query := elastic.NewBoolQuery().Should(pending, abandoned).MinimumNumberShouldMatch(1)
+	if len(paused) > 0 {
+		ids := make([]interface{}, len(paused))
+		for i, id := range paused {
+			ids[i] = id
+		}
+		query.MustNot(elastic.NewTermsQuery("subscription_id.keyword", ids...))
+	}
+	result, err := elasticsearch.Client.SearchLimit(indexWebhookDeliveries, query, "date_created", true, size)
+	return db.parse(result, err)
+}
+
+func (db *webhookDeliveryRepository) search(query elastic.Query, asc bool) (webhook.Deliveries, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.Search(indexWebhookDeliveries, query, "date_created", asc)
+	return db.parse(result, err)
+}
+
+func (db *webhookDeliveryRepository) parse(result *elastic.SearchResult, err error) (webhook.Deliveries, rest_errors.RestErr) {
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return webhook.Deliveries{}, nil
+		}
+		return nil, rest_errors.NewInternalServerErr("error when trying to get webhook deliveries", errors.New("database error"))
+	}
+
+	deliveries := webhook.Deliveries{}
+	for _, hit := range result.Hits.Hits {
+		var d webhook.Delivery
+		bytes, _ := hit.Source.MarshalJSON()
+		if err := json.Unmarshal(bytes, &d); err != nil {
+			continue
+		}
+		d.ID = hit.Id
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+// Claim locks a due delivery until the given time and reports false if
+// another scheduler got it first.
+func (db *webhookDeliveryRepository) Claim(id string, now string, until string) (bool, rest_errors.RestErr) {
+	script := elastic.NewScript(`
+		boolean pending = ctx._source.status == params.pending && ctx._source.next_attempt_at.compareTo(params.now) <= 0;
+		boolean abandoned = ctx._source.status == params.delivering && ctx._source.locked_until != null && ctx._source.locked_until.compareTo(params.now) <= 0;
+		if (pending || abandoned) {
+			ctx._source.status = params.delivering;
+			ctx._source.locked_until = params.until;
+		} else {
+			ctx.op = 'noop';
+		}`).
+		Param("pending", webhook.STATUS_PENDING).
+		Param("delivering", webhook.STATUS_DELIVERING).
+		Param("now", now).
+		Param("until", until)
+	return db.script(id, script, "error when trying to claim webhook delivery")
+}
+
+// Record adds an attempt to the log, keeping the last MaxLog, and moves the
+// delivery to status.
+func (db *webhookDeliveryRepository) Record(id string, status string, attempts int, nextAttemptAt string, attempt webhook.Attempt) rest_errors.RestErr {
+	entry := map[string]interface{}{"at": attempt.At, "duration_ms": attempt.DurationMs}
+	if attempt.StatusCode != 0 {
+		entry["status_code"] = attempt.StatusCode
+	}
+	if attempt.Error != "" {
+		entry["error"] = attempt.Error
+	}
+	script := elastic.NewScript(`
+		if (ctx._source.log == null) {
+			ctx._source.log = [];
+		}
+		ctx._source.log.add(params.entry);
+		while (ctx._source.log.size() > params.max_log) {
+			ctx._source.log.remove(0);
+		}
+		ctx._source.status = params.status;
+		ctx._source.attempts = params.attempts;
+		ctx._source.next_attempt_at = params.next_attempt_at;
+		ctx._source.remove('locked_until');
+		if (params.status == params.delivered) {
+			ctx._source.delivered_at = params.entry.at;
+			ctx._source.remove('last_error');
+		} else {
+			ctx._source.last_error = params.entry.error;
+		}`).
+		Param("entry", entry).
+		Param("max_log", webhook.MaxLog).
+		Param("status", status).
+		Param("attempts", attempts).
+		Param("next_attempt_at", nextAttemptAt).
+		Param("delivered", webhook.STATUS_DELIVERED)
+	_, err := db.script(id, script, "error when trying to record webhook delivery")
+	return err
+}
+
+// Replay makes a dead delivery due again with a fresh set of attempts and
+// reports false if it was not dead.
+func (db *webhookDeliveryRepository) Replay(id string, now string) (bool, rest_errors.RestErr) {
+	return db.script(id, replayScript(now), "error when trying to replay webhook delivery")
+}
+
+func (db *webhookDeliveryRepository) ReplayDead(subscriptionID string, now string) (int64, rest_errors.RestErr) {
+	query := elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("subscription_id.keyword", subscriptionID)).
+		Filter(elastic.NewTermQuery("status.keyword", webhook.STATUS_DEAD))
+	result, err := elasticsearch.Client.UpdateByQuery(indexWebhookDeliveries, query, replayScript(now))
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, rest_errors.NewInternalServerErr("error when trying to replay webhook deliveries", errors.New("database error"))
+	}
+	return result.Updated, nil
+}
+
+func replayScript(now string) *elastic.Script {
+	return elastic.NewScript(`
+		if (ctx._source.status != params.dead) {
+			ctx.op = 'noop';
+		} else {
+			ctx._source.status = params.pending;
+			ctx._source.attempts = 0;
+			ctx._source.next_attempt_at = params.now;
+		}`).
+		Param("dead", webhook.STATUS_DEAD).
+		Param("pending", webhook.STATUS_PENDING).
+		Param("now", now)
+}
+
+func (db *webhookDeliveryRepository) script(id string, script *elastic.Script, message string) (bool, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.UpdateScript(indexWebhookDeliveries, typeProperty, id, script)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return false, rest_errors.NewNotFoundErr(fmt.Sprintf("no webhook delivery was found with id %s", id))
+		}
+		return false, rest_errors.NewInternalServerErr(message, errors.New("database error"))
+	}
+	return result.Result != resultNoop, nil
+}
+
+func (db *webhookDeliveryRepository) DeleteBySubscription(subscriptionID string) rest_errors.RestErr {
+	_, err := db.deleteByQuery(elastic.NewTermQuery("subscription_id.keyword", subscriptionID))
+	return err
+}
+
+// Purge deletes the deliveries made before the given time.
+func (db *webhookDeliveryRepository) Purge(before string) (int64, rest_errors.RestErr) {
+	return db.deleteByQuery(elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("status.keyword", webhook.STATUS_DELIVERED)).
+		Filter(elastic.NewRangeQuery("delivered_at").Lt(before)))
+}
+
+func (db *webhookDeliveryRepository) deleteByQuery(query elastic.Query) (int64, rest_errors.RestErr) {
+	result, err := elasticsearch.Client.DeleteByQuery(indexWebhookDeliveries, query)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, rest_errors.NewInternalServerErr("error when trying to delete webhook deliveries", errors.New("database error"))
+	}
+	return result.Deleted, nil
+}
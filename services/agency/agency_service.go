@@ -0,0 +1,111 @@
+package agency
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/agency"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/domain/query"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	propertyService "github.com/superbkibbles/realestate_property-api/services/property"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+type Service interface {
+	Create(agency.Agency) (*agency.Agency, rest_errors.RestErr)
+	Get(sort string, asc bool, local string) (agency.Agencies, rest_errors.RestErr)
+	GetByID(id string, local string) (*agency.Agency, rest_errors.RestErr)
+	Update(id string, callerAgencyID string, updateRequest agency.UpdateRequest) (*agency.Agency, rest_errors.RestErr)
+	SetVerified(id string, verified bool) (*agency.Agency, rest_errors.RestErr)
+	Delete(id string) rest_errors.RestErr
+	GetProperties(id string, q query.EsQuery, sort string, asc bool, local string, displayCurrency string) (property.Properties, rest_errors.RestErr)
+}
+
+type service struct {
+	agencyRepo      db.AgencyRepository
+	propertyService propertyService.Service
+}
+
+func NewService(agencyRepo db.AgencyRepository, propertyServ propertyService.Service) Service {
+	return &service{
+		agencyRepo:      agencyRepo,
+		propertyService: propertyServ,
+	}
+}
+
+func (s *service) Create(a agency.Agency) (*agency.Agency, rest_errors.RestErr) {
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	// Agencies are verified by an admin after they are created.
+	a.Verified = false
+	a.DateCreated = date_utils.GetNowDBFromat()
+	return s.agencyRepo.Create(a)
+}
+
+func (s *service) Get(sort string, asc bool, local string) (agency.Agencies, rest_errors.RestErr) {
+	agencies, err := s.agencyRepo.Get(sort, asc)
+	if err != nil {
+		return nil, err
+	}
+	for i := range agencies {
+		agencies[i] = agencies[i].Localized(local)
+	}
+	return agencies, nil
+}
+
+func (s *service) GetByID(id string, local string) (*agency.Agency, rest_errors.RestErr) {
+	a, err := s.agencyRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	localized := a.Localized(local)
+	return &localized, nil
+}
+
+// Update only lets an agency edit its own profile.
+func (s *service) Update(id string, callerAgencyID string, updateRequest agency.UpdateRequest) (*agency.Agency, rest_errors.RestErr) {
+	if callerAgencyID == "" || callerAgencyID != id {
+		return nil, rest_errors.NewRestError("agencies can only edit their own profile", http.StatusForbidden, "forbidden", nil)
+	}
+	if err := updateRequest.Validate(); err != nil {
+		return nil, err
+	}
+	return s.agencyRepo.Update(id, updateRequest)
+}
+
+func (s *service) SetVerified(id string, verified bool) (*agency.Agency, rest_errors.RestErr) {
+	return s.agencyRepo.Update(id, agency.UpdateRequest{
+		Fields: []agency.UpdateField{{Field: "verified", Value: verified}},
+	})
+}
+
+// Delete refuses to remove an agency that still has listings.
+func (s *service) Delete(id string) rest_errors.RestErr {
+	if _, err := s.agencyRepo.GetByID(id); err != nil {
+		return err
+	}
+	// Listings waiting to go live count as well.
+	properties, err := s.propertyService.Search(agencyQuery(id, query.EsQuery{Owner: id}), "", false, "", "")
+	if err != nil && err.Status() != http.StatusNotFound {
+		return err
+	}
+	if len(properties) > 0 {
+		return rest_errors.NewRestError(fmt.Sprintf("agency %s still has %d properties", id, len(properties)), http.StatusConflict, "conflict", nil)
+	}
+	return s.agencyRepo.Delete(id)
+}
+
+func (s *service) GetProperties(id string, q query.EsQuery, sort string, asc bool, local string, displayCurrency string) (property.Properties, rest_errors.RestErr) {
+	if _, err := s.agencyRepo.GetByID(id); err != nil {
+		return nil, err
+	}
+	return s.propertyService.Search(agencyQuery(id, q), sort, asc, local, displayCurrency)
+}
+
+func agencyQuery(id string, q query.EsQuery) query.EsQuery {
+	q.Filters = append(q.Filters, query.FieldValue{Field: "agency_id", Value: id})
+	return q
+}
@@ -0,0 +1,79 @@
+package amenity
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/amenity"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+type Service interface {
+	Create(amenity.Amenity) (*amenity.Amenity, rest_errors.RestErr)
+	Get(local string) (amenity.Amenities, rest_errors.RestErr)
+	GetByID(id string, local string) (*amenity.Amenity, rest_errors.RestErr)
+	Update(id string, updateRequest amenity.UpdateRequest) (*amenity.Amenity, rest_errors.RestErr)
+	Delete(id string) rest_errors.RestErr
+}
+
+type service struct {
+	amenityRepo db.AmenityRepository
+	dbRepo      db.DbRepository
+}
+
+func NewService(amenityRepo db.AmenityRepository, dbRepo db.DbRepository) Service {
+	return &service{
+		amenityRepo: amenityRepo,
+		dbRepo:      dbRepo,
+	}
+}
+
+func (s *service) Create(a amenity.Amenity) (*amenity.Amenity, rest_errors.RestErr) {
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	a.Label = ""
+	a.DateCreated = date_utils.GetNowDBFromat()
+	return s.amenityRepo.Create(a)
+}
+
+func (s *service) Get(local string) (amenity.Amenities, rest_errors.RestErr) {
+	amenities, err := s.amenityRepo.Get()
+	if err != nil {
+		return nil, err
+	}
+	return amenities.Localized(local), nil
+}
+
+func (s *service) GetByID(id string, local string) (*amenity.Amenity, rest_errors.RestErr) {
+	a, err := s.amenityRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	a.Label = a.LabelFor(local)
+	return a, nil
+}
+
+func (s *service) Update(id string, updateRequest amenity.UpdateRequest) (*amenity.Amenity, rest_errors.RestErr) {
+	if err := updateRequest.Validate(); err != nil {
+		return nil, err
+	}
+	return s.amenityRepo.Update(id, updateRequest)
+}
+
+// Delete refuses to remove an amenity that properties still have.
+func (s *service) Delete(id string) rest_errors.RestErr {
+	if _, err := s.amenityRepo.GetByID(id); err != nil {
+		return err
+	}
+	count, err := s.dbRepo.CountByAmenity(id)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return rest_errors.NewRestError(fmt.Sprintf("amenity %s is used by %d properties", id, count), http.StatusConflict, "conflict", nil)
+	}
+	return s.amenityRepo.Delete(id)
+}
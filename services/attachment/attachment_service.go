@@ -0,0 +1,241 @@
+package attachment
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/audit"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+	cloudstorage "github.com/superbkibbles/realestate_property-api/repository/cloudStorage"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/utils/crypto_utils"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+	"github.com/superbkibbles/realestate_property-api/utils/media_utils"
+)
+
+const (
+	defaultLinkTTL = 15 * time.Minute
+	downloadPath   = "/api/property/%s/attachments/%s/download"
+)
+
+type Service interface {
+	Upload(propertyID string, request property.AttachmentRequest, actor audit.Actor) (*property.Attachment, rest_errors.RestErr)
+	Delete(propertyID string, attachmentID string, actor audit.Actor) rest_errors.RestErr
+	Link(propertyID string, attachmentID string, agencyID string) (*property.AttachmentLink, rest_errors.RestErr)
+	Open(propertyID string, attachmentID string, expires string, signature string) (io.ReadCloser, *property.Attachment, rest_errors.RestErr)
+}
+
+// Updater writes listing changes through the property service, so they are
+// audited, published and seen by its watchers.
+type Updater interface {
+	Apply(id string, updateRequest property.EsUpdate, actor audit.Actor) (*property.Property, rest_errors.RestErr)
+}
+
+type Config struct {
+	// SigningSecret signs the download links this service issues itself.
+	SigningSecret string
+	PublicURL     string
+	LinkTTL       time.Duration
+}
+
+type service struct {
+	dbRepo    db.DbRepository
+	cloudRepo cloudstorage.CloudStorage
+	updater   Updater
+	config    Config
+}
+
+func NewService(dbRepo db.DbRepository, cloudRepo cloudstorage.CloudStorage, updater Updater, config Config) Service {
+	if config.LinkTTL <= 0 {
+		config.LinkTTL = defaultLinkTTL
+	}
+	return &service{
+		dbRepo:    dbRepo,
+		cloudRepo: cloudRepo,
+		updater:   updater,
+		config:    config,
+	}
+}
+
+func (s *service) Upload(propertyID string, request property.AttachmentRequest, actor audit.Actor) (*property.Attachment, rest_errors.RestErr) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	p, err := s.dbRepo.GetByID(propertyID)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Attachments) >= property.MaxAttachments {
+		return nil, rest_errors.NewBadRequestErr(fmt.Sprintf("a property can have at most %d attachments", property.MaxAttachments))
+	}
+
+	file, openErr := request.File.Open()
+	if openErr != nil {
+		return nil, rest_errors.NewInternalServerErr("Error while trying to open the file", nil)
+	}
+	defer file.Close()
+	content, readErr := ioutil.ReadAll(io.LimitReader(file, property.MaxAttachmentSize+1))
+	if readErr != nil {
+		return nil, rest_errors.NewInternalServerErr("Error while trying to read the file", nil)
+	}
+	if len(content) > property.MaxAttachmentSize {
+		return nil, rest_errors.NewBadRequestErr(fmt.Sprintf("file exceeds the maximum size of %d bytes", property.MaxAttachmentSize))
+	}
+	head := content
+	if len(head) > media_utils.SniffLen {
+		head = head[:media_utils.SniffLen]
+	}
+	mimeType, err := request.ValidateContent(head)
+	if err != nil {
+		return nil, err
+	}
+	if mimeType != media_utils.MIME_PDF {
+		stripped, stripErr := media_utils.StripMetadata(mimeType, content)
+		if stripErr != nil {
+			return nil, rest_errors.NewBadRequestErr("malformed image")
+		}
+		content = stripped
+	}
+
+	attachment := property.Attachment{
+		ID:          crypto_utils.GetMd5(uuid.New().String()),
+		Kind:        request.Kind,
+		Title:       request.Title,
+		Labels:      request.Labels,
+		Visibility:  request.Visibility,
+		Size:        int64(len(content)),
+		DateCreated: date_utils.GetNowDBFromat(),
+	}
+	save := s.cloudRepo.Save
+	if attachment.IsPrivate() {
+		save = s.cloudRepo.SavePrivate
+	}
+	res, err := save(bytes.NewReader(content), attachment.ID, propertyID)
+	if err != nil {
+		return nil, err
+	}
+	attachment.Url = res.Url
+	attachment.PublicID = res.PublicID
+	attachment.FileType = res.Ext
+
+	if err := s.setAttachments(propertyID, append(p.Attachments, attachment), actor); err != nil {
+		s.deleteFile(propertyID, attachment)
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+func (s *service) Delete(propertyID string, attachmentID string, actor audit.Actor) rest_errors.RestErr {
+	p, err := s.dbRepo.GetByID(propertyID)
+	if err != nil {
+		return err
+	}
+	attachments := make([]property.Attachment, 0, len(p.Attachments))
+	var removed *property.Attachment
+	for i, a := range p.Attachments {
+		if a.ID == attachmentID {
+			removed = &p.Attachments[i]
+			continue
+		}
+		attachments = append(attachments, a)
+	}
+	if removed == nil {
+		return rest_errors.NewNotFoundErr(fmt.Sprintf("no attachment was found with id %s", attachmentID))
+	}
+	if err := s.setAttachments(propertyID, attachments, actor); err != nil {
+		return err
+	}
+	return s.deleteFile(propertyID, *removed)
+}
+
+// Link returns the url of a public attachment, or an expiring link to an
+// agency only one for the agency of the listing.
+func (s *service) Link(propertyID string, attachmentID string, agencyID string) (*property.AttachmentLink, rest_errors.RestErr) {
+	p, attachment, err := s.find(propertyID, attachmentID)
+	if err != nil {
+		return nil, err
+	}
+	if !attachment.IsPrivate() {
+		return &property.AttachmentLink{Url: attachment.Url}, nil
+	}
+	if agencyID == "" || agencyID != p.AgencyID {
+		return nil, rest_errors.NewRestError("this document is only available to the agency of the listing", http.StatusForbidden, "forbidden", nil)
+	}
+
+	expiresAt := date_utils.GetNow().Add(s.config.LinkTTL)
+	link, err := s.cloudRepo.PrivateURL(attachment.PublicID, propertyID, attachment.FileType, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if link == "" {
+		expires := strconv.FormatInt(expiresAt.Unix(), 10)
+		link = fmt.Sprintf("%s"+downloadPath+"?expires=%s&signature=%s",
+			s.config.PublicURL, propertyID, attachmentID, expires, s.signature(propertyID, attachmentID, expires))
+	}
+	return &property.AttachmentLink{
+		Url:       link,
+		ExpiresAt: date_utils.GetDBFormat(expiresAt),
+	}, nil
+}
+
+// Open serves an agency only attachment to the holder of a link issued by
+// Link.
+func (s *service) Open(propertyID string, attachmentID string, expires string, signature string) (io.ReadCloser, *property.Attachment, rest_errors.RestErr) {
+	expiresAt, parseErr := strconv.ParseInt(expires, 10, 64)
+	if parseErr != nil || !crypto_utils.VerifyHmacSha256(s.config.SigningSecret, propertyID+"|"+attachmentID+"|"+expires, signature) {
+		return nil, nil, rest_errors.NewUnauthorizedError("invalid download link")
+	}
+	if date_utils.GetNow().Unix() > expiresAt {
+		return nil, nil, rest_errors.NewUnauthorizedError("download link expired")
+	}
+	_, attachment, err := s.find(propertyID, attachmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !attachment.IsPrivate() {
+		return nil, nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no private attachment was found with id %s", attachmentID))
+	}
+	file, err := s.cloudRepo.OpenPrivate(attachment.PublicID, propertyID, attachment.FileType)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, attachment, nil
+}
+
+func (s *service) find(propertyID string, attachmentID string) (*property.Property, *property.Attachment, rest_errors.RestErr) {
+	p, err := s.dbRepo.GetByID(propertyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range p.Attachments {
+		if p.Attachments[i].ID == attachmentID {
+			return p, &p.Attachments[i], nil
+		}
+	}
+	return nil, nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no attachment was found with id %s", attachmentID))
+}
+
+func (s *service) setAttachments(propertyID string, attachments []property.Attachment, actor audit.Actor) rest_errors.RestErr {
+	_, err := s.updater.Apply(propertyID, property.EsUpdate{
+		Fields: []property.UpdatePropertyRequest{{Field: "attachments", Value: attachments}},
+	}, actor)
+	return err
+}
+
+func (s *service) deleteFile(propertyID string, attachment property.Attachment) rest_errors.RestErr {
+	if attachment.IsPrivate() {
+		return s.cloudRepo.DeletePrivate(attachment.PublicID, propertyID)
+	}
+	return s.cloudRepo.Delete(propertyID + "/" + attachment.PublicID)
+}
+
+func (s *service) signature(propertyID string, attachmentID string, expires string) string {
+	return crypto_utils.GetHmacSha256(s.config.SigningSecret, propertyID+"|"+attachmentID+"|"+expires)
+}
@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/logger"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/audit"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+type Service interface {
+	Record(entry *audit.Entry)
+	GetByProperty(propertyID string, agencyID string, q audit.Query) (audit.Entries, rest_errors.RestErr)
+	GetByActor(userID string, q audit.Query) (audit.Entries, rest_errors.RestErr)
+	AsOf(propertyID string, agencyID string, at string) (*property.Property, rest_errors.RestErr)
+}
+
+type service struct {
+	auditRepo db.AuditRepository
+	dbRepo    db.DbRepository
+}
+
+func NewService(auditRepo db.AuditRepository, dbRepo db.DbRepository) Service {
+	return &service{
+		auditRepo: auditRepo,
+		dbRepo:    dbRepo,
+	}
+}
+
+// Record writes entry, if there is one, once the mutation succeeded. A
+// failing write is logged; the mutation can not be undone anymore.
+func (s *service) Record(entry *audit.Entry) {
+	if entry == nil {
+		return
+	}
+	entry.Timestamp = date_utils.GetNow().Format(audit.TimeLayout)
+	if _, err := s.auditRepo.Create(*entry); err != nil {
+		logger.Error(fmt.Sprintf("error while recording %s of property %s", entry.Action, entry.PropertyID), errors.New(err.Message()))
+	}
+}
+
+func (s *service) GetByProperty(propertyID string, agencyID string, q audit.Query) (audit.Entries, rest_errors.RestErr) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := s.property(propertyID, agencyID); err != nil {
+		return nil, err
+	}
+	return s.auditRepo.Find("property_id", propertyID, q)
+}
+
+func (s *service) GetByActor(userID string, q audit.Query) (audit.Entries, rest_errors.RestErr) {
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return nil, rest_errors.NewBadRequestErr("invalid user id")
+	}
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	return s.auditRepo.Find("actor.user_id", userID, q)
+}
+
+// AsOf shows the listing as it was at, by undoing every change made since.
+func (s *service) AsOf(propertyID string, agencyID string, at string) (*property.Property, rest_errors.RestErr) {
+	t, parseErr := time.Parse(time.RFC3339, strings.TrimSpace(at))
+	if parseErr != nil {
+		return nil, rest_errors.NewBadRequestErr("at must be an RFC 3339 time")
+	}
+	current, err := s.property(propertyID, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	if t.After(date_utils.GetNow()) {
+		return current, nil
+	}
+
+	entries, err := s.auditRepo.GetSince(propertyID, t.UTC().Format(audit.TimeLayout))
+	if err != nil {
+		return nil, err
+	}
+	p, existed := audit.AsOf(*current, entries)
+	if !existed {
+		return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("property %s did not exist at %s", propertyID, at))
+	}
+	return p, nil
+}
+
+// property returns the listing if agencyID owns it.
+func (s *service) property(propertyID string, agencyID string) (*property.Property, rest_errors.RestErr) {
+	p, err := s.dbRepo.GetByID(propertyID)
+	if err != nil {
+		return nil, err
+	}
+	if agencyID == "" || agencyID != p.AgencyID {
+		return nil, rest_errors.NewRestError("the audit log of a listing can only be read by its agency", http.StatusForbidden, "forbidden", nil)
+	}
+	return p, nil
+}
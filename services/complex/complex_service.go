@@ -0,0 +1,228 @@
+package complex
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/google/uuid"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/complex"
+	domainCurrency "github.com/superbkibbles/realestate_property-api/domain/currency"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/domain/query"
+	cloudstorage "github.com/superbkibbles/realestate_property-api/repository/cloudStorage"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/services/currency"
+	propertyService "github.com/superbkibbles/realestate_property-api/services/property"
+	"github.com/superbkibbles/realestate_property-api/utils/crypto_utils"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+type Service interface {
+	Create(complex.Complex) (*complex.Complex, rest_errors.RestErr)
+	Get(sort string, asc bool) (complex.Complexes, rest_errors.RestErr)
+	GetByID(id string) (*complex.Complex, rest_errors.RestErr)
+	Update(id string, updateRequest complex.UpdateRequest) (*complex.Complex, rest_errors.RestErr)
+	Delete(id string) rest_errors.RestErr
+	UploadMedia(id string, request property.UploadMediaRequest) (*complex.Complex, rest_errors.RestErr)
+	DeleteMedia(id string, mediaID string) rest_errors.RestErr
+	GetUnits(id string, q query.EsQuery, sort string, asc bool, local string, displayCurrency string) (property.Properties, rest_errors.RestErr)
+	GetStats(id string, displayCurrency string) (*complex.Stats, rest_errors.RestErr)
+}
+
+type service struct {
+	complexRepo     db.ComplexRepository
+	dbRepo          db.DbRepository
+	cloudRepo       cloudstorage.CloudStorage
+	propertyService propertyService.Service
+	currencyService currency.Service
+}
+
+func NewService(complexRepo db.ComplexRepository, dbRepo db.DbRepository, cloudRepo cloudstorage.CloudStorage, propertyServ propertyService.Service, currencyService currency.Service) Service {
+	return &service{
+		complexRepo:     complexRepo,
+		dbRepo:          dbRepo,
+		cloudRepo:       cloudRepo,
+		propertyService: propertyServ,
+		currencyService: currencyService,
+	}
+}
+
+func (s *service) Create(c complex.Complex) (*complex.Complex, rest_errors.RestErr) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	// Media is only added through the upload endpoint.
+	c.Media = nil
+	c.DateCreated = date_utils.GetNowDBFromat()
+	return s.complexRepo.Create(c)
+}
+
+func (s *service) Get(sort string, asc bool) (complex.Complexes, rest_errors.RestErr) {
+	return s.complexRepo.Get(sort, asc)
+}
+
+func (s *service) GetByID(id string) (*complex.Complex, rest_errors.RestErr) {
+	return s.complexRepo.GetByID(id)
+}
+
+// Update copies a new name onto every unit so listings can still be searched
+// by complex name.
+func (s *service) Update(id string, updateRequest complex.UpdateRequest) (*complex.Complex, rest_errors.RestErr) {
+	if err := updateRequest.Validate(); err != nil {
+		return nil, err
+	}
+	current, err := s.complexRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.complexRepo.Update(id, updateRequest)
+	if err != nil {
+		return nil, err
+	}
+	if name, ok := updateRequest.Name(); ok && name != current.Name {
+		if err := s.dbRepo.SetComplexName(id, name); err != nil {
+			return nil, err
+		}
+	}
+	return updated, nil
+}
+
+// Delete refuses to remove a complex that still has units.
+func (s *service) Delete(id string) rest_errors.RestErr {
+	c, err := s.complexRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	stats, err := s.complexRepo.UnitStats(id)
+	if err != nil {
+		return err
+	}
+	if stats.Units > 0 {
+		return rest_errors.NewRestError(fmt.Sprintf("complex %s still has %d units", id, stats.Units), http.StatusConflict, "conflict", nil)
+	}
+	if err := s.complexRepo.Delete(id); err != nil {
+		return err
+	}
+	for _, m := range c.Media {
+		s.cloudRepo.Delete(c.Folder() + "/" + m.PublicID)
+	}
+	return nil
+}
+
+func (s *service) UploadMedia(id string, request property.UploadMediaRequest) (*complex.Complex, rest_errors.RestErr) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	c, err := s.complexRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	media := c.Media
+	var uploaded []string
+	for _, file := range request.Media {
+		m, err := s.upload(file, c.Folder())
+		if err != nil {
+			for _, publicID := range uploaded {
+				s.cloudRepo.Delete(c.Folder() + "/" + publicID)
+			}
+			return nil, err
+		}
+		uploaded = append(uploaded, m.PublicID)
+		media = append(media, *m)
+	}
+
+	updated, err := s.complexRepo.UpdateMedia(id, media)
+	if err != nil {
+		for _, publicID := range uploaded {
+			s.cloudRepo.Delete(c.Folder() + "/" + publicID)
+		}
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (s *service) upload(file property.MediaFile, folder string) (*complex.Media, rest_errors.RestErr) {
+	reader, openErr := file.Open()
+	if openErr != nil {
+		return nil, rest_errors.NewInternalServerErr("Error while trying to open the file", nil)
+	}
+	defer reader.Close()
+
+	res, err := s.cloudRepo.Save(reader, crypto_utils.GetMd5(uuid.New().String()), folder)
+	if err != nil {
+		return nil, err
+	}
+	return &complex.Media{
+		Url:      res.Url,
+		FileType: res.Ext,
+		PublicID: res.PublicID,
+		Kind:     file.Kind,
+	}, nil
+}
+
+func (s *service) DeleteMedia(id string, mediaID string) rest_errors.RestErr {
+	c, err := s.complexRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	media := make([]complex.Media, 0, len(c.Media))
+	var removed *complex.Media
+	for i, m := range c.Media {
+		if path.Base(m.PublicID) == mediaID {
+			removed = &c.Media[i]
+			continue
+		}
+		media = append(media, m)
+	}
+	if removed == nil {
+		return rest_errors.NewNotFoundErr(fmt.Sprintf("no media was found with id %s", mediaID))
+	}
+	if _, err := s.complexRepo.UpdateMedia(id, media); err != nil {
+		return err
+	}
+	return s.cloudRepo.Delete(c.Folder() + "/" + removed.PublicID)
+}
+
+func (s *service) GetUnits(id string, q query.EsQuery, sort string, asc bool, local string, displayCurrency string) (property.Properties, rest_errors.RestErr) {
+	if _, err := s.complexRepo.GetByID(id); err != nil {
+		return nil, err
+	}
+	q.Filters = append(q.Filters, query.FieldValue{Field: "complex_id", Value: id})
+	return s.propertyService.Search(q, sort, asc, local, displayCurrency)
+}
+
+// GetStats returns the price range in displayCurrency, or in the base
+// currency when none is given.
+func (s *service) GetStats(id string, displayCurrency string) (*complex.Stats, rest_errors.RestErr) {
+	if _, err := s.complexRepo.GetByID(id); err != nil {
+		return nil, err
+	}
+	stats, err := s.complexRepo.UnitStats(id)
+	if err != nil {
+		return nil, err
+	}
+
+	rates := s.currencyService.GetRates()
+	target := rates.Base
+	if displayCurrency != "" {
+		if err := domainCurrency.Validate(displayCurrency); err != nil {
+			return nil, err
+		}
+		target = domainCurrency.Normalize(displayCurrency)
+	}
+	for _, price := range []*domainCurrency.Money{stats.MinPrice, stats.MaxPrice} {
+		if price == nil {
+			continue
+		}
+		amount, err := rates.Convert(float64(price.Amount), rates.Base, target)
+		if err != nil {
+			return nil, err
+		}
+		price.Amount = amount
+		price.Currency = target
+	}
+	return stats, nil
+}
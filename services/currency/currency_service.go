@@ -0,0 +1,204 @@
+package currency
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/superbkibbles/bookstore_utils-go/logger"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/currency"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/domain/query"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+// Price fields clients filter and sort on, and the stored base currency
+// field each one is compared through.
+var baseFields = map[string]string{
+	"price":                     "base_price",
+	"rental_terms.monthly_rent": "rental_terms.monthly_base_rent",
+}
+
+type Service interface {
+	GetRates() currency.Rates
+	SetRates(rates currency.Rates) (*currency.Rates, rest_errors.RestErr)
+	Backfill() (int64, rest_errors.RestErr)
+	ToBase(amount int64, code string) (int64, rest_errors.RestErr)
+	NormalizeQuery(q *query.EsQuery, code string) rest_errors.RestErr
+	NormalizeSort(sort string) string
+	Display(properties property.Properties, code string) rest_errors.RestErr
+}
+
+type service struct {
+	dbRepo    db.DbRepository
+	ratesFile string
+
+	mu    sync.RWMutex
+	rates currency.Rates
+}
+
+// NewService loads the exchange rates from ratesFile. Without a usable file
+// only prices in base can be normalized until rates are set.
+func NewService(dbRepo db.DbRepository, ratesFile string, base string) Service {
+	s := &service{
+		dbRepo:    dbRepo,
+		ratesFile: ratesFile,
+		rates: currency.Rates{
+			Base:  currency.Normalize(base),
+			Rates: map[string]float64{currency.Normalize(base): 1},
+		},
+	}
+	if err := s.load(); err != nil {
+		logger.Info(fmt.Sprintf("could not load currency rates from %s: %s", ratesFile, err.Message()))
+	}
+	return s
+}
+
+func (s *service) load() rest_errors.RestErr {
+	bytes, err := ioutil.ReadFile(s.ratesFile)
+	if err != nil {
+		return rest_errors.NewInternalServerErr("error while reading rates file", err)
+	}
+	var rates currency.Rates
+	if err := json.Unmarshal(bytes, &rates); err != nil {
+		return rest_errors.NewInternalServerErr("error while parsing rates file", err)
+	}
+	if err := rates.Validate(); err != nil {
+		return err
+	}
+	if rates.Base != s.rates.Base {
+		return rest_errors.NewBadRequestErr(fmt.Sprintf("rates file is based on %s, expected %s", rates.Base, s.rates.Base))
+	}
+	s.rates = rates
+	return nil
+}
+
+func (s *service) GetRates() currency.Rates {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rates
+}
+
+// SetRates replaces the rates, writes them back to the rates file and
+// renormalizes the stored base prices.
+func (s *service) SetRates(rates currency.Rates) (*currency.Rates, rest_errors.RestErr) {
+	if rates.Base == "" {
+		rates.Base = s.GetRates().Base
+	}
+	if err := rates.Validate(); err != nil {
+		return nil, err
+	}
+	if rates.Base != s.GetRates().Base {
+		return nil, rest_errors.NewBadRequestErr(fmt.Sprintf("rates must be based on %s", s.GetRates().Base))
+	}
+	rates.UpdatedAt = date_utils.GetNowISO()
+
+	s.mu.Lock()
+	s.rates = rates
+	s.mu.Unlock()
+
+	if s.ratesFile != "" {
+		bytes, _ := json.MarshalIndent(rates, "", "  ")
+		if err := ioutil.WriteFile(s.ratesFile, bytes, 0644); err != nil {
+			logger.Error("error while writing rates file", err)
+		}
+	}
+	if err := s.dbRepo.UpdateBasePrices(rates.Rates); err != nil {
+		return nil, err
+	}
+	return &rates, nil
+}
+
+// Backfill sets the base amounts of listings stored before prices were
+// normalized, which searching or sorting by price would leave out otherwise.
+// Listings in a currency without a rate are left until one is set.
+func (s *service) Backfill() (int64, rest_errors.RestErr) {
+	return s.dbRepo.BackfillBasePrices(s.GetRates().Rates)
+}
+
+func (s *service) ToBase(amount int64, code string) (int64, rest_errors.RestErr) {
+	if err := currency.Validate(code); err != nil {
+		return 0, err
+	}
+	rates := s.GetRates()
+	if _, ok := rates.Rates[currency.Normalize(code)]; !ok {
+		return 0, rest_errors.NewBadRequestErr(fmt.Sprintf("no exchange rate for %s", code))
+	}
+	return rates.Convert(float64(amount), code, rates.Base)
+}
+
+// NormalizeQuery rewrites price and rent filters, given in code, into filters
+// on the stored base amounts so listings in different currencies compare
+// correctly.
+func (s *service) NormalizeQuery(q *query.EsQuery, code string) rest_errors.RestErr {
+	rates := s.GetRates()
+	if code == "" {
+		code = rates.Base
+	}
+	toBase := func(value interface{}) (interface{}, rest_errors.RestErr) {
+		amount, ok := value.(float64)
+		if !ok {
+			return value, nil
+		}
+		return rates.Convert(amount, code, rates.Base)
+	}
+
+	for i, fRange := range q.Range {
+		baseField, ok := baseFields[fRange.Field]
+		if !ok {
+			continue
+		}
+		from, err := toBase(fRange.From)
+		if err != nil {
+			return err
+		}
+		to, err := toBase(fRange.To)
+		if err != nil {
+			return err
+		}
+		q.Range[i] = query.RangeStruct{Field: baseField, From: from, To: to}
+	}
+	for i, gtFilter := range q.Gt {
+		baseField, ok := baseFields[gtFilter.Field]
+		if !ok {
+			continue
+		}
+		value, err := toBase(gtFilter.Value)
+		if err != nil {
+			return err
+		}
+		q.Gt[i] = query.GtValue{Field: baseField, Value: value}
+	}
+	return nil
+}
+
+func (s *service) NormalizeSort(sort string) string {
+	if baseField, ok := baseFields[sort]; ok {
+		return baseField
+	}
+	return sort
+}
+
+// Display adds the price converted into code to every property. Listings
+// whose own currency has no rate are left without one.
+func (s *service) Display(properties property.Properties, code string) rest_errors.RestErr {
+	if code == "" {
+		return nil
+	}
+	if err := currency.Validate(code); err != nil {
+		return err
+	}
+	rates := s.GetRates()
+	target := currency.Normalize(code)
+	for i := range properties {
+		amount, err := rates.Convert(float64(properties[i].Price), properties[i].Currency, target)
+		if err != nil {
+			continue
+		}
+		properties[i].DisplayPrice = &currency.Money{Amount: amount, Currency: target}
+	}
+	return nil
+}
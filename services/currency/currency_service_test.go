@@ -0,0 +1,74 @@
+package currency
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/superbkibbles/realestate_property-api/domain/currency"
+	"github.com/superbkibbles/realestate_property-api/domain/query"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	s := &service{rates: currency.Rates{Base: "USD", Rates: map[string]float64{"USD": 1, "IQD": 1310}}}
+	tests := []struct {
+		name     string
+		code     string
+		q        query.EsQuery
+		expected query.EsQuery
+		status   int
+	}{
+		{
+			name:     "range in another currency",
+			code:     "IQD",
+			q:        query.EsQuery{Range: []query.RangeStruct{{Field: "price", From: float64(131000), To: float64(262000)}}},
+			expected: query.EsQuery{Range: []query.RangeStruct{{Field: "base_price", From: int64(100), To: int64(200)}}},
+		},
+		{
+			name:     "open range in base",
+			q:        query.EsQuery{Range: []query.RangeStruct{{Field: "price", From: float64(100)}}},
+			expected: query.EsQuery{Range: []query.RangeStruct{{Field: "base_price", From: int64(100), To: nil}}},
+		},
+		{
+			name:     "greater than",
+			code:     "iqd",
+			q:        query.EsQuery{Gt: []query.GtValue{{Field: "price", Value: float64(1310)}}},
+			expected: query.EsQuery{Gt: []query.GtValue{{Field: "base_price", Value: int64(1)}}},
+		},
+		{
+			name:     "monthly rent",
+			code:     "IQD",
+			q:        query.EsQuery{Range: []query.RangeStruct{{Field: "rental_terms.monthly_rent", To: float64(655000)}}},
+			expected: query.EsQuery{Range: []query.RangeStruct{{Field: "rental_terms.monthly_base_rent", From: nil, To: int64(500)}}},
+		},
+		{
+			name:     "other fields are kept",
+			code:     "IQD",
+			q:        query.EsQuery{Range: []query.RangeStruct{{Field: "area", From: float64(100)}}},
+			expected: query.EsQuery{Range: []query.RangeStruct{{Field: "area", From: float64(100)}}},
+		},
+		{
+			name:   "currency without a rate",
+			code:   "EUR",
+			q:      query.EsQuery{Range: []query.RangeStruct{{Field: "price", From: float64(100)}}},
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.NormalizeQuery(&tt.q, tt.code)
+			if tt.status != 0 {
+				if err == nil || err.Status() != tt.status {
+					t.Fatalf("expected status %d, got %v", tt.status, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err.Message())
+			}
+			if !reflect.DeepEqual(tt.q, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, tt.q)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package duplicate
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/superbkibbles/bookstore_utils-go/logger"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/audit"
+	"github.com/superbkibbles/realestate_property-api/domain/duplicate"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+const DefaultSuspectScore = 0.5
+
+type Service interface {
+	Check(p property.Property) (duplicate.Matches, rest_errors.RestErr)
+	Block(matches duplicate.Matches) rest_errors.RestErr
+	Record(propertyID string, matches duplicate.Matches) (int, rest_errors.RestErr)
+	Scan() (*duplicate.ScanReport, rest_errors.RestErr)
+	Get(status string) (duplicate.Suspects, rest_errors.RestErr)
+	Dismiss(id string, userID string) (*duplicate.Suspect, rest_errors.RestErr)
+	Merge(id string, request duplicate.MergeRequest, actor audit.Actor) (*duplicate.Suspect, rest_errors.RestErr)
+}
+
+// Updater writes listing changes through the property service, so they are
+// audited, published and seen by its watchers.
+type Updater interface {
+	Apply(id string, updateRequest property.EsUpdate, actor audit.Actor) (*property.Property, rest_errors.RestErr)
+}
+
+// Config sets the score a pair needs to be reported and, when BlockScore is
+// above zero, the score at which a new listing is refused outright.
+type Config struct {
+	SuspectScore float64
+	BlockScore   float64
+}
+
+type service struct {
+	dbRepo        db.DbRepository
+	duplicateRepo db.DuplicateRepository
+	updater       Updater
+	config        Config
+}
+
+func NewService(dbRepo db.DbRepository, duplicateRepo db.DuplicateRepository, updater Updater, config Config) Service {
+	if config.SuspectScore <= 0 {
+		config.SuspectScore = DefaultSuspectScore
+	}
+	return &service{
+		dbRepo:        dbRepo,
+		duplicateRepo: duplicateRepo,
+		updater:       updater,
+		config:        config,
+	}
+}
+
+// Check scores p against the active listings that could be the same unit and
+// returns those at or above the suspect score, most likely first.
+func (s *service) Check(p property.Property) (duplicate.Matches, rest_errors.RestErr) {
+	candidates, err := s.dbRepo.DuplicateCandidates(p)
+	if err != nil {
+		return nil, err
+	}
+	matches := duplicate.Matches{}
+	for _, candidate := range candidates {
+		if candidate.ID == p.ID {
+			continue
+		}
+		score, reasons := duplicate.Score(p, candidate)
+		if score < s.config.SuspectScore {
+			continue
+		}
+		matches = append(matches, duplicate.Match{PropertyID: candidate.ID, Score: score, Reasons: reasons})
+	}
+	matches.Sort()
+	return matches, nil
+}
+
+// Block refuses a new listing that is an obvious copy of an existing one.
+func (s *service) Block(matches duplicate.Matches) rest_errors.RestErr {
+	if s.config.BlockScore <= 0 || len(matches) == 0 || matches[0].Score < s.config.BlockScore {
+		return nil
+	}
+	causes := make([]interface{}, 0, len(matches))
+	for _, m := range matches {
+		if m.Score >= s.config.BlockScore {
+			causes = append(causes, m)
+		}
+	}
+	return rest_errors.NewRestError(fmt.Sprintf("listing looks like a duplicate of %s", matches[0].PropertyID), http.StatusConflict, "duplicate", causes)
+}
+
+// Record stores the matches of a listing for review and returns how many
+// pairs were new.
+func (s *service) Record(propertyID string, matches duplicate.Matches) (int, rest_errors.RestErr) {
+	created := 0
+	for _, m := range matches {
+		isNew, err := s.duplicateRepo.Create(duplicate.Suspect{
+			ID:            duplicate.PairID(propertyID, m.PropertyID),
+			PropertyID:    propertyID,
+			DuplicateOfID: m.PropertyID,
+			Score:         m.Score,
+			Reasons:       m.Reasons,
+			Status:        duplicate.STATUS_OPEN,
+			DateCreated:   date_utils.GetNowDBFromat(),
+		})
+		if err != nil {
+			return created, err
+		}
+		if isNew {
+			created++
+		}
+	}
+	return created, nil
+}
+
+// Scan checks every active listing, catching duplicates of listings that were
+// imported or edited after they were created.
+func (s *service) Scan() (*duplicate.ScanReport, rest_errors.RestErr) {
+	report := duplicate.ScanReport{StartedAt: date_utils.GetNowDBFromat()}
+	properties, err := s.dbRepo.GetActive("", false)
+	if err != nil && err.Status() != http.StatusNotFound {
+		return nil, err
+	}
+	for _, p := range properties {
+		report.Scanned++
+		matches, err := s.Check(p)
+		if err == nil {
+			var created int
+			created, err = s.Record(p.ID, matches)
+			report.Suspects += created
+		}
+		if err != nil {
+			report.Failed++
+			logger.Error(fmt.Sprintf("error while checking property %s for duplicates", p.ID), errors.New(err.Message()))
+		}
+	}
+	report.FinishedAt = date_utils.GetNowDBFromat()
+	return &report, nil
+}
+
+func (s *service) Get(status string) (duplicate.Suspects, rest_errors.RestErr) {
+	if err := duplicate.ValidateStatus(status); err != nil {
+		return nil, err
+	}
+	return s.duplicateRepo.Get(status)
+}
+
+// Dismiss marks a pair as distinct listings; it is not reported again.
+func (s *service) Dismiss(id string, userID string) (*duplicate.Suspect, rest_errors.RestErr) {
+	suspect, err := s.open(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.duplicateRepo.Resolve(suspect.ID, duplicate.STATUS_DISMISSED, "", userID, date_utils.GetNowDBFromat())
+}
+
+// Merge keeps one listing of the pair and deactivates the other, pointing it
+// at the one that was kept.
+func (s *service) Merge(id string, request duplicate.MergeRequest, actor audit.Actor) (*duplicate.Suspect, rest_errors.RestErr) {
+	suspect, err := s.open(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := request.Validate(*suspect); err != nil {
+		return nil, err
+	}
+	if _, err := s.dbRepo.GetByID(request.Keep); err != nil {
+		return nil, err
+	}
+	if _, err := s.updater.Apply(suspect.Other(request.Keep), property.EsUpdate{Fields: []property.UpdatePropertyRequest{
+		{Field: "status", Value: property.STATUS_DEACTIVE},
+		{Field: "merged_into", Value: request.Keep},
+		{Field: "open_houses", Value: []property.OpenHouse{}},
+	}}, actor); err != nil {
+		return nil, err
+	}
+	return s.duplicateRepo.Resolve(suspect.ID, duplicate.STATUS_MERGED, request.Keep, actor.UserID, date_utils.GetNowDBFromat())
+}
+
+func (s *service) open(id string) (*duplicate.Suspect, rest_errors.RestErr) {
+	suspect, err := s.duplicateRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if suspect.Status != duplicate.STATUS_OPEN {
+		return nil, rest_errors.NewRestError(fmt.Sprintf("duplicate %s is already %s", id, suspect.Status), http.StatusConflict, "conflict", nil)
+	}
+	return suspect, nil
+}
@@ -0,0 +1,273 @@
+package event
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/superbkibbles/bookstore_utils-go/logger"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/eventsink"
+	"github.com/superbkibbles/realestate_property-api/domain/event"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+const (
+	defaultMaxAttempts = 10
+	defaultLock        = time.Minute
+	defaultRetention   = 7 * 24 * time.Hour
+
+	publishAttempts   = 3
+	publishRetryDelay = 100 * time.Millisecond
+	spoolFile         = "events.jsonl"
+)
+
+// Service publishes listing events through the outbox. Elasticsearch has no
+// transactions spanning documents, so the event is written right after the
+// change it describes and only then sent to the sinks by Dispatch, which
+// retries until every sink has it. Events the outbox does not take are
+// spooled to disk and moved to the outbox by the next Dispatch.
+type Service interface {
+	Publish(events ...event.Event)
+	Dispatch() (*event.DispatchReport, rest_errors.RestErr)
+	GetFailed() ([]event.Delivery, rest_errors.RestErr)
+	Requeue(id string) rest_errors.RestErr
+	Purge() (int64, rest_errors.RestErr)
+}
+
+type Config struct {
+	// MaxAttempts is how often an event is tried before it is left failed.
+	MaxAttempts int
+	// Lock is how long a dispatcher may take to send an event before
+	// another one picks it up.
+	Lock time.Duration
+	// Retention is how long delivered events are kept.
+	Retention time.Duration
+	// SpoolDir keeps the events the outbox could not take. It should
+	// outlive the instance, e.g. be on a persistent volume; without it those
+	// events are lost.
+	SpoolDir string
+}
+
+type service struct {
+	outboxRepo db.OutboxRepository
+	sinks      []eventsink.Sink
+	config     Config
+
+	spoolMu sync.Mutex
+}
+
+func NewService(outboxRepo db.OutboxRepository, sinks []eventsink.Sink, config Config) Service {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultMaxAttempts
+	}
+	if config.Lock <= 0 {
+		config.Lock = defaultLock
+	}
+	if config.Retention <= 0 {
+		config.Retention = defaultRetention
+	}
+	return &service{
+		outboxRepo: outboxRepo,
+		sinks:      sinks,
+		config:     config,
+	}
+}
+
+// Publish writes the events to the outbox, retrying a few times before it
+// spools them to disk. Nothing is written without sinks, as nothing would
+// ever take the events out again; the application always has one for the
+// webhook subscriptions.
+func (s *service) Publish(events ...event.Event) {
+	if len(s.sinks) == 0 {
+		return
+	}
+	for _, e := range events {
+		// The id is set once, so a retry after a write that only seemed to
+		// fail does not store the event twice.
+		e.ID = uuid.New().String()
+		e.OccurredAt = date_utils.GetNow().Format(event.TimeLayout)
+		err := s.write(e)
+		if err == nil {
+			continue
+		}
+		logger.Error(fmt.Sprintf("error while publishing %s of property %s, spooling it", e.Type, e.PropertyID), errors.New(err.Message()))
+		if spoolErr := s.spool(e); spoolErr != nil {
+			logger.Error(fmt.Sprintf("lost event %s of property %s", e.Type, e.PropertyID), spoolErr)
+		}
+	}
+}
+
+func (s *service) write(e event.Event) rest_errors.RestErr {
+	var err rest_errors.RestErr
+	for attempt := 0; attempt < publishAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(publishRetryDelay << (attempt - 1))
+		}
+		if _, err = s.outboxRepo.Create(e); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// spool appends the event to the spool file, one JSON document per line.
+func (s *service) spool(e event.Event) error {
+	if s.config.SpoolDir == "" {
+		return errors.New("no spool directory is configured")
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.spoolMu.Lock()
+	defer s.spoolMu.Unlock()
+	if err := os.MkdirAll(s.config.SpoolDir, 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(s.config.SpoolDir, spoolFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// drainSpool moves spooled events to the outbox and keeps the ones it still
+// does not take.
+func (s *service) drainSpool() {
+	if s.config.SpoolDir == "" {
+		return
+	}
+	s.spoolMu.Lock()
+	defer s.spoolMu.Unlock()
+	path := filepath.Join(s.config.SpoolDir, spoolFile)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Error("error while reading spooled events", err)
+		}
+		return
+	}
+
+	var kept bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var e event.Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			logger.Error("dropping a malformed spooled event", err)
+			continue
+		}
+		if _, err := s.outboxRepo.Create(e); err != nil {
+			kept.Write(line)
+			kept.WriteByte('\n')
+		}
+	}
+	if kept.Len() == 0 {
+		if err := os.Remove(path); err != nil {
+			logger.Error("error while removing spooled events", err)
+		}
+		return
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, kept.Bytes(), 0600); err != nil {
+		logger.Error("error while keeping spooled events", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		logger.Error("error while keeping spooled events", err)
+	}
+}
+
+// Dispatch sends the due events to the sinks that do not have them yet.
+// An event is claimed first so instances running it at the same time do not
+// send it twice, though a dispatcher stopping mid-way can.
+func (s *service) Dispatch() (*event.DispatchReport, rest_errors.RestErr) {
+	s.drainSpool()
+	now := date_utils.GetNow()
+	due, err := s.outboxRepo.GetDue(now.Format(event.TimeLayout))
+	if err != nil {
+		return nil, err
+	}
+	report := event.DispatchReport{}
+	for _, d := range due {
+		claimed, err := s.outboxRepo.Claim(d.ID, now.Format(event.TimeLayout), now.Add(s.config.Lock).Format(event.TimeLayout))
+		if err != nil {
+			return nil, err
+		}
+		if !claimed {
+			continue
+		}
+		if err := s.dispatch(d, &report); err != nil {
+			return nil, err
+		}
+	}
+	return &report, nil
+}
+
+func (s *service) dispatch(d event.Delivery, report *event.DispatchReport) rest_errors.RestErr {
+	var failures []string
+	for _, sink := range s.sinks {
+		if d.Delivered(sink.Name()) {
+			continue
+		}
+		if err := sink.Send(d.Event); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", sink.Name(), err.Error()))
+			continue
+		}
+		d.DeliveredTo = append(d.DeliveredTo, sink.Name())
+	}
+	if len(failures) == 0 {
+		report.Delivered++
+		return s.outboxRepo.Delivered(d.ID, d.DeliveredTo, date_utils.GetNow().Format(event.TimeLayout))
+	}
+
+	attempts := d.Attempts + 1
+	message := strings.Join(failures, "; ")
+	if attempts >= s.config.MaxAttempts {
+		logger.Error(fmt.Sprintf("giving up on event %s after %d attempts", d.ID, attempts), errors.New(message))
+		report.Failed++
+		return s.outboxRepo.Fail(d.ID, attempts, d.DeliveredTo, message)
+	}
+	report.Retried++
+	next := date_utils.GetNow().Add(event.RetryDelay(attempts)).Format(event.TimeLayout)
+	return s.outboxRepo.Retry(d.ID, attempts, d.DeliveredTo, next, message)
+}
+
+func (s *service) GetFailed() ([]event.Delivery, rest_errors.RestErr) {
+	return s.outboxRepo.GetFailed()
+}
+
+// Requeue sends a failed event again on the next dispatch, to the sinks
+// that did not get it.
+func (s *service) Requeue(id string) rest_errors.RestErr {
+	requeued, err := s.outboxRepo.Requeue(id, date_utils.GetNow().Format(event.TimeLayout))
+	if err != nil {
+		return err
+	}
+	if !requeued {
+		return rest_errors.NewRestError("only failed events can be requeued", http.StatusConflict, "conflict", nil)
+	}
+	return nil
+}
+
+// Purge deletes the events delivered longer ago than the retention.
+func (s *service) Purge() (int64, rest_errors.RestErr) {
+	return s.outboxRepo.Purge(date_utils.GetNow().Add(-s.config.Retention).Format(event.TimeLayout))
+}
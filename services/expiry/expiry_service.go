@@ -0,0 +1,223 @@
+package expiry
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/logger"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/notifier"
+	"github.com/superbkibbles/realestate_property-api/domain/audit"
+	"github.com/superbkibbles/realestate_property-api/domain/notification"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+const (
+	eventExpiring = "listing.expiring"
+	eventExpired  = "listing.expired"
+
+	day = 24 * time.Hour
+
+	defaultDuration   = 90 * day
+	defaultWarnBefore = 7 * day
+)
+
+// DefaultDurations is how long a listing stays active per category before it
+// has to be renewed.
+var DefaultDurations = map[string]time.Duration{
+	"apartment": 90 * day,
+	"house":     90 * day,
+	"villa":     120 * day,
+	"land":      180 * day,
+	"farm":      180 * day,
+}
+
+type Service interface {
+	ExpiresAt(category string, from time.Time) string
+	Renew(propertyID string, actor audit.Actor) (*property.Property, rest_errors.RestErr)
+	Run() (*property.ExpiryReport, rest_errors.RestErr)
+}
+
+// Updater writes listing changes through the property service, so they are
+// audited, published and seen by its watchers.
+type Updater interface {
+	Apply(id string, updateRequest property.EsUpdate, actor audit.Actor) (*property.Property, rest_errors.RestErr)
+	Record(action string, before *property.Property, after *property.Property, actor audit.Actor)
+}
+
+type Config struct {
+	// Durations overrides DefaultDurations per category.
+	Durations  map[string]time.Duration
+	WarnBefore time.Duration
+}
+
+type service struct {
+	dbRepo     db.DbRepository
+	agencyRepo db.AgencyRepository
+	notifier   notifier.Notifier
+	updater    Updater
+	config     Config
+}
+
+func NewService(dbRepo db.DbRepository, agencyRepo db.AgencyRepository, notifier notifier.Notifier, updater Updater, config Config) Service {
+	durations := make(map[string]time.Duration, len(DefaultDurations))
+	for category, duration := range DefaultDurations {
+		durations[category] = duration
+	}
+	for category, duration := range config.Durations {
+		durations[category] = duration
+	}
+	config.Durations = durations
+	if config.WarnBefore <= 0 {
+		config.WarnBefore = defaultWarnBefore
+	}
+	return &service{
+		dbRepo:     dbRepo,
+		agencyRepo: agencyRepo,
+		notifier:   notifier,
+		updater:    updater,
+		config:     config,
+	}
+}
+
+func (s *service) ExpiresAt(category string, from time.Time) string {
+	duration, ok := s.config.Durations[category]
+	if !ok {
+		duration = defaultDuration
+	}
+	return from.Add(duration).UTC().Format(time.RFC3339)
+}
+
+// Renew restarts the listing's duration from now. Listings the scheduler
+// deactivated are reactivated; those deactivated by hand stay as they are.
+func (s *service) Renew(propertyID string, actor audit.Actor) (*property.Property, rest_errors.RestErr) {
+	p, err := s.dbRepo.GetByID(propertyID)
+	if err != nil {
+		return nil, err
+	}
+	if actor.AgencyID == "" || actor.AgencyID != p.AgencyID {
+		return nil, rest_errors.NewRestError("listings can only be renewed by their agency", http.StatusForbidden, "forbidden", nil)
+	}
+	if p.IsSold {
+		return nil, rest_errors.NewBadRequestErr("sold listings can not be renewed")
+	}
+
+	fields := []property.UpdatePropertyRequest{
+		{Field: "expires_at", Value: s.ExpiresAt(p.Category, date_utils.GetNow())},
+		{Field: "expiry_warned_at", Value: nil},
+	}
+	if p.Status == property.STATUS_DEACTIVE && p.ExpiredAt != "" {
+		fields = append(fields,
+			property.UpdatePropertyRequest{Field: "status", Value: property.STATUS_ACTIVE},
+			property.UpdatePropertyRequest{Field: "expired_at", Value: nil},
+		)
+	}
+	return s.updater.Apply(p.ID, property.EsUpdate{Fields: fields}, actor)
+}
+
+// Run gives listings without an expiry date one, warns agencies of listings
+// about to expire and deactivates expired ones. Every change is claimed by a
+// conditional update, so instances running at the same time don't notify
+// twice.
+func (s *service) Run() (*property.ExpiryReport, rest_errors.RestErr) {
+	now := date_utils.GetNow()
+	report := property.ExpiryReport{}
+	actor := audit.Actor{UserID: audit.ActorScheduler}
+
+	// Listings from before expiry existed get a full duration from now
+	// rather than expiring all at once.
+	unscheduled, err := s.dbRepo.GetWithoutExpiry()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range unscheduled {
+		if _, err := s.updater.Apply(p.ID, property.EsUpdate{Fields: []property.UpdatePropertyRequest{
+			{Field: "expires_at", Value: s.ExpiresAt(p.Category, now)},
+		}}, actor); err != nil {
+			report.Failed++
+			logger.Error(fmt.Sprintf("error while scheduling the expiry of property %s", p.ID), errors.New(err.Message()))
+			continue
+		}
+		report.Scheduled++
+	}
+
+	expiring, err := s.dbRepo.GetExpiring(now.Add(s.config.WarnBefore).Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	at := now.Format(time.RFC3339)
+	for _, p := range expiring {
+		if p.Expired(now) {
+			expired, err := s.dbRepo.Expire(p.ID, at)
+			if err != nil {
+				report.Failed++
+				logger.Error(fmt.Sprintf("error while expiring property %s", p.ID), errors.New(err.Message()))
+				continue
+			}
+			if expired {
+				report.Expired++
+				s.recordExpiry(p, actor)
+				go s.notifyAgency(p, eventExpired)
+			}
+			continue
+		}
+		if p.ExpiryWarnedAt != "" {
+			continue
+		}
+		claimed, err := s.dbRepo.ClaimExpiryWarning(p.ID, at)
+		if err != nil {
+			report.Failed++
+			logger.Error(fmt.Sprintf("error while warning about the expiry of property %s", p.ID), errors.New(err.Message()))
+			continue
+		}
+		if claimed {
+			report.Warned++
+			go s.notifyAgency(p, eventExpiring)
+		}
+	}
+	return &report, nil
+}
+
+// recordExpiry hands a listing Expire deactivated to the property service,
+// so the change is audited and published and its watchers learn that it left
+// active.
+func (s *service) recordExpiry(before property.Property, actor audit.Actor) {
+	after, err := s.dbRepo.GetByID(before.ID)
+	if err != nil {
+		logger.Error(fmt.Sprintf("error while recording the expiry of property %s", before.ID), errors.New(err.Message()))
+		return
+	}
+	s.updater.Record(audit.ACTION_UPDATE, &before, after, actor)
+}
+
+// notifyAgency emails the agency when it has an address, otherwise the
+// message is only logged.
+func (s *service) notifyAgency(p property.Property, event string) {
+	message := notification.Message{
+		Channel: notification.CHANNEL_LOG,
+		To:      p.AgencyID,
+		Event:   event,
+		Payload: map[string]string{"property_id": p.ID, "title": p.Title, "expires_at": p.ExpiresAt},
+	}
+	if event == eventExpired {
+		message.Subject = fmt.Sprintf("Your listing %s has expired", p.Title)
+		message.Text = fmt.Sprintf("%s expired on %s and is no longer shown. Renew it to publish it again.", p.Title, p.ExpiresAt)
+	} else {
+		message.Subject = fmt.Sprintf("Your listing %s expires soon", p.Title)
+		message.Text = fmt.Sprintf("%s expires on %s. Renew it to keep it active.", p.Title, p.ExpiresAt)
+	}
+	if p.AgencyID != "" {
+		if a, err := s.agencyRepo.GetByID(p.AgencyID); err == nil && strings.Contains(a.Contacts.Email, "@") {
+			message.Channel = notification.CHANNEL_EMAIL
+			message.To = a.Contacts.Email
+		}
+	}
+	if err := s.notifier.Notify(message); err != nil {
+		logger.Error(fmt.Sprintf("error while notifying the agency of property %s about its expiry", p.ID), err)
+	}
+}
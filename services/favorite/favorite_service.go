@@ -0,0 +1,212 @@
+package favorite
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/superbkibbles/bookstore_utils-go/logger"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/notifier"
+	"github.com/superbkibbles/realestate_property-api/domain/favorite"
+	"github.com/superbkibbles/realestate_property-api/domain/notification"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/services/currency"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+const (
+	eventPriceChanged = "favorite.price_changed"
+	eventSold         = "favorite.sold"
+)
+
+type Service interface {
+	Add(userID string, propertyID string, request favorite.Request) (*favorite.Favorite, bool, rest_errors.RestErr)
+	Remove(userID string, propertyID string) rest_errors.RestErr
+	Get(userID string, local string, displayCurrency string) (favorite.Favorites, rest_errors.RestErr)
+	GetPropertyStats(propertyID string) (*favorite.Counts, rest_errors.RestErr)
+	GetAgencyStats(agencyID string) (*favorite.Counts, rest_errors.RestErr)
+	PropertySaved(p property.Property, fields []string)
+}
+
+type service struct {
+	favoriteRepo    db.FavoriteRepository
+	dbRepo          db.DbRepository
+	currencyService currency.Service
+	notifier        notifier.Notifier
+}
+
+func NewService(favoriteRepo db.FavoriteRepository, dbRepo db.DbRepository, currencyService currency.Service, notifier notifier.Notifier) Service {
+	return &service{
+		favoriteRepo:    favoriteRepo,
+		dbRepo:          dbRepo,
+		currencyService: currencyService,
+		notifier:        notifier,
+	}
+}
+
+// Add favorites a listing and reports whether it was new. Adding it again
+// keeps the original favorite.
+func (s *service) Add(userID string, propertyID string, request favorite.Request) (*favorite.Favorite, bool, rest_errors.RestErr) {
+	if userID == "" {
+		return nil, false, rest_errors.NewUnauthorizedError("user id is required")
+	}
+	if err := request.Validate(); err != nil {
+		return nil, false, err
+	}
+	p, err := s.dbRepo.GetByID(propertyID)
+	if err != nil {
+		return nil, false, err
+	}
+	existing, err := s.favoriteRepo.GetByUser(userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(existing) >= favorite.MaxFavoritesPerUser {
+		return nil, false, rest_errors.NewBadRequestErr(fmt.Sprintf("a user can have at most %d favorites", favorite.MaxFavoritesPerUser))
+	}
+
+	f := favorite.Favorite{
+		ID:           favorite.ID(userID, p.ID),
+		UserID:       userID,
+		PropertyID:   p.ID,
+		AgencyID:     p.AgencyID,
+		Channel:      request.Channel,
+		Target:       request.Target,
+		LastPrice:    p.Price,
+		LastCurrency: p.Currency,
+		LastSold:     p.IsSold,
+		DateCreated:  date_utils.GetNowDBFromat(),
+	}
+	created, err := s.favoriteRepo.Create(f)
+	if err != nil {
+		return nil, false, err
+	}
+	if !created {
+		current, err := s.favoriteRepo.GetByID(f.ID)
+		if err != nil {
+			return nil, false, err
+		}
+		return current, false, nil
+	}
+	return &f, true, nil
+}
+
+func (s *service) Remove(userID string, propertyID string) rest_errors.RestErr {
+	if userID == "" {
+		return rest_errors.NewUnauthorizedError("user id is required")
+	}
+	return s.favoriteRepo.Delete(favorite.ID(userID, propertyID))
+}
+
+// Get returns the user's favorites with each listing as it is now, priced in
+// displayCurrency and translated to local.
+func (s *service) Get(userID string, local string, displayCurrency string) (favorite.Favorites, rest_errors.RestErr) {
+	if userID == "" {
+		return nil, rest_errors.NewUnauthorizedError("user id is required")
+	}
+	favorites, err := s.favoriteRepo.GetByUser(userID)
+	if err != nil || len(favorites) == 0 {
+		return favorites, err
+	}
+	ids := make([]string, 0, len(favorites))
+	for _, f := range favorites {
+		ids = append(ids, f.PropertyID)
+	}
+	properties, err := s.dbRepo.GetByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.currencyService.Display(properties, displayCurrency); err != nil {
+		return nil, err
+	}
+	if local != "en" && local != "" {
+		ts, err := s.dbRepo.GetAllTranslated(local)
+		if err != nil {
+			return nil, err
+		}
+		properties = ts.Marshal(properties)
+	}
+
+	byID := make(map[string]*property.Property, len(properties))
+	for i := range properties {
+		byID[properties[i].ID] = &properties[i]
+	}
+	for i := range favorites {
+		favorites[i].Property = byID[favorites[i].PropertyID]
+	}
+	return favorites, nil
+}
+
+func (s *service) GetPropertyStats(propertyID string) (*favorite.Counts, rest_errors.RestErr) {
+	if _, err := s.dbRepo.GetByID(propertyID); err != nil {
+		return nil, err
+	}
+	return s.favoriteRepo.Count("property_id", propertyID)
+}
+
+func (s *service) GetAgencyStats(agencyID string) (*favorite.Counts, rest_errors.RestErr) {
+	return s.favoriteRepo.Count("agency_id", agencyID)
+}
+
+// PropertySaved tells the users who favorited a listing that it was sold or
+// its price changed.
+func (s *service) PropertySaved(p property.Property, fields []string) {
+	if !changes(fields, "price", "currency", "is_sold") {
+		return
+	}
+	favorites, err := s.favoriteRepo.GetByProperty(p.ID)
+	if err != nil {
+		logger.Error(fmt.Sprintf("error while loading favorites of property %s", p.ID), errors.New(err.Message()))
+		return
+	}
+
+	for _, f := range favorites {
+		message := notification.Message{
+			Channel: f.Channel,
+			To:      f.Target,
+			Payload: map[string]interface{}{
+				"property_id":    p.ID,
+				"title":          p.Title,
+				"previous_price": f.LastPrice,
+				"price":          p.Price,
+				"currency":       p.Currency,
+				"is_sold":        p.IsSold,
+			},
+		}
+		if message.To == "" {
+			message.To = f.UserID
+		}
+		switch {
+		case p.IsSold && !f.LastSold:
+			message.Event = eventSold
+			message.Subject = fmt.Sprintf("%s was sold", p.Title)
+			message.Text = fmt.Sprintf("%s, one of your favorites, was sold.", p.Title)
+		case p.Price != f.LastPrice || p.Currency != f.LastCurrency:
+			message.Event = eventPriceChanged
+			message.Subject = fmt.Sprintf("New price for %s", p.Title)
+			message.Text = fmt.Sprintf("%s now costs %d %s, it was %d %s.", p.Title, p.Price, p.Currency, f.LastPrice, f.LastCurrency)
+		default:
+			continue
+		}
+
+		if err := s.notifier.Notify(message); err != nil {
+			logger.Error(fmt.Sprintf("error while notifying favorite %s", f.ID), err)
+			continue
+		}
+		if err := s.favoriteRepo.SetLastState(f.ID, p.Price, p.Currency, p.IsSold); err != nil {
+			logger.Error(fmt.Sprintf("error while updating favorite %s", f.ID), errors.New(err.Message()))
+		}
+	}
+}
+
+func changes(fields []string, names ...string) bool {
+	for _, field := range fields {
+		for _, name := range names {
+			if field == name {
+				return true
+			}
+		}
+	}
+	return false
+}
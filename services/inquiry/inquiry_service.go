@@ -0,0 +1,191 @@
+package inquiry
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/logger"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/notifier"
+	"github.com/superbkibbles/realestate_property-api/domain/inquiry"
+	"github.com/superbkibbles/realestate_property-api/domain/notification"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+const (
+	eventInquiry = "inquiry.created"
+
+	// A phone number can send this many inquiries per rateWindow.
+	rateLimit  = 5
+	rateWindow = time.Hour
+)
+
+type Service interface {
+	Create(propertyID string, userID string, request inquiry.Request) (*inquiry.Inquiry, rest_errors.RestErr)
+	Get(agencyID string, callerAgencyID string, status string, propertyID string) (inquiry.Inquiries, rest_errors.RestErr)
+	Assign(agencyID string, callerAgencyID string, id string, request inquiry.AssignRequest) (*inquiry.Inquiry, rest_errors.RestErr)
+	SetStatus(agencyID string, callerAgencyID string, id string, request inquiry.StatusRequest) (*inquiry.Inquiry, rest_errors.RestErr)
+	GetLeadCounts(agencyID string, callerAgencyID string) (*inquiry.LeadCounts, rest_errors.RestErr)
+}
+
+type service struct {
+	inquiryRepo db.InquiryRepository
+	dbRepo      db.DbRepository
+	agencyRepo  db.AgencyRepository
+	notifier    notifier.Notifier
+}
+
+func NewService(inquiryRepo db.InquiryRepository, dbRepo db.DbRepository, agencyRepo db.AgencyRepository, notifier notifier.Notifier) Service {
+	return &service{
+		inquiryRepo: inquiryRepo,
+		dbRepo:      dbRepo,
+		agencyRepo:  agencyRepo,
+		notifier:    notifier,
+	}
+}
+
+// Create stores an inquiry for the agency of an active listing. Spam is
+// stored as such and answered like any other inquiry, so bots can't tell.
+func (s *service) Create(propertyID string, userID string, request inquiry.Request) (*inquiry.Inquiry, rest_errors.RestErr) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	p, err := s.dbRepo.GetByID(propertyID)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status != property.STATUS_ACTIVE || p.IsSold {
+		return nil, rest_errors.NewBadRequestErr("this listing is no longer available")
+	}
+	if p.AgencyID == "" {
+		return nil, rest_errors.NewBadRequestErr("this listing does not take inquiries")
+	}
+
+	phoneDigits := inquiry.NormalizePhone(request.Phone)
+	since := date_utils.GetNow().Add(-rateWindow).Format(time.RFC3339)
+	sent, err := s.inquiryRepo.CountSince(phoneDigits, since)
+	if err != nil {
+		return nil, err
+	}
+	if sent >= rateLimit {
+		return nil, rest_errors.NewRestError("too many inquiries, please try again later", http.StatusTooManyRequests, "too_many_requests", nil)
+	}
+
+	i := inquiry.Inquiry{
+		PropertyID:           p.ID,
+		AgencyID:             p.AgencyID,
+		UserID:               userID,
+		Name:                 request.Name,
+		Phone:                request.Phone,
+		PhoneDigits:          phoneDigits,
+		Email:                request.Email,
+		Message:              request.Message,
+		PreferredContactTime: request.PreferredContactTime,
+		Status:               inquiry.STATUS_NEW,
+		SpamReasons:          request.SpamReasons(),
+		DateCreated:          date_utils.GetNowISO(),
+	}
+	if len(i.SpamReasons) > 0 {
+		i.Status = inquiry.STATUS_SPAM
+	}
+	created, err := s.inquiryRepo.Create(i)
+	if err != nil {
+		return nil, err
+	}
+	if created.Status != inquiry.STATUS_SPAM {
+		go s.notifyAgency(*created, p.Title)
+	}
+	return created, nil
+}
+
+// notifyAgency emails the agency when it has an address, otherwise the
+// inquiry is only logged.
+func (s *service) notifyAgency(i inquiry.Inquiry, title string) {
+	message := notification.Message{
+		Channel: notification.CHANNEL_LOG,
+		To:      i.AgencyID,
+		Event:   eventInquiry,
+		Subject: fmt.Sprintf("New inquiry about %s", title),
+		Text:    fmt.Sprintf("%s (%s) wrote: %s\nPreferred contact time: %s", i.Name, i.Phone, i.Message, i.PreferredContactTime),
+		Payload: i,
+	}
+	if a, err := s.agencyRepo.GetByID(i.AgencyID); err == nil && strings.Contains(a.Contacts.Email, "@") {
+		message.Channel = notification.CHANNEL_EMAIL
+		message.To = a.Contacts.Email
+	}
+	if err := s.notifier.Notify(message); err != nil {
+		logger.Error(fmt.Sprintf("error while notifying agency %s of inquiry %s", i.AgencyID, i.ID), err)
+	}
+}
+
+func (s *service) Get(agencyID string, callerAgencyID string, status string, propertyID string) (inquiry.Inquiries, rest_errors.RestErr) {
+	if err := authorize(agencyID, callerAgencyID); err != nil {
+		return nil, err
+	}
+	if status != "" {
+		if err := inquiry.ValidateStatus(status); err != nil {
+			return nil, err
+		}
+	}
+	return s.inquiryRepo.GetByAgency(agencyID, status, propertyID)
+}
+
+// Assign hands an inquiry to an agent of the agency; an empty assignee
+// unassigns it.
+func (s *service) Assign(agencyID string, callerAgencyID string, id string, request inquiry.AssignRequest) (*inquiry.Inquiry, rest_errors.RestErr) {
+	if _, err := s.get(agencyID, callerAgencyID, id); err != nil {
+		return nil, err
+	}
+	return s.inquiryRepo.Update(id, property.EsUpdate{Fields: []property.UpdatePropertyRequest{
+		{Field: "assigned_to", Value: strings.TrimSpace(request.AssignedTo)},
+		{Field: "date_updated", Value: date_utils.GetNowISO()},
+	}})
+}
+
+func (s *service) SetStatus(agencyID string, callerAgencyID string, id string, request inquiry.StatusRequest) (*inquiry.Inquiry, rest_errors.RestErr) {
+	if err := inquiry.ValidateStatus(request.Status); err != nil {
+		return nil, err
+	}
+	if _, err := s.get(agencyID, callerAgencyID, id); err != nil {
+		return nil, err
+	}
+	return s.inquiryRepo.Update(id, property.EsUpdate{Fields: []property.UpdatePropertyRequest{
+		{Field: "status", Value: request.Status},
+		{Field: "date_updated", Value: date_utils.GetNowISO()},
+	}})
+}
+
+func (s *service) GetLeadCounts(agencyID string, callerAgencyID string) (*inquiry.LeadCounts, rest_errors.RestErr) {
+	if err := authorize(agencyID, callerAgencyID); err != nil {
+		return nil, err
+	}
+	return s.inquiryRepo.LeadCounts(agencyID)
+}
+
+// get returns an inquiry of agencyID; inquiries of other agencies are
+// reported as missing.
+func (s *service) get(agencyID string, callerAgencyID string, id string) (*inquiry.Inquiry, rest_errors.RestErr) {
+	if err := authorize(agencyID, callerAgencyID); err != nil {
+		return nil, err
+	}
+	i, err := s.inquiryRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if i.AgencyID != agencyID {
+		return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no inquiry was found with id %s", id))
+	}
+	return i, nil
+}
+
+// authorize lets agencies see only their own leads.
+func authorize(agencyID string, callerAgencyID string) rest_errors.RestErr {
+	if callerAgencyID == "" || callerAgencyID != agencyID {
+		return rest_errors.NewRestError("inquiries are only available to their agency", http.StatusForbidden, "forbidden", nil)
+	}
+	return nil
+}
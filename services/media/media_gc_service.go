@@ -0,0 +1,140 @@
+package media
+
+import (
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/complex"
+	"github.com/superbkibbles/realestate_property-api/domain/media"
+	cloudstorage "github.com/superbkibbles/realestate_property-api/repository/cloudStorage"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+// documentID matches the ids Elasticsearch generates, which property and
+// complex folders are named after.
+var documentID = regexp.MustCompile(`^[A-Za-z0-9_-]{20}$`)
+
+type Service interface {
+	CollectOrphans(dryRun bool) (*media.GcReport, rest_errors.RestErr)
+}
+
+type service struct {
+	dbRepo      db.DbRepository
+	complexRepo db.ComplexRepository
+	cloudRepo   cloudstorage.CloudStorage
+	// minAge protects assets that may still be attached by an upload in
+	// progress, e.g. a resumable upload waiting for its completion call.
+	minAge time.Duration
+}
+
+func NewService(dbRepo db.DbRepository, complexRepo db.ComplexRepository, cloudRepo cloudstorage.CloudStorage, minAge time.Duration) Service {
+	return &service{
+		dbRepo:      dbRepo,
+		complexRepo: complexRepo,
+		cloudRepo:   cloudRepo,
+		minAge:      minAge,
+	}
+}
+
+// CollectOrphans compares every property folder in storage with the media the
+// property references in Elasticsearch and reports, or deletes, the rest.
+// Folders not named after a property or complex, and assets without
+// media.ASSET_TAG, belong to someone else and are left alone.
+func (s *service) CollectOrphans(dryRun bool) (*media.GcReport, rest_errors.RestErr) {
+	report := media.GcReport{
+		DryRun:    dryRun,
+		StartedAt: date_utils.GetNowDBFromat(),
+		Orphans:   []media.Orphan{},
+	}
+	folders, err := s.cloudRepo.ListFolders()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := date_utils.GetNow().Add(-s.minAge)
+	for _, folder := range folders {
+		if !documentID.MatchString(strings.TrimPrefix(folder, complex.FOLDER_PREFIX)) {
+			continue
+		}
+		assets, err := s.cloudRepo.List(folder)
+		if err != nil {
+			report.Skipped = append(report.Skipped, folder)
+			continue
+		}
+
+		references, reason, err := s.references(folder)
+		if err != nil {
+			// Never delete anything on a lookup failure.
+			report.Skipped = append(report.Skipped, folder)
+			continue
+		}
+		report.FoldersScanned++
+		report.AssetsScanned += len(assets)
+
+		for _, asset := range assets {
+			if !asset.Owned() || asset.CreatedAt.After(cutoff) || references[path.Base(asset.PublicID)] {
+				continue
+			}
+			orphan := media.Orphan{StoredAsset: asset, Reason: reason}
+			if !dryRun {
+				if err := s.cloudRepo.Delete(asset.PublicID); err != nil {
+					orphan.Error = err.Message()
+				} else {
+					orphan.Deleted = true
+				}
+			}
+			report.Orphans = append(report.Orphans, orphan)
+		}
+	}
+
+	report.FinishedAt = date_utils.GetNowDBFromat()
+	return &report, nil
+}
+
+// references returns the ids of the assets the owner of folder still uses,
+// without their folder, which is how the documents store them. Folders are
+// named after a property, or after a complex with complex.FOLDER_PREFIX.
+func (s *service) references(folder string) (map[string]bool, string, rest_errors.RestErr) {
+	ids := make(map[string]bool)
+	if strings.HasPrefix(folder, complex.FOLDER_PREFIX) {
+		c, err := s.complexRepo.GetByID(strings.TrimPrefix(folder, complex.FOLDER_PREFIX))
+		if err != nil {
+			if err.Status() != http.StatusNotFound {
+				return nil, "", err
+			}
+			return ids, media.REASON_COMPLEX_DELETED, nil
+		}
+		for _, m := range c.Media {
+			ids[path.Base(m.PublicID)] = true
+		}
+		return ids, media.REASON_UNREFERENCED, nil
+	}
+
+	p, err := s.dbRepo.GetByID(folder)
+	if err != nil {
+		if err.Status() != http.StatusNotFound {
+			return nil, "", err
+		}
+		return ids, media.REASON_PROPERTY_DELETED, nil
+	}
+	for _, v := range p.Visuals {
+		ids[path.Base(v.PublicID)] = true
+	}
+	for _, v := range p.Videos {
+		ids[path.Base(v.PublicID)] = true
+	}
+	for _, a := range p.Attachments {
+		ids[path.Base(a.PublicID)] = true
+	}
+	if p.PropertyPic != "" {
+		// The picture is only stored by url, named after its asset.
+		name := path.Base(p.PropertyPic)
+		ids[strings.TrimSuffix(name, path.Ext(name))] = true
+	}
+	return ids, media.REASON_UNREFERENCED, nil
+}
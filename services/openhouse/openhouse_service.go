@@ -0,0 +1,211 @@
+package openhouse
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/superbkibbles/bookstore_utils-go/logger"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/audit"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+type Service interface {
+	Create(propertyID string, agencyID string, request property.OpenHouseRequest) (*property.OpenHouse, rest_errors.RestErr)
+	Get(propertyID string) ([]property.OpenHouse, rest_errors.RestErr)
+	Delete(propertyID string, openHouseID string, agencyID string) rest_errors.RestErr
+	GetRSVPs(propertyID string, openHouseID string, agencyID string) ([]property.RSVP, rest_errors.RestErr)
+	RSVP(propertyID string, openHouseID string, userID string, request property.RSVPRequest) (*property.RSVP, bool, rest_errors.RestErr)
+	CancelRSVP(propertyID string, openHouseID string, userID string) rest_errors.RestErr
+	PropertySaved(p property.Property, fields []string)
+}
+
+// Updater writes listing changes through the property service, so they are
+// audited, published and seen by its watchers.
+type Updater interface {
+	Apply(id string, updateRequest property.EsUpdate, actor audit.Actor) (*property.Property, rest_errors.RestErr)
+}
+
+type service struct {
+	dbRepo   db.DbRepository
+	rsvpRepo db.RSVPRepository
+	updater  Updater
+}
+
+func NewService(dbRepo db.DbRepository, rsvpRepo db.RSVPRepository, updater Updater) Service {
+	return &service{
+		dbRepo:   dbRepo,
+		rsvpRepo: rsvpRepo,
+		updater:  updater,
+	}
+}
+
+func (s *service) Create(propertyID string, agencyID string, request property.OpenHouseRequest) (*property.OpenHouse, rest_errors.RestErr) {
+	p, err := s.activeProperty(propertyID)
+	if err != nil {
+		return nil, err
+	}
+	if agencyID == "" || agencyID != p.AgencyID {
+		return nil, rest_errors.NewRestError("open houses can only be scheduled by the agency of the listing", http.StatusForbidden, "forbidden", nil)
+	}
+	now := date_utils.GetNow()
+	openHouse, err := request.OpenHouse(now)
+	if err != nil {
+		return nil, err
+	}
+	p.DropEndedOpenHouses(now)
+	if len(p.OpenHouses) >= property.MaxOpenHouses {
+		return nil, rest_errors.NewBadRequestErr(fmt.Sprintf("a property can have at most %d upcoming open houses", property.MaxOpenHouses))
+	}
+
+	openHouse.ID = uuid.New().String()
+	if err := s.dbRepo.AddOpenHouse(p.ID, *openHouse, now.Format(time.RFC3339)); err != nil {
+		return nil, err
+	}
+	return openHouse, nil
+}
+
+// Get returns the upcoming open houses of a listing, earliest first.
+func (s *service) Get(propertyID string) ([]property.OpenHouse, rest_errors.RestErr) {
+	p, err := s.dbRepo.GetByID(propertyID)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status != property.STATUS_ACTIVE || p.IsSold {
+		return []property.OpenHouse{}, nil
+	}
+	p.DropEndedOpenHouses(date_utils.GetNow())
+	return p.OpenHouses, nil
+}
+
+func (s *service) Delete(propertyID string, openHouseID string, agencyID string) rest_errors.RestErr {
+	p, err := s.dbRepo.GetByID(propertyID)
+	if err != nil {
+		return err
+	}
+	if agencyID == "" || agencyID != p.AgencyID {
+		return rest_errors.NewRestError("open houses can only be cancelled by the agency of the listing", http.StatusForbidden, "forbidden", nil)
+	}
+	removed, err := s.dbRepo.RemoveOpenHouse(p.ID, openHouseID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return rest_errors.NewNotFoundErr(fmt.Sprintf("no open house was found with id %s", openHouseID))
+	}
+	return nil
+}
+
+// GetRSVPs lists who is coming, for the agency of the listing only.
+func (s *service) GetRSVPs(propertyID string, openHouseID string, agencyID string) ([]property.RSVP, rest_errors.RestErr) {
+	p, err := s.dbRepo.GetByID(propertyID)
+	if err != nil {
+		return nil, err
+	}
+	if agencyID == "" || agencyID != p.AgencyID {
+		return nil, rest_errors.NewRestError("RSVPs are only available to the agency of the listing", http.StatusForbidden, "forbidden", nil)
+	}
+	if p.FindOpenHouse(openHouseID) == nil {
+		return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no open house was found with id %s", openHouseID))
+	}
+	return s.rsvpRepo.GetByOpenHouse(openHouseID)
+}
+
+// RSVP registers the user for an open house and reports whether the RSVP is
+// new. Guests only count once the open house had room for them.
+func (s *service) RSVP(propertyID string, openHouseID string, userID string, request property.RSVPRequest) (*property.RSVP, bool, rest_errors.RestErr) {
+	if userID == "" {
+		return nil, false, rest_errors.NewUnauthorizedError("only signed in users can RSVP")
+	}
+	if err := request.Validate(); err != nil {
+		return nil, false, err
+	}
+	p, err := s.activeProperty(propertyID)
+	if err != nil {
+		return nil, false, err
+	}
+	openHouse := p.FindOpenHouse(openHouseID)
+	if openHouse == nil || openHouse.Ended(date_utils.GetNow()) {
+		return nil, false, rest_errors.NewNotFoundErr(fmt.Sprintf("no upcoming open house was found with id %s", openHouseID))
+	}
+
+	rsvp := property.RSVP{
+		ID:          property.RSVPID(openHouseID, userID),
+		PropertyID:  p.ID,
+		OpenHouseID: openHouseID,
+		UserID:      userID,
+		Name:        request.Name,
+		Guests:      request.Guests,
+		DateCreated: date_utils.GetNowISO(),
+	}
+	created, err := s.rsvpRepo.Create(rsvp)
+	if err != nil {
+		return nil, false, err
+	}
+	if !created {
+		existing, err := s.rsvpRepo.GetByID(rsvp.ID)
+		if err != nil {
+			return nil, false, err
+		}
+		return existing, false, nil
+	}
+
+	counted, err := s.dbRepo.CountRSVP(p.ID, openHouseID, rsvp.Guests)
+	if err != nil || !counted {
+		s.rsvpRepo.Delete(rsvp.ID)
+		if err != nil {
+			return nil, false, err
+		}
+		return nil, false, rest_errors.NewRestError("this open house is full", http.StatusConflict, "conflict", nil)
+	}
+	return &rsvp, true, nil
+}
+
+func (s *service) CancelRSVP(propertyID string, openHouseID string, userID string) rest_errors.RestErr {
+	if userID == "" {
+		return rest_errors.NewUnauthorizedError("only signed in users can RSVP")
+	}
+	rsvp, err := s.rsvpRepo.GetByID(property.RSVPID(openHouseID, userID))
+	if err != nil {
+		return err
+	}
+	if rsvp.PropertyID != propertyID {
+		return rest_errors.NewNotFoundErr(fmt.Sprintf("no RSVP was found for open house %s", openHouseID))
+	}
+	if err := s.rsvpRepo.Delete(rsvp.ID); err != nil {
+		return err
+	}
+	// The open house may be gone already, then there is nothing to count.
+	if _, err := s.dbRepo.CountRSVP(propertyID, openHouseID, -rsvp.Guests); err != nil && err.Status() != http.StatusNotFound {
+		return err
+	}
+	return nil
+}
+
+// PropertySaved removes the open houses of listings that left active.
+func (s *service) PropertySaved(p property.Property, fields []string) {
+	if len(p.OpenHouses) == 0 || (p.Status == property.STATUS_ACTIVE && !p.IsSold) {
+		return
+	}
+	if _, err := s.updater.Apply(p.ID, property.EsUpdate{Fields: []property.UpdatePropertyRequest{
+		{Field: "open_houses", Value: []property.OpenHouse{}},
+	}}, audit.Actor{UserID: audit.ActorScheduler}); err != nil {
+		logger.Error(fmt.Sprintf("error while removing open houses of property %s", p.ID), errors.New(err.Message()))
+	}
+}
+
+func (s *service) activeProperty(propertyID string) (*property.Property, rest_errors.RestErr) {
+	p, err := s.dbRepo.GetByID(propertyID)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status != property.STATUS_ACTIVE || p.IsSold {
+		return nil, rest_errors.NewBadRequestErr("open houses are only available for active listings")
+	}
+	return p, nil
+}
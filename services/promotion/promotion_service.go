@@ -0,0 +1,290 @@
+package promotion
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/superbkibbles/bookstore_utils-go/logger"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/promotion"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/domain/query"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/utils/crypto_utils"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+// DefaultWeights are added to the score of promoted listings per tier;
+// listings without a promotion add 1.
+var DefaultWeights = map[string]float64{
+	promotion.TIER_BASIC:    2,
+	promotion.TIER_PREMIUM:  4,
+	promotion.TIER_FEATURED: 8,
+}
+
+const (
+	defaultPageSize = 20
+	defaultPerPage  = 3
+)
+
+type Service interface {
+	Create(propertyID string, agencyID string, request promotion.Request) (*promotion.Promotion, rest_errors.RestErr)
+	GetByProperty(propertyID string, agencyID string) ([]promotion.ReportEntry, rest_errors.RestErr)
+	Cancel(propertyID string, promotionID string, agencyID string) rest_errors.RestErr
+	Click(propertyID string, promotionID string, visitorID string) (bool, rest_errors.RestErr)
+	Report(agencyID string, callerAgencyID string) (*promotion.Report, rest_errors.RestErr)
+
+	Running(placement string) (promotion.Active, rest_errors.RestErr)
+	Boost(active promotion.Active, q *query.EsQuery)
+	Rank(properties property.Properties, active promotion.Active) property.Properties
+	FlushImpressions() int
+}
+
+// Config caps promoted listings at PerPage out of every PageSize results.
+type Config struct {
+	// Weights overrides DefaultWeights per tier.
+	Weights  map[string]float64
+	PageSize int
+	PerPage  int
+}
+
+type service struct {
+	promotionRepo db.PromotionRepository
+	dbRepo        db.DbRepository
+	config        Config
+
+	// impressions are counted in memory and written by FlushImpressions,
+	// so searches do not wait for a write.
+	mu          sync.Mutex
+	impressions map[string]int64
+}
+
+func NewService(promotionRepo db.PromotionRepository, dbRepo db.DbRepository, config Config) Service {
+	weights := make(map[string]float64, len(DefaultWeights))
+	for tier, weight := range DefaultWeights {
+		weights[tier] = weight
+	}
+	for tier, weight := range config.Weights {
+		weights[tier] = weight
+	}
+	config.Weights = weights
+	if config.PageSize <= 0 {
+		config.PageSize = defaultPageSize
+	}
+	if config.PerPage <= 0 {
+		config.PerPage = defaultPerPage
+	}
+	return &service{
+		promotionRepo: promotionRepo,
+		dbRepo:        dbRepo,
+		config:        config,
+		impressions:   map[string]int64{},
+	}
+}
+
+func (s *service) Create(propertyID string, agencyID string, request promotion.Request) (*promotion.Promotion, rest_errors.RestErr) {
+	p, err := s.property(propertyID, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	if p.IsSold {
+		return nil, rest_errors.NewBadRequestErr("sold listings can not be promoted")
+	}
+	now := date_utils.GetNow()
+	if err := request.Validate(now); err != nil {
+		return nil, err
+	}
+
+	promo := promotion.Promotion{
+		PropertyID:  p.ID,
+		AgencyID:    p.AgencyID,
+		Tier:        request.Tier,
+		Placement:   request.Placement,
+		Start:       request.Start,
+		End:         request.End,
+		DateCreated: date_utils.GetNowISO(),
+	}
+	existing, err := s.promotionRepo.GetByProperty(p.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range existing {
+		if other.Status(now) != promotion.STATUS_ENDED && promo.Overlaps(other) {
+			return nil, rest_errors.NewRestError(fmt.Sprintf("the listing is already promoted from %s to %s", other.Start, other.End), http.StatusConflict, "conflict", nil)
+		}
+	}
+	return s.promotionRepo.Create(promo)
+}
+
+func (s *service) GetByProperty(propertyID string, agencyID string) ([]promotion.ReportEntry, rest_errors.RestErr) {
+	if _, err := s.property(propertyID, agencyID); err != nil {
+		return nil, err
+	}
+	promotions, err := s.promotionRepo.GetByProperty(propertyID)
+	if err != nil {
+		return nil, err
+	}
+	now := date_utils.GetNow()
+	entries := make([]promotion.ReportEntry, 0, len(promotions))
+	for _, p := range promotions {
+		entries = append(entries, p.Entry(now))
+	}
+	return entries, nil
+}
+
+// Cancel stops a promotion right away. Cancelling twice is not an error.
+func (s *service) Cancel(propertyID string, promotionID string, agencyID string) rest_errors.RestErr {
+	if _, err := s.property(propertyID, agencyID); err != nil {
+		return err
+	}
+	promo, err := s.promotionRepo.GetByID(promotionID)
+	if err != nil {
+		return err
+	}
+	if promo.PropertyID != propertyID {
+		return rest_errors.NewNotFoundErr(fmt.Sprintf("no promotion was found with id %s", promotionID))
+	}
+	_, err = s.promotionRepo.Cancel(promotionID, date_utils.GetNowISO())
+	return err
+}
+
+// Click counts a click on a promoted result unless the same visitor already
+// clicked it within the click window. Clicks after the promotion ended are
+// not counted.
+func (s *service) Click(propertyID string, promotionID string, visitorID string) (bool, rest_errors.RestErr) {
+	if visitorID == "" {
+		return false, rest_errors.NewBadRequestErr("invalid visitor")
+	}
+	promo, err := s.promotionRepo.GetByID(promotionID)
+	if err != nil {
+		return false, err
+	}
+	if promo.PropertyID != propertyID {
+		return false, rest_errors.NewNotFoundErr(fmt.Sprintf("no promotion was found with id %s", promotionID))
+	}
+	now := date_utils.GetNow()
+	if promo.Status(now) != promotion.STATUS_ACTIVE {
+		return false, nil
+	}
+	window := now.Unix() / int64(promotion.ClickWindow.Seconds())
+	id := crypto_utils.GetMd5(fmt.Sprintf("%s|%s|%d", promo.ID, visitorID, window))
+	recorded, err := s.promotionRepo.RecordClick(id, promotion.Click{
+		PromotionID: promo.ID,
+		PropertyID:  promo.PropertyID,
+		VisitorID:   visitorID,
+		Timestamp:   now,
+	})
+	if err != nil || !recorded {
+		return false, err
+	}
+	if err := s.promotionRepo.CountClick(promotionID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *service) Report(agencyID string, callerAgencyID string) (*promotion.Report, rest_errors.RestErr) {
+	if callerAgencyID == "" || callerAgencyID != agencyID {
+		return nil, rest_errors.NewRestError("promotion reports can only be read by their agency", http.StatusForbidden, "forbidden", nil)
+	}
+	promotions, err := s.promotionRepo.GetByAgency(agencyID)
+	if err != nil {
+		return nil, err
+	}
+	now := date_utils.GetNow()
+	report := promotion.Report{AgencyID: agencyID, Promotions: make([]promotion.ReportEntry, 0, len(promotions))}
+	for _, p := range promotions {
+		report.Impressions += p.Impressions
+		report.Clicks += p.Clicks
+		report.Promotions = append(report.Promotions, p.Entry(now))
+	}
+	return &report, nil
+}
+
+// Running returns the promotions boosting placement right now. A listing
+// with several, e.g. one per placement, keeps the heaviest.
+func (s *service) Running(placement string) (promotion.Active, rest_errors.RestErr) {
+	promotions, err := s.promotionRepo.GetRunning(placement, date_utils.GetNowISO())
+	if err != nil {
+		return nil, err
+	}
+	active := promotion.Active{}
+	for _, p := range promotions {
+		if current, ok := active[p.PropertyID]; ok && s.config.Weights[current.Tier] >= s.config.Weights[p.Tier] {
+			continue
+		}
+		active[p.PropertyID] = p
+	}
+	return active, nil
+}
+
+func (s *service) Boost(active promotion.Active, q *query.EsQuery) {
+	ids := map[string][]string{}
+	for propertyID, p := range active {
+		ids[p.Tier] = append(ids[p.Tier], propertyID)
+	}
+	for tier, weight := range s.config.Weights {
+		if len(ids[tier]) > 0 {
+			q.Boosts = append(q.Boosts, query.Boost{IDs: ids[tier], Weight: weight})
+		}
+	}
+}
+
+// Rank labels promoted listings, caps them per page and counts an
+// impression for each one returned. The counts are written by
+// FlushImpressions.
+func (s *service) Rank(properties property.Properties, active promotion.Active) property.Properties {
+	active.Mark(properties)
+	ranked := promotion.Cap(properties, s.config.PageSize, s.config.PerPage)
+
+	s.mu.Lock()
+	for _, p := range ranked {
+		if p.Promoted {
+			s.impressions[p.PromotionID]++
+		}
+	}
+	s.mu.Unlock()
+	return ranked
+}
+
+// FlushImpressions writes the impressions counted since the last flush, one
+// update per promotion, and returns how many promotions it updated. Counts
+// that fail to be written are kept for the next flush; those of promotions
+// deleted meanwhile are dropped. Counts not flushed yet are lost when the
+// instance stops.
+func (s *service) FlushImpressions() int {
+	s.mu.Lock()
+	counts := s.impressions
+	s.impressions = map[string]int64{}
+	s.mu.Unlock()
+
+	flushed := 0
+	for id, count := range counts {
+		if err := s.promotionRepo.CountImpressions(id, count); err != nil {
+			if err.Status() == http.StatusNotFound {
+				continue
+			}
+			logger.Error(fmt.Sprintf("error while counting impressions of promotion %s", id), errors.New(err.Message()))
+			s.mu.Lock()
+			s.impressions[id] += count
+			s.mu.Unlock()
+			continue
+		}
+		flushed++
+	}
+	return flushed
+}
+
+// property returns the listing if agencyID owns it.
+func (s *service) property(propertyID string, agencyID string) (*property.Property, rest_errors.RestErr) {
+	p, err := s.dbRepo.GetByID(propertyID)
+	if err != nil {
+		return nil, err
+	}
+	if agencyID == "" || agencyID != p.AgencyID {
+		return nil, rest_errors.NewRestError("listings can only be promoted by their agency", http.StatusForbidden, "forbidden", nil)
+	}
+	return p, nil
+}
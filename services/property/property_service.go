@@ -1,54 +1,270 @@
 package property
 
 import (
-	"mime/multipart"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/superbkibbles/bookstore_utils-go/logger"
 	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	domainAudit "github.com/superbkibbles/realestate_property-api/domain/audit"
+	domainEvent "github.com/superbkibbles/realestate_property-api/domain/event"
+	domainPromotion "github.com/superbkibbles/realestate_property-api/domain/promotion"
 	"github.com/superbkibbles/realestate_property-api/domain/property"
 	"github.com/superbkibbles/realestate_property-api/domain/query"
 	cloudstorage "github.com/superbkibbles/realestate_property-api/repository/cloudStorage"
 	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/services/audit"
+	"github.com/superbkibbles/realestate_property-api/services/currency"
+	"github.com/superbkibbles/realestate_property-api/services/duplicate"
+	"github.com/superbkibbles/realestate_property-api/services/event"
+	"github.com/superbkibbles/realestate_property-api/services/expiry"
+	"github.com/superbkibbles/realestate_property-api/services/promotion"
 	"github.com/superbkibbles/realestate_property-api/utils/crypto_utils"
 	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
 	"github.com/superbkibbles/realestate_property-api/utils/file_utils"
 )
 
 type Service interface {
-	Create(property.Property) (*property.Property, rest_errors.RestErr)
-	Get(sort string, asc bool, local string) (property.Properties, rest_errors.RestErr)
-	GetByID(string, local string) (*property.Property, rest_errors.RestErr)
-	Search(query query.EsQuery, sort string, asc bool, local string) (property.Properties, rest_errors.RestErr)
-	Update(id string, updateRequest property.EsUpdate) (*property.Property, rest_errors.RestErr)
-	UploadMedia(files []*multipart.FileHeader, propertyID string) rest_errors.RestErr
-	DeleteMedia(propertyID string, mediaID string) rest_errors.RestErr
-	UploadProperyPic(id string, fileHeader *multipart.FileHeader) (*property.Property, rest_errors.RestErr)
-	GetActive(sort string, asc bool, local string) (property.Properties, rest_errors.RestErr)
-	GetDeactive(sort string, asc bool, local string) (property.Properties, rest_errors.RestErr)
-	Translate(id string, translateProperty property.TranslateProperty, local string) (*property.Property, rest_errors.RestErr)
-	GetTranslated(id string, local string) (*property.TranslateProperty, rest_errors.RestErr)
+	Create(p property.Property, actor domainAudit.Actor) (*property.Property, rest_errors.RestErr)
+	Get(sort string, asc bool, local string, displayCurrency string, agencyID string) (property.Properties, rest_errors.RestErr)
+	GetByID(id string, local string, displayCurrency string, agencyID string) (*property.Property, rest_errors.RestErr)
+	Search(query query.EsQuery, sort string, asc bool, local string, displayCurrency string) (property.Properties, rest_errors.RestErr)
+	Update(id string, updateRequest property.EsUpdate, actor domainAudit.Actor) (*property.Property, rest_errors.RestErr)
+	Apply(id string, updateRequest property.EsUpdate, actor domainAudit.Actor) (*property.Property, rest_errors.RestErr)
+	Record(action string, before *property.Property, after *property.Property, actor domainAudit.Actor)
+	UploadMedia(request property.UploadMediaRequest, propertyID string, actor domainAudit.Actor) (property.UploadResults, rest_errors.RestErr)
+	DeleteMedia(propertyID string, mediaID string, actor domainAudit.Actor) rest_errors.RestErr
+	UploadProperyPic(id string, request property.UploadMediaRequest, actor domainAudit.Actor) (*property.Property, rest_errors.RestErr)
+	GetActive(sort string, asc bool, local string, displayCurrency string) (property.Properties, rest_errors.RestErr)
+	GetDeactive(sort string, asc bool, local string, displayCurrency string, agencyID string) (property.Properties, rest_errors.RestErr)
+	Translate(id string, translateProperty property.TranslateProperty, local string, actor domainAudit.Actor) (*property.Property, rest_errors.RestErr)
+	GetTranslated(id string, local string, agencyID string) (*property.TranslateProperty, rest_errors.RestErr)
+	GetPriceHistory(id string, agencyID string) (property.PriceHistory, rest_errors.RestErr)
+	EmbedAgencies(properties property.Properties) rest_errors.RestErr
+	Facets(query query.EsQuery, fields []string, local string, displayCurrency string) (query.Facets, rest_errors.RestErr)
+	Similar(id string, size int, local string, displayCurrency string, agencyID string) (property.Properties, rest_errors.RestErr)
+	Schedule(id string, actor domainAudit.Actor, request property.ScheduleRequest) (*property.Property, rest_errors.RestErr)
+	ScheduleChange(id string, agencyID string, userID string, request property.ScheduledChangeRequest) (*property.ScheduledChange, rest_errors.RestErr)
+	GetScheduledChanges(id string, agencyID string) (property.ScheduledChanges, rest_errors.RestErr)
+	CancelScheduledChange(id string, changeID string, agencyID string) rest_errors.RestErr
+	RunSchedule() (*property.ScheduleReport, rest_errors.RestErr)
 }
 
+// Watcher is told about listings after they were created or updated, e.g. to
+// alert users. Fields names what an update changed and is empty on create.
+// Watchers run in the background.
+type Watcher interface {
+	PropertySaved(p property.Property, fields []string)
+}
+
+const (
+	maxConcurrentUploads = 4
+
+	defaultSimilarSize = 6
+	maxSimilarSize     = 24
+)
+
 type service struct {
-	dbRepo    db.DbRepository
-	cloudRepo cloudstorage.CloudStorage
+	dbRepo           db.DbRepository
+	cloudRepo        cloudstorage.CloudStorage
+	priceRepo        db.PriceHistoryRepository
+	agencyRepo       db.AgencyRepository
+	complexRepo      db.ComplexRepository
+	amenityRepo      db.AmenityRepository
+	changeRepo       db.ScheduledChangeRepository
+	currencyService  currency.Service
+	duplicateService duplicate.Service
+	expiryService    expiry.Service
+	promotionService promotion.Service
+	auditService     audit.Service
+	eventService     event.Service
+	watchers         []Watcher
 }
 
-func NewService(dbRepo db.DbRepository, cloudRepo cloudstorage.CloudStorage) Service {
+func NewService(dbRepo db.DbRepository, cloudRepo cloudstorage.CloudStorage, priceRepo db.PriceHistoryRepository, agencyRepo db.AgencyRepository, complexRepo db.ComplexRepository, amenityRepo db.AmenityRepository, changeRepo db.ScheduledChangeRepository, currencyService currency.Service, duplicateService duplicate.Service, expiryService expiry.Service, promotionService promotion.Service, auditService audit.Service, eventService event.Service, watchers ...Watcher) Service {
 	return &service{
-		dbRepo:    dbRepo,
-		cloudRepo: cloudRepo,
+		dbRepo:           dbRepo,
+		cloudRepo:        cloudRepo,
+		priceRepo:        priceRepo,
+		agencyRepo:       agencyRepo,
+		complexRepo:      complexRepo,
+		amenityRepo:      amenityRepo,
+		changeRepo:       changeRepo,
+		currencyService:  currencyService,
+		duplicateService: duplicateService,
+		expiryService:    expiryService,
+		promotionService: promotionService,
+		auditService:     auditService,
+		eventService:     eventService,
+		watchers:         watchers,
+	}
+}
+
+// record audits a change of the listing and publishes the events it makes.
+// It returns the fields that changed.
+func (s *service) record(action string, before *property.Property, after *property.Property, actor domainAudit.Actor) []string {
+	entry := domainAudit.NewEntry(after.ID, action, actor, before, after)
+	if entry == nil {
+		return nil
+	}
+	s.auditService.Record(entry)
+	s.eventService.Publish(domainEvent.FromChange(action, entry.Fields, before, after)...)
+	return entry.Fields
+}
+
+// Record audits and publishes a change another service had to write itself,
+// e.g. with a conditional update, and tells the watchers about it.
+func (s *service) Record(action string, before *property.Property, after *property.Property, actor domainAudit.Actor) {
+	fields := s.record(action, before, after, actor)
+	if len(fields) == 0 {
+		return
+	}
+	s.notify(*after, fields)
+	if action == domainAudit.ACTION_UPLOAD_MEDIA {
+		go s.checkDuplicates(*after)
+	}
+}
+
+func (s *service) notify(p property.Property, fields []string) {
+	for _, w := range s.watchers {
+		go w.PropertySaved(p, fields)
 	}
 }
 
-func (s *service) Update(id string, updateRequest property.EsUpdate) (*property.Property, rest_errors.RestErr) {
+func (s *service) Update(id string, updateRequest property.EsUpdate, actor domainAudit.Actor) (*property.Property, rest_errors.RestErr) {
 	if err := updateRequest.Validate(); err != nil {
 		return nil, err
 	}
-	return s.dbRepo.Update(id, updateRequest)
+	return s.update(id, updateRequest, actor)
+}
+
+// Apply updates a listing for other services, which set fields clients can
+// not, so the change is audited, published and seen by the watchers like any
+// other update.
+func (s *service) Apply(id string, updateRequest property.EsUpdate, actor domainAudit.Actor) (*property.Property, rest_errors.RestErr) {
+	return s.update(id, updateRequest, actor)
+}
+
+// update applies a validated request. The scheduler uses it to apply
+// queued changes the same way as direct updates.
+func (s *service) update(id string, updateRequest property.EsUpdate, actor domainAudit.Actor) (*property.Property, rest_errors.RestErr) {
+	current, err := s.dbRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateFrom(id, current, updateRequest, actor)
+}
+
+// updateFrom applies a validated request to the listing as it was before,
+// which the audit entry and events are diffed against.
+func (s *service) updateFrom(id string, current *property.Property, updateRequest property.EsUpdate, actor domainAudit.Actor) (*property.Property, rest_errors.RestErr) {
+	for _, field := range updateRequest.Fields {
+		switch field.Field {
+		case "agency_id":
+			agencyID, _ := field.Value.(string)
+			if err := s.checkAgency(agencyID); err != nil {
+				return nil, err
+			}
+		case "complex_id":
+			complexID, _ := field.Value.(string)
+			complexName, err := s.complexName(complexID)
+			if err != nil {
+				return nil, err
+			}
+			updateRequest.Fields = append(updateRequest.Fields, property.UpdatePropertyRequest{Field: "complex_name", Value: complexName})
+		case "amenities":
+			amenities, _ := field.Value.([]string)
+			if err := s.checkAmenities(amenities); err != nil {
+				return nil, err
+			}
+		case "status":
+			if field.Value != property.STATUS_ACTIVE {
+				continue
+			}
+			// Going live by hand drops a pending publish_at.
+			if current.Unpublished() {
+				updateRequest.Fields = append(updateRequest.Fields, property.UpdatePropertyRequest{Field: "publish_at", Value: nil})
+			}
+			// Reactivating an expired listing renews it, or the scheduler
+			// would deactivate it again right away.
+			if now := date_utils.GetNow(); current.Expired(now) {
+				updateRequest.Fields = append(updateRequest.Fields,
+					property.UpdatePropertyRequest{Field: "expires_at", Value: s.expiryService.ExpiresAt(current.Category, now)},
+					property.UpdatePropertyRequest{Field: "expiry_warned_at", Value: nil},
+					property.UpdatePropertyRequest{Field: "expired_at", Value: nil},
+				)
+			}
+		}
+	}
+	if !updateRequest.Has("price", "currency", "for_rent", "rental_terms") {
+		result, err := s.dbRepo.Update(id, updateRequest)
+		if err != nil {
+			return nil, err
+		}
+		s.record(domainAudit.ACTION_UPDATE, current, result, actor)
+		s.notify(*result, updateRequest.FieldNames())
+		return result, nil
+	}
+
+	// Derived price fields depend on the rest of the listing.
+	change, err := updateRequest.PriceChange(current)
+	if err != nil {
+		return nil, err
+	}
+	updated := *current
+	if err := updateRequest.ApplyTo(&updated); err != nil {
+		return nil, err
+	}
+	if err := updated.ValidateRentalTerms(); err != nil {
+		return nil, err
+	}
+
+	if change != nil {
+		basePrice, err := s.currencyService.ToBase(change.NewPrice, change.NewCurrency)
+		if err != nil {
+			return nil, err
+		}
+		updated.BasePrice = basePrice
+		change.ChangedBy = actor.UserID
+		change.ChangedAt = date_utils.GetNowISO()
+		updateRequest.Fields = append(updateRequest.Fields,
+			property.UpdatePropertyRequest{Field: "base_price", Value: basePrice},
+			property.UpdatePropertyRequest{Field: "previous_price", Value: change.OldPrice},
+			property.UpdatePropertyRequest{Field: "price_changed_at", Value: change.ChangedAt},
+			property.UpdatePropertyRequest{Field: "price_reduced", Value: change.IsReduction()},
+		)
+	}
+	if updated.ForRent {
+		updated.NormalizeRent()
+		updateRequest.Fields = append(updateRequest.Fields, property.UpdatePropertyRequest{Field: "rental_terms", Value: updated.RentalTerms})
+	}
+
+	result, err := s.dbRepo.Update(id, updateRequest)
+	if err != nil {
+		return nil, err
+	}
+	if change != nil {
+		if _, err := s.priceRepo.Create(*change); err != nil {
+			logger.Error(fmt.Sprintf("error while trying to record price change of property %s", id), errors.New(err.Message()))
+		}
+	}
+	s.record(domainAudit.ACTION_UPDATE, current, result, actor)
+	s.notify(*result, updateRequest.FieldNames())
+	return result, nil
+}
+
+func (s *service) GetPriceHistory(id string, agencyID string) (property.PriceHistory, rest_errors.RestErr) {
+	if _, err := s.visibleProperty(id, agencyID); err != nil {
+		return nil, err
+	}
+	return s.priceRepo.GetByPropertyID(id)
 }
 
-func (s *service) Translate(id string, translateProperty property.TranslateProperty, local string) (*property.Property, rest_errors.RestErr) {
+func (s *service) Translate(id string, translateProperty property.TranslateProperty, local string, actor domainAudit.Actor) (*property.Property, rest_errors.RestErr) {
 	translateProperty.Local = local
 	translateProperty.PropertyID = id
 	if err := translateProperty.Validate(); err != nil {
@@ -68,6 +284,7 @@ func (s *service) Translate(id string, translateProperty property.TranslatePrope
 		if err != nil {
 			return nil, err
 		}
+		s.recordTranslation(ts, translateProperty, actor)
 		return tpp.Marshal(p), nil
 	}
 
@@ -75,34 +292,194 @@ func (s *service) Translate(id string, translateProperty property.TranslatePrope
 	if err := s.dbRepo.Translate(translateProperty); err != nil {
 		return nil, err
 	}
+	s.recordTranslation(nil, translateProperty, actor)
 
 	return translateProperty.Marshal(p), nil
 }
 
-func (s *service) GetTranslated(id string, local string) (*property.TranslateProperty, rest_errors.RestErr) {
+func (s *service) recordTranslation(before *property.TranslateProperty, after property.TranslateProperty, actor domainAudit.Actor) {
+	if before != nil {
+		after.ID = before.ID
+	}
+	if entry := domainAudit.NewEntry(after.PropertyID, domainAudit.ACTION_TRANSLATE, actor, before, after); entry != nil {
+		entry.Local = after.Local
+		s.auditService.Record(entry)
+	}
+}
+
+func (s *service) GetTranslated(id string, local string, agencyID string) (*property.TranslateProperty, rest_errors.RestErr) {
+	if _, err := s.visibleProperty(id, agencyID); err != nil {
+		return nil, err
+	}
 	return s.dbRepo.GetTranslateById(id, local)
 }
 
-func (s *service) Create(p property.Property) (*property.Property, rest_errors.RestErr) {
+// visibleProperty returns the listing unless it is waiting to go live and
+// agencyID is not its agency, which is told it does not exist. Every public
+// read of a single listing goes through it.
+func (s *service) visibleProperty(id string, agencyID string) (*property.Property, rest_errors.RestErr) {
+	p, err := s.dbRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if !p.VisibleTo(agencyID) {
+		return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no Property was found with id %s", id))
+	}
+	return p, nil
+}
+
+func (s *service) Create(p property.Property, actor domainAudit.Actor) (*property.Property, rest_errors.RestErr) {
 	if err := p.Validate(); err != nil {
 		return nil, err
 	}
+	if err := s.checkAgency(p.AgencyID); err != nil {
+		return nil, err
+	}
+	complexName, err := s.complexName(p.ComplexID)
+	if err != nil {
+		return nil, err
+	}
+	p.ComplexName = complexName
+	if err := s.checkAmenities(p.Amenities); err != nil {
+		return nil, err
+	}
 
+	basePrice, err := s.currencyService.ToBase(p.Price, p.Currency)
+	if err != nil {
+		return nil, err
+	}
+	p.BasePrice = basePrice
+	p.NormalizeRent()
+	p.Agency = nil
+	p.Promoted = false
+	p.PromotionID = ""
+	p.OpenHouses = nil
+	p.MergedInto = ""
+	if err := p.ValidateSchedule(date_utils.GetNow()); err != nil {
+		return nil, err
+	}
 	p.Status = property.STATUS_ACTIVE
 	p.DateCreated = date_utils.GetNowDBFromat()
+	p.ExpiresAt = s.expiryService.ExpiresAt(p.Category, date_utils.GetNow())
+	if p.Unpublished() {
+		// The listing runs for its full duration from the day it goes live.
+		publishAt, _ := time.Parse(time.RFC3339, p.PublishAt)
+		p.Status = property.STATUS_DEACTIVE
+		p.ExpiresAt = s.expiryService.ExpiresAt(p.Category, publishAt)
+	}
+	p.ExpiryWarnedAt = ""
+	p.ExpiredAt = ""
+
+	// A failing duplicate check must not stop listings from being created;
+	// the batch scan picks them up later.
+	matches, checkErr := s.duplicateService.Check(p)
+	if checkErr != nil {
+		logger.Error("error while checking new property for duplicates", errors.New(checkErr.Message()))
+	} else if err := s.duplicateService.Block(matches); err != nil {
+		return nil, err
+	}
+
 	newProperty, err := s.dbRepo.Create(p)
 	if err != nil {
 		return nil, err
 	}
+	if len(matches) > 0 {
+		if _, err := s.duplicateService.Record(newProperty.ID, matches); err != nil {
+			logger.Error(fmt.Sprintf("error while recording duplicates of property %s", newProperty.ID), errors.New(err.Message()))
+		}
+	}
+	s.record(domainAudit.ACTION_CREATE, nil, newProperty, actor)
+	s.notify(*newProperty, nil)
 
 	return newProperty, nil
 }
 
-func (s *service) Get(sort string, asc bool, local string) (property.Properties, rest_errors.RestErr) {
-	properties, err := s.dbRepo.Get(sort, asc)
+// checkAgency makes sure a listing only references an existing agency.
+func (s *service) checkAgency(agencyID string) rest_errors.RestErr {
+	if agencyID == "" {
+		return nil
+	}
+	if _, err := s.agencyRepo.GetByID(agencyID); err != nil {
+		if err.Status() == http.StatusNotFound {
+			return rest_errors.NewBadRequestErr(fmt.Sprintf("unknown agency %s", agencyID))
+		}
+		return err
+	}
+	return nil
+}
+
+// complexName looks up the name stored with listings in a complex, so they
+// can be searched by it.
+func (s *service) complexName(complexID string) (string, rest_errors.RestErr) {
+	if complexID == "" {
+		return "", nil
+	}
+	c, err := s.complexRepo.GetByID(complexID)
+	if err != nil {
+		if err.Status() == http.StatusNotFound {
+			return "", rest_errors.NewBadRequestErr(fmt.Sprintf("unknown complex %s", complexID))
+		}
+		return "", err
+	}
+	return c.Name, nil
+}
+
+// checkAmenities makes sure every amenity is in the catalogue.
+func (s *service) checkAmenities(ids []string) rest_errors.RestErr {
+	if len(ids) == 0 {
+		return nil
+	}
+	amenities, err := s.amenityRepo.GetByIDs(ids)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(amenities))
+	for _, a := range amenities {
+		known[a.ID] = true
+	}
+	for _, id := range ids {
+		if !known[id] {
+			return rest_errors.NewBadRequestErr(fmt.Sprintf("unknown amenity %s", id))
+		}
+	}
+	return nil
+}
+
+// EmbedAgencies adds a summary of its agency to every property.
+func (s *service) EmbedAgencies(properties property.Properties) rest_errors.RestErr {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, p := range properties {
+		if p.AgencyID != "" && !seen[p.AgencyID] {
+			seen[p.AgencyID] = true
+			ids = append(ids, p.AgencyID)
+		}
+	}
+	agencies, err := s.agencyRepo.GetByIDs(ids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]int, len(agencies))
+	for i := range agencies {
+		byID[agencies[i].ID] = i
+	}
+	for i := range properties {
+		if j, ok := byID[properties[i].AgencyID]; ok {
+			properties[i].Agency = agencies[j].Summary()
+		}
+	}
+	return nil
+}
+
+func (s *service) Get(sort string, asc bool, local string, displayCurrency string, agencyID string) (property.Properties, rest_errors.RestErr) {
+	properties, err := s.list(domainPromotion.PLACEMENT_LIST, query.EsQuery{Owner: agencyID}, sort, asc, s.dbRepo.Get)
 	if err != nil {
 		return nil, err
 	}
+	properties = properties.VisibleTo(agencyID)
+	if err := s.currencyService.Display(properties, displayCurrency); err != nil {
+		return nil, err
+	}
 	if local == "en" || local == "" {
 		return properties, nil
 	}
@@ -115,11 +492,15 @@ func (s *service) Get(sort string, asc bool, local string) (property.Properties,
 	return ts.Marshal(properties), nil
 }
 
-func (s *service) GetActive(sort string, asc bool, local string) (property.Properties, rest_errors.RestErr) {
-	properties, err := s.dbRepo.GetActive(sort, asc)
+func (s *service) GetActive(sort string, asc bool, local string, displayCurrency string) (property.Properties, rest_errors.RestErr) {
+	active := query.EsQuery{Filters: []query.FieldValue{{Field: "status", Value: property.STATUS_ACTIVE}}}
+	properties, err := s.list(domainPromotion.PLACEMENT_LIST, active, sort, asc, s.dbRepo.GetActive)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.currencyService.Display(properties, displayCurrency); err != nil {
+		return nil, err
+	}
 	if local == "en" || local == "" {
 		return properties, nil
 	}
@@ -131,11 +512,15 @@ func (s *service) GetActive(sort string, asc bool, local string) (property.Prope
 	return ts.Marshal(properties), nil
 }
 
-func (s *service) GetDeactive(sort string, asc bool, local string) (property.Properties, rest_errors.RestErr) {
-	properties, err := s.dbRepo.GetDeactive(sort, asc)
+func (s *service) GetDeactive(sort string, asc bool, local string, displayCurrency string, agencyID string) (property.Properties, rest_errors.RestErr) {
+	properties, err := s.dbRepo.GetDeactive(s.currencyService.NormalizeSort(sort), asc)
 	if err != nil {
 		return nil, err
 	}
+	properties = properties.VisibleTo(agencyID)
+	if err := s.currencyService.Display(properties, displayCurrency); err != nil {
+		return nil, err
+	}
 	if local == "en" || local == "" {
 		return properties, nil
 	}
@@ -147,11 +532,16 @@ func (s *service) GetDeactive(sort string, asc bool, local string) (property.Pro
 	return ts.Marshal(properties), nil
 }
 
-func (s *service) GetByID(id string, local string) (*property.Property, rest_errors.RestErr) {
-	p, err := s.dbRepo.GetByID(id)
+func (s *service) GetByID(id string, local string, displayCurrency string, agencyID string) (*property.Property, rest_errors.RestErr) {
+	p, err := s.visibleProperty(id, agencyID)
 	if err != nil {
 		return nil, err
 	}
+	properties := property.Properties{*p}
+	if err := s.currencyService.Display(properties, displayCurrency); err != nil {
+		return nil, err
+	}
+	p = &properties[0]
 	if local == "en" || local == "" {
 		return p, nil
 	}
@@ -163,11 +553,46 @@ func (s *service) GetByID(id string, local string) (*property.Property, rest_err
 	return tp.Marshal(p), nil
 }
 
-func (s *service) Search(query query.EsQuery, sort string, asc bool, local string) (property.Properties, rest_errors.RestErr) {
-	properties, err := s.dbRepo.Search(query, sort, asc)
+// list runs get unless the results are ranked by relevance and listings
+// are promoted in placement; then q is searched with the promoted listings
+// boosted. Promotions never stop listings from being returned.
+func (s *service) list(placement string, q query.EsQuery, sort string, asc bool, get func(sort string, asc bool) (property.Properties, rest_errors.RestErr)) (property.Properties, rest_errors.RestErr) {
+	active, err := s.promotionService.Running(placement)
+	if err != nil {
+		logger.Error("error while getting running promotions", errors.New(err.Message()))
+		active = domainPromotion.Active{}
+	}
+	if sort != "" || len(active) == 0 {
+		properties, err := get(s.currencyService.NormalizeSort(sort), asc)
+		if err != nil {
+			return nil, err
+		}
+		active.Mark(properties)
+		return properties, nil
+	}
+
+	s.promotionService.Boost(active, &q)
+	properties, err := s.dbRepo.Search(q, "", false)
+	if err != nil {
+		return nil, err
+	}
+	return s.promotionService.Rank(properties, active), nil
+}
+
+func (s *service) Search(query query.EsQuery, sort string, asc bool, local string, displayCurrency string) (property.Properties, rest_errors.RestErr) {
+	query.NormalizeAmenities()
+	if err := s.currencyService.NormalizeQuery(&query, displayCurrency); err != nil {
+		return nil, err
+	}
+	properties, err := s.list(domainPromotion.PLACEMENT_SEARCH, query, sort, asc, func(sort string, asc bool) (property.Properties, rest_errors.RestErr) {
+		return s.dbRepo.Search(query, sort, asc)
+	})
 	if err != nil {
 		return nil, err
 	}
+	if err := s.currencyService.Display(properties, displayCurrency); err != nil {
+		return nil, err
+	}
 
 	if local == "en" || local == "" {
 		return properties, nil
@@ -180,48 +605,180 @@ func (s *service) Search(query query.EsQuery, sort string, asc bool, local strin
 	return ts.Marshal(properties), nil
 }
 
-func (s *service) UploadMedia(files []*multipart.FileHeader, propertyID string) rest_errors.RestErr {
+// Similar recommends listings comparable to id, best match first.
+func (s *service) Similar(id string, size int, local string, displayCurrency string, agencyID string) (property.Properties, rest_errors.RestErr) {
+	if size <= 0 {
+		size = defaultSimilarSize
+	}
+	if size > maxSimilarSize {
+		size = maxSimilarSize
+	}
+	p, err := s.visibleProperty(id, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	properties, err := s.dbRepo.Similar(*p, size)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.currencyService.Display(properties, displayCurrency); err != nil {
+		return nil, err
+	}
+	if local == "en" || local == "" || len(properties) == 0 {
+		return properties, nil
+	}
+	ts, err := s.dbRepo.GetAllTranslated(local)
+	if err != nil {
+		return nil, err
+	}
+	return ts.Marshal(properties), nil
+}
+
+// Facets labels the amenity facets in local.
+func (s *service) Facets(q query.EsQuery, fields []string, local string, displayCurrency string) (query.Facets, rest_errors.RestErr) {
+	q.NormalizeAmenities()
+	if err := s.currencyService.NormalizeQuery(&q, displayCurrency); err != nil {
+		return nil, err
+	}
+	facets, err := s.dbRepo.Facets(q, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	amenityFacets := facets["amenities"]
+	if len(amenityFacets) == 0 {
+		return facets, nil
+	}
+	ids := make([]string, 0, len(amenityFacets))
+	for _, facet := range amenityFacets {
+		ids = append(ids, facet.Value)
+	}
+	amenities, err := s.amenityRepo.GetByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	labels := make(map[string]string, len(amenities))
+	for _, a := range amenities {
+		labels[a.ID] = a.LabelFor(local)
+	}
+	for i := range amenityFacets {
+		amenityFacets[i].Label = labels[amenityFacets[i].Value]
+	}
+	return facets, nil
+}
+
+func (s *service) UploadMedia(request property.UploadMediaRequest, propertyID string, actor domainAudit.Actor) (property.UploadResults, rest_errors.RestErr) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
 	p, err := s.dbRepo.GetByID(propertyID)
 	if err != nil {
-		return err
+		return nil, err
 	}
+
+	results := s.uploadFiles(request.Media, p.ID)
+	if results.Failed() == len(results) {
+		causes := make([]interface{}, 0, len(results))
+		for _, result := range results {
+			causes = append(causes, property.FileError{File: result.File, Error: result.Error})
+		}
+		return results, rest_errors.NewRestError("none of the files could be uploaded", http.StatusBadGateway, "upload_failed", causes)
+	}
+
 	visuals := p.Visuals
 	videos := p.Videos
-	for _, file := range files {
-		f, err := file.Open()
-		if err != nil {
-			return rest_errors.NewInternalServerErr("Error while trying to open the file", nil)
+	for _, result := range results {
+		if result.Error != "" {
+			continue
 		}
-		defer f.Close()
-		var visual property.Visual
-		var video property.Video
-		res, cloudErr := s.cloudRepo.Save(f, propertyID+crypto_utils.GetMd5(uuid.New().String()), p.ID)
-		if err != nil {
-			return cloudErr
-		}
-		if res.Url != "" {
-			v := res.Url
-			ext := res.Ext
-			publicID := res.PublicID
-
-			if ext == "mp4" || ext == "mov" {
-				video.Url = v
-				video.FileType = ext
-				video.PublicID = publicID
-				videos = append(videos, video)
-			} else {
-				visual.Url = v
-				visual.FileType = ext
-				visual.PublicID = publicID
-				visuals = append(visuals, visual)
-			}
+		if result.Kind == property.MEDIA_KIND_VIDEO {
+			videos = append(videos, property.Video{Url: result.Url, FileType: result.FileType, PublicID: result.PublicID})
+		} else {
+			visuals = append(visuals, property.Visual{Url: result.Url, FileType: result.FileType, PublicID: result.PublicID, Hash: result.Hash})
 		}
 	}
 
-	return s.dbRepo.UploadMedia(visuals, videos, propertyID)
+	if err := s.dbRepo.UploadMedia(visuals, videos, propertyID); err != nil {
+		s.deleteUploaded(results, p.ID)
+		return nil, err
+	}
+	s.recordMedia(domainAudit.ACTION_UPLOAD_MEDIA, *p, visuals, videos, actor)
+	after := *p
+	after.Visuals = visuals
+	go s.checkDuplicates(after)
+	return results, nil
+}
+
+// checkDuplicates checks a listing again once it has photos, which the check
+// on create has nothing to compare with yet.
+func (s *service) checkDuplicates(p property.Property) {
+	matches, err := s.duplicateService.Check(p)
+	if err == nil {
+		_, err = s.duplicateService.Record(p.ID, matches)
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("error while checking property %s for duplicates", p.ID), errors.New(err.Message()))
+	}
+}
+
+// uploadFiles pushes the files to cloud storage with bounded concurrency and
+// returns one result per file, in the same order as media.
+func (s *service) uploadFiles(media []property.MediaFile, folderName string) property.UploadResults {
+	results := make(property.UploadResults, len(media))
+	sem := make(chan struct{}, maxConcurrentUploads)
+	var wg sync.WaitGroup
+	for i := range media {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = s.uploadFile(media[i], folderName)
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+func (s *service) uploadFile(media property.MediaFile, folderName string) property.UploadResult {
+	result := property.UploadResult{File: media.Name, Kind: media.Kind, FileType: media.Ext, Hash: media.Hash}
+	f, err := media.Open()
+	if err != nil {
+		result.Error = "error while trying to open the file"
+		return result
+	}
+	defer f.Close()
+
+	res, cloudErr := s.cloudRepo.Save(f, folderName+crypto_utils.GetMd5(uuid.New().String()), folderName)
+	if cloudErr != nil {
+		result.Error = cloudErr.Message()
+		return result
+	}
+	if res.Url == "" {
+		result.Error = "cloud storage returned no url"
+		s.cloudRepo.Delete(folderName + "/" + res.PublicID)
+		return result
+	}
+	result.Url = res.Url
+	result.PublicID = res.PublicID
+	return result
+}
+
+// deleteUploaded removes assets that were stored but never attached to the
+// property, so they don't linger in cloud storage. Stored ids are relative to
+// folderName, which storage needs to find them.
+func (s *service) deleteUploaded(results property.UploadResults, folderName string) {
+	for _, result := range results {
+		if result.Error != "" || result.PublicID == "" {
+			continue
+		}
+		if err := s.cloudRepo.Delete(folderName + "/" + result.PublicID); err != nil {
+			logger.Error(fmt.Sprintf("error while trying to clean up asset %s", result.PublicID), errors.New(err.Message()))
+		}
+	}
 }
 
-func (s *service) DeleteMedia(propertyID string, mediaID string) rest_errors.RestErr {
+func (s *service) DeleteMedia(propertyID string, mediaID string, actor domainAudit.Actor) rest_errors.RestErr {
 	p, err := s.dbRepo.GetByID(propertyID)
 	if err != nil {
 		return err
@@ -233,7 +790,7 @@ func (s *service) DeleteMedia(propertyID string, mediaID string) rest_errors.Res
 	for _, v := range p.Visuals {
 		if v.PublicID == mediaID {
 			if v.FileType != "mp4" && v.FileType != "mov" {
-				if err := s.cloudRepo.Delete(mediaID); err != nil {
+				if err := s.cloudRepo.Delete(propertyID + "/" + mediaID); err != nil {
 					return err
 				}
 				continue
@@ -245,7 +802,7 @@ func (s *service) DeleteMedia(propertyID string, mediaID string) rest_errors.Res
 	for _, v := range p.Videos {
 		if v.PublicID == mediaID {
 			if v.FileType == "mp4" || v.FileType == "mov" {
-				if err := s.cloudRepo.Delete(mediaID); err != nil {
+				if err := s.cloudRepo.Delete(propertyID + "/" + mediaID); err != nil {
 					return err
 				}
 				continue
@@ -254,27 +811,44 @@ func (s *service) DeleteMedia(propertyID string, mediaID string) rest_errors.Res
 		videos = append(videos, v)
 	}
 
-	return s.dbRepo.UploadMedia(visuals, videos, propertyID)
+	if err := s.dbRepo.UploadMedia(visuals, videos, propertyID); err != nil {
+		return err
+	}
+	s.recordMedia(domainAudit.ACTION_DELETE_MEDIA, *p, visuals, videos, actor)
+	return nil
+}
+
+func (s *service) recordMedia(action string, before property.Property, visuals []property.Visual, videos []property.Video, actor domainAudit.Actor) {
+	after := before
+	after.Visuals = visuals
+	after.Videos = videos
+	s.record(action, &before, &after, actor)
 }
 
-func (srv *service) UploadProperyPic(propertyID string, fileHeader *multipart.FileHeader) (*property.Property, rest_errors.RestErr) {
+func (srv *service) UploadProperyPic(propertyID string, request property.UploadMediaRequest, actor domainAudit.Actor) (*property.Property, rest_errors.RestErr) {
+	request.ImagesOnly = true
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
 	p, err := srv.dbRepo.GetByID(propertyID)
 	if err != nil {
 		return nil, err
 	}
+	before := *p
 	if p.PropertyPic != "" {
 		file_utils.DeleteFile(p.PropertyPic, propertyID)
 		p.PropertyPic = ""
 	}
-	file, fErr := fileHeader.Open()
+	file, fErr := request.Media[0].Open()
 	if fErr != nil {
 		return nil, rest_errors.NewInternalServerErr("Error while trying to open the file", nil)
 	}
+	defer file.Close()
 	res, cloudErr := srv.cloudRepo.Save(file, propertyID+crypto_utils.GetMd5(uuid.New().String()), p.ID)
-	filePath := res.Url
 	if cloudErr != nil {
 		return nil, cloudErr
 	}
+	filePath := res.Url
 	p.PropertyPic = filePath
 
 	var es property.EsUpdate
@@ -285,7 +859,10 @@ func (srv *service) UploadProperyPic(propertyID string, fileHeader *multipart.Fi
 
 	es.Fields = append(es.Fields, update)
 
-	srv.dbRepo.Update(propertyID, es)
+	if _, err := srv.dbRepo.Update(propertyID, es); err != nil {
+		return nil, err
+	}
+	srv.record(domainAudit.ACTION_UPLOAD_MEDIA, &before, p, actor)
 
 	return p, nil
 }
@@ -0,0 +1,249 @@
+package property
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/logger"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	domainAudit "github.com/superbkibbles/realestate_property-api/domain/audit"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+// Schedule sets when a listing goes live and comes down. A listing with a
+// publish_at stays deactivated and hidden until then.
+func (s *service) Schedule(id string, actor domainAudit.Actor, request property.ScheduleRequest) (*property.Property, rest_errors.RestErr) {
+	p, err := s.scheduledProperty(id, actor.AgencyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := request.Validate(date_utils.GetNow()); err != nil {
+		return nil, err
+	}
+	if p.IsSold && (request.PublishAt != "" || request.UnpublishAt != "") {
+		return nil, rest_errors.NewBadRequestErr("sold listings can not be scheduled")
+	}
+
+	esUpdate := property.EsUpdate{Fields: []property.UpdatePropertyRequest{
+		{Field: "publish_at", Value: nullable(request.PublishAt)},
+		{Field: "unpublish_at", Value: nullable(request.UnpublishAt)},
+	}}
+	if request.PublishAt != "" {
+		if p.Status == property.STATUS_ACTIVE && !p.Unpublished() {
+			return nil, rest_errors.NewBadRequestErr("the listing is already live")
+		}
+		publishAt, _ := time.Parse(time.RFC3339, request.PublishAt)
+		esUpdate.Fields = append(esUpdate.Fields,
+			property.UpdatePropertyRequest{Field: "status", Value: property.STATUS_DEACTIVE},
+			property.UpdatePropertyRequest{Field: "expires_at", Value: s.expiryService.ExpiresAt(p.Category, publishAt)},
+			property.UpdatePropertyRequest{Field: "expiry_warned_at", Value: nil},
+			property.UpdatePropertyRequest{Field: "expired_at", Value: nil},
+		)
+	}
+
+	result, err := s.dbRepo.Update(id, esUpdate)
+	if err != nil {
+		return nil, err
+	}
+	s.record(domainAudit.ACTION_UPDATE, p, result, actor)
+	s.notify(*result, esUpdate.FieldNames())
+	return result, nil
+}
+
+// ScheduleChange queues fields to update at apply_at, e.g. a new price that
+// must not be seen before launch.
+func (s *service) ScheduleChange(id string, agencyID string, userID string, request property.ScheduledChangeRequest) (*property.ScheduledChange, rest_errors.RestErr) {
+	p, err := s.scheduledProperty(id, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := request.Validate(date_utils.GetNow()); err != nil {
+		return nil, err
+	}
+	changes, err := s.changeRepo.GetByProperty(id)
+	if err != nil {
+		return nil, err
+	}
+	pending := 0
+	for _, c := range changes {
+		if c.Status == property.CHANGE_PENDING {
+			pending++
+		}
+	}
+	if pending >= property.MaxScheduledChanges {
+		return nil, rest_errors.NewBadRequestErr(fmt.Sprintf("a listing can have at most %d pending changes", property.MaxScheduledChanges))
+	}
+
+	return s.changeRepo.Create(property.ScheduledChange{
+		PropertyID:  id,
+		AgencyID:    p.AgencyID,
+		ApplyAt:     request.ApplyAt,
+		Fields:      request.Fields,
+		Status:      property.CHANGE_PENDING,
+		CreatedBy:   userID,
+		DateCreated: date_utils.GetNowISO(),
+	})
+}
+
+func (s *service) GetScheduledChanges(id string, agencyID string) (property.ScheduledChanges, rest_errors.RestErr) {
+	if _, err := s.scheduledProperty(id, agencyID); err != nil {
+		return nil, err
+	}
+	return s.changeRepo.GetByProperty(id)
+}
+
+func (s *service) CancelScheduledChange(id string, changeID string, agencyID string) rest_errors.RestErr {
+	if _, err := s.scheduledProperty(id, agencyID); err != nil {
+		return err
+	}
+	c, err := s.changeRepo.GetByID(changeID)
+	if err != nil {
+		return err
+	}
+	if c.PropertyID != id {
+		return rest_errors.NewNotFoundErr(fmt.Sprintf("no scheduled change was found with id %s", changeID))
+	}
+	cancelled, err := s.changeRepo.SetStatus(changeID, property.CHANGE_PENDING, property.CHANGE_CANCELLED)
+	if err != nil {
+		return err
+	}
+	if !cancelled && c.Status != property.CHANGE_CANCELLED {
+		return rest_errors.NewRestError("only pending changes can be cancelled", http.StatusConflict, "conflict", nil)
+	}
+	return nil
+}
+
+// changeLock is how long an instance has to apply a change before another
+// one takes it over.
+const changeLock = time.Minute
+
+// RunSchedule applies due changes, then publishes and unpublishes due
+// listings, so a listing goes live with the changes embargoed until then.
+// Every step is claimed first, so instances running it at the same time do
+// not apply anything twice.
+func (s *service) RunSchedule() (*property.ScheduleReport, rest_errors.RestErr) {
+	now := date_utils.GetNowISO()
+	report := property.ScheduleReport{}
+
+	changes, err := s.changeRepo.GetDue(now)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range changes {
+		claimed, err := s.changeRepo.Claim(c.ID, now, date_utils.GetNow().Add(changeLock).Format(time.RFC3339))
+		if err != nil {
+			return nil, err
+		}
+		if !claimed {
+			continue
+		}
+		status, message := property.CHANGE_APPLIED, ""
+		if err := s.applyChange(c); err != nil {
+			logger.Error(fmt.Sprintf("error while applying scheduled change %s of property %s", c.ID, c.PropertyID), errors.New(err.Message()))
+			status, message = property.CHANGE_FAILED, err.Message()
+			report.Failed++
+		} else {
+			report.Applied++
+		}
+		if err := s.changeRepo.Finish(c.ID, status, message, date_utils.GetNowISO()); err != nil {
+			return nil, err
+		}
+	}
+
+	published, err := s.transition("publish_at", property.STATUS_ACTIVE, now)
+	if err != nil {
+		return nil, err
+	}
+	report.Published = published
+	unpublished, err := s.transition("unpublish_at", property.STATUS_DEACTIVE, now)
+	if err != nil {
+		return nil, err
+	}
+	report.Unpublished = unpublished
+	return &report, nil
+}
+
+// applyChange validates the fields again as the listing, its agency or its
+// complex may have changed since the change was queued.
+func (s *service) applyChange(c property.ScheduledChange) rest_errors.RestErr {
+	esUpdate := property.EsUpdate{Fields: c.Fields}
+	if err := esUpdate.Validate(); err != nil {
+		return err
+	}
+	_, err := s.update(c.PropertyID, esUpdate, domainAudit.Actor{UserID: c.CreatedBy, AgencyID: c.AgencyID})
+	return err
+}
+
+// transition sets status on the listings whose field is due. A listing that
+// fails keeps its schedule and is retried on the next run.
+func (s *service) transition(field string, status string, now string) (int, rest_errors.RestErr) {
+	due, err := s.dbRepo.GetDueSchedule(field, now)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, p := range due {
+		claimed, err := s.dbRepo.ClaimSchedule(p.ID, field, now)
+		if err != nil {
+			return count, err
+		}
+		if !claimed {
+			continue
+		}
+		esUpdate := property.EsUpdate{Fields: []property.UpdatePropertyRequest{{Field: "status", Value: status}}}
+		if _, err := s.applyTransition(p, field, esUpdate); err != nil {
+			logger.Error(fmt.Sprintf("error while applying %s of property %s", field, p.ID), errors.New(err.Message()))
+			at := p.PublishAt
+			if field == "unpublish_at" {
+				at = p.UnpublishAt
+			}
+			restore := property.EsUpdate{Fields: []property.UpdatePropertyRequest{{Field: field, Value: at}}}
+			if _, err := s.dbRepo.Update(p.ID, restore); err != nil {
+				logger.Error(fmt.Sprintf("error while restoring %s of property %s", field, p.ID), errors.New(err.Message()))
+			}
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+// applyTransition updates a listing whose field was claimed. The claim
+// already removed the field, so the listing is diffed as it was before the
+// claim and the audit entry and events show it going away.
+func (s *service) applyTransition(claimed property.Property, field string, esUpdate property.EsUpdate) (*property.Property, rest_errors.RestErr) {
+	current, err := s.dbRepo.GetByID(claimed.ID)
+	if err != nil {
+		return nil, err
+	}
+	before := *current
+	if field == "publish_at" {
+		before.PublishAt = claimed.PublishAt
+	} else {
+		before.UnpublishAt = claimed.UnpublishAt
+	}
+	return s.updateFrom(claimed.ID, &before, esUpdate, domainAudit.Actor{UserID: domainAudit.ActorScheduler})
+}
+
+// scheduledProperty returns the listing if agencyID owns it.
+func (s *service) scheduledProperty(id string, agencyID string) (*property.Property, rest_errors.RestErr) {
+	p, err := s.dbRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if agencyID == "" || agencyID != p.AgencyID {
+		return nil, rest_errors.NewRestError("listings can only be scheduled by their agency", http.StatusForbidden, "forbidden", nil)
+	}
+	return p, nil
+}
+
+// nullable removes empty values from the document instead of storing "".
+func nullable(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,265 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/superbkibbles/bookstore_utils-go/logger"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/notifier"
+	"github.com/superbkibbles/realestate_property-api/domain/notification"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/domain/search"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/services/currency"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+const (
+	eventMatch  = "saved_search.match"
+	eventDigest = "saved_search.digest"
+)
+
+type Service interface {
+	Create(userID string, s search.SavedSearch) (*search.SavedSearch, rest_errors.RestErr)
+	Get(userID string) (search.SavedSearches, rest_errors.RestErr)
+	GetByID(userID string, id string) (*search.SavedSearch, rest_errors.RestErr)
+	Update(userID string, id string, s search.SavedSearch) (*search.SavedSearch, rest_errors.RestErr)
+	Delete(userID string, id string) rest_errors.RestErr
+	PropertySaved(p property.Property, fields []string)
+	SendDigests() (*search.DigestReport, rest_errors.RestErr)
+}
+
+type service struct {
+	searchRepo      db.SavedSearchRepository
+	alertRepo       db.SearchAlertRepository
+	dbRepo          db.DbRepository
+	currencyService currency.Service
+	notifier        notifier.Notifier
+}
+
+func NewService(searchRepo db.SavedSearchRepository, alertRepo db.SearchAlertRepository, dbRepo db.DbRepository, currencyService currency.Service, notifier notifier.Notifier) Service {
+	return &service{
+		searchRepo:      searchRepo,
+		alertRepo:       alertRepo,
+		dbRepo:          dbRepo,
+		currencyService: currencyService,
+		notifier:        notifier,
+	}
+}
+
+func (s *service) Create(userID string, saved search.SavedSearch) (*search.SavedSearch, rest_errors.RestErr) {
+	if userID == "" {
+		return nil, rest_errors.NewUnauthorizedError("user id is required")
+	}
+	if err := s.validate(&saved); err != nil {
+		return nil, err
+	}
+	existing, err := s.searchRepo.GetByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= search.MaxSearchesPerUser {
+		return nil, rest_errors.NewBadRequestErr(fmt.Sprintf("a user can save at most %d searches", search.MaxSearchesPerUser))
+	}
+	saved.UserID = userID
+	saved.LastNotifiedAt = ""
+	saved.DateCreated = date_utils.GetNowDBFromat()
+	return s.searchRepo.Create(saved)
+}
+
+func (s *service) Get(userID string) (search.SavedSearches, rest_errors.RestErr) {
+	if userID == "" {
+		return nil, rest_errors.NewUnauthorizedError("user id is required")
+	}
+	return s.searchRepo.GetByUser(userID)
+}
+
+// GetByID only returns searches of userID; others are reported as missing.
+func (s *service) GetByID(userID string, id string) (*search.SavedSearch, rest_errors.RestErr) {
+	if userID == "" {
+		return nil, rest_errors.NewUnauthorizedError("user id is required")
+	}
+	saved, err := s.searchRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if saved.UserID != userID {
+		return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no saved search was found with id %s", id))
+	}
+	return saved, nil
+}
+
+func (s *service) Update(userID string, id string, saved search.SavedSearch) (*search.SavedSearch, rest_errors.RestErr) {
+	if _, err := s.GetByID(userID, id); err != nil {
+		return nil, err
+	}
+	if err := s.validate(&saved); err != nil {
+		return nil, err
+	}
+	return s.searchRepo.Update(id, saved)
+}
+
+func (s *service) Delete(userID string, id string) rest_errors.RestErr {
+	if _, err := s.GetByID(userID, id); err != nil {
+		return err
+	}
+	return s.searchRepo.Delete(id)
+}
+
+// validate also makes sure the query can be evaluated in the search's
+// currency.
+func (s *service) validate(saved *search.SavedSearch) rest_errors.RestErr {
+	if err := saved.Validate(); err != nil {
+		return err
+	}
+	q := saved.Query
+	return s.currencyService.NormalizeQuery(&q, saved.Currency)
+}
+
+// PropertySaved matches a created or updated listing against every saved
+// search. Each listing is announced once per search, either right away or in
+// the next digest. It is meant to run in the background.
+func (s *service) PropertySaved(p property.Property, fields []string) {
+	if p.Status != property.STATUS_ACTIVE || p.IsSold {
+		return
+	}
+	bytes, _ := json.Marshal(p)
+	var doc map[string]interface{}
+	if err := json.Unmarshal(bytes, &doc); err != nil {
+		return
+	}
+	searches, err := s.searchRepo.GetAll()
+	if err != nil {
+		logger.Error("error while loading saved searches", errors.New(err.Message()))
+		return
+	}
+
+	for _, saved := range searches {
+		q := saved.Query
+		q.Range = append(q.Range[:0:0], q.Range...)
+		q.Gt = append(q.Gt[:0:0], q.Gt...)
+		if err := s.currencyService.NormalizeQuery(&q, saved.Currency); err != nil || !q.Matches(doc) {
+			continue
+		}
+		alert := search.Alert{
+			ID:          search.AlertID(saved.ID, p.ID),
+			SearchID:    saved.ID,
+			UserID:      saved.UserID,
+			PropertyID:  p.ID,
+			Title:       s.title(p, saved.Local),
+			Price:       p.Price,
+			Currency:    p.Currency,
+			Status:      search.ALERT_PENDING,
+			DateCreated: date_utils.GetNowDBFromat(),
+		}
+		isNew, err := s.alertRepo.Create(alert)
+		if err != nil {
+			logger.Error(fmt.Sprintf("error while recording alert for saved search %s", saved.ID), errors.New(err.Message()))
+			continue
+		}
+		if !isNew || saved.Frequency == search.FREQUENCY_DIGEST {
+			continue
+		}
+		if err := s.send(saved, search.Alerts{alert}); err != nil {
+			logger.Error(fmt.Sprintf("error while sending alert for saved search %s", saved.ID), errors.New(err.Message()))
+		}
+	}
+}
+
+// SendDigests sends every pending alert, one message per saved search.
+func (s *service) SendDigests() (*search.DigestReport, rest_errors.RestErr) {
+	report := search.DigestReport{StartedAt: date_utils.GetNowDBFromat()}
+	pending, err := s.alertRepo.GetPending()
+	if err != nil {
+		return nil, err
+	}
+	bySearch := map[string]search.Alerts{}
+	var order []string
+	for _, alert := range pending {
+		if _, ok := bySearch[alert.SearchID]; !ok {
+			order = append(order, alert.SearchID)
+		}
+		bySearch[alert.SearchID] = append(bySearch[alert.SearchID], alert)
+	}
+
+	for _, searchID := range order {
+		alerts := bySearch[searchID]
+		saved, err := s.searchRepo.GetByID(searchID)
+		if err != nil && err.Status() == http.StatusNotFound {
+			// The search was deleted, drop what it collected.
+			s.alertRepo.MarkSent(alertIDs(alerts), date_utils.GetNowDBFromat())
+			continue
+		}
+		if err == nil {
+			err = s.send(*saved, alerts)
+		}
+		if err != nil {
+			report.Failed++
+			logger.Error(fmt.Sprintf("error while sending digest for saved search %s", searchID), errors.New(err.Message()))
+			continue
+		}
+		report.Searches++
+		report.Alerts += len(alerts)
+	}
+	report.FinishedAt = date_utils.GetNowDBFromat()
+	return &report, nil
+}
+
+func (s *service) send(saved search.SavedSearch, alerts search.Alerts) rest_errors.RestErr {
+	message := notification.Message{
+		Channel: saved.Channel,
+		To:      saved.Target,
+		Event:   eventMatch,
+		Subject: fmt.Sprintf("New listing for %s", saved.Name),
+		Payload: map[string]interface{}{
+			"search_id":   saved.ID,
+			"search_name": saved.Name,
+			"alerts":      alerts,
+		},
+	}
+	if len(alerts) > 1 {
+		message.Event = eventDigest
+		message.Subject = fmt.Sprintf("%d new listings for %s", len(alerts), saved.Name)
+	}
+	if message.To == "" {
+		message.To = saved.UserID
+	}
+	var text strings.Builder
+	for _, alert := range alerts {
+		fmt.Fprintf(&text, "%s: %d %s (%s)\n", alert.Title, alert.Price, alert.Currency, alert.PropertyID)
+	}
+	message.Text = text.String()
+
+	if err := s.notifier.Notify(message); err != nil {
+		return rest_errors.NewInternalServerErr("error when trying to send alert", err)
+	}
+	now := date_utils.GetNowDBFromat()
+	if err := s.alertRepo.MarkSent(alertIDs(alerts), now); err != nil {
+		return err
+	}
+	return s.searchRepo.SetLastNotified(saved.ID, now)
+}
+
+// title is the listing title in the search's language when translated.
+func (s *service) title(p property.Property, local string) string {
+	if local == "" || local == "en" {
+		return p.Title
+	}
+	tp, err := s.dbRepo.GetTranslateById(p.ID, local)
+	if err != nil || tp.Title == "" {
+		return p.Title
+	}
+	return tp.Title
+}
+
+func alertIDs(alerts search.Alerts) []string {
+	ids := make([]string, 0, len(alerts))
+	for _, alert := range alerts {
+		ids = append(ids, alert.ID)
+	}
+	return ids
+}
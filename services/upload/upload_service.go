@@ -0,0 +1,372 @@
+package upload
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/audit"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/domain/upload"
+	cloudstorage "github.com/superbkibbles/realestate_property-api/repository/cloudStorage"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/utils/crypto_utils"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+const (
+	// ticketTTL is how long a ticket may go without receiving data. Every
+	// accepted chunk extends it, so large uploads only fail when they stall.
+	ticketTTL  = 30 * time.Minute
+	uploadPath = "/api/property/uploads/"
+)
+
+type Config struct {
+	SigningSecret string
+	PublicURL     string
+	// TempDir holds partially uploaded files. Chunks of one upload must reach
+	// the same instance, or TempDir must be shared between instances.
+	TempDir string
+}
+
+type Service interface {
+	CreateTicket(propertyID string, request upload.TicketRequest) (*upload.Ticket, rest_errors.RestErr)
+	GetStatus(ticketID string, token string) (*upload.Ticket, rest_errors.RestErr)
+	WriteChunk(ticketID string, token string, offset int64, chunk io.Reader) (*upload.Ticket, rest_errors.RestErr)
+	WriteDirect(ticketID string, token string, body io.Reader) (*upload.Ticket, rest_errors.RestErr)
+	Complete(propertyID string, ticketID string, token string, actor audit.Actor) (*property.Property, rest_errors.RestErr)
+}
+
+// Recorder hands media the service added to the property service, so the
+// upload is audited, published and seen by its watchers.
+type Recorder interface {
+	Record(action string, before *property.Property, after *property.Property, actor audit.Actor)
+}
+
+type service struct {
+	dbRepo     db.DbRepository
+	ticketRepo db.UploadTicketRepository
+	cloudRepo  cloudstorage.CloudStorage
+	recorder   Recorder
+	config     Config
+
+	mu    sync.Mutex
+	locks map[string]*ticketLock
+}
+
+// ticketLock serializes requests for one ticket. It is dropped once nobody
+// holds or waits for it.
+type ticketLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func NewService(dbRepo db.DbRepository, ticketRepo db.UploadTicketRepository, cloudRepo cloudstorage.CloudStorage, recorder Recorder, config Config) Service {
+	return &service{
+		dbRepo:     dbRepo,
+		ticketRepo: ticketRepo,
+		cloudRepo:  cloudRepo,
+		recorder:   recorder,
+		config:     config,
+		locks:      map[string]*ticketLock{},
+	}
+}
+
+func (s *service) CreateTicket(propertyID string, request upload.TicketRequest) (*upload.Ticket, rest_errors.RestErr) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	p, err := s.dbRepo.GetByID(propertyID)
+	if err != nil {
+		return nil, err
+	}
+
+	ticket := upload.Ticket{
+		PropertyID:  p.ID,
+		PublicID:    p.ID + crypto_utils.GetMd5(uuid.New().String()),
+		Folder:      p.ID,
+		Kind:        request.Kind,
+		Mode:        request.Mode,
+		FileName:    request.FileName,
+		Length:      request.Length,
+		Status:      upload.STATUS_PENDING,
+		ExpiresAt:   date_utils.GetDBFormat(date_utils.GetNow().Add(ticketTTL)),
+		DateCreated: date_utils.GetNowDBFromat(),
+	}
+
+	var direct *upload.DirectUpload
+	if ticket.Mode == upload.MODE_DIRECT {
+		if direct, err = s.cloudRepo.SignUpload(ticket.PublicID, ticket.Folder, ticket.Kind); err != nil {
+			return nil, err
+		}
+	}
+
+	created, err := s.ticketRepo.Create(ticket)
+	if err != nil {
+		return nil, err
+	}
+	created.Token = s.token(created)
+	uploadURL := fmt.Sprintf("%s%s%s?token=%s", s.config.PublicURL, uploadPath, created.ID, created.Token)
+	if created.Mode == upload.MODE_RESUMABLE {
+		created.UploadURL = uploadURL
+		return created, nil
+	}
+
+	if direct == nil {
+		// The storage backend can't take uploads itself, so this service does.
+		direct = &upload.DirectUpload{Url: uploadURL, Method: http.MethodPut}
+	}
+	created.Direct = direct
+	return created, nil
+}
+
+func (s *service) GetStatus(ticketID string, token string) (*upload.Ticket, rest_errors.RestErr) {
+	return s.authorize(ticketID, token)
+}
+
+func (s *service) WriteChunk(ticketID string, token string, offset int64, chunk io.Reader) (*upload.Ticket, rest_errors.RestErr) {
+	unlock := s.lock(ticketID)
+	defer unlock()
+
+	ticket, err := s.authorize(ticketID, token)
+	if err != nil {
+		return nil, err
+	}
+	if ticket.Mode != upload.MODE_RESUMABLE {
+		return nil, rest_errors.NewBadRequestErr("ticket is not for a resumable upload")
+	}
+	if ticket.Status != upload.STATUS_PENDING {
+		return nil, rest_errors.NewRestError("upload is already finished", http.StatusConflict, "conflict", nil)
+	}
+	if offset != ticket.Offset {
+		return nil, rest_errors.NewRestError(fmt.Sprintf("upload offset is %d", ticket.Offset), http.StatusConflict, "conflict", nil)
+	}
+
+	written, writeErr := s.writePart(ticket.ID, ticket.Offset, ticket.Length-ticket.Offset, chunk)
+	ticket.Offset += written
+	if writeErr != nil || ticket.Offset < ticket.Length {
+		// Keep what arrived so the client can resume from the new offset.
+		updated, err := s.ticketRepo.Update(ticket.ID, property.EsUpdate{Fields: []property.UpdatePropertyRequest{
+			{Field: "offset", Value: ticket.Offset},
+			{Field: "expires_at", Value: date_utils.GetDBFormat(date_utils.GetNow().Add(ticketTTL))},
+		}})
+		if writeErr != nil {
+			return nil, writeErr
+		}
+		return updated, err
+	}
+	return s.finish(ticket)
+}
+
+func (s *service) WriteDirect(ticketID string, token string, body io.Reader) (*upload.Ticket, rest_errors.RestErr) {
+	unlock := s.lock(ticketID)
+	defer unlock()
+
+	ticket, err := s.authorize(ticketID, token)
+	if err != nil {
+		return nil, err
+	}
+	if ticket.Mode != upload.MODE_DIRECT {
+		return nil, rest_errors.NewBadRequestErr("ticket is not for a direct upload")
+	}
+	if ticket.Status != upload.STATUS_PENDING {
+		return nil, rest_errors.NewRestError("upload is already finished", http.StatusConflict, "conflict", nil)
+	}
+
+	written, err := s.writePart(ticket.ID, 0, upload.MaxUploadSize, body)
+	if err != nil {
+		return nil, err
+	}
+	ticket.Offset = written
+	ticket.Length = written
+	return s.finish(ticket)
+}
+
+func (s *service) Complete(propertyID string, ticketID string, token string, actor audit.Actor) (*property.Property, rest_errors.RestErr) {
+	unlock := s.lock(ticketID)
+	defer unlock()
+
+	ticket, err := s.authorize(ticketID, token)
+	if err != nil {
+		return nil, err
+	}
+	if ticket.PropertyID != propertyID {
+		return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no upload ticket was found with id %s", ticketID))
+	}
+
+	switch ticket.Status {
+	case upload.STATUS_COMPLETED:
+		return s.dbRepo.GetByID(propertyID)
+	case upload.STATUS_REJECTED:
+		return nil, rest_errors.NewBadRequestErr("upload was rejected")
+	case upload.STATUS_PENDING:
+		if ticket.Mode != upload.MODE_DIRECT {
+			return nil, rest_errors.NewBadRequestErr("upload has not finished")
+		}
+		// The client uploaded straight to storage, ask it what arrived.
+		res, err := s.cloudRepo.Find(ticket.PublicID, ticket.Folder, ticket.Kind)
+		if err != nil {
+			if err.Status() == http.StatusNotFound {
+				return nil, rest_errors.NewBadRequestErr("upload has not finished")
+			}
+			return nil, err
+		}
+		if res.Ext != "mp4" && res.Ext != "mov" {
+			s.cloudRepo.Delete(ticket.Folder + "/" + ticket.PublicID)
+			s.setStatus(ticket.ID, upload.STATUS_REJECTED)
+			return nil, rest_errors.NewBadRequestErr(fmt.Sprintf("file type %s is not allowed", res.Ext))
+		}
+		ticket.Url = res.Url
+		ticket.FileType = res.Ext
+	}
+
+	p, err := s.dbRepo.GetByID(propertyID)
+	if err != nil {
+		return nil, err
+	}
+	before := *p
+	if ticket.Kind == property.MEDIA_KIND_VIDEO {
+		p.Videos = append(p.Videos, property.Video{Url: ticket.Url, FileType: ticket.FileType, PublicID: ticket.PublicID})
+	} else {
+		p.Visuals = append(p.Visuals, property.Visual{Url: ticket.Url, FileType: ticket.FileType, PublicID: ticket.PublicID, Hash: ticket.Hash})
+	}
+	if err := s.dbRepo.UploadMedia(p.Visuals, p.Videos, propertyID); err != nil {
+		return nil, err
+	}
+	s.setStatus(ticket.ID, upload.STATUS_COMPLETED)
+	s.recorder.Record(audit.ACTION_UPLOAD_MEDIA, &before, p, actor)
+
+	return p, nil
+}
+
+// finish validates the fully received file and moves it into storage. The
+// part file is kept until the ticket is uploaded or rejected, so a failed
+// save can be resumed by sending the last chunk again.
+func (s *service) finish(ticket *upload.Ticket) (*upload.Ticket, rest_errors.RestErr) {
+	path := s.partPath(ticket.ID)
+
+	open := func() (io.ReadSeekCloser, error) {
+		return os.Open(path)
+	}
+	media, inspectErr := property.InspectMedia(ticket.FileName, ticket.Length, upload.MaxUploadSize, open)
+	if inspectErr == nil && media.Kind != ticket.Kind {
+		inspectErr = fmt.Errorf("file is not a %s", ticket.Kind)
+	}
+	if inspectErr != nil {
+		os.Remove(path)
+		s.setStatus(ticket.ID, upload.STATUS_REJECTED)
+		return nil, rest_errors.NewBadRequestErr(inspectErr.Error())
+	}
+
+	f, openErr := media.Open()
+	if openErr != nil {
+		return nil, rest_errors.NewInternalServerErr("Error while trying to open the file", openErr)
+	}
+	res, err := s.cloudRepo.Save(f, ticket.PublicID, ticket.Folder)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := s.ticketRepo.Update(ticket.ID, property.EsUpdate{Fields: []property.UpdatePropertyRequest{
+		{Field: "offset", Value: ticket.Offset},
+		{Field: "length", Value: ticket.Length},
+		{Field: "url", Value: res.Url},
+		{Field: "file_type", Value: media.Ext},
+		{Field: "hash", Value: media.Hash},
+		{Field: "status", Value: upload.STATUS_UPLOADED},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(path)
+	return updated, nil
+}
+
+// writePart writes at most limit bytes from r to the ticket's part file,
+// starting at offset, and returns how many bytes were written.
+func (s *service) writePart(ticketID string, offset int64, limit int64, r io.Reader) (int64, rest_errors.RestErr) {
+	if err := os.MkdirAll(s.config.TempDir, 0700); err != nil {
+		return 0, rest_errors.NewInternalServerErr("Error while creating upload folder", err)
+	}
+	f, err := os.OpenFile(s.partPath(ticketID), os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, rest_errors.NewInternalServerErr("Error while opening upload", err)
+	}
+	defer f.Close()
+	// Drop anything past offset left behind by an interrupted request.
+	if err := f.Truncate(offset); err != nil {
+		return 0, rest_errors.NewInternalServerErr("Error while opening upload", err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, rest_errors.NewInternalServerErr("Error while opening upload", err)
+	}
+
+	written, err := io.Copy(f, io.LimitReader(r, limit+1))
+	if written > limit {
+		f.Truncate(offset)
+		return 0, rest_errors.NewRestError("upload is larger than announced", http.StatusRequestEntityTooLarge, "too_large", nil)
+	}
+	if err != nil {
+		return written, rest_errors.NewBadRequestErr("upload was interrupted")
+	}
+	return written, nil
+}
+
+func (s *service) authorize(ticketID string, token string) (*upload.Ticket, rest_errors.RestErr) {
+	ticket, err := s.ticketRepo.GetByID(ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if !crypto_utils.VerifyHmacSha256(s.config.SigningSecret, ticket.ID+"|"+ticket.DateCreated, token) {
+		return nil, rest_errors.NewUnauthorizedError("invalid upload token")
+	}
+	expiresAt, parseErr := date_utils.ParseDBFormat(ticket.ExpiresAt)
+	if parseErr != nil || date_utils.GetNow().After(expiresAt) {
+		return nil, rest_errors.NewRestError("upload ticket has expired", http.StatusGone, "gone", nil)
+	}
+	return ticket, nil
+}
+
+// token signs the fields of the ticket that never change. Expiry is checked
+// against the stored ticket, as it moves with every chunk.
+func (s *service) token(ticket *upload.Ticket) string {
+	return crypto_utils.GetHmacSha256(s.config.SigningSecret, ticket.ID+"|"+ticket.DateCreated)
+}
+
+func (s *service) setStatus(ticketID string, status string) {
+	s.ticketRepo.Update(ticketID, property.EsUpdate{Fields: []property.UpdatePropertyRequest{
+		{Field: "status", Value: status},
+	}})
+}
+
+func (s *service) partPath(ticketID string) string {
+	return filepath.Join(s.config.TempDir, filepath.Base(ticketID)+".part")
+}
+
+func (s *service) lock(ticketID string) func() {
+	s.mu.Lock()
+	l, ok := s.locks[ticketID]
+	if !ok {
+		l = &ticketLock{}
+		s.locks[ticketID] = l
+	}
+	l.refs++
+	s.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if l.refs--; l.refs == 0 {
+			delete(s.locks, ticketID)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/audit"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/domain/upload"
+	cloudstorage "github.com/superbkibbles/realestate_property-api/repository/cloudStorage"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+// propertyRepo holds one property; the upload service only reads it and
+// sets its media.
+type propertyRepo struct {
+	db.DbRepository
+	p property.Property
+}
+
+func (r *propertyRepo) GetByID(id string) (*property.Property, rest_errors.RestErr) {
+	p := r.p
+	p.Visuals = append([]property.Visual{}, r.p.Visuals...)
+	return &p, nil
+}
+
+func (r *propertyRepo) UploadMedia(visuals []property.Visual, videos []property.Video, propertyID string) rest_errors.RestErr {
+	r.p.Visuals, r.p.Videos = visuals, videos
+	return nil
+}
+
+// ticketRepo applies updates by json field name, like the partial updates
+// of the elasticsearch repository.
+type ticketRepo map[string]upload.Ticket
+
+func (r ticketRepo) Create(t upload.Ticket) (*upload.Ticket, rest_errors.RestErr) {
+	t.ID = "ticket-1"
+	r[t.ID] = t
+	return &t, nil
+}
+
+func (r ticketRepo) GetByID(id string) (*upload.Ticket, rest_errors.RestErr) {
+	t, ok := r[id]
+	if !ok {
+		return nil, rest_errors.NewNotFoundErr("no upload ticket was found with id " + id)
+	}
+	return &t, nil
+}
+
+func (r ticketRepo) Update(id string, updateRequest property.EsUpdate) (*upload.Ticket, rest_errors.RestErr) {
+	doc := map[string]interface{}{}
+	bytes, _ := json.Marshal(r[id])
+	json.Unmarshal(bytes, &doc)
+	for _, field := range updateRequest.Fields {
+		doc[field.Field] = field.Value
+	}
+	bytes, _ = json.Marshal(doc)
+	var t upload.Ticket
+	json.Unmarshal(bytes, &t)
+	r[id] = t
+	return &t, nil
+}
+
+type recorderFunc func(action string, before *property.Property, after *property.Property, actor audit.Actor)
+
+func (f recorderFunc) Record(action string, before *property.Property, after *property.Property, actor audit.Actor) {
+	f(action, before, after, actor)
+}
+
+func pngImage(t *testing.T) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	for x := 0; x < 32; x++ {
+		for y := 0; y < 32; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 8), G: uint8(y * 8), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func imageTicket(t *testing.T, s Service, length int) *upload.Ticket {
+	ticket, err := s.CreateTicket("property-1", upload.TicketRequest{
+		Mode:     upload.MODE_RESUMABLE,
+		Kind:     property.MEDIA_KIND_IMAGE,
+		FileName: "front.png",
+		Length:   int64(length),
+	})
+	if err != nil {
+		t.Fatal(err.Message())
+	}
+	return ticket
+}
+
+func TestResumableUpload(t *testing.T) {
+	dir := t.TempDir()
+	properties := &propertyRepo{p: property.Property{ID: "property-1"}}
+	tickets := ticketRepo{}
+	var records []string
+	recorder := recorderFunc(func(action string, before *property.Property, after *property.Property, actor audit.Actor) {
+		if len(before.Visuals) != 0 || len(after.Visuals) != 1 || actor.UserID != "user-1" {
+			t.Errorf("expected the added image recorded for user-1, got %+v, %+v, %+v", before.Visuals, after.Visuals, actor)
+		}
+		records = append(records, action)
+	})
+	storage := filepath.Join(dir, "media")
+	s := NewService(properties, tickets, cloudstorage.NewLocalRepository(storage, "http://localhost/media"), recorder, Config{
+		SigningSecret: "secret",
+		TempDir:       filepath.Join(dir, "parts"),
+	})
+
+	data := pngImage(t)
+	ticket := imageTicket(t, s, len(data))
+	half := int64(len(data) / 2)
+
+	// A chunk extends a ticket about to expire.
+	stored := tickets[ticket.ID]
+	stored.ExpiresAt = date_utils.GetDBFormat(date_utils.GetNow().Add(time.Minute))
+	tickets[ticket.ID] = stored
+	partial, err := s.WriteChunk(ticket.ID, ticket.Token, 0, bytes.NewReader(data[:half]))
+	if err != nil {
+		t.Fatal(err.Message())
+	}
+	expiresAt, _ := date_utils.ParseDBFormat(partial.ExpiresAt)
+	if partial.Offset != half || expiresAt.Before(date_utils.GetNow().Add(ticketTTL-time.Minute)) {
+		t.Errorf("expected offset %d and the ticket extended by %s, got %d and %s", half, ticketTTL, partial.Offset, partial.ExpiresAt)
+	}
+	if _, err := s.WriteChunk(ticket.ID, ticket.Token, 0, bytes.NewReader(data)); err == nil || err.Status() != http.StatusConflict {
+		t.Fatalf("expected a conflict for a chunk at the wrong offset, got %v", err)
+	}
+
+	uploaded, err := s.WriteChunk(ticket.ID, ticket.Token, half, bytes.NewReader(data[half:]))
+	if err != nil {
+		t.Fatal(err.Message())
+	}
+	if uploaded.Status != upload.STATUS_UPLOADED || uploaded.FileType != "png" {
+		t.Fatalf("expected an uploaded png, got %+v", uploaded)
+	}
+	if _, statErr := os.Stat(filepath.Join(storage, ticket.Folder, ticket.PublicID+".png")); statErr != nil {
+		t.Errorf("expected the file in storage: %v", statErr)
+	}
+
+	if _, err := s.Complete("property-1", ticket.ID, "forged", audit.Actor{UserID: "user-1"}); err == nil || err.Status() != http.StatusUnauthorized {
+		t.Errorf("expected completion with an invalid token refused, got %v", err)
+	}
+	p, err := s.Complete("property-1", ticket.ID, ticket.Token, audit.Actor{UserID: "user-1"})
+	if err != nil {
+		t.Fatal(err.Message())
+	}
+	if len(p.Visuals) != 1 || p.Visuals[0].PublicID != ticket.PublicID || len(records) != 1 || records[0] != audit.ACTION_UPLOAD_MEDIA {
+		t.Fatalf("expected the image added and recorded once, got %+v and %v", p.Visuals, records)
+	}
+
+	// Completing again returns the property without adding the image twice.
+	if p, err := s.Complete("property-1", ticket.ID, ticket.Token, audit.Actor{UserID: "user-1"}); err != nil || len(p.Visuals) != 1 || len(records) != 1 {
+		t.Errorf("expected a completed upload left alone, got %v", err)
+	}
+}
+
+func TestResumeAfterFailedSave(t *testing.T) {
+	dir := t.TempDir()
+	tickets := ticketRepo{}
+	// Storage fails while a file sits where its folder belongs.
+	storage := filepath.Join(dir, "media")
+	if err := os.WriteFile(storage, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	s := NewService(&propertyRepo{p: property.Property{ID: "property-1"}}, tickets, cloudstorage.NewLocalRepository(storage, "http://localhost/media"), nil, Config{
+		SigningSecret: "secret",
+		TempDir:       filepath.Join(dir, "parts"),
+	})
+
+	data := pngImage(t)
+	ticket := imageTicket(t, s, len(data))
+	half := int64(len(data) / 2)
+	if _, err := s.WriteChunk(ticket.ID, ticket.Token, 0, bytes.NewReader(data[:half])); err != nil {
+		t.Fatal(err.Message())
+	}
+	if _, err := s.WriteChunk(ticket.ID, ticket.Token, half, bytes.NewReader(data[half:])); err == nil {
+		t.Fatal("expected the failed save reported")
+	}
+	if got := tickets[ticket.ID]; got.Status != upload.STATUS_PENDING || got.Offset != half {
+		t.Fatalf("expected the ticket pending at offset %d, got %s at %d", half, got.Status, got.Offset)
+	}
+
+	os.Remove(storage)
+	uploaded, err := s.WriteChunk(ticket.ID, ticket.Token, half, bytes.NewReader(data[half:]))
+	if err != nil {
+		t.Fatal(err.Message())
+	}
+	if uploaded.Status != upload.STATUS_UPLOADED || uploaded.Hash == "" {
+		t.Errorf("expected the resumed upload stored as a valid image, got %+v", uploaded)
+	}
+}
+
+func TestWriteChunkRefusals(t *testing.T) {
+	data := pngImage(t)
+	tests := []struct {
+		name    string
+		token   func(ticket *upload.Ticket) string
+		body    []byte
+		expires time.Duration
+		status  int
+	}{
+		{name: "invalid token", token: func(*upload.Ticket) string { return "forged" }, body: data, expires: time.Hour, status: http.StatusUnauthorized},
+		{name: "expired ticket", body: data, expires: -time.Second, status: http.StatusGone},
+		{name: "larger than announced", body: append(append([]byte{}, data...), 0), expires: time.Hour, status: http.StatusRequestEntityTooLarge},
+		{name: "not an image", body: bytes.Repeat([]byte("plain text "), len(data)/11+1)[:len(data)], expires: time.Hour, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tickets := ticketRepo{}
+			s := NewService(&propertyRepo{p: property.Property{ID: "property-1"}}, tickets, nil, nil, Config{
+				SigningSecret: "secret",
+				TempDir:       t.TempDir(),
+			})
+			ticket := imageTicket(t, s, len(data))
+			stored := tickets[ticket.ID]
+			stored.ExpiresAt = date_utils.GetDBFormat(date_utils.GetNow().Add(tt.expires))
+			tickets[ticket.ID] = stored
+			token := ticket.Token
+			if tt.token != nil {
+				token = tt.token(ticket)
+			}
+
+			if _, err := s.WriteChunk(ticket.ID, token, 0, bytes.NewReader(tt.body)); err == nil || err.Status() != tt.status {
+				t.Errorf("expected %d, got %v", tt.status, err)
+			}
+		})
+	}
+}
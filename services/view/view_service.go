@@ -0,0 +1,94 @@
+package view
+
+import (
+	"fmt"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/view"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/utils/crypto_utils"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+type Service interface {
+	Record(propertyID string, visitorID string) (bool, rest_errors.RestErr)
+	GetPropertyStats(propertyID string, request view.StatsRequest) (*view.Stats, rest_errors.RestErr)
+	GetAgencyStats(agencyID string, request view.StatsRequest) (*view.Stats, rest_errors.RestErr)
+}
+
+type service struct {
+	dbRepo   db.DbRepository
+	viewRepo db.ViewRepository
+}
+
+func NewService(dbRepo db.DbRepository, viewRepo db.ViewRepository) Service {
+	return &service{
+		dbRepo:   dbRepo,
+		viewRepo: viewRepo,
+	}
+}
+
+// Record counts a view unless the same visitor already viewed the property
+// within the current dedup window. It reports whether the view was counted.
+func (s *service) Record(propertyID string, visitorID string) (bool, rest_errors.RestErr) {
+	if visitorID == "" {
+		return false, rest_errors.NewBadRequestErr("invalid visitor")
+	}
+	p, err := s.dbRepo.GetByID(propertyID)
+	if err != nil {
+		return false, err
+	}
+
+	now := date_utils.GetNow()
+	window := now.Unix() / int64(view.DedupWindow.Seconds())
+	id := crypto_utils.GetMd5(fmt.Sprintf("%s|%s|%d", p.ID, visitorID, window))
+	recorded, err := s.viewRepo.Record(id, view.View{
+		PropertyID: p.ID,
+		AgencyID:   p.AgencyID,
+		VisitorID:  visitorID,
+		Timestamp:  now,
+	})
+	if err != nil || !recorded {
+		return false, err
+	}
+
+	if err := s.dbRepo.IncrementViewers(p.ID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *service) GetPropertyStats(propertyID string, request view.StatsRequest) (*view.Stats, rest_errors.RestErr) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := s.dbRepo.GetByID(propertyID); err != nil {
+		return nil, err
+	}
+	return s.stats("property_id", propertyID, request)
+}
+
+func (s *service) GetAgencyStats(agencyID string, request view.StatsRequest) (*view.Stats, rest_errors.RestErr) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	return s.stats("agency_id", agencyID, request)
+}
+
+func (s *service) stats(field string, id string, request view.StatsRequest) (*view.Stats, rest_errors.RestErr) {
+	days, err := s.viewRepo.DailyViews(field, id, request.FromTime, request.ToTime)
+	if err != nil {
+		return nil, err
+	}
+	stats := view.Stats{
+		ID:   id,
+		From: request.From,
+		To:   request.To,
+		Days: []view.DailyViews{},
+	}
+	for _, day := range days {
+		stats.Total += day.Views
+		stats.Days = append(stats.Days, day)
+	}
+	return &stats, nil
+}
@@ -0,0 +1,358 @@
+package viewing
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/property"
+	"github.com/superbkibbles/realestate_property-api/domain/viewing"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/utils/calendar_utils"
+	"github.com/superbkibbles/realestate_property-api/utils/crypto_utils"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+const (
+	feedPath = "/api/viewing/feed/%s/calendar.ics?token=%s"
+	// The feed keeps recent viewings so agents still see what they just did.
+	feedHistory = 30 * 24 * time.Hour
+)
+
+type Service interface {
+	PublishSlots(propertyID string, agencyID string, agentID string, request viewing.SlotsRequest) (viewing.Slots, rest_errors.RestErr)
+	GetSlots(propertyID string, agencyID string) (viewing.Slots, rest_errors.RestErr)
+	WithdrawSlot(propertyID string, slotID string, agencyID string) rest_errors.RestErr
+	Book(propertyID string, slotID string, userID string, request viewing.BookingRequest) (*viewing.Booking, rest_errors.RestErr)
+	GetBookings(userID string) (viewing.Bookings, rest_errors.RestErr)
+	GetBooking(id string, userID string, agencyID string) (*viewing.Booking, rest_errors.RestErr)
+	Cancel(id string, userID string, agencyID string) (*viewing.Booking, rest_errors.RestErr)
+	Calendar(id string, userID string, agencyID string) ([]byte, rest_errors.RestErr)
+	FeedLink(agentID string) (*viewing.FeedLink, rest_errors.RestErr)
+	Feed(agentID string, token string) ([]byte, rest_errors.RestErr)
+}
+
+type Config struct {
+	// SigningSecret signs the agents' calendar feed links.
+	SigningSecret string
+	PublicURL     string
+}
+
+type service struct {
+	viewingRepo db.ViewingRepository
+	dbRepo      db.DbRepository
+	config      Config
+}
+
+func NewService(viewingRepo db.ViewingRepository, dbRepo db.DbRepository, config Config) Service {
+	return &service{
+		viewingRepo: viewingRepo,
+		dbRepo:      dbRepo,
+		config:      config,
+	}
+}
+
+// PublishSlots adds the calling agent's availability to a listing of their
+// agency. Slots may not overlap the agent's other slots on any listing.
+func (s *service) PublishSlots(propertyID string, agencyID string, agentID string, request viewing.SlotsRequest) (viewing.Slots, rest_errors.RestErr) {
+	if agentID == "" {
+		return nil, rest_errors.NewUnauthorizedError("only signed in agents can publish viewing slots")
+	}
+	p, err := s.activeProperty(propertyID)
+	if err != nil {
+		return nil, err
+	}
+	if agencyID == "" || agencyID != p.AgencyID {
+		return nil, rest_errors.NewRestError("viewing slots can only be published by the agency of the listing", http.StatusForbidden, "forbidden", nil)
+	}
+	if err := request.Validate(date_utils.GetNow()); err != nil {
+		return nil, err
+	}
+	// Slots published before agents had a calendar are only found by
+	// searching.
+	for _, slot := range request.Slots {
+		overlaps, err := s.viewingRepo.HasOverlap(agentID, slot.Start, slot.End)
+		if err != nil {
+			return nil, err
+		}
+		if overlaps {
+			return nil, rest_errors.NewRestError(fmt.Sprintf("the agent already has a slot between %s and %s", slot.Start, slot.End), http.StatusConflict, "conflict", nil)
+		}
+	}
+
+	slots := make(viewing.Slots, 0, len(request.Slots))
+	for _, slot := range request.Slots {
+		slots = append(slots, viewing.Slot{
+			ID:          uuid.New().String(),
+			PropertyID:  p.ID,
+			AgencyID:    p.AgencyID,
+			AgentID:     agentID,
+			Start:       slot.Start,
+			End:         slot.End,
+			Status:      viewing.SLOT_OPEN,
+			DateCreated: date_utils.GetNowISO(),
+		})
+	}
+	reserved, err := s.viewingRepo.ReserveTimes(agentID, slots, date_utils.GetNowISO())
+	if err != nil {
+		return nil, err
+	}
+	if !reserved {
+		return nil, rest_errors.NewRestError("the agent already has a slot at one of these times", http.StatusConflict, "conflict", nil)
+	}
+	for i, slot := range slots {
+		if _, err := s.viewingRepo.CreateSlot(slot); err != nil {
+			for _, unsaved := range slots[i:] {
+				s.viewingRepo.FreeTime(agentID, unsaved.ID)
+			}
+			return nil, err
+		}
+	}
+	return slots, nil
+}
+
+// GetSlots returns the upcoming open slots of a listing. The listing's
+// agency also sees booked and withdrawn ones.
+func (s *service) GetSlots(propertyID string, agencyID string) (viewing.Slots, rest_errors.RestErr) {
+	p, err := s.dbRepo.GetByID(propertyID)
+	if err != nil {
+		return nil, err
+	}
+	if agencyID != "" && agencyID == p.AgencyID {
+		return s.viewingRepo.GetSlots(p.ID, date_utils.GetNowISO(), "")
+	}
+	if p.Status != property.STATUS_ACTIVE || p.IsSold {
+		return viewing.Slots{}, nil
+	}
+	return s.viewingRepo.GetSlots(p.ID, date_utils.GetNowISO(), viewing.SLOT_OPEN)
+}
+
+func (s *service) WithdrawSlot(propertyID string, slotID string, agencyID string) rest_errors.RestErr {
+	slot, err := s.slot(propertyID, slotID)
+	if err != nil {
+		return err
+	}
+	if agencyID == "" || agencyID != slot.AgencyID {
+		return rest_errors.NewRestError("viewing slots can only be withdrawn by the agency of the listing", http.StatusForbidden, "forbidden", nil)
+	}
+	withdrawn, err := s.viewingRepo.WithdrawSlot(slot.ID)
+	if err != nil {
+		return err
+	}
+	if !withdrawn && slot.Status != viewing.SLOT_WITHDRAWN {
+		return rest_errors.NewRestError("this slot is booked, cancel the viewing first", http.StatusConflict, "conflict", nil)
+	}
+	return s.viewingRepo.FreeTime(slot.AgentID, slot.ID)
+}
+
+// Book reserves an open slot. The slot is claimed before the booking is
+// stored, so a second buyer racing for it gets a conflict.
+func (s *service) Book(propertyID string, slotID string, userID string, request viewing.BookingRequest) (*viewing.Booking, rest_errors.RestErr) {
+	if userID == "" {
+		return nil, rest_errors.NewUnauthorizedError("only signed in users can book viewings")
+	}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	p, err := s.activeProperty(propertyID)
+	if err != nil {
+		return nil, err
+	}
+	slot, err := s.slot(propertyID, slotID)
+	if err != nil {
+		return nil, err
+	}
+	if start, _ := slot.Times(); !start.After(date_utils.GetNow()) || slot.Status != viewing.SLOT_OPEN {
+		return nil, rest_errors.NewRestError("this slot is no longer available", http.StatusConflict, "conflict", nil)
+	}
+
+	bookingID := uuid.New().String()
+	booked, err := s.viewingRepo.BookSlot(slot.ID, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if !booked {
+		return nil, rest_errors.NewRestError("this slot is no longer available", http.StatusConflict, "conflict", nil)
+	}
+
+	booking, err := s.viewingRepo.CreateBooking(viewing.Booking{
+		ID:            bookingID,
+		SlotID:        slot.ID,
+		PropertyID:    p.ID,
+		PropertyTitle: p.Title,
+		Address:       address(*p),
+		AgencyID:      slot.AgencyID,
+		AgentID:       slot.AgentID,
+		UserID:        userID,
+		Name:          request.Name,
+		Phone:         request.Phone,
+		Email:         request.Email,
+		Note:          request.Note,
+		Start:         slot.Start,
+		End:           slot.End,
+		Status:        viewing.BOOKING_CONFIRMED,
+		DateCreated:   date_utils.GetNowISO(),
+	})
+	if err != nil {
+		s.viewingRepo.ReleaseSlot(slot.ID, bookingID)
+		return nil, err
+	}
+	return booking, nil
+}
+
+func (s *service) GetBookings(userID string) (viewing.Bookings, rest_errors.RestErr) {
+	if userID == "" {
+		return nil, rest_errors.NewUnauthorizedError("only signed in users have viewings")
+	}
+	return s.viewingRepo.GetBookingsByUser(userID)
+}
+
+// GetBooking returns a booking to the buyer who made it or to the agency of
+// the listing.
+func (s *service) GetBooking(id string, userID string, agencyID string) (*viewing.Booking, rest_errors.RestErr) {
+	booking, err := s.viewingRepo.GetBooking(id)
+	if err != nil {
+		return nil, err
+	}
+	if (userID == "" || userID != booking.UserID) && (agencyID == "" || agencyID != booking.AgencyID) {
+		return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no viewing was found with id %s", id))
+	}
+	return booking, nil
+}
+
+// Cancel frees the slot for other buyers. Cancelling twice is not an error.
+func (s *service) Cancel(id string, userID string, agencyID string) (*viewing.Booking, rest_errors.RestErr) {
+	booking, err := s.GetBooking(id, userID, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	cancelledBy := userID
+	if booking.UserID != userID {
+		cancelledBy = agencyID
+	}
+	cancelled, changed, err := s.viewingRepo.CancelBooking(booking.ID, cancelledBy, date_utils.GetNowISO())
+	if err != nil {
+		return nil, err
+	}
+	if changed {
+		if err := s.viewingRepo.ReleaseSlot(booking.SlotID, booking.ID); err != nil {
+			return nil, err
+		}
+	}
+	return cancelled, nil
+}
+
+// Calendar returns a booking as an .ics file.
+func (s *service) Calendar(id string, userID string, agencyID string) ([]byte, rest_errors.RestErr) {
+	booking, err := s.GetBooking(id, userID, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	return calendar_utils.Calendar("", event(*booking)), nil
+}
+
+// FeedLink returns the agent's calendar subscription address. It does not
+// expire, calendar apps keep polling the same address.
+func (s *service) FeedLink(agentID string) (*viewing.FeedLink, rest_errors.RestErr) {
+	if agentID == "" {
+		return nil, rest_errors.NewUnauthorizedError("only signed in agents have a calendar feed")
+	}
+	return &viewing.FeedLink{
+		Url: fmt.Sprintf("%s"+feedPath, s.config.PublicURL, url.PathEscape(agentID), s.feedToken(agentID)),
+	}, nil
+}
+
+func (s *service) Feed(agentID string, token string) ([]byte, rest_errors.RestErr) {
+	if !crypto_utils.VerifyHmacSha256(s.config.SigningSecret, "viewing-feed|"+agentID, token) {
+		return nil, rest_errors.NewUnauthorizedError("invalid calendar feed link")
+	}
+	bookings, err := s.viewingRepo.GetBookingsByAgent(agentID, date_utils.GetNow().Add(-feedHistory).Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	events := make([]calendar_utils.Event, 0, len(bookings))
+	for _, booking := range bookings {
+		events = append(events, event(booking))
+	}
+	return calendar_utils.Calendar("Viewings", events...), nil
+}
+
+func (s *service) feedToken(agentID string) string {
+	return crypto_utils.GetHmacSha256(s.config.SigningSecret, "viewing-feed|"+agentID)
+}
+
+func (s *service) activeProperty(propertyID string) (*property.Property, rest_errors.RestErr) {
+	p, err := s.dbRepo.GetByID(propertyID)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status != property.STATUS_ACTIVE || p.IsSold {
+		return nil, rest_errors.NewBadRequestErr("viewings are only available for active listings")
+	}
+	return p, nil
+}
+
+func (s *service) slot(propertyID string, slotID string) (*viewing.Slot, rest_errors.RestErr) {
+	slot, err := s.viewingRepo.GetSlot(slotID)
+	if err != nil {
+		return nil, err
+	}
+	if slot.PropertyID != propertyID {
+		return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no viewing slot was found with id %s", slotID))
+	}
+	return slot, nil
+}
+
+func event(b viewing.Booking) calendar_utils.Event {
+	start, end := b.Times()
+	stamp, stampErr := time.Parse(time.RFC3339, b.DateCreated)
+	e := calendar_utils.Event{
+		UID:         b.ID + "@viewing",
+		Start:       start,
+		End:         end,
+		Summary:     "Viewing: " + b.PropertyTitle,
+		Description: description(b),
+		Location:    b.Address,
+		Status:      calendar_utils.STATUS_CONFIRMED,
+		Stamp:       stamp,
+	}
+	if stampErr != nil {
+		e.Stamp = date_utils.GetNow()
+	}
+	if b.Status == viewing.BOOKING_CANCELLED {
+		e.Status = calendar_utils.STATUS_CANCELLED
+		e.Sequence = 1
+		if cancelledAt, err := time.Parse(time.RFC3339, b.DateCancelled); err == nil {
+			e.Stamp = cancelledAt
+		}
+	}
+	return e
+}
+
+func description(b viewing.Booking) string {
+	lines := []string{"Viewing for " + b.Name}
+	if b.Phone != "" {
+		lines = append(lines, "Phone: "+b.Phone)
+	}
+	if b.Email != "" {
+		lines = append(lines, "Email: "+b.Email)
+	}
+	if b.Note != "" {
+		lines = append(lines, b.Note)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func address(p property.Property) string {
+	parts := make([]string, 0, 3)
+	for _, part := range []string{p.Location, p.City, p.Country} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
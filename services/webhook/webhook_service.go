@@ -0,0 +1,487 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/superbkibbles/bookstore_utils-go/logger"
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/clients/eventsink"
+	"github.com/superbkibbles/realestate_property-api/domain/event"
+	"github.com/superbkibbles/realestate_property-api/domain/webhook"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+	"github.com/superbkibbles/realestate_property-api/utils/net_utils"
+)
+
+const (
+	defaultMaxAttempts = 8
+	defaultTimeout     = 10 * time.Second
+	defaultRetention   = 30 * 24 * time.Hour
+	defaultBatchSize   = 500
+	defaultWorkers     = 8
+)
+
+type Service interface {
+	Create(agencyID string, request webhook.Request) (*webhook.Subscription, rest_errors.RestErr)
+	Get(agencyID string) (webhook.Subscriptions, rest_errors.RestErr)
+	GetByID(agencyID string, id string) (*webhook.Subscription, rest_errors.RestErr)
+	Update(agencyID string, id string, request webhook.Request) (*webhook.Subscription, rest_errors.RestErr)
+	Delete(agencyID string, id string) rest_errors.RestErr
+	Test(agencyID string, id string) (*webhook.Delivery, rest_errors.RestErr)
+
+	GetDeliveries(agencyID string, id string, status string) (webhook.Deliveries, rest_errors.RestErr)
+	GetDeadLetters(agencyID string) (webhook.Deliveries, rest_errors.RestErr)
+	Replay(agencyID string, id string, deliveryID string) rest_errors.RestErr
+	ReplayAll(agencyID string, id string) (int64, rest_errors.RestErr)
+
+	// Sink hands events from the outbox to the matching subscriptions.
+	Sink() eventsink.Sink
+	Deliver() (*webhook.DeliveryReport, rest_errors.RestErr)
+	Purge() (int64, rest_errors.RestErr)
+}
+
+type Config struct {
+	// MaxAttempts is how often a delivery is tried before it is dead.
+	MaxAttempts int
+	// Timeout is how long a subscriber may take to respond.
+	Timeout time.Duration
+	// Retention is how long successful deliveries are kept in the log.
+	Retention time.Duration
+	// BatchSize is how many due deliveries one run sends at most.
+	BatchSize int
+	// Workers is how many subscriptions are sent to at once.
+	Workers int
+}
+
+type service struct {
+	subscriptionRepo db.SubscriptionRepository
+	deliveryRepo     db.WebhookDeliveryRepository
+	client           *http.Client
+	config           Config
+}
+
+func NewService(subscriptionRepo db.SubscriptionRepository, deliveryRepo db.WebhookDeliveryRepository, config Config) Service {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultMaxAttempts
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.Retention <= 0 {
+		config.Retention = defaultRetention
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.Workers <= 0 {
+		config.Workers = defaultWorkers
+	}
+	return &service{
+		subscriptionRepo: subscriptionRepo,
+		deliveryRepo:     deliveryRepo,
+		client:           net_utils.NewPublicClient(config.Timeout),
+		config:           config,
+	}
+}
+
+// Create returns the secret this once, generating one if none was given.
+func (s *service) Create(agencyID string, request webhook.Request) (*webhook.Subscription, rest_errors.RestErr) {
+	if agencyID == "" {
+		return nil, rest_errors.NewUnauthorizedError("agency id is required")
+	}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	if request.Secret == "" {
+		secret, err := newSecret()
+		if err != nil {
+			return nil, rest_errors.NewInternalServerErr("error when trying to generate webhook secret", err)
+		}
+		request.Secret = secret
+	}
+	return s.subscriptionRepo.Create(webhook.Subscription{
+		AgencyID:    agencyID,
+		URL:         request.URL,
+		Secret:      request.Secret,
+		EventTypes:  request.EventTypes,
+		Active:      request.Active == nil || *request.Active,
+		DateCreated: date_utils.GetNowISO(),
+	})
+}
+
+func (s *service) Get(agencyID string) (webhook.Subscriptions, rest_errors.RestErr) {
+	if agencyID == "" {
+		return nil, rest_errors.NewUnauthorizedError("agency id is required")
+	}
+	subscriptions, err := s.subscriptionRepo.GetByAgency(agencyID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+	return subscriptions, nil
+}
+
+func (s *service) GetByID(agencyID string, id string) (*webhook.Subscription, rest_errors.RestErr) {
+	sub, err := s.subscription(agencyID, id)
+	if err != nil {
+		return nil, err
+	}
+	sub.Secret = ""
+	return sub, nil
+}
+
+// Update replaces the subscription, keeping the secret unless a new one is
+// given.
+func (s *service) Update(agencyID string, id string, request webhook.Request) (*webhook.Subscription, rest_errors.RestErr) {
+	sub, err := s.subscription(agencyID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	sub.URL = request.URL
+	sub.EventTypes = request.EventTypes
+	sub.Active = request.Active == nil || *request.Active
+	if request.Secret != "" {
+		sub.Secret = request.Secret
+	}
+	sub.DateUpdated = date_utils.GetNowISO()
+	updated, err := s.subscriptionRepo.Update(id, *sub)
+	if err != nil {
+		return nil, err
+	}
+	updated.Secret = ""
+	return updated, nil
+}
+
+// Delete also drops the deliveries of the subscription, pending or not.
+func (s *service) Delete(agencyID string, id string) rest_errors.RestErr {
+	if _, err := s.subscription(agencyID, id); err != nil {
+		return err
+	}
+	if err := s.subscriptionRepo.Delete(id); err != nil {
+		return err
+	}
+	if err := s.deliveryRepo.DeleteBySubscription(id); err != nil {
+		logger.Error(fmt.Sprintf("error while deleting deliveries of webhook subscription %s", id), errors.New(err.Message()))
+	}
+	return nil
+}
+
+// Test sends a ping right away, so a subscriber can check its endpoint and
+// signature. A failed ping is not retried.
+func (s *service) Test(agencyID string, id string) (*webhook.Delivery, rest_errors.RestErr) {
+	sub, err := s.subscription(agencyID, id)
+	if err != nil {
+		return nil, err
+	}
+	e := event.Event{
+		ID:         uuid.New().String(),
+		Type:       webhook.TYPE_PING,
+		AgencyID:   sub.AgencyID,
+		OccurredAt: date_utils.GetNow().Format(event.TimeLayout),
+	}
+	d, err := s.newDelivery(*sub, e)
+	if err != nil {
+		return nil, err
+	}
+	// Locked, so the scheduler does not send it as well.
+	d.Status = webhook.STATUS_DELIVERING
+	d.LockedUntil = date_utils.GetNow().Add(2 * s.config.Timeout).Format(event.TimeLayout)
+	if _, err := s.deliveryRepo.Create(*d); err != nil {
+		return nil, err
+	}
+
+	attempt := s.post(*sub, *d)
+	d.Attempts = 1
+	d.Status = webhook.STATUS_DELIVERED
+	if attempt.Error != "" {
+		d.Status, d.LastError = webhook.STATUS_DEAD, attempt.Error
+	} else {
+		d.DeliveredAt = attempt.At
+	}
+	d.LockedUntil = ""
+	d.Log = []webhook.Attempt{attempt}
+	if err := s.deliveryRepo.Record(d.ID, d.Status, d.Attempts, d.NextAttemptAt, attempt); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (s *service) GetDeliveries(agencyID string, id string, status string) (webhook.Deliveries, rest_errors.RestErr) {
+	if _, err := s.subscription(agencyID, id); err != nil {
+		return nil, err
+	}
+	status = strings.ToLower(strings.TrimSpace(status))
+	switch status {
+	case "", webhook.STATUS_PENDING, webhook.STATUS_DELIVERING, webhook.STATUS_DELIVERED, webhook.STATUS_DEAD:
+	default:
+		return nil, rest_errors.NewBadRequestErr("status must be pending, delivering, delivered or dead")
+	}
+	return s.deliveryRepo.GetBySubscription(id, status)
+}
+
+func (s *service) GetDeadLetters(agencyID string) (webhook.Deliveries, rest_errors.RestErr) {
+	if agencyID == "" {
+		return nil, rest_errors.NewUnauthorizedError("agency id is required")
+	}
+	return s.deliveryRepo.GetDead(agencyID)
+}
+
+// Replay sends a dead delivery again on the next run, with the same body
+// and a fresh set of attempts.
+func (s *service) Replay(agencyID string, id string, deliveryID string) rest_errors.RestErr {
+	if _, err := s.subscription(agencyID, id); err != nil {
+		return err
+	}
+	d, err := s.deliveryRepo.GetByID(deliveryID)
+	if err != nil {
+		return err
+	}
+	if d.SubscriptionID != id {
+		return rest_errors.NewNotFoundErr(fmt.Sprintf("no webhook delivery was found with id %s", deliveryID))
+	}
+	replayed, err := s.deliveryRepo.Replay(deliveryID, date_utils.GetNow().Format(event.TimeLayout))
+	if err != nil {
+		return err
+	}
+	if !replayed {
+		return rest_errors.NewRestError("only dead deliveries can be replayed", http.StatusConflict, "conflict", nil)
+	}
+	return nil
+}
+
+// ReplayAll replays every dead delivery of the subscription.
+func (s *service) ReplayAll(agencyID string, id string) (int64, rest_errors.RestErr) {
+	if _, err := s.subscription(agencyID, id); err != nil {
+		return 0, err
+	}
+	return s.deliveryRepo.ReplayDead(id, date_utils.GetNow().Format(event.TimeLayout))
+}
+
+func (s *service) Sink() eventsink.Sink {
+	return &subscriptionSink{service: s}
+}
+
+// subscriptionSink queues a delivery per matching subscription. The ids of
+// deliveries come from the event, so an event the outbox sends again is
+// not queued twice.
+type subscriptionSink struct {
+	service *service
+}
+
+func (sink *subscriptionSink) Name() string {
+	return "subscriptions"
+}
+
+func (sink *subscriptionSink) Send(e event.Event) error {
+	subscriptions, err := sink.service.subscriptionRepo.GetByAgency(e.AgencyID)
+	if err != nil {
+		return errors.New(err.Message())
+	}
+	for _, sub := range subscriptions {
+		if !sub.Matches(e) {
+			continue
+		}
+		d, err := sink.service.newDelivery(sub, e)
+		if err != nil {
+			return errors.New(err.Message())
+		}
+		if _, err := sink.service.deliveryRepo.Create(*d); err != nil {
+			return errors.New(err.Message())
+		}
+	}
+	return nil
+}
+
+// Deliver sends up to BatchSize due deliveries, backing off exponentially
+// after every failure until MaxAttempts, when the delivery is dead. Each
+// subscription gets its deliveries in order, while up to Workers
+// subscriptions are sent to at once. Deliveries of paused subscriptions wait
+// until they are resumed.
+func (s *service) Deliver() (*webhook.DeliveryReport, rest_errors.RestErr) {
+	paused, err := s.subscriptionRepo.GetPaused()
+	if err != nil {
+		return nil, err
+	}
+	pausedIDs := make([]string, 0, len(paused))
+	for _, sub := range paused {
+		pausedIDs = append(pausedIDs, sub.ID)
+	}
+	due, err := s.deliveryRepo.GetDue(date_utils.GetNow().Format(event.TimeLayout), pausedIDs, s.config.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	bySubscription := map[string]webhook.Deliveries{}
+	order := []string{}
+	for _, d := range due {
+		if _, ok := bySubscription[d.SubscriptionID]; !ok {
+			order = append(order, d.SubscriptionID)
+		}
+		bySubscription[d.SubscriptionID] = append(bySubscription[d.SubscriptionID], d)
+	}
+
+	report := webhook.DeliveryReport{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, s.config.Workers)
+	for _, id := range order {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(id string, deliveries webhook.Deliveries) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			r := s.deliverTo(id, deliveries)
+			mu.Lock()
+			report.Delivered += r.Delivered
+			report.Retried += r.Retried
+			report.Dead += r.Dead
+			report.Failed += r.Failed
+			mu.Unlock()
+		}(id, bySubscription[id])
+	}
+	wg.Wait()
+	return &report, nil
+}
+
+// deliverTo sends the deliveries of one subscription one after the other.
+// A delivery that cannot be claimed or recorded is logged and left for the
+// next run.
+func (s *service) deliverTo(subscriptionID string, deliveries webhook.Deliveries) webhook.DeliveryReport {
+	report := webhook.DeliveryReport{}
+	sub, err := s.subscriptionRepo.GetByID(subscriptionID)
+	if err != nil && err.Status() != http.StatusNotFound {
+		logger.Error(fmt.Sprintf("error while getting webhook subscription %s", subscriptionID), errors.New(err.Message()))
+		report.Failed += len(deliveries)
+		return report
+	}
+	if sub != nil && !sub.Active {
+		// Paused since the deliveries were picked, they wait like the others.
+		return report
+	}
+
+	for _, d := range deliveries {
+		now := date_utils.GetNow()
+		// Each request may take up to the timeout, so the lock covers the
+		// slowest subscriber with room to spare.
+		claimed, err := s.deliveryRepo.Claim(d.ID, now.Format(event.TimeLayout), now.Add(2*s.config.Timeout).Format(event.TimeLayout))
+		if err != nil {
+			logger.Error(fmt.Sprintf("error while claiming webhook delivery %s", d.ID), errors.New(err.Message()))
+			report.Failed++
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		var attempt webhook.Attempt
+		if sub == nil {
+			attempt = webhook.Attempt{At: date_utils.GetNow().Format(event.TimeLayout), Error: "the subscription was deleted"}
+		} else {
+			attempt = s.post(*sub, d)
+		}
+		attempts := d.Attempts + 1
+		status, next := webhook.STATUS_DELIVERED, d.NextAttemptAt
+		switch {
+		case attempt.Error == "":
+		case sub == nil || attempts >= s.config.MaxAttempts:
+			status = webhook.STATUS_DEAD
+		default:
+			status = webhook.STATUS_PENDING
+			next = date_utils.GetNow().Add(event.RetryDelay(attempts)).Format(event.TimeLayout)
+		}
+		if err := s.deliveryRepo.Record(d.ID, status, attempts, next, attempt); err != nil {
+			logger.Error(fmt.Sprintf("error while recording webhook delivery %s", d.ID), errors.New(err.Message()))
+			report.Failed++
+			continue
+		}
+		switch status {
+		case webhook.STATUS_DELIVERED:
+			report.Delivered++
+		case webhook.STATUS_DEAD:
+			report.Dead++
+		default:
+			report.Retried++
+		}
+	}
+	return report
+}
+
+// Purge deletes successful deliveries older than the retention.
+func (s *service) Purge() (int64, rest_errors.RestErr) {
+	return s.deliveryRepo.Purge(date_utils.GetNow().Add(-s.config.Retention).Format(event.TimeLayout))
+}
+
+func (s *service) newDelivery(sub webhook.Subscription, e event.Event) (*webhook.Delivery, rest_errors.RestErr) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return nil, rest_errors.NewInternalServerErr("error when trying to encode event", err)
+	}
+	now := date_utils.GetNow().Format(event.TimeLayout)
+	return &webhook.Delivery{
+		ID:             webhook.DeliveryID(sub.ID, e.ID),
+		SubscriptionID: sub.ID,
+		AgencyID:       sub.AgencyID,
+		EventID:        e.ID,
+		EventType:      e.Type,
+		Payload:        string(payload),
+		Status:         webhook.STATUS_PENDING,
+		NextAttemptAt:  now,
+		DateCreated:    now,
+		Log:            []webhook.Attempt{},
+	}, nil
+}
+
+// post sends the delivery once and returns what happened for the log.
+func (s *service) post(sub webhook.Subscription, d webhook.Delivery) webhook.Attempt {
+	start := date_utils.GetNow()
+	e := event.Event{ID: d.EventID, Type: d.EventType}
+	code, err := eventsink.Post(s.client, sub.URL, sub.Secret, e, []byte(d.Payload))
+	attempt := webhook.Attempt{
+		At:         start.Format(event.TimeLayout),
+		StatusCode: code,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	return attempt
+}
+
+// subscription returns the subscription if agencyID owns it.
+func (s *service) subscription(agencyID string, id string) (*webhook.Subscription, rest_errors.RestErr) {
+	if agencyID == "" {
+		return nil, rest_errors.NewUnauthorizedError("agency id is required")
+	}
+	sub, err := s.subscriptionRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if sub.AgencyID != agencyID {
+		return nil, rest_errors.NewNotFoundErr(fmt.Sprintf("no webhook subscription was found with id %s", id))
+	}
+	return sub, nil
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
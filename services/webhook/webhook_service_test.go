@@ -0,0 +1,322 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/superbkibbles/bookstore_utils-go/rest_errors"
+	"github.com/superbkibbles/realestate_property-api/domain/event"
+	"github.com/superbkibbles/realestate_property-api/domain/webhook"
+	"github.com/superbkibbles/realestate_property-api/repository/db"
+	"github.com/superbkibbles/realestate_property-api/utils/crypto_utils"
+	"github.com/superbkibbles/realestate_property-api/utils/date_utils"
+)
+
+// subscriptionRepo only serves the lookups made while delivering.
+type subscriptionRepo struct {
+	db.SubscriptionRepository
+	subscriptions map[string]webhook.Subscription
+}
+
+func (r *subscriptionRepo) GetByID(id string) (*webhook.Subscription, rest_errors.RestErr) {
+	s, ok := r.subscriptions[id]
+	if !ok {
+		return nil, rest_errors.NewNotFoundErr("no webhook subscription was found with id " + id)
+	}
+	return &s, nil
+}
+
+func (r *subscriptionRepo) GetPaused() (webhook.Subscriptions, rest_errors.RestErr) {
+	paused := webhook.Subscriptions{}
+	for _, s := range r.subscriptions {
+		if !s.Active {
+			paused = append(paused, s)
+		}
+	}
+	return paused, nil
+}
+
+// deliveryRepo follows the scripts of the elasticsearch repository. It is
+// locked as subscriptions are delivered to concurrently.
+type deliveryRepo struct {
+	db.WebhookDeliveryRepository
+	mu         sync.Mutex
+	deliveries map[string]webhook.Delivery
+	failClaim  string
+}
+
+func (r *deliveryRepo) get(id string) webhook.Delivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.deliveries[id]
+}
+
+func (r *deliveryRepo) GetByID(id string) (*webhook.Delivery, rest_errors.RestErr) {
+	d, ok := r.deliveries[id]
+	if !ok {
+		return nil, rest_errors.NewNotFoundErr("no webhook delivery was found with id " + id)
+	}
+	return &d, nil
+}
+
+func (r *deliveryRepo) GetDue(now string, paused []string, size int) (webhook.Deliveries, rest_errors.RestErr) {
+	due := webhook.Deliveries{}
+	for _, d := range r.deliveries {
+		skip := false
+		for _, id := range paused {
+			skip = skip || d.SubscriptionID == id
+		}
+		if !skip && d.Status == webhook.STATUS_PENDING && d.NextAttemptAt <= now {
+			due = append(due, d)
+		}
+	}
+	if len(due) > size {
+		due = due[:size]
+	}
+	return due, nil
+}
+
+func (r *deliveryRepo) Claim(id string, now string, until string) (bool, rest_errors.RestErr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id == r.failClaim {
+		return false, rest_errors.NewInternalServerErr("error when trying to claim webhook delivery", nil)
+	}
+	d := r.deliveries[id]
+	if d.Status != webhook.STATUS_PENDING {
+		return false, nil
+	}
+	d.Status, d.LockedUntil = webhook.STATUS_DELIVERING, until
+	r.deliveries[id] = d
+	return true, nil
+}
+
+func (r *deliveryRepo) Record(id string, status string, attempts int, nextAttemptAt string, attempt webhook.Attempt) rest_errors.RestErr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d := r.deliveries[id]
+	d.Log = append(d.Log, attempt)
+	d.Status, d.Attempts, d.NextAttemptAt, d.LockedUntil, d.LastError = status, attempts, nextAttemptAt, "", attempt.Error
+	r.deliveries[id] = d
+	return nil
+}
+
+func (r *deliveryRepo) Replay(id string, now string) (bool, rest_errors.RestErr) {
+	d := r.deliveries[id]
+	if d.Status != webhook.STATUS_DEAD {
+		return false, nil
+	}
+	d.Status, d.Attempts, d.NextAttemptAt = webhook.STATUS_PENDING, 0, now
+	r.deliveries[id] = d
+	return true, nil
+}
+
+func (r *deliveryRepo) ReplayDead(subscriptionID string, now string) (int64, rest_errors.RestErr) {
+	var replayed int64
+	for id, d := range r.deliveries {
+		if d.SubscriptionID != subscriptionID {
+			continue
+		}
+		if ok, _ := r.Replay(id, now); ok {
+			replayed++
+		}
+	}
+	return replayed, nil
+}
+
+// subscriber answers every request with status and hands it to received.
+func subscriber(t *testing.T, status int, received chan<- *http.Request) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if received != nil {
+			received <- r
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// queue adds a pending delivery of a new event. Subscribers on the loopback
+// address are refused by Create, so deliveries are put in the repository
+// directly.
+func queue(t *testing.T, s *service, deliveries *deliveryRepo, sub webhook.Subscription, eventID string) webhook.Delivery {
+	d, err := s.newDelivery(sub, event.Event{ID: eventID, Type: event.Types[0], AgencyID: sub.AgencyID})
+	if err != nil {
+		t.Fatal(err.Message())
+	}
+	deliveries.deliveries[d.ID] = *d
+	return *d
+}
+
+func TestDeliverSignsPayload(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	server := subscriber(t, http.StatusOK, received)
+	sub := webhook.Subscription{ID: "sub-1", AgencyID: "agency-1", URL: server.URL, Secret: "0123456789abcdef", Active: true}
+	deliveries := &deliveryRepo{deliveries: map[string]webhook.Delivery{}}
+	s := NewService(&subscriptionRepo{subscriptions: map[string]webhook.Subscription{sub.ID: sub}}, deliveries, Config{}).(*service)
+	s.client = server.Client()
+	d := queue(t, s, deliveries, sub, "event-1")
+
+	report, err := s.Deliver()
+	if err != nil {
+		t.Fatal(err.Message())
+	}
+	if report.Delivered != 1 {
+		t.Fatalf("expected 1 delivered, got %+v", report)
+	}
+	r := <-received
+	if signature, expected := r.Header.Get("X-Signature"), "sha256="+crypto_utils.GetHmacSha256(sub.Secret, d.Payload); signature != expected {
+		t.Errorf("expected signature %s, got %s", expected, signature)
+	}
+	if got := deliveries.get(d.ID); got.Status != webhook.STATUS_DELIVERED || got.Attempts != 1 {
+		t.Errorf("expected a delivered delivery after 1 attempt, got %+v", got)
+	}
+}
+
+func TestDeliverBacksOffUntilDead(t *testing.T) {
+	server := subscriber(t, http.StatusInternalServerError, nil)
+	sub := webhook.Subscription{ID: "sub-1", AgencyID: "agency-1", URL: server.URL, Active: true}
+	deliveries := &deliveryRepo{deliveries: map[string]webhook.Delivery{}}
+	s := NewService(&subscriptionRepo{subscriptions: map[string]webhook.Subscription{sub.ID: sub}}, deliveries, Config{MaxAttempts: 3}).(*service)
+	s.client = server.Client()
+	d := queue(t, s, deliveries, sub, "event-1")
+
+	tests := []struct {
+		attempts int
+		status   string
+		delay    time.Duration
+	}{
+		{attempts: 1, status: webhook.STATUS_PENDING, delay: event.RetryDelay(1)},
+		{attempts: 2, status: webhook.STATUS_PENDING, delay: event.RetryDelay(2)},
+		{attempts: 3, status: webhook.STATUS_DEAD},
+	}
+	for _, tt := range tests {
+		started := date_utils.GetNow()
+		if _, err := s.Deliver(); err != nil {
+			t.Fatal(err.Message())
+		}
+		got := deliveries.get(d.ID)
+		if got.Status != tt.status || got.Attempts != tt.attempts || got.LastError == "" {
+			t.Fatalf("attempt %d: expected %s with an error, got %+v", tt.attempts, tt.status, got)
+		}
+		if tt.delay > 0 {
+			next, _ := time.Parse(event.TimeLayout, got.NextAttemptAt)
+			if delay := next.Sub(started); delay < tt.delay-time.Second || delay > tt.delay+time.Second {
+				t.Errorf("attempt %d: expected a retry after %s, got %s", tt.attempts, tt.delay, delay)
+			}
+		}
+
+		// Not due again before the backoff is over.
+		if report, _ := s.Deliver(); report.Retried+report.Dead != 0 {
+			t.Fatalf("attempt %d: expected nothing due during the backoff, got %+v", tt.attempts, report)
+		}
+		got.NextAttemptAt = started.Add(-time.Second).Format(event.TimeLayout)
+		deliveries.deliveries[d.ID] = got
+	}
+}
+
+func TestDeliverSkips(t *testing.T) {
+	server := subscriber(t, http.StatusOK, nil)
+	active := webhook.Subscription{ID: "sub-1", AgencyID: "agency-1", URL: server.URL, Active: true}
+	paused := webhook.Subscription{ID: "sub-2", AgencyID: "agency-1", URL: server.URL, Active: false}
+	deliveries := &deliveryRepo{deliveries: map[string]webhook.Delivery{}}
+	s := NewService(&subscriptionRepo{subscriptions: map[string]webhook.Subscription{active.ID: active, paused.ID: paused}}, deliveries, Config{}).(*service)
+	s.client = server.Client()
+	broken := queue(t, s, deliveries, active, "event-1")
+	sent := queue(t, s, deliveries, active, "event-2")
+	waiting := queue(t, s, deliveries, paused, "event-1")
+	deliveries.failClaim = broken.ID
+
+	report, err := s.Deliver()
+	if err != nil {
+		t.Fatal(err.Message())
+	}
+	if report.Delivered != 1 || report.Failed != 1 || report.Dead != 0 {
+		t.Fatalf("expected 1 delivered and 1 failed, got %+v", report)
+	}
+	tests := []struct {
+		name     string
+		id       string
+		status   string
+		attempts int
+	}{
+		{name: "failed claim", id: broken.ID, status: webhook.STATUS_PENDING, attempts: 0},
+		{name: "after a failed claim", id: sent.ID, status: webhook.STATUS_DELIVERED, attempts: 1},
+		{name: "paused subscription", id: waiting.ID, status: webhook.STATUS_PENDING, attempts: 0},
+	}
+	for _, tt := range tests {
+		if got := deliveries.get(tt.id); got.Status != tt.status || got.Attempts != tt.attempts {
+			t.Errorf("%s: expected %s after %d attempts, got %+v", tt.name, tt.status, tt.attempts, got)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	sub := webhook.Subscription{ID: "sub-1", AgencyID: "agency-1", Active: true}
+	other := webhook.Subscription{ID: "sub-2", AgencyID: "agency-1", Active: true}
+	deliveries := &deliveryRepo{deliveries: map[string]webhook.Delivery{}}
+	s := NewService(&subscriptionRepo{subscriptions: map[string]webhook.Subscription{sub.ID: sub, other.ID: other}}, deliveries, Config{}).(*service)
+	dead := queue(t, s, deliveries, sub, "event-1")
+	pending := queue(t, s, deliveries, sub, "event-2")
+	otherDead := queue(t, s, deliveries, other, "event-1")
+	for _, d := range []webhook.Delivery{dead, otherDead} {
+		d.Status, d.Attempts = webhook.STATUS_DEAD, 8
+		deliveries.deliveries[d.ID] = d
+	}
+
+	tests := []struct {
+		name   string
+		agency string
+		id     string
+		status int
+	}{
+		{name: "other agency", agency: "agency-2", id: dead.ID, status: http.StatusNotFound},
+		{name: "other subscription", agency: "agency-1", id: otherDead.ID, status: http.StatusNotFound},
+		{name: "not dead", agency: "agency-1", id: pending.ID, status: http.StatusConflict},
+		{name: "dead", agency: "agency-1", id: dead.ID},
+	}
+	for _, tt := range tests {
+		err := s.Replay(tt.agency, sub.ID, tt.id)
+		if (err == nil && tt.status != 0) || (err != nil && err.Status() != tt.status) {
+			t.Errorf("%s: expected status %d, got %v", tt.name, tt.status, err)
+		}
+	}
+	if got := deliveries.get(dead.ID); got.Status != webhook.STATUS_PENDING || got.Attempts != 0 {
+		t.Errorf("expected the replayed delivery pending with fresh attempts, got %+v", got)
+	}
+}
+
+func TestReplayAll(t *testing.T) {
+	sub := webhook.Subscription{ID: "sub-1", AgencyID: "agency-1", Active: true}
+	other := webhook.Subscription{ID: "sub-2", AgencyID: "agency-1", Active: true}
+	deliveries := &deliveryRepo{deliveries: map[string]webhook.Delivery{}}
+	s := NewService(&subscriptionRepo{subscriptions: map[string]webhook.Subscription{sub.ID: sub, other.ID: other}}, deliveries, Config{}).(*service)
+	for _, d := range []webhook.Delivery{
+		queue(t, s, deliveries, sub, "event-1"),
+		queue(t, s, deliveries, sub, "event-2"),
+		queue(t, s, deliveries, other, "event-1"),
+	} {
+		d.Status, d.Attempts = webhook.STATUS_DEAD, 8
+		deliveries.deliveries[d.ID] = d
+	}
+
+	replayed, err := s.ReplayAll("agency-1", sub.ID)
+	if err != nil {
+		t.Fatal(err.Message())
+	}
+	if replayed != 2 {
+		t.Fatalf("expected 2 replayed, got %d", replayed)
+	}
+	for id, d := range deliveries.deliveries {
+		expected := webhook.STATUS_PENDING
+		if d.SubscriptionID == other.ID {
+			expected = webhook.STATUS_DEAD
+		}
+		if d.Status != expected {
+			t.Errorf("expected %s to be %s, got %s", id, expected, d.Status)
+		}
+	}
+}
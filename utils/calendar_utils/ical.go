@@ -0,0 +1,89 @@
+package calendar_utils
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	productID   = "-//realestate//property-api//EN"
+	icalLayout  = "20060102T150405Z"
+	maxLineSize = 75
+
+	STATUS_CONFIRMED = "CONFIRMED"
+	STATUS_CANCELLED = "CANCELLED"
+)
+
+// Event is one VEVENT. Calendar apps match updates by UID, so it must stay
+// the same for the life of the event and Sequence must grow with every
+// change.
+type Event struct {
+	UID         string
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	Location    string
+	Status      string
+	Sequence    int
+	Stamp       time.Time
+}
+
+// Calendar renders events as an RFC 5545 calendar.
+func Calendar(name string, events ...Event) []byte {
+	var b strings.Builder
+	writeLine(&b, "BEGIN:VCALENDAR")
+	writeLine(&b, "VERSION:2.0")
+	writeLine(&b, "PRODID:"+productID)
+	writeLine(&b, "CALSCALE:GREGORIAN")
+	writeLine(&b, "METHOD:PUBLISH")
+	if name != "" {
+		writeLine(&b, "X-WR-CALNAME:"+escape(name))
+	}
+	for _, event := range events {
+		writeLine(&b, "BEGIN:VEVENT")
+		writeLine(&b, "UID:"+escape(event.UID))
+		writeLine(&b, "DTSTAMP:"+event.Stamp.UTC().Format(icalLayout))
+		writeLine(&b, "DTSTART:"+event.Start.UTC().Format(icalLayout))
+		writeLine(&b, "DTEND:"+event.End.UTC().Format(icalLayout))
+		writeLine(&b, "SUMMARY:"+escape(event.Summary))
+		if event.Description != "" {
+			writeLine(&b, "DESCRIPTION:"+escape(event.Description))
+		}
+		if event.Location != "" {
+			writeLine(&b, "LOCATION:"+escape(event.Location))
+		}
+		if event.Status != "" {
+			writeLine(&b, "STATUS:"+event.Status)
+		}
+		writeLine(&b, "SEQUENCE:"+strconv.Itoa(event.Sequence))
+		writeLine(&b, "END:VEVENT")
+	}
+	writeLine(&b, "END:VCALENDAR")
+	return []byte(b.String())
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+func escape(value string) string {
+	return escaper.Replace(value)
+}
+
+// writeLine folds lines longer than 75 octets without splitting a UTF-8
+// sequence. Continuation lines start with a space, which counts too.
+func writeLine(b *strings.Builder, line string) {
+	size := maxLineSize
+	for len(line) > size {
+		cut := size
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		size = maxLineSize - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
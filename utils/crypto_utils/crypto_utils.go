@@ -1,7 +1,9 @@
 package crypto_utils
 
 import (
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 )
 
@@ -12,3 +14,14 @@ func GetMd5(input string) string {
 	hash.Write([]byte(input))
 	return hex.EncodeToString(hash.Sum(nil))
 }
+
+func GetHmacSha256(secret string, input string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(input))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyHmacSha256 compares a hex signature against input in constant time.
+func VerifyHmacSha256(secret string, input string, signature string) bool {
+	return hmac.Equal([]byte(GetHmacSha256(secret, input)), []byte(signature))
+}
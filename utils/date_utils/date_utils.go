@@ -18,3 +18,16 @@ func GetNowString() string {
 func GetNowDBFromat() string {
 	return GetNow().Format(apiDBLayout)
 }
+
+// GetNowISO is for dates Elasticsearch should map and range-query as dates.
+func GetNowISO() string {
+	return GetNow().Format(time.RFC3339)
+}
+
+func GetDBFormat(t time.Time) string {
+	return t.UTC().Format(apiDBLayout)
+}
+
+func ParseDBFormat(value string) (time.Time, error) {
+	return time.Parse(apiDBLayout, value)
+}
@@ -0,0 +1,53 @@
+package media_utils
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+const (
+	MIME_JPEG      = "image/jpeg"
+	MIME_PNG       = "image/png"
+	MIME_WEBP      = "image/webp"
+	MIME_MP4       = "video/mp4"
+	MIME_QUICKTIME = "video/quicktime"
+	MIME_PDF       = "application/pdf"
+
+	// SniffLen is the number of leading bytes DetectContentType looks at.
+	SniffLen = 512
+)
+
+var (
+	ftypBox        = []byte("ftyp")
+	quickTimeBrand = []byte("qt  ")
+)
+
+// DetectContentType sniffs the MIME type of a file from its leading magic
+// bytes, ignoring whatever name or Content-Type header the client sent.
+func DetectContentType(head []byte) string {
+	if len(head) >= 12 && bytes.Equal(head[4:8], ftypBox) && bytes.Equal(head[8:12], quickTimeBrand) {
+		return MIME_QUICKTIME
+	}
+	contentType := http.DetectContentType(head)
+	return strings.TrimSpace(strings.Split(contentType, ";")[0])
+}
+
+// ExtensionFor returns the file extension we store alongside a sniffed MIME type.
+func ExtensionFor(mimeType string) string {
+	switch mimeType {
+	case MIME_JPEG:
+		return "jpg"
+	case MIME_PNG:
+		return "png"
+	case MIME_WEBP:
+		return "webp"
+	case MIME_MP4:
+		return "mp4"
+	case MIME_QUICKTIME:
+		return "mov"
+	case MIME_PDF:
+		return "pdf"
+	}
+	return ""
+}
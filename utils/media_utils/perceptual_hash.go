@@ -0,0 +1,84 @@
+package media_utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"math/bits"
+	"strconv"
+)
+
+const (
+	hashWidth  = 9
+	hashHeight = 8
+)
+
+// PerceptualHash returns a 64 bit difference hash (dHash) of a JPEG or PNG
+// image as 16 hex characters. Re-encoded, resized or slightly edited copies
+// of a photo get hashes only a few bits apart.
+func PerceptualHash(data []byte) (string, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	bounds := img.Bounds()
+	if bounds.Dx() < hashWidth || bounds.Dy() < hashHeight {
+		return "", fmt.Errorf("image is too small to hash")
+	}
+
+	// Average the pixels of each cell of a 9x8 grid into a gray level.
+	var gray [hashHeight][hashWidth]float64
+	for y := 0; y < hashHeight; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/hashHeight
+		y1 := bounds.Min.Y + (y+1)*bounds.Dy()/hashHeight
+		for x := 0; x < hashWidth; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/hashWidth
+			x1 := bounds.Min.X + (x+1)*bounds.Dx()/hashWidth
+			gray[y][x] = averageGray(img, x0, y0, x1, y1)
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < hashHeight; y++ {
+		for x := 0; x < hashWidth-1; x++ {
+			hash <<= 1
+			if gray[y][x] > gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return fmt.Sprintf("%016x", hash), nil
+}
+
+// HashDistance is the number of differing bits between two perceptual
+// hashes, or 64 if either is not a valid hash.
+func HashDistance(a string, b string) int {
+	x, errA := strconv.ParseUint(a, 16, 64)
+	y, errB := strconv.ParseUint(b, 16, 64)
+	if errA != nil || errB != nil {
+		return 64
+	}
+	return bits.OnesCount64(x ^ y)
+}
+
+// averageGray samples at most 8x8 pixels of the cell, which is plenty for
+// a 64 bit hash and keeps large photos cheap.
+func averageGray(img image.Image, x0 int, y0 int, x1 int, y1 int) float64 {
+	stepX := (x1 - x0 + 7) / 8
+	stepY := (y1 - y0 + 7) / 8
+	var sum float64
+	var n int
+	for y := y0; y < y1; y += stepY {
+		for x := x0; x < x1; x += stepX {
+			r, g, b, _ := img.At(x, y).RGBA()
+			sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
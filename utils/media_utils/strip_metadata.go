@@ -0,0 +1,166 @@
+package media_utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	ErrMalformedImage = errors.New("malformed image data")
+
+	pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+
+	// Chunks that can carry EXIF, XMP (which may include GPS) or free text.
+	pngMetadataChunks = map[string]bool{
+		"eXIf": true,
+		"tEXt": true,
+		"iTXt": true,
+		"zTXt": true,
+		"tIME": true,
+	}
+)
+
+const (
+	jpegSOI   = 0xD8
+	jpegEOI   = 0xD9
+	jpegSOS   = 0xDA
+	jpegAPP1  = 0xE1 // EXIF and XMP
+	jpegAPP13 = 0xED // IPTC / Photoshop
+
+	webpFlagXMP  = 0x04
+	webpFlagEXIF = 0x08
+)
+
+// StripMetadata removes EXIF, XMP and similar metadata (including GPS
+// coordinates) from an image. Pixel data is left untouched.
+func StripMetadata(mimeType string, data []byte) ([]byte, error) {
+	switch mimeType {
+	case MIME_JPEG:
+		return stripJPEG(data)
+	case MIME_PNG:
+		return stripPNG(data)
+	case MIME_WEBP:
+		return stripWebP(data)
+	}
+	return data, nil
+}
+
+func stripJPEG(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != jpegSOI {
+		return nil, ErrMalformedImage
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+
+	i := 2
+	for i < len(data) {
+		if data[i] != 0xFF || i+1 >= len(data) {
+			return nil, ErrMalformedImage
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF:
+			// fill byte
+			i++
+			continue
+		case marker == jpegSOS || marker == jpegEOI:
+			// Entropy coded data follows, no more metadata segments.
+			return append(out, data[i:]...), nil
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			// Stand-alone markers without a length.
+			out = append(out, data[i:i+2]...)
+			i += 2
+			continue
+		}
+
+		if i+4 > len(data) {
+			return nil, ErrMalformedImage
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, ErrMalformedImage
+		}
+		if marker != jpegAPP1 && marker != jpegAPP13 {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	return out, nil
+}
+
+func stripPNG(data []byte) ([]byte, error) {
+	if len(data) < len(pngSignature) || !bytes.Equal(data[:len(pngSignature)], pngSignature) {
+		return nil, ErrMalformedImage
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+
+	i := len(pngSignature)
+	for i < len(data) {
+		if i+8 > len(data) {
+			return nil, ErrMalformedImage
+		}
+		length := int(binary.BigEndian.Uint32(data[i : i+4]))
+		chunkType := string(data[i+4 : i+8])
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, ErrMalformedImage
+		}
+		if !pngMetadataChunks[chunkType] {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+		if chunkType == "IEND" {
+			break
+		}
+	}
+	return out, nil
+}
+
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrMalformedImage
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:12]...)
+
+	i := 12
+	for i < len(data) {
+		if i+8 > len(data) {
+			return nil, ErrMalformedImage
+		}
+		fourCC := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4 : i+8]))
+		payloadEnd := i + 8 + size
+		if size < 0 || payloadEnd > len(data) {
+			return nil, ErrMalformedImage
+		}
+		// Odd sized chunks are followed by a pad byte, which some encoders
+		// leave out after the last chunk.
+		end := payloadEnd + size%2
+		if end > len(data) {
+			end = len(data)
+		}
+		switch fourCC {
+		case "EXIF", "XMP ":
+			i = end
+			continue
+		case "VP8X":
+			chunk := append([]byte(nil), data[i:payloadEnd]...)
+			if size > 0 {
+				chunk[8] &^= webpFlagEXIF | webpFlagXMP
+			}
+			out = append(out, chunk...)
+		default:
+			out = append(out, data[i:payloadEnd]...)
+		}
+		if size%2 == 1 {
+			out = append(out, 0)
+		}
+		i = end
+	}
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out, nil
+}
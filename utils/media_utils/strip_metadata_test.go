@@ -0,0 +1,127 @@
+package media_utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func jpegSegment(marker byte, payload string) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+func pngChunk(chunkType string, data string) []byte {
+	chunk := make([]byte, 4, 12+len(data))
+	binary.BigEndian.PutUint32(chunk, uint32(len(data)))
+	chunk = append(chunk, chunkType...)
+	chunk = append(chunk, data...)
+	return append(chunk, 0, 0, 0, 0)
+}
+
+func webpChunk(fourCC string, payload []byte, pad bool) []byte {
+	chunk := make([]byte, 8, 9+len(payload))
+	copy(chunk, fourCC)
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(payload)))
+	chunk = append(chunk, payload...)
+	if pad && len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func webp(chunks ...[]byte) []byte {
+	body := append([]byte("WEBP"), bytes.Join(chunks, nil)...)
+	header := []byte("RIFF\x00\x00\x00\x00")
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(body)))
+	return append(header, body...)
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestStripMetadata(t *testing.T) {
+	soi := []byte{0xFF, jpegSOI}
+	scan := []byte{0xFF, jpegSOS, 0x00, 0x02, 0x12, 0x34, 0xFF, jpegEOI}
+	vp8x := func(flags byte) []byte { return []byte{flags, 0, 0, 0, 9, 0, 0, 9, 0, 0} }
+	vp8 := []byte("pixels!")
+
+	tests := []struct {
+		name     string
+		mimeType string
+		data     []byte
+		expected []byte
+	}{
+		{
+			name:     "jpeg exif and iptc",
+			mimeType: MIME_JPEG,
+			data:     concat(soi, jpegSegment(0xE0, "JFIF"), jpegSegment(jpegAPP1, "Exif GPS"), jpegSegment(jpegAPP13, "IPTC"), jpegSegment(0xDB, "table"), scan),
+			expected: concat(soi, jpegSegment(0xE0, "JFIF"), jpegSegment(0xDB, "table"), scan),
+		},
+		{
+			name:     "jpeg fill bytes",
+			mimeType: MIME_JPEG,
+			data:     concat(soi, []byte{0xFF}, jpegSegment(jpegAPP1, "Exif"), scan),
+			expected: concat(soi, scan),
+		},
+		{
+			name:     "png text and exif",
+			mimeType: MIME_PNG,
+			data:     concat(pngSignature, pngChunk("IHDR", "header"), pngChunk("tEXt", "Author"), pngChunk("eXIf", "GPS"), pngChunk("IDAT", "pixels"), pngChunk("IEND", "")),
+			expected: concat(pngSignature, pngChunk("IHDR", "header"), pngChunk("IDAT", "pixels"), pngChunk("IEND", "")),
+		},
+		{
+			name:     "webp exif and xmp",
+			mimeType: MIME_WEBP,
+			data:     webp(webpChunk("VP8X", vp8x(webpFlagEXIF|webpFlagXMP|0x10), true), webpChunk("VP8 ", vp8, true), webpChunk("EXIF", []byte("GPS"), true), webpChunk("XMP ", []byte("<x/>"), true)),
+			expected: webp(webpChunk("VP8X", vp8x(0x10), true), webpChunk("VP8 ", vp8, true)),
+		},
+		{
+			name:     "webp pad byte left out after the last chunk",
+			mimeType: MIME_WEBP,
+			data:     webp(webpChunk("EXIF", []byte("GPS"), true), webpChunk("VP8 ", vp8, false)),
+			expected: webp(webpChunk("VP8 ", vp8, true)),
+		},
+		{
+			name:     "other types untouched",
+			mimeType: "video/mp4",
+			data:     []byte("not an image"),
+			expected: []byte("not an image"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripped, err := StripMetadata(tt.mimeType, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(stripped, tt.expected) {
+				t.Errorf("expected %x, got %x", tt.expected, stripped)
+			}
+		})
+	}
+}
+
+func TestStripMetadataMalformed(t *testing.T) {
+	tests := []struct {
+		name     string
+		mimeType string
+		data     []byte
+	}{
+		{name: "jpeg without start", mimeType: MIME_JPEG, data: []byte{0x00, 0x01, 0x02, 0x03}},
+		{name: "jpeg segment past the end", mimeType: MIME_JPEG, data: []byte{0xFF, jpegSOI, 0xFF, jpegAPP1, 0x10, 0x00, 'E'}},
+		{name: "png without signature", mimeType: MIME_PNG, data: []byte("GIF89a-not-png")},
+		{name: "png chunk past the end", mimeType: MIME_PNG, data: concat(pngSignature, []byte{0, 0, 1, 0}, []byte("IDAT"))},
+		{name: "webp without header", mimeType: MIME_WEBP, data: []byte("RIFF\x04\x00\x00\x00WAVE")},
+		{name: "webp chunk past the end", mimeType: MIME_WEBP, data: concat(webp(), []byte("VP8 \xff\x00\x00\x00"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := StripMetadata(tt.mimeType, tt.data); err != ErrMalformedImage {
+				t.Errorf("expected ErrMalformedImage, got %v", err)
+			}
+		})
+	}
+}
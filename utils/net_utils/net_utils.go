@@ -0,0 +1,102 @@
+package net_utils
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// privateBlocks are the ranges a public target must not reach besides the
+// loopback, link-local, multicast and unspecified ones net.IP reports itself.
+var privateBlocks = parseBlocks(
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"100.64.0.0/10",
+	"0.0.0.0/8",
+	"fc00::/7",
+)
+
+func parseBlocks(cidrs ...string) []*net.IPNet {
+	blocks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, block, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// PublicIP reports whether ip is routable on the internet, so neither
+// loopback, private, link-local, multicast nor unspecified.
+func PublicIP(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, block := range privateBlocks {
+		if block.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckPublicHost resolves host and fails unless every address it has is
+// public. An IP literal is checked without a lookup.
+func CheckPublicHost(host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if !PublicIP(ip) {
+			return fmt.Errorf("%s is not a public address", host)
+		}
+		return nil
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return err
+	}
+	if len(ips) == 0 {
+		return fmt.Errorf("%s has no address", host)
+	}
+	for _, ip := range ips {
+		if !PublicIP(ip) {
+			return fmt.Errorf("%s resolves to %s which is not a public address", host, ip)
+		}
+	}
+	return nil
+}
+
+// NewPublicClient returns a client for user supplied URLs. It refuses to
+// connect to addresses that are not public, checked on the address actually
+// dialed so a name cannot be rebound after validation, skips any proxy and
+// does not follow redirects.
+func NewPublicClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !PublicIP(net.ParseIP(host)) {
+				return fmt.Errorf("refusing to connect to %s", host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}